@@ -0,0 +1,112 @@
+package rill
+
+import (
+	"github.com/destel/rill/internal/core"
+)
+
+// Aggregator is a stateful consumer of items, used by [Aggregate].
+// Add is called for each item of the stream, and returns an error to stop the aggregation.
+// Use [NewAccumulator] and [NewKeyedAccumulator] to create common aggregators, or implement this interface directly.
+type Aggregator[A any] interface {
+	Add(a A) error
+}
+
+// Aggregate feeds each item of the input stream into all the aggregators, computing several independent
+// aggregations in a single pass. This avoids splitting the stream into several copies and running a separate reducer on each one.
+// After Aggregate returns, the results can be read from the aggregators.
+//
+//	count := rill.NewKeyedAccumulator(func(u User) string { return u.Country }, func(cnt int, u User) (int, error) {
+//		return cnt + 1, nil
+//	})
+//	oldest := rill.NewAccumulator(0, func(age int, u User) (int, error) {
+//		if u.Age > age {
+//			return u.Age, nil
+//		}
+//		return age, nil
+//	})
+//
+//	err := rill.Aggregate[User](users, count, oldest)
+//	fmt.Println(count.Result(), oldest.Result())
+//
+// This is a blocking ordered function that processes items sequentially, so aggregators don't need to be safe for concurrent use.
+// See the package documentation for more information on blocking ordered functions and error handling.
+func Aggregate[A any](in <-chan Try[A], aggs ...Aggregator[A]) error {
+	core.Claim(in)
+	discard := core.DrainSink(in)
+
+	for a := range in {
+		if a.Error != nil {
+			DrainNB(in)
+			return a.Error
+		}
+
+		for _, agg := range aggs {
+			if err := agg.Add(a.Value); err != nil {
+				discard(a)
+				DrainNB(in)
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Accumulator is an [Aggregator] that folds all items into a single value.
+type Accumulator[A, V any] struct {
+	value V
+	f     func(V, A) (V, error)
+}
+
+// NewAccumulator creates an [Accumulator] that starts with the initial value
+// and updates it with the function f for each item.
+func NewAccumulator[A, V any](initial V, f func(V, A) (V, error)) *Accumulator[A, V] {
+	return &Accumulator[A, V]{value: initial, f: f}
+}
+
+// Add implements the [Aggregator] interface.
+func (acc *Accumulator[A, V]) Add(a A) error {
+	v, err := acc.f(acc.value, a)
+	if err != nil {
+		return err
+	}
+
+	acc.value = v
+	return nil
+}
+
+// Result returns the accumulated value.
+func (acc *Accumulator[A, V]) Result() V {
+	return acc.value
+}
+
+// KeyedAccumulator is an [Aggregator] that folds items into separate values for each key.
+type KeyedAccumulator[A any, K comparable, V any] struct {
+	values map[K]V
+	key    func(A) K
+	f      func(V, A) (V, error)
+}
+
+// NewKeyedAccumulator creates a [KeyedAccumulator] that groups items by key,
+// and updates the value of each group with the function f. Each value starts from the zero value of V.
+func NewKeyedAccumulator[A any, K comparable, V any](key func(A) K, f func(V, A) (V, error)) *KeyedAccumulator[A, K, V] {
+	return &KeyedAccumulator[A, K, V]{values: make(map[K]V), key: key, f: f}
+}
+
+// Add implements the [Aggregator] interface.
+func (acc *KeyedAccumulator[A, K, V]) Add(a A) error {
+	k := acc.key(a)
+
+	v, err := acc.f(acc.values[k], a)
+	if err != nil {
+		return err
+	}
+
+	acc.values[k] = v
+	return nil
+}
+
+// Result returns the accumulated values for each key.
+func (acc *KeyedAccumulator[A, K, V]) Result() map[K]V {
+	return acc.values
+}
@@ -0,0 +1,86 @@
+package rill
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/destel/rill/internal/th"
+)
+
+func TestAggregate(t *testing.T) {
+	newAggs := func() (*KeyedAccumulator[int, int, int], *KeyedAccumulator[int, bool, int], *Accumulator[int, int]) {
+		countByMod3 := NewKeyedAccumulator(func(x int) int { return x % 3 }, func(cnt int, x int) (int, error) {
+			return cnt + 1, nil
+		})
+		sumByParity := NewKeyedAccumulator(func(x int) bool { return x%2 == 0 }, func(sum int, x int) (int, error) {
+			return sum + x, nil
+		})
+		max := NewAccumulator(-1, func(max int, x int) (int, error) {
+			if x > max {
+				return x, nil
+			}
+			return max, nil
+		})
+		return countByMod3, sumByParity, max
+	}
+
+	t.Run("empty", func(t *testing.T) {
+		countByMod3, sumByParity, max := newAggs()
+
+		err := Aggregate[int](FromSlice[int](nil, nil), countByMod3, sumByParity, max)
+		th.ExpectNoError(t, err)
+		th.ExpectValue(t, len(countByMod3.Result()), 0)
+		th.ExpectValue(t, len(sumByParity.Result()), 0)
+		th.ExpectValue(t, max.Result(), -1)
+	})
+
+	t.Run("correctness", func(t *testing.T) {
+		countByMod3, sumByParity, max := newAggs()
+
+		err := Aggregate[int](FromChan(th.FromRange(0, 10), nil), countByMod3, sumByParity, max)
+		th.ExpectNoError(t, err)
+
+		th.ExpectValue(t, len(countByMod3.Result()), 3)
+		th.ExpectValue(t, countByMod3.Result()[0], 4)
+		th.ExpectValue(t, countByMod3.Result()[1], 3)
+		th.ExpectValue(t, countByMod3.Result()[2], 3)
+
+		th.ExpectValue(t, sumByParity.Result()[true], 0+2+4+6+8)
+		th.ExpectValue(t, sumByParity.Result()[false], 1+3+5+7+9)
+
+		th.ExpectValue(t, max.Result(), 9)
+	})
+
+	t.Run("error in input", func(t *testing.T) {
+		countByMod3, sumByParity, max := newAggs()
+
+		in := FromChan(th.FromRange(0, 1000), nil)
+		in = replaceWithError(in, 100, fmt.Errorf("err100"))
+
+		err := Aggregate[int](in, countByMod3, sumByParity, max)
+		th.ExpectError(t, err, "err100")
+		th.ExpectValue(t, max.Result(), 99)
+
+		time.Sleep(1 * time.Second)
+		th.ExpectDrainedChan(t, in)
+	})
+
+	t.Run("error in aggregator", func(t *testing.T) {
+		in := FromChan(th.FromRange(0, 1000), nil)
+
+		acc := NewAccumulator(0, func(sum int, x int) (int, error) {
+			if x == 100 {
+				return 0, fmt.Errorf("err100")
+			}
+			return sum + x, nil
+		})
+
+		err := Aggregate[int](in, acc)
+		th.ExpectError(t, err, "err100")
+		th.ExpectValue(t, acc.Result(), 99*100/2)
+
+		time.Sleep(1 * time.Second)
+		th.ExpectDrainedChan(t, in)
+	})
+}
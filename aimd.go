@@ -0,0 +1,125 @@
+package rill
+
+import (
+	"sync"
+	"time"
+)
+
+// AIMDConfig configures an [AIMD] controller.
+type AIMDConfig struct {
+	Min     int // minimum concurrency, at least 1
+	Max     int // maximum concurrency, at least Min
+	Initial int // initial concurrency, Min if zero
+
+	// LatencyTarget is the latency above which a call is considered a sign of an overloaded downstream.
+	// Zero means that latency is ignored, and only errors are taken into account.
+	LatencyTarget time.Duration
+
+	// Backoff is the factor the concurrency is multiplied by on overload. Must be between 0 and 1. Defaults to 0.5.
+	Backoff float64
+
+	// IsOverload reports whether an error is a sign of an overloaded downstream, such as a timeout or HTTP 429.
+	// Other errors are treated as successful calls. If nil, every error is a sign of overload.
+	IsOverload func(error) bool
+}
+
+// AIMD is a controller that automatically tunes the concurrency of a stage, using the
+// additive-increase/multiplicative-decrease algorithm, similar to TCP congestion control.
+// After every full round of successful calls, the concurrency is increased by one.
+// When a call fails or is too slow, the concurrency is multiplied by the backoff factor.
+// This way a pipeline hitting a flaky downstream regulates itself, instead of requiring a hand-tuned n.
+//
+// The controller adjusts its [Limit], so it works with [MapL], [OrderedMapL] and [WithLimiter].
+// Outcomes of the calls are reported with [AIMD.Observe], or automatically by wrapping the function with [Adaptive]:
+//
+//	ctrl := rill.NewAIMD(rill.AIMDConfig{Min: 1, Max: 50, LatencyTarget: 200 * time.Millisecond})
+//	users := rill.MapL(ids, ctrl.Limit(), rill.Adaptive(ctrl, fetchUser))
+//
+// AIMD is safe for concurrent use.
+type AIMD struct {
+	cfg   AIMDConfig
+	limit *Limit
+
+	mu        sync.Mutex
+	successes int // successful calls since the last change of the limit
+	observed  int // calls since the last decrease
+}
+
+// NewAIMD creates a new [AIMD] controller.
+func NewAIMD(cfg AIMDConfig) *AIMD {
+	cfg.Min = normalizeLimit(cfg.Min)
+	if cfg.Max < cfg.Min {
+		cfg.Max = cfg.Min
+	}
+	if cfg.Initial < cfg.Min {
+		cfg.Initial = cfg.Min
+	}
+	if cfg.Initial > cfg.Max {
+		cfg.Initial = cfg.Max
+	}
+	if cfg.Backoff <= 0 || cfg.Backoff >= 1 {
+		cfg.Backoff = 0.5
+	}
+
+	return &AIMD{
+		cfg:      cfg,
+		limit:    NewLimit(cfg.Initial),
+		observed: cfg.Initial, // the first overload is acted upon immediately
+	}
+}
+
+// Limit returns the limit controlled by the controller.
+func (c *AIMD) Limit() *Limit {
+	return c.limit
+}
+
+// Observe reports the outcome of a single call to the controller.
+func (c *AIMD) Observe(latency time.Duration, err error) {
+	overload := false
+	if err != nil && (c.cfg.IsOverload == nil || c.cfg.IsOverload(err)) {
+		overload = true
+	}
+	if c.cfg.LatencyTarget > 0 && latency > c.cfg.LatencyTarget {
+		overload = true
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	current := c.limit.Get()
+	c.observed++
+
+	if overload {
+		// calls that were started before the previous decrease still report the old overload,
+		// so decrease at most once per round of calls
+		if c.observed < current {
+			return
+		}
+
+		next := int(float64(current) * c.cfg.Backoff)
+		if next < c.cfg.Min {
+			next = c.cfg.Min
+		}
+
+		c.limit.Set(next)
+		c.successes = 0
+		c.observed = 0
+		return
+	}
+
+	c.successes++
+	if c.successes >= current && current < c.cfg.Max {
+		c.limit.Set(current + 1)
+		c.successes = 0
+	}
+}
+
+// Adaptive wraps the function f, reporting the latency and the error of each call to the controller c.
+func Adaptive[A, B any](c *AIMD, f func(A) (B, error)) func(A) (B, error) {
+	return func(a A) (B, error) {
+		start := time.Now()
+		b, err := f(a)
+		c.Observe(time.Since(start), err)
+		return b, err
+	}
+}
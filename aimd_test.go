@@ -0,0 +1,118 @@
+package rill
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/destel/rill/internal/th"
+)
+
+func TestAIMD(t *testing.T) {
+	errOverload := errors.New("overload")
+	errOther := errors.New("other")
+
+	t.Run("config", func(t *testing.T) {
+		c := NewAIMD(AIMDConfig{})
+		th.ExpectValue(t, c.Limit().Get(), 1)
+
+		c = NewAIMD(AIMDConfig{Min: 2, Max: 10, Initial: 20})
+		th.ExpectValue(t, c.Limit().Get(), 10)
+	})
+
+	t.Run("additive increase", func(t *testing.T) {
+		c := NewAIMD(AIMDConfig{Min: 2, Max: 4})
+
+		// a full round of successful calls increases the limit by one
+		for i := 0; i < 2; i++ {
+			c.Observe(0, nil)
+		}
+		th.ExpectValue(t, c.Limit().Get(), 3)
+
+		for i := 0; i < 3; i++ {
+			c.Observe(0, nil)
+		}
+		th.ExpectValue(t, c.Limit().Get(), 4)
+
+		// never above max
+		for i := 0; i < 100; i++ {
+			c.Observe(0, nil)
+		}
+		th.ExpectValue(t, c.Limit().Get(), 4)
+	})
+
+	t.Run("multiplicative decrease", func(t *testing.T) {
+		c := NewAIMD(AIMDConfig{Min: 2, Max: 100, Initial: 40})
+
+		c.Observe(0, errOverload)
+		th.ExpectValue(t, c.Limit().Get(), 20)
+
+		// overloads right after a decrease are ignored until a full round of calls is observed
+		for i := 0; i < 19; i++ {
+			c.Observe(0, errOverload)
+		}
+		th.ExpectValue(t, c.Limit().Get(), 20)
+
+		c.Observe(0, errOverload)
+		th.ExpectValue(t, c.Limit().Get(), 10)
+
+		// never below min
+		for i := 0; i < 100; i++ {
+			c.Observe(0, errOverload)
+		}
+		th.ExpectValue(t, c.Limit().Get(), 2)
+	})
+
+	t.Run("latency target", func(t *testing.T) {
+		c := NewAIMD(AIMDConfig{Min: 1, Max: 100, Initial: 10, LatencyTarget: 100 * time.Millisecond})
+
+		c.Observe(50*time.Millisecond, nil)
+		th.ExpectValue(t, c.Limit().Get(), 10)
+
+		c.Observe(200*time.Millisecond, nil)
+		th.ExpectValue(t, c.Limit().Get(), 5)
+	})
+
+	t.Run("is overload", func(t *testing.T) {
+		c := NewAIMD(AIMDConfig{Min: 1, Max: 100, Initial: 10, Backoff: 0.2, IsOverload: func(err error) bool {
+			return errors.Is(err, errOverload)
+		}})
+
+		c.Observe(0, errOther)
+		th.ExpectValue(t, c.Limit().Get(), 10)
+
+		c.Observe(0, fmt.Errorf("wrapped: %w", errOverload))
+		th.ExpectValue(t, c.Limit().Get(), 2)
+	})
+
+	t.Run("adaptive stage", func(t *testing.T) {
+		c := NewAIMD(AIMDConfig{Min: 1, Max: 8})
+
+		var maxActive, active atomic.Int64
+		f := func(x int) (int, error) {
+			cur := active.Add(1)
+			defer active.Add(-1)
+			for {
+				m := maxActive.Load()
+				if cur <= m || maxActive.CompareAndSwap(m, cur) {
+					break
+				}
+			}
+
+			time.Sleep(5 * time.Millisecond)
+			return x, nil
+		}
+
+		outSlice, err := ToSlice(MapL(FromChan(th.FromRange(0, 200), nil), c.Limit(), Adaptive(c, f)))
+		th.ExpectNoError(t, err)
+		th.ExpectValue(t, len(outSlice), 200)
+
+		// the downstream is healthy, so the concurrency grows up to the maximum
+		th.ExpectValue(t, c.Limit().Get(), 8)
+		if maxActive.Load() > 8 {
+			t.Errorf("concurrency exceeded the maximum: %d", maxActive.Load())
+		}
+	})
+}
@@ -1,6 +1,8 @@
 package rill
 
 import (
+	"math/bits"
+	"sync"
 	"time"
 
 	"github.com/destel/rill/internal/core"
@@ -25,6 +27,214 @@ func Batch[A any](in <-chan Try[A], size int, timeout time.Duration) <-chan Try[
 	return FromChans(batches, errs)
 }
 
+// BatchWithMin is like [Batch], but never emits batches smaller than minSize, except for the last one at the end of the stream.
+// When the timeout expires and the batch has fewer than minSize items, it's held beyond the timeout until it reaches minSize.
+// This is useful when the downstream cost of tiny batches outweighs the added latency.
+// If minSize is 1 or less, BatchWithMin behaves exactly like Batch. If minSize is greater than size, it's treated as size,
+// so batches are emitted only when they are full, or at the end of the stream.
+//
+// This is a non-blocking ordered function that processes items sequentially.
+//
+// See the package documentation for more information on non-blocking ordered functions and error handling.
+func BatchWithMin[A any](in <-chan Try[A], minSize, size int, timeout time.Duration) <-chan Try[[]A] {
+	values, errs := ToChans(in)
+	batches := core.MinBatch(values, minSize, size, timeout)
+	return FromChans(batches, errs)
+}
+
+// BatchWithStats is like [Batch], but additionally returns a [BatchStats] object, that tracks the distribution of
+// emitted batch sizes and the number of batches emitted because of the timeout.
+// This data helps to choose the size and timeout parameters: for example, if most batches are much smaller than the size
+// and are emitted on timeout, the timeout is probably too short.
+//
+// This is a non-blocking ordered function that processes items sequentially.
+//
+// See the package documentation for more information on non-blocking ordered functions and error handling.
+func BatchWithStats[A any](in <-chan Try[A], size int, timeout time.Duration) (<-chan Try[[]A], *BatchStats) {
+	stats := &BatchStats{}
+	values, errs := ToChans(in)
+	batches := core.ObservedBatch(values, size, timeout, stats.observe)
+	return FromChans(batches, errs), stats
+}
+
+// PooledBatch is like [Batch], but takes slices for batches from a pool, instead of allocating a new slice for each batch.
+// This lowers GC pressure in pipelines that batch large numbers of items.
+//
+// A batch is returned to the pool with [ReleaseBatch], once it's no longer needed. Batches that are never released are
+// simply garbage collected. After a batch is released, it must not be used anymore, since its contents will be overwritten
+// by subsequent batches:
+//
+//	batches := rill.PooledBatch(users, 100, 1*time.Second)
+//
+//	err := rill.ForEach(batches, 5, func(batch []User) error {
+//		defer rill.ReleaseBatch(batch)
+//		return saveUsers(batch)
+//	})
+//
+// This is a non-blocking ordered function that processes items sequentially.
+//
+// See the package documentation for more information on non-blocking ordered functions and error handling.
+func PooledBatch[A any](in <-chan Try[A], size int, timeout time.Duration) <-chan Try[[]A] {
+	pool := batchPoolFor[A]()
+
+	values, errs := ToChans(in)
+	batches := core.AllocBatch(values, size, timeout, nil, func() []A {
+		return pool.get(size)
+	})
+	return FromChans(batches, errs)
+}
+
+// ReleaseBatch returns a batch emitted by [PooledBatch] to the pool, so its underlying array can be reused for subsequent batches.
+// The batch must not be used after the call, and must be released exactly once. Releasing the same batch twice,
+// or releasing a slice that is still in use elsewhere, leads to the same array being handed out to several batches.
+func ReleaseBatch[A any](batch []A) {
+	if cap(batch) == 0 {
+		return
+	}
+
+	var zero A
+	batch = batch[:cap(batch)]
+	for i := range batch {
+		batch[i] = zero // don't hold references to items of the released batch
+	}
+	batchPoolFor[A]().put(batch[:0])
+}
+
+// batchPools holds a *batchPool[A] for each item type A used with PooledBatch.
+var batchPools sync.Map
+
+type batchPool[A any] struct {
+	pool sync.Pool // of *[]A
+}
+
+func batchPoolFor[A any]() *batchPool[A] {
+	key := any((*A)(nil))
+	if p, ok := batchPools.Load(key); ok {
+		return p.(*batchPool[A])
+	}
+
+	p, _ := batchPools.LoadOrStore(key, &batchPool[A]{})
+	return p.(*batchPool[A])
+}
+
+func (p *batchPool[A]) get(size int) []A {
+	if size < 1 {
+		size = 1
+	}
+
+	if ptr, _ := p.pool.Get().(*[]A); ptr != nil && cap(*ptr) >= size {
+		return *ptr
+	}
+	return make([]A, 0, size)
+}
+
+func (p *batchPool[A]) put(batch []A) {
+	p.pool.Put(&batch)
+}
+
+// BatchStats holds statistics about the batches emitted by [BatchWithStats].
+// Batch sizes are tracked using an exponential histogram with power of two bucket boundaries.
+// All methods are safe for concurrent use and can be called while the batching is still in progress.
+type BatchStats struct {
+	mu       sync.Mutex
+	batches  int64
+	items    int64
+	timedOut int64
+	buckets  []int64 // buckets[i] counts batches with sizes in [2^i, 2^(i+1))
+}
+
+// BatchSizeBucket is a single bucket of the batch size histogram.
+// It holds the number of batches with sizes in the [Min, Max] range.
+type BatchSizeBucket struct {
+	Min   int
+	Max   int
+	Count int64
+}
+
+func (s *BatchStats) observe(size int, timedOut bool) {
+	i := bits.Len(uint(size)) - 1
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for len(s.buckets) <= i {
+		s.buckets = append(s.buckets, 0)
+	}
+	s.buckets[i]++
+	s.batches++
+	s.items += int64(size)
+	if timedOut {
+		s.timedOut++
+	}
+}
+
+// Batches returns the total number of emitted batches.
+func (s *BatchStats) Batches() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.batches
+}
+
+// TimedOut returns the number of batches that were emitted because of the timeout, before reaching the maximum size.
+func (s *BatchStats) TimedOut() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.timedOut
+}
+
+// MeanSize returns the average size of emitted batches, or zero if no batches were emitted yet.
+func (s *BatchStats) MeanSize() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.batches == 0 {
+		return 0
+	}
+	return float64(s.items) / float64(s.batches)
+}
+
+// Histogram returns the distribution of emitted batch sizes, ordered from the smallest sizes to the largest.
+// Bucket boundaries are powers of two: 1, 2-3, 4-7, 8-15 and so on. Empty buckets are omitted.
+func (s *BatchStats) Histogram() []BatchSizeBucket {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var res []BatchSizeBucket
+	for i, cnt := range s.buckets {
+		if cnt == 0 {
+			continue
+		}
+		res = append(res, BatchSizeBucket{Min: 1 << i, Max: 1<<(i+1) - 1, Count: cnt})
+	}
+	return res
+}
+
+// WindowByTime groups items from the input stream into non-overlapping (tumbling) time windows of duration d,
+// based on their arrival time. Each window is emitted as a slice at its end, independent of the number of items in it.
+// Windows are aligned to the moment WindowByTime is called. Empty windows are not emitted.
+// If d is zero or negative, all items are collected into a single window, that is emitted when the input stream ends.
+//
+// This is a non-blocking ordered function that processes items sequentially.
+//
+// See the package documentation for more information on non-blocking ordered functions and error handling.
+func WindowByTime[A any](in <-chan Try[A], d time.Duration) <-chan Try[[]A] {
+	values, errs := ToChans(in)
+	windows := core.WindowByTime(values, d)
+	return FromChans(windows, errs)
+}
+
+// SessionWindow groups items from the input stream into per-key sessions, based on their arrival time.
+// A session is closed and emitted as a slice when no items with its key arrive for the duration of gap.
+// This is useful for grouping user activity events into sessions. All open sessions are emitted when the input stream ends.
+//
+// This is a non-blocking function that processes items sequentially. Items within each session preserve their order.
+//
+// See the package documentation for more information on non-blocking functions and error handling.
+func SessionWindow[A any, K comparable](in <-chan Try[A], gap time.Duration, key func(A) K) <-chan Try[[]A] {
+	values, errs := ToChans(in)
+	sessions := core.SessionWindow(values, gap, key)
+	return FromChans(sessions, errs)
+}
+
 // Unbatch is the inverse of [Batch]. It takes a stream of batches and returns a stream of individual items.
 //
 // This is a non-blocking ordered function that processes items sequentially.
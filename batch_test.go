@@ -3,6 +3,7 @@ package rill
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/destel/rill/internal/th"
 )
@@ -27,6 +28,154 @@ func TestBatch(t *testing.T) {
 
 }
 
+func TestBatchWithMin(t *testing.T) {
+	// most logic is covered by the core package tests
+
+	t.Run("correctness", func(t *testing.T) {
+		in := FromChan(th.FromRange(0, 10), fmt.Errorf("err0"))
+		in = replaceWithError(in, 5, fmt.Errorf("err5"))
+
+		batches, errs := toSliceAndErrors(BatchWithMin(in, 2, 3, 1*time.Second))
+
+		th.ExpectValue(t, len(batches), 3)
+		th.ExpectSlice(t, batches[0], []int{0, 1, 2})
+		th.ExpectSlice(t, batches[1], []int{3, 4, 6})
+		th.ExpectSlice(t, batches[2], []int{7, 8, 9})
+		th.ExpectSlice(t, errs, []string{"err0", "err5"})
+	})
+}
+
+func TestBatchWithStats(t *testing.T) {
+	t.Run("correctness", func(t *testing.T) {
+		in := make(chan Try[int])
+		go func() {
+			defer close(in)
+			for i := 0; i < 20; i++ {
+				in <- Try[int]{Value: i}
+			}
+			in <- Try[int]{Error: fmt.Errorf("err")}
+			time.Sleep(1 * time.Second)
+			in <- Try[int]{Value: 20}
+			in <- Try[int]{Value: 21}
+			time.Sleep(1 * time.Second)
+			in <- Try[int]{Value: 22}
+		}()
+
+		out, stats := BatchWithStats(in, 8, 500*time.Millisecond)
+
+		batches, errs := toSliceAndErrors(out)
+		th.ExpectValue(t, len(batches), 5)
+		th.ExpectSlice(t, errs, []string{"err"})
+
+		th.ExpectValue(t, stats.Batches(), int64(5))
+		th.ExpectValue(t, stats.TimedOut(), int64(2))
+		th.ExpectValue(t, stats.MeanSize(), 23.0/5)
+		th.ExpectSlice(t, stats.Histogram(), []BatchSizeBucket{
+			{Min: 1, Max: 1, Count: 1},
+			{Min: 2, Max: 3, Count: 1},
+			{Min: 4, Max: 7, Count: 1},
+			{Min: 8, Max: 15, Count: 2},
+		})
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		out, stats := BatchWithStats(FromSlice[int](nil, nil), 8, -1)
+		th.ExpectValue(t, len(th.ToSlice(out)), 0)
+		th.ExpectValue(t, stats.Batches(), int64(0))
+		th.ExpectValue(t, stats.MeanSize(), 0.0)
+		th.ExpectValue(t, len(stats.Histogram()), 0)
+	})
+}
+
+func TestPooledBatch(t *testing.T) {
+	t.Run("correctness", func(t *testing.T) {
+		in := FromChan(th.FromRange(0, 10), fmt.Errorf("err0"))
+		in = replaceWithError(in, 5, fmt.Errorf("err5"))
+
+		batches, errs := toSliceAndErrors(PooledBatch(in, 3, -1))
+
+		th.ExpectValue(t, len(batches), 3)
+		th.ExpectSlice(t, batches[0], []int{0, 1, 2})
+		th.ExpectSlice(t, batches[1], []int{3, 4, 6})
+		th.ExpectSlice(t, batches[2], []int{7, 8, 9})
+		th.ExpectSlice(t, errs, []string{"err0", "err5"})
+	})
+
+	t.Run("release", func(t *testing.T) {
+		in := FromChan(th.FromRange(0, 1000), nil)
+
+		arrays := make(map[*int]bool)
+		reused := false
+
+		err := ForEach(PooledBatch(in, 2, -1), 1, func(batch []int) error {
+			ptr := &batch[:1][0]
+			if arrays[ptr] {
+				reused = true
+			}
+			arrays[ptr] = true
+
+			ReleaseBatch(batch)
+			return nil
+		})
+		th.ExpectNoError(t, err)
+
+		// sync.Pool gives no guarantees, so just check that arrays are reused at least sometimes
+		th.ExpectValue(t, reused, true)
+	})
+
+	t.Run("release clears items", func(t *testing.T) {
+		batches, _ := toSliceAndErrors(PooledBatch(FromSlice([]*int{new(int), new(int)}, nil), 2, -1))
+		th.ExpectValue(t, len(batches), 1)
+
+		batch := batches[0]
+		ReleaseBatch(batch)
+		th.ExpectValue(t, batch[:2][0] == nil && batch[:2][1] == nil, true)
+	})
+
+	t.Run("release empty", func(t *testing.T) {
+		ReleaseBatch([]int(nil))
+
+		batches, _ := toSliceAndErrors(PooledBatch(FromChan(th.FromRange(0, 3), nil), 3, -1))
+		th.ExpectSlice(t, batches[0], []int{0, 1, 2})
+	})
+}
+
+func TestWindowByTime(t *testing.T) {
+	// most logic is covered by the core package tests
+
+	t.Run("correctness", func(t *testing.T) {
+		in := FromChan(th.FromRange(0, 10), fmt.Errorf("err0"))
+		in = replaceWithError(in, 5, fmt.Errorf("err5"))
+
+		windows, errs := toSliceAndErrors(WindowByTime(in, 1*time.Second))
+
+		th.ExpectValue(t, len(windows), 1)
+		th.ExpectSlice(t, windows[0], []int{0, 1, 2, 3, 4, 6, 7, 8, 9})
+		th.ExpectSlice(t, errs, []string{"err0", "err5"})
+	})
+}
+
+func TestSessionWindow(t *testing.T) {
+	// most logic is covered by the core package tests
+
+	t.Run("correctness", func(t *testing.T) {
+		in := FromChan(th.FromRange(0, 10), fmt.Errorf("err0"))
+		in = replaceWithError(in, 5, fmt.Errorf("err5"))
+
+		sessions, errs := toSliceAndErrors(SessionWindow(in, 1*time.Second, func(x int) int {
+			return x % 2
+		}))
+
+		th.ExpectValue(t, len(sessions), 2)
+		if sessions[0][0] == 1 {
+			sessions[0], sessions[1] = sessions[1], sessions[0]
+		}
+		th.ExpectSlice(t, sessions[0], []int{0, 2, 4, 6, 8})
+		th.ExpectSlice(t, sessions[1], []int{1, 3, 7, 9})
+		th.ExpectSlice(t, errs, []string{"err0", "err5"})
+	})
+}
+
 func TestUnbatch(t *testing.T) {
 	// most logic is covered by the common package tests
 
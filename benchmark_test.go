@@ -138,3 +138,72 @@ func BenchmarkReduce(b *testing.B) {
 		})
 	}
 }
+
+// BenchmarkMapChain and BenchmarkChunkMapChain compare a chain of stages with cheap functions,
+// where the cost of per-item channel synchronization dominates.
+func BenchmarkMapChain(b *testing.B) {
+	for _, n := range []int{1, 4} {
+		runBenchmark(b, th.Name(n), func(in <-chan Try[int]) {
+			out := in
+			for i := 0; i < 3; i++ {
+				out = Map(out, n, func(x int) (int, error) { return x + 1, nil })
+			}
+			Drain(out)
+		})
+	}
+}
+
+func BenchmarkChunkMapChain(b *testing.B) {
+	for _, n := range []int{1, 4} {
+		runBenchmark(b, th.Name(n), func(in <-chan Try[int]) {
+			out := Chunk(in, 256)
+			for i := 0; i < 3; i++ {
+				out = ChunkMap(out, n, func(x int) (int, error) { return x + 1, nil })
+			}
+			Drain(out)
+		})
+	}
+}
+
+func BenchmarkMapChainBuffered(b *testing.B) {
+	for _, n := range []int{1, 4} {
+		runBenchmark(b, th.Name(n), func(in <-chan Try[int]) {
+			out := in
+			for i := 0; i < 3; i++ {
+				out = Map(out, n, func(x int) (int, error) { return x + 1, nil }, WithBuffer(256))
+			}
+			Drain(out)
+		})
+	}
+}
+
+func BenchmarkOrderedMapAndDrain(b *testing.B) {
+	for _, n := range []int{2, 4, 8} {
+		runBenchmark(b, th.Name(n), func(in <-chan Try[int]) {
+			out := OrderedMap(in, n, func(x int) (int, error) {
+				benchmarkIteration()
+				return x, nil
+			})
+
+			Drain(out)
+		})
+	}
+}
+
+// BenchmarkBatch and BenchmarkPooledBatch compare allocations of batch slices, run with -benchmem.
+func BenchmarkBatch(b *testing.B) {
+	runBenchmark(b, "", func(in <-chan Try[int]) {
+		_ = ForEach(Batch(in, 1000, -1), 1, func(batch []int) error {
+			return nil
+		})
+	})
+}
+
+func BenchmarkPooledBatch(b *testing.B) {
+	runBenchmark(b, "", func(in <-chan Try[int]) {
+		_ = ForEach(PooledBatch(in, 1000, -1), 1, func(batch []int) error {
+			ReleaseBatch(batch)
+			return nil
+		})
+	})
+}
@@ -0,0 +1,219 @@
+package rill
+
+import (
+	"runtime"
+
+	"github.com/destel/rill/internal/core"
+)
+
+// Chunk groups items of the input stream into small slices (chunks) of up to size items. Passing chunks between stages,
+// instead of individual items, amortizes the cost of channel synchronization, which dominates in pipelines
+// that process many small items with cheap functions.
+//
+// Unlike [Batch], Chunk never waits for a chunk to fill up: it blocks only until the first item of a chunk arrives,
+// and then adds items that are immediately available. This way it adds no latency, and chunks naturally grow
+// when the upstream is faster than the downstream.
+//
+// Chunked streams are processed by [ChunkMap], [ChunkFilter], [ChunkForEach] and their ordered versions,
+// which still call the user functions for each item individually. [Unbatch] turns a chunked stream back into a stream of items:
+//
+//	chunks := rill.Chunk(numbers, 256)
+//	chunks = rill.ChunkMap(chunks, 4, square)
+//	chunks = rill.ChunkFilter(chunks, 4, isEven)
+//	squares := rill.Unbatch(chunks)
+//
+// Errors are never put into chunks. Each error is sent to the output stream on its own, after the chunk with the preceding items.
+//
+// This is a non-blocking ordered function that processes items sequentially.
+//
+// See the package documentation for more information on non-blocking ordered functions and error handling.
+func Chunk[A any](in <-chan Try[A], size int) <-chan Try[[]A] {
+	if in == nil {
+		return nil
+	}
+	if size < 1 {
+		size = 1
+	}
+
+	out := make(chan Try[[]A])
+
+	// receive returns the next item if it's immediately available.
+	// It yields once before giving up, to let a ready upstream goroutine send the item.
+	receive := func() (a Try[A], ok bool, ready bool) {
+		select {
+		case a, ok = <-in:
+			return a, ok, true
+		default:
+		}
+
+		runtime.Gosched()
+
+		select {
+		case a, ok = <-in:
+			return a, ok, true
+		default:
+			return a, false, false
+		}
+	}
+
+	go func() {
+		defer close(out)
+
+		chunk := make([]A, 0, size)
+		flush := func() {
+			if len(chunk) > 0 {
+				out <- Try[[]A]{Value: chunk}
+				chunk = make([]A, 0, size)
+			}
+		}
+
+		for {
+			a, ok := <-in
+			if !ok {
+				return
+			}
+
+			for ready := true; ready; a, ok, ready = receive() {
+				if !ok {
+					flush()
+					return
+				}
+
+				if a.Error != nil {
+					flush()
+					out <- Try[[]A]{Error: a.Error}
+					continue
+				}
+
+				chunk = append(chunk, a.Value)
+				if len(chunk) >= size {
+					flush()
+				}
+			}
+
+			flush()
+		}
+	}()
+
+	return out
+}
+
+// ChunkMap applies the function f to each item of each chunk of the input stream, and returns a chunked stream of results.
+// See [Chunk] for details on chunked streams. If f returns an error for an item, the error is sent to the output stream
+// on its own, right after the chunk with the results of the preceding items.
+//
+// This is a non-blocking unordered function that processes chunks concurrently using n goroutines.
+// Items within a chunk are always processed sequentially and keep their order.
+// An ordered version of this function, [OrderedChunkMap], is also available.
+//
+// See the package documentation for more information on non-blocking unordered functions and error handling.
+func ChunkMap[A, B any](in <-chan Try[[]A], n int, f func(A) (B, error)) <-chan Try[[]B] {
+	return chunkFilterMap(in, n, false, func(a A) (B, bool, error) {
+		b, err := f(a)
+		return b, true, err
+	})
+}
+
+// OrderedChunkMap is the ordered version of [ChunkMap].
+func OrderedChunkMap[A, B any](in <-chan Try[[]A], n int, f func(A) (B, error)) <-chan Try[[]B] {
+	return chunkFilterMap(in, n, true, func(a A) (B, bool, error) {
+		b, err := f(a)
+		return b, true, err
+	})
+}
+
+// ChunkFilter removes the items of each chunk of the input stream, for which the function f returns false.
+// See [Chunk] for details on chunked streams. Chunks that become empty are not sent to the output stream.
+//
+// This is a non-blocking unordered function that processes chunks concurrently using n goroutines.
+// Items within a chunk are always processed sequentially and keep their order.
+// An ordered version of this function, [OrderedChunkFilter], is also available.
+//
+// See the package documentation for more information on non-blocking unordered functions and error handling.
+func ChunkFilter[A any](in <-chan Try[[]A], n int, f func(A) (bool, error)) <-chan Try[[]A] {
+	return chunkFilterMap(in, n, false, func(a A) (A, bool, error) {
+		keep, err := f(a)
+		return a, keep, err
+	})
+}
+
+// OrderedChunkFilter is the ordered version of [ChunkFilter].
+func OrderedChunkFilter[A any](in <-chan Try[[]A], n int, f func(A) (bool, error)) <-chan Try[[]A] {
+	return chunkFilterMap(in, n, true, func(a A) (A, bool, error) {
+		keep, err := f(a)
+		return a, keep, err
+	})
+}
+
+// ChunkForEach applies the function f to each item of each chunk of the input stream.
+// See [Chunk] for details on chunked streams.
+//
+// This is a blocking unordered function that processes chunks concurrently using n goroutines.
+// When n = 1, processing becomes sequential, making the function ordered and similar to a regular for-range loop.
+//
+// See the package documentation for more information on blocking unordered functions and error handling.
+func ChunkForEach[A any](in <-chan Try[[]A], n int, f func(A) error) error {
+	return ForEach(in, n, func(chunk []A) error {
+		for _, a := range chunk {
+			if err := f(a); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func chunkFilterMap[A, B any](in <-chan Try[[]A], n int, ordered bool, f func(A) (B, bool, error)) <-chan Try[[]B] {
+	if in == nil {
+		return nil
+	}
+
+	// process applies f to the chunk, splitting the results around the errors
+	process := func(chunk Try[[]A]) []Try[[]B] {
+		if chunk.Error != nil {
+			return []Try[[]B]{{Error: chunk.Error}}
+		}
+
+		var res []Try[[]B]
+		bb := make([]B, 0, len(chunk.Value))
+
+		for _, a := range chunk.Value {
+			b, keep, err := f(a)
+			switch {
+			case err != nil:
+				if len(bb) > 0 {
+					res = append(res, Try[[]B]{Value: bb})
+					bb = make([]B, 0, len(chunk.Value))
+				}
+				res = append(res, Try[[]B]{Error: err})
+			case keep:
+				bb = append(bb, b)
+			}
+		}
+
+		if len(bb) > 0 {
+			res = append(res, Try[[]B]{Value: bb})
+		}
+		return res
+	}
+
+	out := make(chan Try[[]B])
+
+	if ordered {
+		core.OrderedLoop(in, out, n, func(chunk Try[[]A], canWrite <-chan struct{}) {
+			res := process(chunk)
+			<-canWrite
+			for _, r := range res {
+				out <- r
+			}
+		})
+	} else {
+		core.Loop(in, out, n, func(chunk Try[[]A]) {
+			for _, r := range process(chunk) {
+				out <- r
+			}
+		})
+	}
+
+	return out
+}
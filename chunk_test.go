@@ -0,0 +1,163 @@
+package rill
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/destel/rill/internal/th"
+)
+
+func universalChunkMap[A, B any](ord bool, in <-chan Try[[]A], n int, f func(A) (B, error)) <-chan Try[[]B] {
+	if ord {
+		return OrderedChunkMap(in, n, f)
+	}
+	return ChunkMap(in, n, f)
+}
+
+func universalChunkFilter[A any](ord bool, in <-chan Try[[]A], n int, f func(A) (bool, error)) <-chan Try[[]A] {
+	if ord {
+		return OrderedChunkFilter(in, n, f)
+	}
+	return ChunkFilter(in, n, f)
+}
+
+func TestChunk(t *testing.T) {
+	t.Run("nil", func(t *testing.T) {
+		th.ExpectValue(t, Chunk[int](nil, 10), nil)
+	})
+
+	t.Run("correctness", func(t *testing.T) {
+		in := FromChan(th.FromRange(0, 1000), nil)
+		in = replaceWithError(in, 500, fmt.Errorf("err500"))
+
+		var values []int
+		var errs []string
+		for chunk := range Chunk(in, 64) {
+			if chunk.Error != nil {
+				errs = append(errs, chunk.Error.Error())
+				continue
+			}
+
+			if len(chunk.Value) == 0 || len(chunk.Value) > 64 {
+				t.Errorf("unexpected chunk size %d", len(chunk.Value))
+			}
+			values = append(values, chunk.Value...)
+		}
+
+		th.ExpectValue(t, len(values), 999)
+		th.ExpectSorted(t, values)
+		th.ExpectSlice(t, errs, []string{"err500"})
+	})
+
+	t.Run("no latency", func(t *testing.T) {
+		in := make(chan Try[int])
+		defer close(in)
+
+		out := Chunk(in, 100)
+
+		th.ExpectNotHang(t, 1*time.Second, func() {
+			in <- Try[int]{Value: 1}
+			th.ExpectSlice(t, (<-out).Value, []int{1})
+		})
+	})
+}
+
+func TestChunkMap(t *testing.T) {
+	th.TestBothOrderings(t, func(t *testing.T, ord bool) {
+		for _, n := range []int{1, 5} {
+			t.Run(th.Name("nil", n), func(t *testing.T) {
+				out := universalChunkMap(ord, nil, n, func(x int) (int, error) { return x, nil })
+				th.ExpectValue(t, out, nil)
+			})
+
+			t.Run(th.Name("correctness", n), func(t *testing.T) {
+				in := FromChan(th.FromRange(0, 1000), nil)
+				in = replaceWithError(in, 150, fmt.Errorf("err150"))
+
+				out := universalChunkMap(ord, Chunk(in, 32), n, func(x int) (string, error) {
+					if x == 500 {
+						return "", fmt.Errorf("err500")
+					}
+					return fmt.Sprintf("%03d", x), nil
+				})
+
+				outSlice, errSlice := toSliceAndErrors(Unbatch(out))
+				if ord {
+					th.ExpectSorted(t, outSlice)
+				}
+				th.Sort(outSlice)
+				th.Sort(errSlice)
+
+				th.ExpectValue(t, len(outSlice), 998)
+				th.ExpectSlice(t, errSlice, []string{"err150", "err500"})
+			})
+		}
+	})
+}
+
+func TestChunkFilter(t *testing.T) {
+	th.TestBothOrderings(t, func(t *testing.T, ord bool) {
+		for _, n := range []int{1, 5} {
+			t.Run(th.Name("correctness", n), func(t *testing.T) {
+				in := FromChan(th.FromRange(0, 1000), nil)
+
+				out := universalChunkFilter(ord, Chunk(in, 32), n, func(x int) (bool, error) {
+					if x == 500 {
+						return false, fmt.Errorf("err500")
+					}
+					return x%100 == 0, nil
+				})
+
+				var values []int
+				var errs []string
+				for chunk := range out {
+					if chunk.Error != nil {
+						errs = append(errs, chunk.Error.Error())
+						continue
+					}
+					if len(chunk.Value) == 0 {
+						t.Errorf("empty chunks must not be emitted")
+					}
+					values = append(values, chunk.Value...)
+				}
+
+				if ord {
+					th.ExpectSorted(t, values)
+				}
+				th.Sort(values)
+
+				th.ExpectSlice(t, values, []int{0, 100, 200, 300, 400, 600, 700, 800, 900})
+				th.ExpectSlice(t, errs, []string{"err500"})
+			})
+		}
+	})
+}
+
+func TestChunkForEach(t *testing.T) {
+	t.Run("correctness", func(t *testing.T) {
+		var sum int
+		err := ChunkForEach(Chunk(FromChan(th.FromRange(0, 100), nil), 16), 1, func(x int) error {
+			sum += x
+			return nil
+		})
+
+		th.ExpectNoError(t, err)
+		th.ExpectValue(t, sum, 4950)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		in := FromChan(th.FromRange(0, 1000), nil)
+
+		err := ChunkForEach(Chunk(in, 16), 3, func(x int) error {
+			if x == 100 {
+				return fmt.Errorf("err100")
+			}
+			return nil
+		})
+
+		th.ExpectError(t, err, "err100")
+		time.Sleep(1 * time.Second)
+		th.ExpectDrainedChan(t, in)
+	})
+}
@@ -1,27 +1,43 @@
 package rill
 
 import (
+	"errors"
+	"sync"
+
 	"github.com/destel/rill/internal/core"
 )
 
 // ForEach applies a function f to each item in an input stream.
 //
+// ForEach accepts [WithStageName], [WithRecover] and [WithLimiter] options. When labeled with a name,
+// the returned error is wrapped into [PipelineError].
+//
 // This is a blocking unordered function that processes items concurrently using n goroutines.
 // When n = 1, processing becomes sequential, making the function ordered and similar to a regular for-range loop.
 //
 // See the package documentation for more information on blocking unordered functions and error handling.
-func ForEach[A any](in <-chan Try[A], n int, f func(A) error) error {
+func ForEach[A any](in <-chan Try[A], n int, f func(A) error, opts ...StageOption) error {
+	cfg := newStageConfig("ForEach", in, n, opts)
+	f = stageErrFunc(cfg, f)
+	report := newStageReport(cfg)
+
 	var retErr error
 	var once core.OnceWithWait
 	setReturns := func(err error) {
 		once.Do(func() {
-			retErr = err
+			retErr = report.wrap(err)
 		})
 	}
 
+	core.Claim(in)
+	discard := core.DrainSink(in)
+
 	go func() {
 		core.ForEach(in, n, func(a Try[A]) {
 			if once.WasCalled() {
+				core.NotifyDrainStart(in)
+				report.addSkipped()
+				discard(a)
 				return // drain
 			}
 
@@ -31,7 +47,9 @@ func ForEach[A any](in <-chan Try[A], n int, f func(A) error) error {
 			}
 			if err != nil {
 				setReturns(err)
+				return
 			}
+			report.addProcessed()
 		})
 
 		setReturns(nil)
@@ -41,17 +59,56 @@ func ForEach[A any](in <-chan Try[A], n int, f func(A) error) error {
 	return retErr
 }
 
+// ForEachAll is similar to [ForEach], but doesn't stop on errors. It processes all items of the input stream,
+// collects every error, both from the stream and from the function f, and returns them joined with [errors.Join].
+// This is useful for batch jobs, where partial success matters more than failing fast.
+// If there were no errors, ForEachAll returns nil.
+//
+// This is a blocking unordered function that processes items concurrently using n goroutines.
+// When n = 1, processing becomes sequential, making the function ordered and similar to a regular for-range loop.
+// In this case errors are also joined in the order they occurred.
+//
+// See the package documentation for more information on blocking unordered functions and error handling.
+func ForEachAll[A any](in <-chan Try[A], n int, f func(A) error) error {
+	var mu sync.Mutex
+	var errs []error
+
+	core.Claim(in)
+
+	core.ForEach(in, n, func(a Try[A]) {
+		err := a.Error
+		if err == nil {
+			err = f(a.Value)
+		}
+		if err == nil {
+			return
+		}
+
+		mu.Lock()
+		errs = append(errs, err)
+		mu.Unlock()
+	})
+
+	return errors.Join(errs...)
+}
+
 // Err returns the first error encountered in the input stream or nil if there were no errors.
 //
+// Err accepts the [WithStageName] option. When labeled with a name, the returned error is wrapped into [PipelineError].
+//
 // This is a blocking ordered function that processes items sequentially.
 // See the package documentation for more information on blocking ordered functions and error handling.
-func Err[A any](in <-chan Try[A]) error {
+func Err[A any](in <-chan Try[A], opts ...StageOption) error {
+	report := newStageReport(newStageConfig("Err", in, 1, opts))
+
+	core.Claim(in)
 	defer DrainNB(in)
 
 	for a := range in {
 		if a.Error != nil {
-			return a.Error
+			return report.wrap(a.Error)
 		}
+		report.addProcessed()
 	}
 
 	return nil
@@ -63,6 +120,7 @@ func Err[A any](in <-chan Try[A]) error {
 // This is a blocking ordered function that processes items sequentially.
 // See the package documentation for more information on blocking ordered functions and error handling.
 func First[A any](in <-chan Try[A]) (value A, found bool, err error) {
+	core.Claim(in)
 	defer DrainNB(in)
 
 	for a := range in {
@@ -73,6 +131,35 @@ func First[A any](in <-chan Try[A]) (value A, found bool, err error) {
 	return
 }
 
+// FirstN returns up to count first values from the input stream. The rest of the stream is drained in the background.
+// This is useful to get a sample of results and stop the expensive upstream work as early as possible.
+// If an error is encountered before count values are collected, FirstN returns the values collected so far and the error.
+//
+// This is a blocking ordered function that processes items sequentially.
+// See the package documentation for more information on blocking ordered functions and error handling.
+func FirstN[A any](in <-chan Try[A], count int) ([]A, error) {
+	core.Claim(in)
+	defer DrainNB(in)
+
+	if count <= 0 {
+		return nil, nil
+	}
+
+	res := make([]A, 0, count)
+	for a := range in {
+		if a.Error != nil {
+			return res, a.Error
+		}
+
+		res = append(res, a.Value)
+		if len(res) >= count {
+			break
+		}
+	}
+
+	return res, nil
+}
+
 // Any checks if there is an item in the input stream that satisfies the condition f.
 // This function returns true as soon as it finds such an item. Otherwise, it returns false.
 //
@@ -91,9 +178,14 @@ func Any[A any](in <-chan Try[A], n int, f func(A) (bool, error)) (bool, error)
 		})
 	}
 
+	core.Claim(in)
+	discard := core.DrainSink(in)
+
 	go func() {
 		core.ForEach(in, n, func(a Try[A]) {
 			if once.WasCalled() {
+				core.NotifyDrainStart(in)
+				discard(a)
 				return // drain
 			}
 
@@ -120,6 +212,113 @@ func Any[A any](in <-chan Try[A], n int, f func(A) (bool, error)) (bool, error)
 	return retFound, retErr
 }
 
+// Find returns an item from the input stream that satisfies the condition f. As soon as such an item is found,
+// evaluation of f stops and the rest of the stream is drained in the background.
+// The found return flag is set to false if there were no such items, otherwise it is set to true.
+//
+// This is a blocking unordered function that processes items concurrently using n goroutines.
+// When n = 1, processing becomes sequential, making the function ordered.
+// An ordered version of this function, [OrderedFind], is also available.
+//
+// See the package documentation for more information on blocking unordered functions and error handling.
+func Find[A any](in <-chan Try[A], n int, f func(A) (bool, error)) (value A, found bool, err error) {
+	var zero A
+	var once core.OnceWithWait
+	setReturns := func(v A, ok bool, e error) {
+		once.Do(func() {
+			value, found, err = v, ok, e
+		})
+	}
+
+	core.Claim(in)
+	discard := core.DrainSink(in)
+
+	go func() {
+		core.ForEach(in, n, func(a Try[A]) {
+			if once.WasCalled() {
+				core.NotifyDrainStart(in)
+				discard(a)
+				return // drain
+			}
+
+			if a.Error != nil {
+				setReturns(zero, false, a.Error)
+				return
+			}
+
+			ok, err := f(a.Value)
+			if err != nil {
+				setReturns(zero, false, err)
+				return
+			}
+			if ok {
+				setReturns(a.Value, true, nil)
+				return
+			}
+		})
+
+		setReturns(zero, false, nil)
+	}()
+
+	once.Wait()
+	return
+}
+
+// OrderedFind is the ordered version of [Find]. It evaluates the condition f concurrently using n goroutines,
+// but returns the earliest matching item in the stream order. Errors are also handled in the stream order:
+// an error is returned only if no matching item precedes it.
+//
+// Compared to combining [OrderedFilter] and [First], OrderedFind stops evaluating f for the rest of the stream
+// as soon as the match is found.
+//
+// This is a blocking ordered function that processes items concurrently using n goroutines.
+// See the package documentation for more information on blocking ordered functions and error handling.
+func OrderedFind[A any](in <-chan Try[A], n int, f func(A) (bool, error)) (value A, found bool, err error) {
+	var zero A
+	var once core.OnceWithWait
+	setReturns := func(v A, ok bool, e error) {
+		once.Do(func() {
+			value, found, err = v, ok, e
+		})
+	}
+
+	discard := core.DrainSink(in)
+	done := make(chan struct{})
+
+	core.OrderedLoop(in, done, n, func(a Try[A], canWrite <-chan struct{}) {
+		if once.WasCalled() {
+			// the result can only be set by a preceding item, so this one can be skipped
+			<-canWrite
+			core.NotifyDrainStart(in)
+			discard(a)
+			return
+		}
+
+		var ok bool
+		var e error
+		if a.Error != nil {
+			e = a.Error
+		} else {
+			ok, e = f(a.Value)
+		}
+
+		<-canWrite
+		if e != nil {
+			setReturns(zero, false, e)
+		} else if ok {
+			setReturns(a.Value, true, nil)
+		}
+	})
+
+	go func() {
+		<-done
+		setReturns(zero, false, nil)
+	}()
+
+	once.Wait()
+	return
+}
+
 // All checks if all items in the input stream satisfy the condition f.
 // This function returns false as soon as it finds an item that does not satisfy the condition. Otherwise, it returns true,
 // including the case when the stream was empty.
@@ -137,3 +336,96 @@ func All[A any](in <-chan Try[A], n int, f func(A) (bool, error)) (bool, error)
 	})
 	return !res, err // negate
 }
+
+// Number is a constraint that permits any integer or floating-point type.
+type Number interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+		~float32 | ~float64
+}
+
+// Count returns the number of items in the input stream.
+//
+// This is a blocking ordered function that processes items sequentially.
+// See the package documentation for more information on blocking ordered functions and error handling.
+func Count[A any](in <-chan Try[A]) (int, error) {
+	core.Claim(in)
+
+	count := 0
+	for a := range in {
+		if a.Error != nil {
+			DrainNB(in)
+			return count, a.Error
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+// Sum returns the sum of all items in the input stream. The sum of an empty stream is zero.
+//
+// This is a blocking ordered function that processes items sequentially.
+// See the package documentation for more information on blocking ordered functions and error handling.
+func Sum[A Number](in <-chan Try[A]) (A, error) {
+	core.Claim(in)
+
+	var sum A
+	for a := range in {
+		if a.Error != nil {
+			DrainNB(in)
+			return sum, a.Error
+		}
+		sum += a.Value
+	}
+
+	return sum, nil
+}
+
+// Min returns the smallest item in the input stream, according to the less function.
+// If several items are equally small, the first one is returned.
+// The found return flag is set to false if the stream was empty, otherwise it is set to true.
+//
+// This is a blocking ordered function that processes items sequentially.
+// See the package documentation for more information on blocking ordered functions and error handling.
+func Min[A any](in <-chan Try[A], less func(a, b A) bool) (value A, found bool, err error) {
+	core.Claim(in)
+
+	for a := range in {
+		if a.Error != nil {
+			DrainNB(in)
+			return value, found, a.Error
+		}
+
+		if !found || less(a.Value, value) {
+			value = a.Value
+			found = true
+		}
+	}
+
+	return value, found, nil
+}
+
+// Max returns the largest item in the input stream, according to the less function.
+// If several items are equally large, the first one is returned.
+// The found return flag is set to false if the stream was empty, otherwise it is set to true.
+//
+// This is a blocking ordered function that processes items sequentially.
+// See the package documentation for more information on blocking ordered functions and error handling.
+func Max[A any](in <-chan Try[A], less func(a, b A) bool) (value A, found bool, err error) {
+	core.Claim(in)
+
+	for a := range in {
+		if a.Error != nil {
+			DrainNB(in)
+			return value, found, a.Error
+		}
+
+		if !found || less(value, a.Value) {
+			value = a.Value
+			found = true
+		}
+	}
+
+	return value, found, nil
+}
@@ -2,6 +2,7 @@ package rill
 
 import (
 	"fmt"
+	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -37,6 +38,43 @@ func TestErr(t *testing.T) {
 	})
 }
 
+func TestForEachAll(t *testing.T) {
+	for _, n := range []int{1, 5} {
+		t.Run(th.Name("no errors", n), func(t *testing.T) {
+			var sum atomic.Int64
+			err := ForEachAll(FromChan(th.FromRange(0, 10), nil), n, func(x int) error {
+				sum.Add(int64(x))
+				return nil
+			})
+
+			th.ExpectNoError(t, err)
+			th.ExpectValue(t, sum.Load(), int64(45))
+		})
+
+		t.Run(th.Name("errors", n), func(t *testing.T) {
+			in := FromChan(th.FromRange(0, 1000), nil)
+			in = replaceWithError(in, 100, fmt.Errorf("err100"))
+
+			var cnt atomic.Int64
+			err := ForEachAll(in, n, func(x int) error {
+				cnt.Add(1)
+				if x == 500 {
+					return fmt.Errorf("err500")
+				}
+				return nil
+			})
+
+			if n == 1 {
+				th.ExpectError(t, err, "err100\nerr500")
+			} else if !strings.Contains(err.Error(), "err100") || !strings.Contains(err.Error(), "err500") {
+				t.Errorf("expected both errors, got %v", err)
+			}
+			th.ExpectValue(t, cnt.Load(), int64(999))
+			th.ExpectDrainedChan(t, in)
+		})
+	}
+}
+
 func TestFirst(t *testing.T) {
 	t.Run("empty", func(t *testing.T) {
 		in := FromChan(th.FromSlice([]int{}), nil)
@@ -73,6 +111,54 @@ func TestFirst(t *testing.T) {
 	})
 }
 
+func TestFirstN(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		res, err := FirstN(FromSlice[int](nil, nil), 10)
+		th.ExpectNoError(t, err)
+		th.ExpectValue(t, len(res), 0)
+	})
+
+	t.Run("zero count", func(t *testing.T) {
+		in := FromChan(th.FromRange(0, 1000), nil)
+		res, err := FirstN(in, 0)
+		th.ExpectNoError(t, err)
+		th.ExpectValue(t, len(res), 0)
+
+		time.Sleep(1 * time.Second)
+		th.ExpectDrainedChan(t, in)
+	})
+
+	t.Run("short stream", func(t *testing.T) {
+		res, err := FirstN(FromChan(th.FromRange(0, 5), nil), 10)
+		th.ExpectNoError(t, err)
+		th.ExpectSlice(t, res, []int{0, 1, 2, 3, 4})
+	})
+
+	t.Run("long stream", func(t *testing.T) {
+		in := FromChan(th.FromRange(0, 1000), nil)
+		in = replaceWithError(in, 100, fmt.Errorf("err100"))
+
+		res, err := FirstN(in, 5)
+		th.ExpectNoError(t, err)
+		th.ExpectSlice(t, res, []int{0, 1, 2, 3, 4})
+
+		time.Sleep(1 * time.Second)
+		th.ExpectDrainedChan(t, in)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		in := FromChan(th.FromRange(0, 1000), nil)
+		in = replaceWithError(in, 3, fmt.Errorf("err3"))
+
+		res, err := FirstN(in, 5)
+		th.ExpectError(t, err, "err3")
+		th.ExpectSlice(t, res, []int{0, 1, 2})
+
+		time.Sleep(1 * time.Second)
+		th.ExpectDrainedChan(t, in)
+	})
+}
+
 func TestForEach(t *testing.T) {
 	for _, n := range []int{1, 5} {
 
@@ -144,6 +230,108 @@ func TestForEach(t *testing.T) {
 	}
 }
 
+func universalFind[A any](ord bool, in <-chan Try[A], n int, f func(A) (bool, error)) (A, bool, error) {
+	if ord {
+		return OrderedFind(in, n, f)
+	}
+	return Find(in, n, f)
+}
+
+func TestFind(t *testing.T) {
+	th.TestBothOrderings(t, func(t *testing.T, ord bool) {
+		for _, n := range []int{1, 5} {
+			t.Run(th.Name("empty", n), func(t *testing.T) {
+				_, found, err := universalFind(ord, FromSlice([]int{}, nil), n, func(int) (bool, error) {
+					return true, nil
+				})
+
+				th.ExpectNoError(t, err)
+				th.ExpectValue(t, found, false)
+			})
+
+			t.Run(th.Name("not found", n), func(t *testing.T) {
+				in := FromChan(th.FromRange(0, 1000), nil)
+
+				_, found, err := universalFind(ord, in, n, func(x int) (bool, error) {
+					return x < 0, nil
+				})
+
+				th.ExpectNoError(t, err)
+				th.ExpectValue(t, found, false)
+				th.ExpectDrainedChan(t, in)
+			})
+
+			t.Run(th.Name("found", n), func(t *testing.T) {
+				in := FromChan(th.FromRange(0, 1000), nil)
+
+				var cnt atomic.Int64
+				x, found, err := universalFind(ord, in, n, func(x int) (bool, error) {
+					cnt.Add(1)
+					return x >= 100 && x%10 == 0, nil
+				})
+
+				th.ExpectNoError(t, err)
+				th.ExpectValue(t, found, true)
+				if ord || n == 1 {
+					th.ExpectValue(t, x, 100)
+				} else if x < 100 || x%10 != 0 {
+					t.Errorf("unexpected value %d", x)
+				}
+
+				// wait until it drained
+				time.Sleep(1 * time.Second)
+
+				th.ExpectDrainedChan(t, in)
+				if cnt.Load() > 900 {
+					t.Errorf("extra calls to f were made")
+				}
+			})
+
+			t.Run(th.Name("error before match", n), func(t *testing.T) {
+				in := FromChan(th.FromRange(0, 1000), nil)
+				in = replaceWithError(in, 50, fmt.Errorf("err50"))
+
+				_, found, err := universalFind(ord, in, n, func(x int) (bool, error) {
+					if x == 60 {
+						return false, fmt.Errorf("err60")
+					}
+					return x == 100, nil
+				})
+
+				th.ExpectValue(t, found, false)
+				if ord || n == 1 {
+					th.ExpectError(t, err, "err50")
+				} else if err == nil {
+					t.Errorf("expected error")
+				}
+
+				// wait until it drained
+				time.Sleep(1 * time.Second)
+				th.ExpectDrainedChan(t, in)
+			})
+
+			t.Run(th.Name("error after match", n), func(t *testing.T) {
+				in := FromChan(th.FromRange(0, 1000), nil)
+				in = replaceWithError(in, 101, fmt.Errorf("err101"))
+
+				x, found, err := universalFind(ord, in, n, func(x int) (bool, error) {
+					return x == 100, nil
+				})
+
+				if ord || n == 1 {
+					th.ExpectNoError(t, err)
+					th.ExpectValue(t, found, true)
+					th.ExpectValue(t, x, 100)
+				}
+
+				// wait until it drained
+				time.Sleep(1 * time.Second)
+				th.ExpectDrainedChan(t, in)
+			})
+		}
+	})
+}
+
 func TestAnyAll(t *testing.T) {
 	for _, n := range []int{1, 5} {
 		t.Run(th.Name("empty", n), func(t *testing.T) {
@@ -303,3 +491,96 @@ func TestAnyAll(t *testing.T) {
 	}
 
 }
+
+func TestCount(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		cnt, err := Count(FromSlice[int](nil, nil))
+		th.ExpectNoError(t, err)
+		th.ExpectValue(t, cnt, 0)
+	})
+
+	t.Run("correctness", func(t *testing.T) {
+		cnt, err := Count(FromChan(th.FromRange(0, 100), nil))
+		th.ExpectNoError(t, err)
+		th.ExpectValue(t, cnt, 100)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		in := FromChan(th.FromRange(0, 1000), nil)
+		in = replaceWithError(in, 100, fmt.Errorf("err100"))
+
+		cnt, err := Count(in)
+		th.ExpectError(t, err, "err100")
+		th.ExpectValue(t, cnt, 100)
+
+		time.Sleep(1 * time.Second)
+		th.ExpectDrainedChan(t, in)
+	})
+}
+
+func TestSum(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		sum, err := Sum(FromSlice[float64](nil, nil))
+		th.ExpectNoError(t, err)
+		th.ExpectValue(t, sum, 0.0)
+	})
+
+	t.Run("correctness", func(t *testing.T) {
+		sum, err := Sum(FromChan(th.FromRange(0, 100), nil))
+		th.ExpectNoError(t, err)
+		th.ExpectValue(t, sum, 4950)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		in := FromChan(th.FromRange(0, 1000), nil)
+		in = replaceWithError(in, 100, fmt.Errorf("err100"))
+
+		_, err := Sum(in)
+		th.ExpectError(t, err, "err100")
+
+		time.Sleep(1 * time.Second)
+		th.ExpectDrainedChan(t, in)
+	})
+}
+
+func TestMinMax(t *testing.T) {
+	type item struct {
+		Key, ID int
+	}
+	less := func(a, b item) bool { return a.Key < b.Key }
+
+	t.Run("empty", func(t *testing.T) {
+		_, found, err := Min(FromSlice[item](nil, nil), less)
+		th.ExpectNoError(t, err)
+		th.ExpectValue(t, found, false)
+
+		_, found, err = Max(FromSlice[item](nil, nil), less)
+		th.ExpectNoError(t, err)
+		th.ExpectValue(t, found, false)
+	})
+
+	t.Run("correctness", func(t *testing.T) {
+		items := []item{{3, 0}, {1, 1}, {5, 2}, {1, 3}, {5, 4}, {2, 5}}
+
+		min, found, err := Min(FromSlice(items, nil), less)
+		th.ExpectNoError(t, err)
+		th.ExpectValue(t, found, true)
+		th.ExpectValue(t, min, item{1, 1})
+
+		max, found, err := Max(FromSlice(items, nil), less)
+		th.ExpectNoError(t, err)
+		th.ExpectValue(t, found, true)
+		th.ExpectValue(t, max, item{5, 2})
+	})
+
+	t.Run("error", func(t *testing.T) {
+		in := FromChan(th.FromRange(0, 1000), nil)
+		in = replaceWithError(in, 100, fmt.Errorf("err100"))
+
+		_, _, err := Max(in, func(a, b int) bool { return a < b })
+		th.ExpectError(t, err, "err100")
+
+		time.Sleep(1 * time.Second)
+		th.ExpectDrainedChan(t, in)
+	})
+}
@@ -0,0 +1,152 @@
+package rill
+
+import (
+	"sync"
+
+	"github.com/destel/rill/internal/core"
+)
+
+type controlMsg[C any] struct {
+	Cmd  C
+	Done *sync.WaitGroup
+}
+
+type controlSub[C any] struct {
+	cmds chan controlMsg[C]
+	done chan struct{} // closed when the worker exits
+}
+
+// Control broadcasts commands, such as "reload config", "rotate credentials" or "flush caches",
+// to every worker goroutine of a stage. This allows to reconfigure long-running pipelines without restarting them.
+// Each worker receives the command at a safe point between items.
+//
+// A Control must be created with [NewControl] and attached to a stage created with [MapControlled].
+// The same Control can be attached to several stages.
+type Control[C any] struct {
+	mu   sync.Mutex
+	subs map[*controlSub[C]]struct{}
+}
+
+// NewControl creates a new [Control] for commands of type C.
+func NewControl[C any]() *Control[C] {
+	return &Control[C]{
+		subs: make(map[*controlSub[C]]struct{}),
+	}
+}
+
+// Send delivers the command to all running workers of attached stages and blocks until each of them has handled it.
+// Workers that are busy processing an item receive the command after they're done with that item.
+// Commands are delivered in the same order as they were sent.
+func (c *Control[C]) Send(cmd C) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for sub := range c.subs {
+		wg.Add(1)
+		select {
+		case sub.cmds <- controlMsg[C]{Cmd: cmd, Done: &wg}:
+		case <-sub.done:
+			wg.Done()
+		}
+	}
+
+	wg.Wait()
+}
+
+func (c *Control[C]) subscribe() *controlSub[C] {
+	sub := &controlSub[C]{
+		cmds: make(chan controlMsg[C]),
+		done: make(chan struct{}),
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.subs[sub] = struct{}{}
+	return sub
+}
+
+func (c *Control[C]) unsubscribe(sub *controlSub[C]) {
+	close(sub.done) // unblock a concurrent Send before acquiring the lock
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.subs, sub)
+}
+
+// MapControlled is similar to [Map], but each worker goroutine also receives commands sent to ctrl.
+// Every command is passed to the onCmd function once per worker, in that worker's goroutine, between items.
+// This means that onCmd is never called concurrently with f within the same worker,
+// but it can be called concurrently by different workers.
+//
+// This is a non-blocking unordered function that processes items concurrently using n goroutines.
+//
+// See the package documentation for more information on non-blocking unordered functions and error handling.
+func MapControlled[A, B, C any](in <-chan Try[A], n int, ctrl *Control[C], onCmd func(C), f func(A) (B, error)) <-chan Try[B] {
+	if in == nil {
+		return nil
+	}
+
+	core.Claim(in)
+	launch := core.LauncherFor(in)
+
+	out := make(chan Try[B])
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		sub := ctrl.subscribe()
+
+		wg.Add(1)
+		launch(func() {
+			defer wg.Done()
+			defer ctrl.unsubscribe(sub)
+
+			handle := func(msg controlMsg[C]) {
+				defer msg.Done.Done()
+				onCmd(msg.Cmd)
+			}
+
+			for {
+				// commands have priority over items
+				select {
+				case msg := <-sub.cmds:
+					handle(msg)
+					continue
+				default:
+				}
+
+				select {
+				case msg := <-sub.cmds:
+					handle(msg)
+
+				case a, ok := <-in:
+					if !ok {
+						return
+					}
+
+					if a.Error != nil {
+						out <- Try[B]{Error: a.Error}
+						continue
+					}
+
+					b, err := f(a.Value)
+					if err != nil {
+						out <- Try[B]{Error: err}
+						continue
+					}
+
+					out <- Try[B]{Value: b}
+				}
+			}
+		})
+	}
+
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	return out
+}
@@ -0,0 +1,93 @@
+package rill
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/destel/rill/internal/th"
+)
+
+func TestMapControlled(t *testing.T) {
+	t.Run("nil", func(t *testing.T) {
+		out := MapControlled(nil, 3, NewControl[string](), func(string) {}, func(x int) (int, error) {
+			return x, nil
+		})
+		th.ExpectValue(t, out, nil)
+	})
+
+	t.Run("correctness", func(t *testing.T) {
+		in := FromChan(th.FromRange(0, 20), nil)
+		in = replaceWithError(in, 15, fmt.Errorf("err15"))
+
+		out := MapControlled(in, 3, NewControl[string](), func(string) {}, func(x int) (int, error) {
+			if x == 5 {
+				return 0, fmt.Errorf("err05")
+			}
+			return 2 * x, nil
+		})
+
+		outSlice, errs := toSliceAndErrors(out)
+
+		expectedSlice := make([]int, 0, 20)
+		for i := 0; i < 20; i++ {
+			if i == 5 || i == 15 {
+				continue
+			}
+			expectedSlice = append(expectedSlice, 2*i)
+		}
+
+		th.Sort(outSlice)
+		th.Sort(errs)
+		th.ExpectSlice(t, outSlice, expectedSlice)
+		th.ExpectSlice(t, errs, []string{"err05", "err15"})
+	})
+
+	t.Run("commands", func(t *testing.T) {
+		ctrl := NewControl[int]()
+		in := make(chan Try[int])
+
+		var mu sync.Mutex
+		var factor int64 = 1
+		var received atomic.Int64
+
+		out := MapControlled(in, 4, ctrl, func(f int) {
+			received.Add(1)
+			mu.Lock()
+			factor = int64(f)
+			mu.Unlock()
+		}, func(x int) (int, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			return x * int(factor), nil
+		})
+
+		in <- Try[int]{Value: 1}
+		th.ExpectValue(t, <-out, Try[int]{Value: 1})
+
+		ctrl.Send(10) // blocks until all 4 workers have handled the command
+		th.ExpectValue(t, received.Load(), int64(4))
+
+		in <- Try[int]{Value: 2}
+		th.ExpectValue(t, <-out, Try[int]{Value: 20})
+
+		close(in)
+		th.ExpectValue(t, len(th.ToSlice(out)), 0)
+	})
+
+	t.Run("send to finished stage", func(t *testing.T) {
+		ctrl := NewControl[int]()
+		out := MapControlled(FromSlice([]int{1, 2, 3}, nil), 3, ctrl, func(int) {}, func(x int) (int, error) {
+			return x, nil
+		})
+
+		_, err := ToSlice(out)
+		th.ExpectNoError(t, err)
+
+		th.ExpectNotHang(t, 1*time.Second, func() {
+			ctrl.Send(1)
+		})
+	})
+}
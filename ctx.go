@@ -0,0 +1,219 @@
+package rill
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/destel/rill/internal/core"
+)
+
+// MapCtx is similar to [Map], but passes a context to the function f. This removes the need to thread the context
+// through closures manually. The context passed to f is derived from ctx, and is canceled when the stage finishes,
+// or when its output stream starts to be drained, for example after an early return of a downstream blocking function.
+//
+// Once ctx is done, f is no longer called for the remaining items. Instead, a single ctx.Err() error is sent
+// to the output stream, and the rest of the input stream is discarded. Errors already present in the input stream
+// are still forwarded.
+//
+// This is a non-blocking unordered function that processes items concurrently using n goroutines.
+// An ordered version of this function, [OrderedMapCtx], is also available.
+//
+// See the package documentation for more information on non-blocking unordered functions and error handling.
+func MapCtx[A, B any](ctx context.Context, in <-chan Try[A], n int, f func(context.Context, A) (B, error)) <-chan Try[B] {
+	return filterMapCtx(ctx, in, n, false, func(ctx context.Context, a A) (B, bool, error) {
+		b, err := f(ctx, a)
+		return b, true, err
+	})
+}
+
+// OrderedMapCtx is the ordered version of [MapCtx].
+func OrderedMapCtx[A, B any](ctx context.Context, in <-chan Try[A], n int, f func(context.Context, A) (B, error)) <-chan Try[B] {
+	return filterMapCtx(ctx, in, n, true, func(ctx context.Context, a A) (B, bool, error) {
+		b, err := f(ctx, a)
+		return b, true, err
+	})
+}
+
+// FilterCtx is similar to [Filter], but passes a context to the function f.
+// The context is handled the same way as in [MapCtx].
+//
+// This is a non-blocking unordered function that processes items concurrently using n goroutines.
+// An ordered version of this function, [OrderedFilterCtx], is also available.
+//
+// See the package documentation for more information on non-blocking unordered functions and error handling.
+func FilterCtx[A any](ctx context.Context, in <-chan Try[A], n int, f func(context.Context, A) (bool, error)) <-chan Try[A] {
+	return filterMapCtx(ctx, in, n, false, func(ctx context.Context, a A) (A, bool, error) {
+		keep, err := f(ctx, a)
+		return a, keep, err
+	})
+}
+
+// OrderedFilterCtx is the ordered version of [FilterCtx].
+func OrderedFilterCtx[A any](ctx context.Context, in <-chan Try[A], n int, f func(context.Context, A) (bool, error)) <-chan Try[A] {
+	return filterMapCtx(ctx, in, n, true, func(ctx context.Context, a A) (A, bool, error) {
+		keep, err := f(ctx, a)
+		return a, keep, err
+	})
+}
+
+// ForEachCtx is similar to [ForEach], but passes a context to the function f. The context passed to f is derived from ctx,
+// and is canceled when ForEachCtx returns, so work started by f is stopped on early return.
+// Once ctx is done, f is no longer called, and ForEachCtx returns ctx.Err() as soon as the calls of f that are already
+// in progress return, even if the input stream has no new items. In this case the rest of the input stream is drained in the background.
+// No calls of f are in progress after ForEachCtx returns.
+//
+// This is a blocking unordered function that processes items concurrently using n goroutines.
+// When n = 1, processing becomes sequential, making the function ordered and similar to a regular for-range loop.
+//
+// See the package documentation for more information on blocking unordered functions and error handling.
+func ForEachCtx[A any](ctx context.Context, in <-chan Try[A], n int, f func(context.Context, A) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Calls of f hold the read lock. Taking the write lock after ctx is canceled waits for the calls in progress,
+	// and all later ones see the canceled ctx and don't call f.
+	var running sync.RWMutex
+
+	res := make(chan error, 1)
+	go func() {
+		res <- ForEach(in, n, func(a A) error {
+			running.RLock()
+			defer running.RUnlock()
+
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			return f(ctx, a)
+		})
+	}()
+
+	var err error
+	select {
+	case err = <-res:
+	case <-ctx.Done():
+		DrainNB(in)
+		err = ctx.Err()
+	}
+
+	cancel()
+	running.Lock()
+	defer running.Unlock()
+
+	return err
+}
+
+// WithContext bounds the input stream by the context. Items are forwarded as is until ctx is done.
+// After that, a single ctx.Err() error is sent to the output stream, the output stream is closed,
+// and the rest of the input stream is drained in the background. This gives a standard way to stop the whole pipeline
+// on a deadline or cancellation, without making each stage context-aware:
+//
+//	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+//	defer cancel()
+//
+//	users := rill.Map(rill.WithContext(ctx, ids), 10, fetchUser)
+//	err := rill.ForEach(users, 1, saveUser) // returns context.DeadlineExceeded on timeout
+//
+// This is a non-blocking ordered function that processes items sequentially.
+//
+// See the package documentation for more information on non-blocking ordered functions and error handling.
+func WithContext[A any](ctx context.Context, in <-chan Try[A]) <-chan Try[A] {
+	if in == nil {
+		return nil
+	}
+
+	out := make(chan Try[A])
+
+	go func() {
+		defer close(out)
+
+		for {
+			// check the context first, so that cancellation wins over ready items
+			if err := ctx.Err(); err != nil {
+				DrainNB(in)
+				out <- Try[A]{Error: err}
+				return
+			}
+
+			select {
+			case <-ctx.Done():
+			case a, ok := <-in:
+				if !ok {
+					return
+				}
+				out <- a
+			}
+		}
+	}()
+
+	return out
+}
+
+func filterMapCtx[A, B any](ctx context.Context, in <-chan Try[A], n int, ordered bool, f func(context.Context, A) (B, bool, error)) <-chan Try[B] {
+	if in == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	out := make(chan Try[B])
+	stop, release := core.StopOnDrain((<-chan Try[B])(out))
+	discard := core.DrainSink(in)
+
+	var ctxErrSent atomic.Bool
+	process := func(a Try[A]) (Try[B], bool) {
+		if a.Error != nil {
+			return Try[B]{Error: a.Error}, true
+		}
+
+		if err := ctx.Err(); err != nil {
+			// stop doing work, report the cancellation only once
+			if ctxErrSent.CompareAndSwap(false, true) {
+				return Try[B]{Error: err}, true
+			}
+			discard(a)
+			return Try[B]{}, false
+		}
+
+		b, keep, err := f(ctx, a.Value)
+		if err != nil {
+			return Try[B]{Error: err}, true
+		}
+		return Try[B]{Value: b}, keep
+	}
+
+	done := make(chan struct{})
+
+	if ordered {
+		core.OrderedLoop(in, done, n, func(a Try[A], canWrite <-chan struct{}) {
+			b, keep := process(a)
+			<-canWrite
+			if keep {
+				out <- b
+			}
+		})
+	} else {
+		core.Loop(in, done, n, func(a Try[A]) {
+			if b, keep := process(a); keep {
+				out <- b
+			}
+		})
+	}
+
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-done:
+		}
+	}()
+
+	go func() {
+		<-done
+		release()
+		cancel()
+		close(out)
+	}()
+
+	return out
+}
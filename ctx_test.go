@@ -0,0 +1,296 @@
+package rill
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/destel/rill/internal/th"
+)
+
+func universalMapCtx[A, B any](ord bool, ctx context.Context, in <-chan Try[A], n int, f func(context.Context, A) (B, error)) <-chan Try[B] {
+	if ord {
+		return OrderedMapCtx(ctx, in, n, f)
+	}
+	return MapCtx(ctx, in, n, f)
+}
+
+func universalFilterCtx[A any](ord bool, ctx context.Context, in <-chan Try[A], n int, f func(context.Context, A) (bool, error)) <-chan Try[A] {
+	if ord {
+		return OrderedFilterCtx(ctx, in, n, f)
+	}
+	return FilterCtx(ctx, in, n, f)
+}
+
+func TestMapCtx(t *testing.T) {
+	th.TestBothOrderings(t, func(t *testing.T, ord bool) {
+		for _, n := range []int{1, 5} {
+			t.Run(th.Name("nil", n), func(t *testing.T) {
+				out := universalMapCtx(ord, context.Background(), nil, n, func(ctx context.Context, x int) (int, error) { return x, nil })
+				th.ExpectValue(t, out, nil)
+			})
+
+			t.Run(th.Name("correctness", n), func(t *testing.T) {
+				in := FromChan(th.FromRange(0, 20), nil)
+				in = replaceWithError(in, 15, fmt.Errorf("err15"))
+
+				out := universalMapCtx(ord, context.Background(), in, n, func(ctx context.Context, x int) (string, error) {
+					if ctx.Err() != nil {
+						t.Errorf("context must not be canceled")
+					}
+					if x == 5 {
+						return "", fmt.Errorf("err05")
+					}
+					return fmt.Sprintf("%03d", x), nil
+				})
+
+				outSlice, errSlice := toSliceAndErrors(out)
+				if ord {
+					th.ExpectSorted(t, outSlice)
+				}
+				sort.Strings(outSlice)
+				sort.Strings(errSlice)
+
+				th.ExpectValue(t, len(outSlice), 18)
+				th.ExpectSlice(t, errSlice, []string{"err05", "err15"})
+			})
+
+			t.Run(th.Name("parent canceled", n), func(t *testing.T) {
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+
+				in := FromChan(th.FromRange(0, 1000), nil)
+
+				var calls atomic.Int64
+				out := universalMapCtx(ord, ctx, in, n, func(ctx context.Context, x int) (int, error) {
+					if calls.Add(1) == 100 {
+						cancel()
+					}
+					return x, nil
+				})
+
+				outSlice, errSlice := toSliceAndErrors(out)
+				th.ExpectSlice(t, errSlice, []string{context.Canceled.Error()})
+				if calls.Load() > 200 || len(outSlice) > 200 {
+					t.Errorf("work was not stopped: %d calls", calls.Load())
+				}
+				th.ExpectDrainedChan(t, in)
+			})
+
+			t.Run(th.Name("canceled on drain", n), func(t *testing.T) {
+				in := FromChan(th.FromRange(0, 1000), nil)
+
+				var started, canceled atomic.Int64
+				out := universalMapCtx(ord, context.Background(), in, n, func(ctx context.Context, x int) (int, error) {
+					if x == 0 {
+						return x, nil
+					}
+
+					started.Add(1)
+					select {
+					case <-ctx.Done():
+						canceled.Add(1)
+						return 0, ctx.Err()
+					case <-time.After(10 * time.Second):
+						return x, nil
+					}
+				})
+
+				th.ExpectNotHang(t, 5*time.Second, func() {
+					_, _, err := First(out)
+					th.ExpectNoError(t, err)
+					time.Sleep(1 * time.Second)
+				})
+
+				// every in-flight call must be canceled instead of waiting for the timeout
+				if canceled.Load() != started.Load() {
+					t.Errorf("context was not canceled: %d of %d calls canceled", canceled.Load(), started.Load())
+				}
+			})
+		}
+	})
+}
+
+func TestFilterCtx(t *testing.T) {
+	// most logic is covered by the MapCtx tests
+
+	th.TestBothOrderings(t, func(t *testing.T, ord bool) {
+		for _, n := range []int{1, 5} {
+			t.Run(th.Name("correctness", n), func(t *testing.T) {
+				in := FromChan(th.FromRange(0, 20), nil)
+				in = replaceWithError(in, 15, fmt.Errorf("err15"))
+
+				out := universalFilterCtx(ord, context.Background(), in, n, func(ctx context.Context, x int) (bool, error) {
+					return x%2 == 0, nil
+				})
+
+				outSlice, errSlice := toSliceAndErrors(out)
+				if ord {
+					th.ExpectSorted(t, outSlice)
+				}
+				th.Sort(outSlice)
+
+				th.ExpectSlice(t, outSlice, []int{0, 2, 4, 6, 8, 10, 12, 14, 16, 18})
+				th.ExpectSlice(t, errSlice, []string{"err15"})
+			})
+		}
+	})
+}
+
+func TestForEachCtx(t *testing.T) {
+	for _, n := range []int{1, 5} {
+		t.Run(th.Name("correctness", n), func(t *testing.T) {
+			var sum atomic.Int64
+			err := ForEachCtx(context.Background(), FromChan(th.FromRange(0, 10), nil), n, func(ctx context.Context, x int) error {
+				sum.Add(int64(x))
+				return nil
+			})
+
+			th.ExpectNoError(t, err)
+			th.ExpectValue(t, sum.Load(), int64(45))
+		})
+
+		t.Run(th.Name("parent canceled", n), func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			in := FromChan(th.FromRange(0, 1000), nil)
+
+			var calls atomic.Int64
+			err := ForEachCtx(ctx, in, n, func(ctx context.Context, x int) error {
+				if calls.Add(1) == 100 {
+					cancel()
+				}
+				return nil
+			})
+
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("expected context.Canceled, got %v", err)
+			}
+
+			time.Sleep(1 * time.Second)
+			th.ExpectDrainedChan(t, in)
+			if calls.Load() > 200 {
+				t.Errorf("work was not stopped: %d calls", calls.Load())
+			}
+		})
+
+		t.Run(th.Name("idle input", n), func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			src := make(chan Try[int])
+			defer close(src)
+
+			time.AfterFunc(100*time.Millisecond, cancel)
+
+			th.ExpectNotHang(t, 10*time.Second, func() {
+				err := ForEachCtx(ctx, src, n, func(ctx context.Context, x int) error {
+					return nil
+				})
+				th.ExpectValue(t, err, context.Canceled)
+			})
+
+			// the input is drained
+			th.ExpectNotHang(t, 10*time.Second, func() {
+				src <- Try[int]{Value: 1}
+				src <- Try[int]{Value: 2}
+			})
+		})
+
+		t.Run(th.Name("waits for running calls", n), func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var running atomic.Int64
+			err := ForEachCtx(ctx, FromChan(th.FromRange(0, 1000), nil), n, func(_ context.Context, x int) error {
+				running.Add(1)
+				defer running.Add(-1)
+
+				if x == 10 {
+					cancel()
+				}
+				// keep running for a while after the cancellation, ignoring the context
+				time.Sleep(10 * time.Millisecond)
+				return nil
+			})
+
+			th.ExpectValue(t, err, context.Canceled)
+			th.ExpectValue(t, running.Load(), int64(0))
+		})
+
+		t.Run(th.Name("canceled on return", n), func(t *testing.T) {
+			var inner context.Context
+			err := ForEachCtx(context.Background(), FromChan(th.FromRange(0, 10), nil), 1, func(ctx context.Context, x int) error {
+				inner = ctx
+				return fmt.Errorf("err")
+			})
+
+			th.ExpectError(t, err, "err")
+			th.ExpectValue(t, inner.Err(), context.Canceled)
+		})
+	}
+}
+
+func TestWithContext(t *testing.T) {
+	t.Run("nil", func(t *testing.T) {
+		th.ExpectValue(t, WithContext[int](context.Background(), nil), nil)
+	})
+
+	t.Run("not canceled", func(t *testing.T) {
+		in := FromChan(th.FromRange(0, 20), nil)
+		in = replaceWithError(in, 5, fmt.Errorf("err05"))
+
+		outSlice, errSlice := toSliceAndErrors(WithContext(context.Background(), in))
+		th.ExpectValue(t, len(outSlice), 19)
+		th.ExpectSorted(t, outSlice)
+		th.ExpectSlice(t, errSlice, []string{"err05"})
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		in := FromChan(th.FromRange(0, 1000), nil)
+		out := WithContext(ctx, in)
+
+		var values []int
+		var errs []error
+		for x := range out {
+			if x.Error != nil {
+				errs = append(errs, x.Error)
+				continue
+			}
+			values = append(values, x.Value)
+			if x.Value == 100 {
+				cancel()
+			}
+		}
+
+		th.ExpectSlice(t, errs, []error{context.Canceled})
+		// at most one item may already be in flight when the context is canceled
+		if len(values) < 101 || len(values) > 102 {
+			t.Errorf("expected 101 or 102 values, got %d", len(values))
+		}
+
+		time.Sleep(1 * time.Second)
+		th.ExpectDrainedChan(t, in)
+	})
+
+	t.Run("deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		defer cancel()
+
+		in := make(chan Try[int]) // never sends anything
+		defer close(in)
+
+		th.ExpectNotHang(t, 5*time.Second, func() {
+			_, errSlice := toSliceAndErrors(WithContext(ctx, in))
+			th.ExpectSlice(t, errSlice, []string{context.DeadlineExceeded.Error()})
+		})
+	})
+}
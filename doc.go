@@ -53,6 +53,9 @@
 //			// process res.Value
 //	 }
 //
+// The same applies to functions with multiple outputs, such as [Split2] and [Partition]. All outputs must be consumed,
+// so if some of them are not needed or are abandoned midway, call [DrainNB] on them to let the rest of the pipeline continue.
+//
 // # Unordered functions
 //
 // Functions such as [Map], [Filter], and [FlatMap] write items to the output stream as soon as they become available.
@@ -0,0 +1,384 @@
+package rill
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/destel/rill/internal/core"
+)
+
+// PipelineError is an error annotated with the context of the pipeline stage it was first observed at.
+// Such errors are produced by the [Stage] function, by stages labeled with [WithStageName],
+// and by blocking functions, such as [ForEach] or [ToSlice], labeled with [WithStageName].
+// Errors are returned as is by the blocking functions, so a single error value carries enough information to be logged or reported.
+// Use errors.As to extract it.
+type PipelineError struct {
+	Stage     string        // name of the stage
+	Processed int64         // number of values the stage had processed before the error
+	Skipped   int64         // number of values a blocking function discarded without processing because of the error, by the time it returned
+	Elapsed   time.Duration // time since the stage started processing
+	Err       error         // the original error
+}
+
+func (e *PipelineError) Error() string {
+	return fmt.Sprintf("stage %s: %v (processed %d, skipped %d, after %v)", e.Stage, e.Err, e.Processed, e.Skipped, e.Elapsed)
+}
+
+func (e *PipelineError) Unwrap() error {
+	return e.Err
+}
+
+// Stage labels the point of the pipeline it's inserted at with a name.
+// It forwards all items as is, but wraps errors into [PipelineError] with the stage name, the number of values
+// that passed through the stage before the error, and the time since the first item has arrived.
+// Errors that are already wrapped by an upstream Stage are forwarded unchanged, so the label of the first stage
+// that observed the error is preserved.
+//
+//	users := rill.Stage(rill.Map(ids, 10, fetchUser), "fetch users")
+//	err := rill.ForEach(users, 1, saveUser)
+//
+//	var pe *rill.PipelineError
+//	if errors.As(err, &pe) {
+//		log.Printf("stage %q failed after %d items: %v", pe.Stage, pe.Processed, pe.Err)
+//	}
+//
+// Blocking functions can be labeled the same way with [WithStageName], to report errors returned by their own functions.
+//
+// This is a non-blocking ordered function that processes items sequentially.
+//
+// See the package documentation for more information on non-blocking ordered functions and error handling.
+func Stage[A any](in <-chan Try[A], name string) <-chan Try[A] {
+	if in == nil {
+		return nil
+	}
+
+	label := stageLabeler[A](name)
+	return core.FilterMap(in, 1, func(a Try[A]) (Try[A], bool) {
+		return label(a), true
+	})
+}
+
+// stageLabeler returns a function that implements Stage for a single item. It must be called sequentially.
+func stageLabeler[A any](name string) func(Try[A]) Try[A] {
+	var start time.Time
+	var processed int64
+
+	return func(a Try[A]) Try[A] {
+		if start.IsZero() {
+			start = time.Now()
+		}
+
+		if a.Error == nil {
+			processed++
+			return a
+		}
+
+		var pe *PipelineError
+		if errors.As(a.Error, &pe) {
+			return a
+		}
+
+		return Try[A]{Error: &PipelineError{
+			Stage:     name,
+			Processed: processed,
+			Elapsed:   time.Since(start),
+			Err:       a.Error,
+		}}
+	}
+}
+
+// stageReport counts values processed by a blocking function labeled with WithStageName,
+// to wrap the returned error into PipelineError. A nil report is valid and does nothing.
+type stageReport struct {
+	name      string
+	start     time.Time
+	processed atomic.Int64
+	skipped   atomic.Int64
+}
+
+func newStageReport(c stageConfig) *stageReport {
+	if c.name == "" {
+		return nil
+	}
+	return &stageReport{name: c.name, start: time.Now()}
+}
+
+func (r *stageReport) addProcessed() {
+	if r != nil {
+		r.processed.Add(1)
+	}
+}
+
+func (r *stageReport) addSkipped() {
+	if r != nil {
+		r.skipped.Add(1)
+	}
+}
+
+// wrap wraps a non-nil error into PipelineError. Errors already wrapped by an upstream stage are returned as is.
+func (r *stageReport) wrap(err error) error {
+	if r == nil || err == nil {
+		return err
+	}
+
+	var pe *PipelineError
+	if errors.As(err, &pe) {
+		return err
+	}
+
+	return &PipelineError{
+		Stage:     r.name,
+		Processed: r.processed.Load(),
+		Skipped:   r.skipped.Load(),
+		Elapsed:   time.Since(r.start),
+		Err:       err,
+	}
+}
+
+// ItemError is an error annotated with the input item that caused it. Such errors are produced by [MapE],
+// and can be extracted using errors.As to route failed items to retries or dead-letter queues.
+// The error message is the same as the one of the original error.
+//
+//	var ie *rill.ItemError[int]
+//	if errors.As(err, &ie) {
+//		log.Printf("user %d failed: %v", ie.Item, ie.Err)
+//	}
+type ItemError[A any] struct {
+	Item A     // the input item
+	Err  error // the original error
+}
+
+func (e *ItemError[A]) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ItemError[A]) Unwrap() error {
+	return e.Err
+}
+
+// MapE is similar to [Map], but every error returned by the function f is wrapped into [ItemError] with the item that caused it.
+// Errors that are already present in the input stream are forwarded as is.
+//
+// This is a non-blocking unordered function that processes items concurrently using n goroutines.
+// An ordered version of this function, [OrderedMapE], is also available.
+//
+// See the package documentation for more information on non-blocking unordered functions and error handling.
+func MapE[A, B any](in <-chan Try[A], n int, f func(A) (B, error)) <-chan Try[B] {
+	return Map(in, n, withItemError(f))
+}
+
+// OrderedMapE is the ordered version of [MapE].
+func OrderedMapE[A, B any](in <-chan Try[A], n int, f func(A) (B, error)) <-chan Try[B] {
+	return OrderedMap(in, n, withItemError(f))
+}
+
+func withItemError[A, B any](f func(A) (B, error)) func(A) (B, error) {
+	return func(a A) (B, error) {
+		b, err := f(a)
+		if err != nil {
+			return b, &ItemError[A]{Item: a, Err: err}
+		}
+		return b, nil
+	}
+}
+
+// WithDeadLetter diverts all errors from the input stream into the dlq channel, instead of propagating them downstream.
+// This way failures can be persisted for later replay, while the main pipeline keeps flowing.
+// Combined with [MapE], items sent to dlq also carry the input values that caused the errors:
+//
+//	dlq := make(chan rill.Try[User])
+//	go func() {
+//		for x := range dlq {
+//			var ie *rill.ItemError[int]
+//			if errors.As(x.Error, &ie) {
+//				requeue(ie.Item)
+//			}
+//		}
+//	}()
+//
+//	users := rill.WithDeadLetter(rill.MapE(ids, 10, fetchUser), dlq)
+//
+// Sending to dlq blocks, so it must be consumed concurrently with the returned stream.
+// The dlq channel is not closed by WithDeadLetter, so it can be shared among several stages.
+//
+// This is a non-blocking ordered function that processes items sequentially.
+//
+// See the package documentation for more information on non-blocking ordered functions and error handling.
+func WithDeadLetter[A any](in <-chan Try[A], dlq chan<- Try[A]) <-chan Try[A] {
+	return core.FilterMap(in, 1, func(a Try[A]) (Try[A], bool) {
+		if a.Error != nil {
+			dlq <- a
+			return a, false
+		}
+		return a, true
+	})
+}
+
+// PanicError is an error produced by [Recover], [RecoverErr] and [RecoverFilterMap] from a panic in a user-provided function.
+// It holds the value passed to panic and the stack trace of the goroutine at the moment of the panic.
+type PanicError struct {
+	Value any    // the value passed to panic
+	Stack []byte // stack trace, as returned by debug.Stack
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("rill: panic: %v", e.Value)
+}
+
+// Unwrap returns the panic value if it's an error, or nil otherwise.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// Recover wraps the function f, converting panics into errors of type [PanicError].
+// By default, a panic in a user-provided function crashes the whole process. With Recover, it's propagated through
+// the regular error path instead, like any other error returned by f:
+//
+//	users := rill.Map(ids, 10, rill.Recover(func(id int) (*User, error) {
+//		return parseUser(id) // may panic
+//	}))
+//
+// The wrapped function can be used with [Map], [Filter] and all other functions with a compatible signature.
+// For functions that return only an error, such as the ones used by [ForEach], see [RecoverErr].
+// For functions used by [FilterMap], see [RecoverFilterMap].
+func Recover[A, B any](f func(A) (B, error)) func(A) (B, error) {
+	return func(a A) (b B, err error) {
+		defer recoverInto(&err)
+		return f(a)
+	}
+}
+
+// RecoverErr is similar to [Recover], but wraps functions that return only an error, such as the ones used by [ForEach].
+func RecoverErr[A any](f func(A) error) func(A) error {
+	return func(a A) (err error) {
+		defer recoverInto(&err)
+		return f(a)
+	}
+}
+
+// RecoverFilterMap is similar to [Recover], but wraps functions used by [FilterMap].
+// When f panics, the wrapped function returns a [PanicError] and false.
+func RecoverFilterMap[A, B any](f func(A) (B, bool, error)) func(A) (B, bool, error) {
+	return func(a A) (b B, keep bool, err error) {
+		defer recoverInto(&err)
+		return f(a)
+	}
+}
+
+func recoverInto(err *error) {
+	if r := recover(); r != nil {
+		*err = &PanicError{Value: r, Stack: debug.Stack()}
+	}
+}
+
+// SuppressedErrors is a summary error emitted by [SampleErrors] in place of the errors it has dropped.
+type SuppressedErrors struct {
+	Class string // class of the dropped errors, as returned by the classifier
+	Count int64  // number of dropped errors
+	Last  error  // the last dropped error
+}
+
+func (e *SuppressedErrors) Error() string {
+	return fmt.Sprintf("rill: %d errors of class %q suppressed, last: %v", e.Count, e.Class, e.Last)
+}
+
+// SampleErrors limits the rate of errors in the stream, so that a flood of identical failures doesn't turn into
+// millions of log lines downstream. Errors are grouped into classes by the classify function, and within each time window
+// only the first k errors of each class are passed through. The rest are dropped and counted.
+// At the end of each window, and when the input stream ends, a single [SuppressedErrors] is emitted for every class
+// that had dropped errors. If classify is nil, all errors belong to the same class.
+//
+//	users := rill.SampleErrors(rill.Map(ids, 10, fetchUser), 5, time.Minute, func(err error) string {
+//		var netErr net.Error
+//		if errors.As(err, &netErr) {
+//			return "network"
+//		}
+//		return "other"
+//	})
+//
+// Values are always forwarded as is. Setting the window to zero or a negative value makes it infinite: only the first k
+// errors of each class are passed through, and a single SuppressedErrors per class is emitted when the input stream ends.
+//
+// This is a non-blocking ordered function that processes items sequentially.
+//
+// See the package documentation for more information on non-blocking ordered functions and error handling.
+func SampleErrors[A any](in <-chan Try[A], k int, window time.Duration, classify func(error) string) <-chan Try[A] {
+	if in == nil {
+		return nil
+	}
+
+	if classify == nil {
+		classify = func(error) string { return "" }
+	}
+
+	out := make(chan Try[A])
+
+	go func() {
+		defer close(out)
+
+		var tick <-chan time.Time
+		if window > 0 {
+			ticker := time.NewTicker(window)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+
+		passed := make(map[string]int)
+		suppressed := make(map[string]*SuppressedErrors)
+
+		flush := func() {
+			classes := make([]string, 0, len(suppressed))
+			for class := range suppressed {
+				classes = append(classes, class)
+			}
+			sort.Strings(classes)
+
+			for _, class := range classes {
+				out <- Try[A]{Error: suppressed[class]}
+			}
+
+			passed = make(map[string]int)
+			suppressed = make(map[string]*SuppressedErrors)
+		}
+
+		for {
+			select {
+			case a, ok := <-in:
+				if !ok {
+					flush()
+					return
+				}
+
+				if a.Error == nil {
+					out <- a
+					continue
+				}
+
+				class := classify(a.Error)
+				if passed[class] < k {
+					passed[class]++
+					out <- a
+					continue
+				}
+
+				se := suppressed[class]
+				if se == nil {
+					se = &SuppressedErrors{Class: class}
+					suppressed[class] = se
+				}
+				se.Count++
+				se.Last = a.Error
+
+			case <-tick:
+				flush()
+			}
+		}
+	}()
+
+	return out
+}
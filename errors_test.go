@@ -0,0 +1,415 @@
+package rill
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/destel/rill/internal/th"
+)
+
+func TestStage(t *testing.T) {
+	t.Run("nil", func(t *testing.T) {
+		th.ExpectValue(t, Stage[int](nil, "test"), nil)
+	})
+
+	t.Run("correctness", func(t *testing.T) {
+		in := FromChan(th.FromRange(0, 20), nil)
+		in = replaceWithError(in, 5, fmt.Errorf("err05"))
+		in = Stage(in, "source")
+
+		in = OrderedMap(in, 1, func(x int) (int, error) {
+			if x == 10 {
+				return 0, fmt.Errorf("err10")
+			}
+			return x, nil
+		})
+		in = Stage(in, "map")
+
+		var pes []*PipelineError
+		for x := range in {
+			if x.Error == nil {
+				continue
+			}
+
+			var pe *PipelineError
+			if !errors.As(x.Error, &pe) {
+				t.Fatalf("expected PipelineError, got %v", x.Error)
+			}
+			pes = append(pes, pe)
+		}
+
+		th.ExpectValue(t, len(pes), 2)
+
+		th.ExpectValue(t, pes[0].Stage, "source")
+		th.ExpectValue(t, pes[0].Processed, int64(5))
+		th.ExpectValue(t, pes[0].Skipped, int64(0))
+		th.ExpectError(t, pes[0].Err, "err05")
+		th.ExpectError(t, errors.Unwrap(pes[0]), "err05")
+
+		th.ExpectValue(t, pes[1].Stage, "map")
+		th.ExpectValue(t, pes[1].Processed, int64(9))
+		th.ExpectValue(t, pes[1].Skipped, int64(0))
+		th.ExpectError(t, pes[1].Err, "err10")
+	})
+
+	t.Run("terminal function", func(t *testing.T) {
+		in := FromChan(th.FromRange(0, 20), nil)
+		in = replaceWithError(in, 5, fmt.Errorf("err05"))
+
+		err := ForEach(Stage(in, "source"), 1, func(x int) error { return nil })
+
+		var pe *PipelineError
+		if !errors.As(err, &pe) {
+			t.Fatalf("expected PipelineError, got %v", err)
+		}
+		th.ExpectValue(t, pe.Stage, "source")
+		if !strings.HasPrefix(pe.Error(), "stage source: err05 (processed 5, skipped 0, after ") {
+			t.Errorf("unexpected error message: %s", pe.Error())
+		}
+	})
+
+	t.Run("elapsed", func(t *testing.T) {
+		in := make(chan Try[int])
+		out := Stage(in, "source")
+
+		// the time before the first item must not be counted
+		time.Sleep(500 * time.Millisecond)
+
+		go func() {
+			defer close(in)
+			in <- Try[int]{Value: 1}
+			in <- Try[int]{Error: fmt.Errorf("err")}
+		}()
+
+		_, err := ToSlice(out)
+
+		var pe *PipelineError
+		if !errors.As(err, &pe) {
+			t.Fatalf("expected PipelineError, got %v", err)
+		}
+		if pe.Elapsed >= 500*time.Millisecond {
+			t.Errorf("expected elapsed time to be measured from the first item, got %v", pe.Elapsed)
+		}
+	})
+}
+
+func TestWithStageNameBlocking(t *testing.T) {
+	expectPipelineError := func(t *testing.T, err error, stage string, processed int64, cause string) *PipelineError {
+		t.Helper()
+
+		var pe *PipelineError
+		if !errors.As(err, &pe) {
+			t.Fatalf("expected PipelineError, got %v", err)
+		}
+		th.ExpectValue(t, pe.Stage, stage)
+		th.ExpectValue(t, pe.Processed, processed)
+		th.ExpectError(t, pe.Err, cause)
+		return pe
+	}
+
+	t.Run("ForEach", func(t *testing.T) {
+		in := FromChan(th.FromRange(0, 20), nil)
+
+		err := ForEach(in, 1, func(x int) error {
+			if x == 10 {
+				return fmt.Errorf("err10")
+			}
+			return nil
+		}, WithStageName("save"))
+
+		expectPipelineError(t, err, "save", 10, "err10")
+	})
+
+	t.Run("ForEach recover", func(t *testing.T) {
+		in := FromChan(th.FromRange(0, 20), nil)
+
+		err := ForEach(in, 1, func(x int) error {
+			if x == 3 {
+				panic("boom")
+			}
+			return nil
+		}, WithStageName("save"), WithRecover())
+
+		pe := expectPipelineError(t, err, "save", 3, "rill: panic: boom")
+		var panicErr *PanicError
+		if !errors.As(pe, &panicErr) {
+			t.Errorf("expected PanicError, got %v", pe.Err)
+		}
+	})
+
+	t.Run("ToSlice", func(t *testing.T) {
+		in := FromChan(th.FromRange(0, 20), nil)
+		in = replaceWithError(in, 5, fmt.Errorf("err05"))
+
+		outSlice, err := ToSlice(in, WithStageName("collect"))
+		th.ExpectSlice(t, outSlice, []int{0, 1, 2, 3, 4})
+		expectPipelineError(t, err, "collect", 5, "err05")
+	})
+
+	t.Run("Err", func(t *testing.T) {
+		in := FromChan(th.FromRange(0, 20), nil)
+		in = replaceWithError(in, 7, fmt.Errorf("err07"))
+
+		err := Err(in, WithStageName("check"))
+		expectPipelineError(t, err, "check", 7, "err07")
+	})
+
+	t.Run("no error", func(t *testing.T) {
+		err := ForEach(FromChan(th.FromRange(0, 20), nil), 1, func(x int) error { return nil }, WithStageName("save"))
+		th.ExpectNoError(t, err)
+	})
+
+	t.Run("upstream stage", func(t *testing.T) {
+		in := FromChan(th.FromRange(0, 20), nil)
+		in = replaceWithError(in, 5, fmt.Errorf("err05"))
+
+		_, err := ToSlice(Stage(in, "source"), WithStageName("collect"))
+		expectPipelineError(t, err, "source", 5, "err05")
+	})
+}
+
+func universalMapE[A, B any](ord bool, in <-chan Try[A], n int, f func(A) (B, error)) <-chan Try[B] {
+	if ord {
+		return OrderedMapE(in, n, f)
+	}
+	return MapE(in, n, f)
+}
+
+func TestMapE(t *testing.T) {
+	th.TestBothOrderings(t, func(t *testing.T, ord bool) {
+		for _, n := range []int{1, 5} {
+			t.Run(th.Name("nil", n), func(t *testing.T) {
+				out := universalMapE(ord, nil, n, func(x int) (int, error) { return x, nil })
+				th.ExpectValue(t, out, nil)
+			})
+
+			t.Run(th.Name("correctness", n), func(t *testing.T) {
+				in := FromChan(th.FromRange(0, 20), nil)
+				in = replaceWithError(in, 15, fmt.Errorf("err15"))
+
+				errSentinel := errors.New("sentinel")
+
+				out := universalMapE(ord, in, n, func(x int) (string, error) {
+					if x%5 == 2 {
+						return "", fmt.Errorf("bad %d: %w", x, errSentinel)
+					}
+					return fmt.Sprint(x), nil
+				})
+
+				var items []int
+				var errs []string
+				for x := range out {
+					if x.Error == nil {
+						continue
+					}
+
+					errs = append(errs, x.Error.Error())
+
+					var ie *ItemError[int]
+					if !errors.As(x.Error, &ie) {
+						th.ExpectError(t, x.Error, "err15") // input errors are not wrapped
+						continue
+					}
+
+					if !errors.Is(x.Error, errSentinel) {
+						t.Errorf("expected error to wrap the original one")
+					}
+					items = append(items, ie.Item)
+				}
+
+				th.Sort(items)
+				th.Sort(errs)
+				th.ExpectSlice(t, items, []int{2, 7, 12, 17})
+				th.ExpectSlice(t, errs, []string{"bad 12: sentinel", "bad 17: sentinel", "bad 2: sentinel", "bad 7: sentinel", "err15"})
+			})
+		}
+	})
+}
+
+func TestWithDeadLetter(t *testing.T) {
+	t.Run("nil", func(t *testing.T) {
+		th.ExpectValue(t, WithDeadLetter[int](nil, make(chan Try[int])), nil)
+	})
+
+	t.Run("correctness", func(t *testing.T) {
+		in := FromChan(th.FromRange(0, 20), nil)
+		in = replaceWithError(in, 5, fmt.Errorf("err05"))
+
+		dlq := make(chan Try[string])
+		out := WithDeadLetter(MapE(in, 3, func(x int) (string, error) {
+			if x%10 == 7 {
+				return "", fmt.Errorf("bad")
+			}
+			return fmt.Sprint(x), nil
+		}), dlq)
+
+		var outSlice []string
+		var outErrs []string
+		var failedItems []int
+		var dlqErrs []string
+
+		th.DoConcurrently(
+			func() {
+				outSlice, outErrs = toSliceAndErrors(out)
+				close(dlq)
+			},
+			func() {
+				for x := range dlq {
+					dlqErrs = append(dlqErrs, x.Error.Error())
+
+					var ie *ItemError[int]
+					if errors.As(x.Error, &ie) {
+						failedItems = append(failedItems, ie.Item)
+					}
+				}
+			},
+		)
+
+		th.ExpectValue(t, len(outSlice), 17)
+		th.ExpectValue(t, len(outErrs), 0)
+
+		th.Sort(dlqErrs)
+		th.Sort(failedItems)
+		th.ExpectSlice(t, dlqErrs, []string{"bad", "bad", "err05"})
+		th.ExpectSlice(t, failedItems, []int{7, 17})
+	})
+}
+
+func TestRecover(t *testing.T) {
+	t.Run("values", func(t *testing.T) {
+		in := FromChan(th.FromRange(0, 10), nil)
+		in = replaceWithError(in, 3, fmt.Errorf("err3"))
+
+		out := Map(in, 3, Recover(func(x int) (int, error) {
+			if x == 5 {
+				panic("boom")
+			}
+			if x == 7 {
+				return 0, fmt.Errorf("err7")
+			}
+			return x, nil
+		}))
+
+		outSlice, errs := toSliceAndErrors(out)
+		th.Sort(outSlice)
+		th.Sort(errs)
+
+		th.ExpectSlice(t, outSlice, []int{0, 1, 2, 4, 6, 8, 9})
+		th.ExpectSlice(t, errs, []string{"err3", "err7", "rill: panic: boom"})
+	})
+
+	t.Run("filter map", func(t *testing.T) {
+		in := FromChan(th.FromRange(0, 10), nil)
+
+		out := FilterMap(in, 3, RecoverFilterMap(func(x int) (int, bool, error) {
+			if x == 5 {
+				panic("boom")
+			}
+			return x * 2, x%2 == 1, nil
+		}))
+
+		outSlice, errs := toSliceAndErrors(out)
+		th.Sort(outSlice)
+
+		th.ExpectSlice(t, outSlice, []int{2, 6, 14, 18})
+		th.ExpectSlice(t, errs, []string{"rill: panic: boom"})
+	})
+
+	t.Run("panic error", func(t *testing.T) {
+		errBoom := errors.New("boom")
+
+		err := ForEach(FromChan(th.FromRange(0, 10), nil), 1, RecoverErr(func(x int) error {
+			if x == 5 {
+				panic(errBoom)
+			}
+			return nil
+		}))
+
+		th.ExpectError(t, err, "rill: panic: boom")
+
+		var pe *PanicError
+		if !errors.As(err, &pe) {
+			t.Fatalf("expected PanicError")
+		}
+		if !errors.Is(err, errBoom) {
+			t.Errorf("expected error to wrap the panic value")
+		}
+		if !strings.Contains(string(pe.Stack), "TestRecover") {
+			t.Errorf("expected stack trace to contain the test function")
+		}
+	})
+}
+
+func TestSampleErrors(t *testing.T) {
+	t.Run("nil", func(t *testing.T) {
+		th.ExpectValue(t, SampleErrors[int](nil, 1, 1*time.Second, nil), nil)
+	})
+
+	t.Run("correctness", func(t *testing.T) {
+		in := FromChan(th.FromRange(0, 100), nil)
+		in = OrderedMap(in, 1, func(x int) (int, error) {
+			switch {
+			case x%10 == 1:
+				return 0, fmt.Errorf("odd%d", x)
+			case x%10 == 2:
+				return 0, fmt.Errorf("even%d", x)
+			}
+			return x, nil
+		})
+
+		out := SampleErrors(in, 3, 1*time.Hour, func(err error) string {
+			if strings.HasPrefix(err.Error(), "odd") {
+				return "odd"
+			}
+			return "even"
+		})
+
+		outSlice, errs := toSliceAndErrors(out)
+		th.ExpectValue(t, len(outSlice), 80)
+		th.ExpectSlice(t, errs, []string{
+			"odd1", "even2", "odd11", "even12", "odd21", "even22",
+			`rill: 7 errors of class "even" suppressed, last: even92`,
+			`rill: 7 errors of class "odd" suppressed, last: odd91`,
+		})
+	})
+
+	for _, window := range []time.Duration{0, -1} {
+		t.Run(th.Name("infinite window", window), func(t *testing.T) {
+			in := FromChan(th.FromRange(0, 10), nil)
+			in = OrderedMap(in, 1, func(x int) (int, error) {
+				return 0, fmt.Errorf("err%d", x)
+			})
+
+			_, errs := toSliceAndErrors(SampleErrors(in, 2, window, nil))
+			th.ExpectSlice(t, errs, []string{
+				"err0", "err1",
+				`rill: 8 errors of class "" suppressed, last: err9`,
+			})
+		})
+	}
+
+	t.Run("windows", func(t *testing.T) {
+		in := make(chan Try[int])
+		go func() {
+			defer close(in)
+			for i := 0; i < 5; i++ {
+				in <- Try[int]{Error: fmt.Errorf("err%d", i)}
+			}
+			time.Sleep(1 * time.Second)
+			for i := 5; i < 10; i++ {
+				in <- Try[int]{Error: fmt.Errorf("err%d", i)}
+			}
+		}()
+
+		_, errs := toSliceAndErrors(SampleErrors(in, 2, 500*time.Millisecond, nil))
+		th.ExpectSlice(t, errs, []string{
+			"err0", "err1",
+			`rill: 3 errors of class "" suppressed, last: err4`,
+			"err5", "err6",
+			`rill: 3 errors of class "" suppressed, last: err9`,
+		})
+	})
+}
@@ -66,3 +66,23 @@ func ExampleToSeq2() {
 		fmt.Printf("%+v\n", val)
 	}
 }
+
+func ExampleToBatchSeq() {
+	// Convert a slice of numbers into a stream
+	numbers := rill.FromSlice([]int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, nil)
+
+	// Transform each number
+	// Concurrency = 3
+	squares := rill.Map(numbers, 3, func(x int) (int, error) {
+		return square(x), nil
+	})
+
+	// Pull batches of up to 4 results, for example to write them in bulk
+	for batch, err := range rill.ToBatchSeq(squares, 4) {
+		if err != nil {
+			fmt.Println("Error:", err)
+			break // cleanup is done regardless of early exit
+		}
+		fmt.Println("Batch:", batch)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/destel/rill"
@@ -716,6 +717,51 @@ func ExampleMerge() {
 	printStream(numbers)
 }
 
+func ExampleMergeTagged() {
+	// Convert slices of numbers into streams
+	odds := rill.FromSlice([]int{1, 3, 5, 7, 9}, nil)
+	evens := rill.FromSlice([]int{2, 4, 6, 8, 10}, nil)
+
+	// Merge the streams, remembering where each number came from
+	numbers := rill.MergeTagged(map[string]<-chan rill.Try[int]{
+		"odds":  odds,
+		"evens": evens,
+	})
+
+	printStream(numbers)
+}
+
+func ExampleNewService() {
+	ctx := context.Background()
+
+	// Create a service that squares numbers in batches of up to 5.
+	// Partial batches are processed after 100ms.
+	// Concurrency = 2
+	s := rill.NewService(2, 5, 100*time.Millisecond, func(numbers []int) ([]int, error) {
+		fmt.Println("Processing batch:", numbers)
+
+		res := make([]int, len(numbers))
+		for i, x := range numbers {
+			res[i] = x * x
+		}
+		return res, nil
+	})
+	defer s.Close()
+
+	// Make concurrent calls. They are automatically grouped into batches.
+	var wg sync.WaitGroup
+	for i := 1; i <= 12; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := s.Call(ctx, i)
+			fmt.Println("Call:", i, res, err)
+		}()
+	}
+	wg.Wait()
+}
+
 func ExampleReduce() {
 	// Convert a slice of numbers into a stream
 	numbers := rill.FromSlice([]int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, nil)
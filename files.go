@@ -0,0 +1,140 @@
+package rill
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/destel/rill/internal/core"
+)
+
+// ToFiles writes each item from the input stream to its own file. The path of each file is determined by the path function,
+// and the content is produced by the write function.
+// Each file is first written to a temporary file in the same directory, and then atomically renamed to the final path.
+// This way readers never observe partially written files. In case of an error, the temporary file is removed.
+// New files are created with mode 0666 (before umask), the same way as with [os.Create]. Existing files are overwritten. In case of an early return, files that are already being written are completed in the background.
+//
+// This is a blocking unordered function that processes items concurrently using n goroutines.
+// When n = 1, processing becomes sequential, making the function ordered.
+//
+// See the package documentation for more information on blocking unordered functions and error handling.
+func ToFiles[A any](in <-chan Try[A], n int, path func(A) (string, error), write func(w io.Writer, a A) error) error {
+	return ForEach(in, n, func(a A) error {
+		p, err := path(a)
+		if err != nil {
+			return err
+		}
+
+		return writeFileAtomic(p, func(w io.Writer) error {
+			return write(w, a)
+		})
+	})
+}
+
+func writeFileAtomic(path string, write func(w io.Writer) error) (err error) {
+	f, err := createTemp(path)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = f.Close()
+			_ = os.Remove(f.Name())
+		}
+	}()
+
+	w := bufio.NewWriter(f)
+	if err := write(w); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
+
+// createTemp creates a new temporary file in the same directory as path.
+// Unlike os.CreateTemp, which makes files accessible only by the owner, it creates the file with mode 0666
+// before umask, the same way os.Create does. The mode is kept after the file is renamed to path.
+func createTemp(path string) (*os.File, error) {
+	prefix := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".tmp-")
+
+	for try := 0; ; try++ {
+		name := prefix + strconv.FormatUint(uint64(rand.Uint32()), 10)
+
+		f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o666)
+		if os.IsExist(err) && try < 10000 {
+			continue
+		}
+		return f, err
+	}
+}
+
+// errStopWalk is used to stop fs.WalkDir early.
+var errStopWalk = errors.New("rill: stop walk")
+
+// WalkDir walks the file tree rooted at root in fsys, and streams the paths of all files in it, in lexical order.
+// Directories are not included in the stream. To walk the local file system, use [os.DirFS]:
+//
+//	files := rill.WalkDir(ctx, os.DirFS("/data"), ".")
+//	err := rill.ForEach(files, 10, processFile)
+//
+// Errors encountered while reading directories are sent to the output stream, and walking continues with the next entry.
+// If ctx is canceled, walking stops, and ctx.Err() is sent to the output stream as the last item.
+// Walking also stops when the output stream is drained or stopped with [Pipeline.StopSources].
+func WalkDir(ctx context.Context, fsys fs.FS, root string) <-chan Try[string] {
+	out := make(chan Try[string])
+	stop, release := core.StopOnDrain((<-chan Try[string])(out))
+
+	go func() {
+		defer close(out)
+		defer release()
+
+		send := func(x Try[string]) error {
+			select {
+			case out <- x:
+				return nil
+			case <-stop:
+				return errStopWalk
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		err := fs.WalkDir(fsys, root, func(path string, d fs.DirEntry, err error) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err != nil {
+				return send(Try[string]{Error: err})
+			}
+			if d.IsDir() {
+				return nil
+			}
+			return send(Try[string]{Value: path})
+		})
+
+		if err != nil && err != errStopWalk {
+			select {
+			case out <- Try[string]{Error: err}:
+			case <-stop:
+			}
+		}
+	}()
+
+	return out
+}
@@ -0,0 +1,186 @@
+package rill
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/destel/rill/internal/th"
+)
+
+func TestToFiles(t *testing.T) {
+	readDir := func(t *testing.T, dir string) map[string]string {
+		entries, err := os.ReadDir(dir)
+		th.ExpectNoError(t, err)
+
+		res := make(map[string]string)
+		for _, e := range entries {
+			data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+			th.ExpectNoError(t, err)
+			res[e.Name()] = string(data)
+		}
+		return res
+	}
+
+	// withWorkers returns the input stream that makes the consuming stage start its workers in wg
+	withWorkers := func(wg *sync.WaitGroup, in <-chan Try[int]) <-chan Try[int] {
+		return WithLauncher(in, func(f func()) {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				f()
+			}()
+		})
+	}
+
+	for _, n := range []int{1, 5} {
+		t.Run(th.Name("correctness", n), func(t *testing.T) {
+			dir := t.TempDir()
+
+			err := ToFiles(FromChan(th.FromRange(0, 20), nil), n, func(x int) (string, error) {
+				return filepath.Join(dir, fmt.Sprintf("%02d.txt", x)), nil
+			}, func(w io.Writer, x int) error {
+				_, err := fmt.Fprintf(w, "item %d", x)
+				return err
+			})
+			th.ExpectNoError(t, err)
+
+			files := readDir(t, dir)
+			th.ExpectValue(t, len(files), 20)
+			for i := 0; i < 20; i++ {
+				th.ExpectValue(t, files[fmt.Sprintf("%02d.txt", i)], fmt.Sprintf("item %d", i))
+			}
+		})
+
+		t.Run(th.Name("file mode", n), func(t *testing.T) {
+			dir := t.TempDir()
+
+			err := ToFiles(FromChan(th.FromRange(0, 3), nil), n, func(x int) (string, error) {
+				return filepath.Join(dir, fmt.Sprintf("%02d.txt", x)), nil
+			}, func(w io.Writer, x int) error {
+				_, err := fmt.Fprintf(w, "item %d", x)
+				return err
+			})
+			th.ExpectNoError(t, err)
+
+			// the mode must be the same as for files created with os.Create
+			ref, err := os.Create(filepath.Join(dir, "ref"))
+			th.ExpectNoError(t, err)
+			th.ExpectNoError(t, ref.Close())
+			refInfo, err := os.Stat(ref.Name())
+			th.ExpectNoError(t, err)
+
+			for i := 0; i < 3; i++ {
+				info, err := os.Stat(filepath.Join(dir, fmt.Sprintf("%02d.txt", i)))
+				th.ExpectNoError(t, err)
+				th.ExpectValue(t, info.Mode(), refInfo.Mode())
+			}
+		})
+
+		t.Run(th.Name("write error", n), func(t *testing.T) {
+			dir := t.TempDir()
+
+			var wg sync.WaitGroup
+			in := withWorkers(&wg, FromChan(th.FromRange(0, 20), nil))
+
+			err := ToFiles(in, n, func(x int) (string, error) {
+				return filepath.Join(dir, fmt.Sprintf("%02d.txt", x)), nil
+			}, func(w io.Writer, x int) error {
+				if x == 10 {
+					_, _ = fmt.Fprint(w, "partial")
+					return fmt.Errorf("err10")
+				}
+				_, err := fmt.Fprintf(w, "item %d", x)
+				return err
+			})
+			th.ExpectError(t, err, "err10")
+
+			// wait for in-flight items to complete
+			wg.Wait()
+
+			// no partial or temporary files are left
+			for name, content := range readDir(t, dir) {
+				if name == "10.txt" || content == "partial" || filepath.Ext(name) != ".txt" {
+					t.Errorf("unexpected file %s", name)
+				}
+			}
+		})
+
+		t.Run(th.Name("path error", n), func(t *testing.T) {
+			dir := t.TempDir()
+
+			var wg sync.WaitGroup
+			in := withWorkers(&wg, FromChan(th.FromRange(0, 20), nil))
+
+			err := ToFiles(in, n, func(x int) (string, error) {
+				if x == 10 {
+					return "", fmt.Errorf("err10")
+				}
+				return filepath.Join(dir, fmt.Sprintf("%02d.txt", x)), nil
+			}, func(w io.Writer, x int) error {
+				return nil
+			})
+			th.ExpectError(t, err, "err10")
+
+			// wait for in-flight writes to finish before the temp dir is removed
+			wg.Wait()
+		})
+	}
+}
+
+func TestWalkDir(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.txt":         {Data: []byte("a")},
+		"dir/b.txt":     {Data: []byte("b")},
+		"dir/sub/c.txt": {Data: []byte("c")},
+		"empty":         {Mode: fs.ModeDir},
+	}
+
+	t.Run("correctness", func(t *testing.T) {
+		outSlice, err := ToSlice(WalkDir(context.Background(), fsys, "."))
+		th.ExpectNoError(t, err)
+		th.ExpectSlice(t, outSlice, []string{"a.txt", "dir/b.txt", "dir/sub/c.txt"})
+	})
+
+	t.Run("subdirectory", func(t *testing.T) {
+		outSlice, err := ToSlice(WalkDir(context.Background(), fsys, "dir/sub"))
+		th.ExpectNoError(t, err)
+		th.ExpectSlice(t, outSlice, []string{"dir/sub/c.txt"})
+	})
+
+	t.Run("missing root", func(t *testing.T) {
+		outSlice, errs := toSliceAndErrors(WalkDir(context.Background(), fsys, "missing"))
+		th.ExpectValue(t, len(outSlice), 0)
+		th.ExpectValue(t, len(errs), 1)
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		out := WalkDir(ctx, fsys, ".")
+		x := <-out
+		th.ExpectValue(t, x.Value, "a.txt")
+
+		cancel()
+
+		_, errs := toSliceAndErrors(out)
+		th.ExpectSlice(t, errs, []string{context.Canceled.Error()})
+	})
+
+	t.Run("os", func(t *testing.T) {
+		dir := t.TempDir()
+		th.ExpectNoError(t, os.MkdirAll(filepath.Join(dir, "x", "y"), 0o755))
+		th.ExpectNoError(t, os.WriteFile(filepath.Join(dir, "x", "y", "z.txt"), []byte("z"), 0o644))
+
+		outSlice, err := ToSlice(WalkDir(context.Background(), os.DirFS(dir), "."))
+		th.ExpectNoError(t, err)
+		th.ExpectSlice(t, outSlice, []string{"x/y/z.txt"})
+	})
+}
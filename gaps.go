@@ -0,0 +1,74 @@
+package rill
+
+import (
+	"math"
+	"time"
+
+	"github.com/destel/rill/internal/core"
+)
+
+// Anomaly describes two consecutive items of a stream that were flagged by [DetectGaps] or [DetectRateAnomalies].
+type Anomaly[A any] struct {
+	Prev A
+	Cur  A
+	Gap  time.Duration // difference between the timestamps of Cur and Prev
+	Rate float64       // absolute rate of change per second, set only by DetectRateAnomalies
+}
+
+// DetectGaps forwards all items of the input stream as is, and calls onGap for every pair of consecutive items,
+// whose timestamps differ by more than maxGap. Timestamps are extracted using the ts function.
+// This is useful for detecting missing data in sensor readings or metrics ingestion.
+// Items that go back in time are not flagged. Errors are forwarded as is and don't break the chain of items.
+//
+// This is a non-blocking ordered function that processes items sequentially.
+//
+// See the package documentation for more information on non-blocking ordered functions and error handling.
+func DetectGaps[A any](in <-chan Try[A], ts func(A) time.Time, maxGap time.Duration, onGap func(Anomaly[A])) <-chan Try[A] {
+	return forEachConsecutive(in, func(prev, cur A) {
+		gap := ts(cur).Sub(ts(prev))
+		if gap > maxGap {
+			onGap(Anomaly[A]{Prev: prev, Cur: cur, Gap: gap})
+		}
+	})
+}
+
+// DetectRateAnomalies forwards all items of the input stream as is, and calls onAnomaly for every pair of consecutive items,
+// whose value changes faster than maxRate per second. Timestamps and values are extracted using the ts and value functions.
+// Pairs of items with the same timestamp, or going back in time, are skipped, since the rate is undefined for them.
+// Errors are forwarded as is and don't break the chain of items.
+//
+// This is a non-blocking ordered function that processes items sequentially.
+//
+// See the package documentation for more information on non-blocking ordered functions and error handling.
+func DetectRateAnomalies[A any](in <-chan Try[A], ts func(A) time.Time, value func(A) float64, maxRate float64, onAnomaly func(Anomaly[A])) <-chan Try[A] {
+	return forEachConsecutive(in, func(prev, cur A) {
+		gap := ts(cur).Sub(ts(prev))
+		if gap <= 0 {
+			return
+		}
+
+		rate := math.Abs(value(cur)-value(prev)) / gap.Seconds()
+		if rate > maxRate {
+			onAnomaly(Anomaly[A]{Prev: prev, Cur: cur, Gap: gap, Rate: rate})
+		}
+	})
+}
+
+// forEachConsecutive forwards all items as is, and calls f for every pair of consecutive values.
+func forEachConsecutive[A any](in <-chan Try[A], f func(prev, cur A)) <-chan Try[A] {
+	var prev A
+	hasPrev := false
+
+	return core.FilterMap(in, 1, func(a Try[A]) (Try[A], bool) {
+		if a.Error != nil {
+			return a, true
+		}
+
+		if hasPrev {
+			f(prev, a.Value)
+		}
+		prev, hasPrev = a.Value, true
+
+		return a, true
+	})
+}
@@ -0,0 +1,79 @@
+package rill
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/destel/rill/internal/th"
+)
+
+type reading struct {
+	At    time.Time
+	Value float64
+}
+
+func makeReadings(offsets []int, values []float64) []reading {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	res := make([]reading, len(offsets))
+	for i := range offsets {
+		res[i] = reading{At: start.Add(time.Duration(offsets[i]) * time.Second), Value: values[i]}
+	}
+	return res
+}
+
+func TestDetectGaps(t *testing.T) {
+	ts := func(r reading) time.Time { return r.At }
+
+	t.Run("nil", func(t *testing.T) {
+		th.ExpectValue(t, DetectGaps(nil, ts, 1*time.Second, func(Anomaly[reading]) {}), nil)
+	})
+
+	t.Run("correctness", func(t *testing.T) {
+		readings := makeReadings([]int{0, 1, 2, 10, 11, 5, 30}, []float64{0, 0, 0, 0, 0, 0, 0})
+		in := FromSlice(readings, nil)
+		in = replaceWithError(in, readings[4], fmt.Errorf("err"))
+
+		var gaps []time.Duration
+		out := DetectGaps(in, ts, 5*time.Second, func(a Anomaly[reading]) {
+			gaps = append(gaps, a.Gap)
+		})
+
+		outSlice, errs := toSliceAndErrors(out)
+		th.ExpectValue(t, len(outSlice), 6)
+		th.ExpectSlice(t, errs, []string{"err"})
+		th.ExpectSlice(t, gaps, []time.Duration{8 * time.Second, 25 * time.Second})
+	})
+}
+
+func TestDetectRateAnomalies(t *testing.T) {
+	ts := func(r reading) time.Time { return r.At }
+	value := func(r reading) float64 { return r.Value }
+
+	t.Run("nil", func(t *testing.T) {
+		th.ExpectValue(t, DetectRateAnomalies(nil, ts, value, 1, func(Anomaly[reading]) {}), nil)
+	})
+
+	t.Run("correctness", func(t *testing.T) {
+		readings := makeReadings([]int{0, 1, 2, 2, 4, 6}, []float64{0, 1, 5, 100, 90, 91})
+		in := FromSlice(readings, nil)
+
+		var anomalies []Anomaly[reading]
+		out := DetectRateAnomalies(in, ts, value, 2, func(a Anomaly[reading]) {
+			anomalies = append(anomalies, a)
+		})
+
+		outSlice, errs := toSliceAndErrors(out)
+		th.ExpectValue(t, len(outSlice), 6)
+		th.ExpectValue(t, len(errs), 0)
+
+		// 1->5 in 1s, 100->90 in 2s. The pair with equal timestamps is skipped.
+		th.ExpectValue(t, len(anomalies), 2)
+		th.ExpectValue(t, anomalies[0].Cur.Value, 5.0)
+		th.ExpectValue(t, anomalies[0].Rate, 4.0)
+		th.ExpectValue(t, anomalies[1].Cur.Value, 90.0)
+		th.ExpectValue(t, anomalies[1].Rate, 5.0)
+		th.ExpectValue(t, anomalies[1].Gap, 2*time.Second)
+	})
+}
@@ -0,0 +1,194 @@
+package rill
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// StageInfo describes a single stage of a [Pipeline], as reported by [Pipeline.Describe].
+type StageInfo struct {
+	ID          int    // unique within the pipeline, in the order of stage creation
+	Name        string // name given with WithStageName, or "source" for streams attached with WithPipeline
+	Func        string // name of the function that created the stage, such as "Map" or "OrderedFilter"
+	Concurrency int    // number of goroutines, zero for sources
+	Buffer      int    // size of the output buffer, zero if not buffered
+	Input       int    // ID of the upstream stage, or -1 for sources
+}
+
+// graphRef points to the pipeline stage that has produced a stream.
+type graphRef struct {
+	p       *Pipeline
+	stage   int
+	streams *graphStreamSet
+}
+
+// graphStreams maps streams that belong to a pipeline to their producing stages.
+// Keys are receive-only channels, values are of type graphRef.
+var graphStreams sync.Map
+
+// graphStreamSet holds the streams recorded for a source or a named stage, including the outputs of unnamed stages
+// downstream of it. All of them are removed from graphStreams at once, right before the output of the source or named stage is closed.
+type graphStreamSet struct {
+	mu       sync.Mutex
+	keys     []any
+	released bool
+}
+
+// record maps the stream to the stage in graphStreams.
+func (r graphRef) record(key any) {
+	r.streams.mu.Lock()
+	defer r.streams.mu.Unlock()
+
+	if r.streams.released {
+		return
+	}
+	graphStreams.Store(key, r)
+	r.streams.keys = append(r.streams.keys, key)
+}
+
+func (s *graphStreamSet) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range s.keys {
+		graphStreams.Delete(key)
+	}
+	s.keys = nil
+	s.released = true
+}
+
+// trackStage applies the name of the stage to its output, and records the stage in the graph of the pipeline
+// its input stream belongs to, if any.
+//
+// Named stages already forward their outputs through an extra goroutine, that wraps errors into PipelineError,
+// so the same goroutine keeps the record and removes it once the output is closed.
+// Unnamed stages are not recorded, and their outputs are used as is: they are treated as the outputs
+// of the nearest named upstream stage, and their records are removed together with its own.
+func trackStage[A any](c stageConfig, out <-chan Try[A]) <-chan Try[A] {
+	v, ok := graphStreams.Load(c.in)
+
+	if c.name == "" {
+		if ok {
+			v.(graphRef).record(out)
+		}
+		return out
+	}
+
+	if !ok {
+		return Stage(out, c.name)
+	}
+
+	upstream := v.(graphRef)
+	ref := graphRef{
+		p: upstream.p,
+		stage: upstream.p.addStage(StageInfo{
+			Name:        c.name,
+			Func:        c.fn,
+			Concurrency: c.n,
+			Buffer:      max0(c.buffer),
+			Input:       upstream.stage,
+		}),
+		streams: &graphStreamSet{},
+	}
+
+	label := stageLabeler[A](c.name)
+	res := make(chan Try[A])
+	ref.record((<-chan Try[A])(res))
+
+	go func() {
+		defer close(res)
+		defer ref.streams.release()
+
+		for a := range out {
+			res <- label(a)
+		}
+	}()
+
+	return res
+}
+
+func max0(x int) int {
+	if x < 0 {
+		return 0
+	}
+	return x
+}
+
+func (p *Pipeline) addStage(s StageInfo) int {
+	p.graphMu.Lock()
+	defer p.graphMu.Unlock()
+
+	s.ID = len(p.graph)
+	p.graph = append(p.graph, s)
+	return s.ID
+}
+
+// Describe returns the stages of the pipeline, in the order they were created.
+// The pipeline graph starts from the sources attached with [WithPipeline], and includes stages named with [WithStageName]
+// that are downstream of them. Unnamed stages are not included, but don't break the graph.
+// Functions that don't accept [StageOption], such as [Batch] or [Merge], do break it, so stages downstream of them
+// are not included.
+func (p *Pipeline) Describe() []StageInfo {
+	p.graphMu.Lock()
+	defer p.graphMu.Unlock()
+
+	res := make([]StageInfo, len(p.graph))
+	copy(res, p.graph)
+	return res
+}
+
+// stageLabel returns a human-readable label of the stage, such as "fetch users: Map ×10, buffer 64".
+func stageLabel(s StageInfo) string {
+	if s.Input < 0 {
+		return s.Name
+	}
+
+	label := fmt.Sprintf("%s: %s ×%d", s.Name, s.Func, s.Concurrency)
+	if s.Buffer > 0 {
+		label += fmt.Sprintf(", buffer %d", s.Buffer)
+	}
+	return label
+}
+
+// DumpDOT returns the graph of the pipeline in the Graphviz DOT format. See [Pipeline.Describe] for details.
+//
+//	fmt.Println(p.DumpDOT()) // render with: dot -Tsvg pipeline.dot > pipeline.svg
+func (p *Pipeline) DumpDOT() string {
+	var sb strings.Builder
+	sb.WriteString("digraph pipeline {\n")
+	sb.WriteString("\trankdir=LR;\n")
+
+	stages := p.Describe()
+	for _, s := range stages {
+		fmt.Fprintf(&sb, "\ts%d [label=%q];\n", s.ID, stageLabel(s))
+	}
+	for _, s := range stages {
+		if s.Input >= 0 {
+			fmt.Fprintf(&sb, "\ts%d -> s%d;\n", s.Input, s.ID)
+		}
+	}
+
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// DumpMermaid returns the graph of the pipeline as a Mermaid flowchart, which can be embedded into Markdown documents.
+// See [Pipeline.Describe] for details.
+func (p *Pipeline) DumpMermaid() string {
+	var sb strings.Builder
+	sb.WriteString("flowchart LR\n")
+
+	stages := p.Describe()
+	for _, s := range stages {
+		label := strings.ReplaceAll(stageLabel(s), `"`, "#quot;")
+		fmt.Fprintf(&sb, "    s%d[\"%s\"]\n", s.ID, label)
+	}
+	for _, s := range stages {
+		if s.Input >= 0 {
+			fmt.Fprintf(&sb, "    s%d --> s%d\n", s.Input, s.ID)
+		}
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,77 @@
+package rill
+
+import (
+	"testing"
+
+	"github.com/destel/rill/internal/th"
+)
+
+func TestPipelineDescribe(t *testing.T) {
+	p := NewPipeline()
+
+	ids := WithPipeline(p, FromChan(th.FromRange(0, 100), nil))
+	users := Map(ids, 10, func(x int) (int, error) { return x, nil }, WithStageName("fetch users"), WithBuffer(64))
+	users = OrderedFilter(users, 1, func(x int) (bool, error) { return x%2 == 0, nil }) // unnamed
+	saved := OrderedTap(users, 4, func(x int) error { return nil }, WithStageName(`save "users"`))
+
+	// stages not attached to the pipeline are ignored
+	other := Map(FromSlice([]int{1}, nil), 1, func(x int) (int, error) { return x, nil }, WithStageName("other"))
+
+	th.ExpectNoError(t, Err(saved))
+	th.ExpectNoError(t, Err(other))
+
+	th.ExpectSlice(t, p.Describe(), []StageInfo{
+		{ID: 0, Name: "source", Func: "WithPipeline", Input: -1},
+		{ID: 1, Name: "fetch users", Func: "Map", Concurrency: 10, Buffer: 64, Input: 0},
+		{ID: 2, Name: `save "users"`, Func: "OrderedTap", Concurrency: 4, Input: 1},
+	})
+
+	th.ExpectValue(t, p.DumpDOT(), `digraph pipeline {
+	rankdir=LR;
+	s0 [label="source"];
+	s1 [label="fetch users: Map ×10, buffer 64"];
+	s2 [label="save \"users\": OrderedTap ×4"];
+	s0 -> s1;
+	s1 -> s2;
+}
+`)
+
+	th.ExpectValue(t, p.DumpMermaid(), `flowchart LR
+    s0["source"]
+    s1["fetch users: Map ×10, buffer 64"]
+    s2["save #quot;users#quot;: OrderedTap ×4"]
+    s0 --> s1
+    s1 --> s2
+`)
+}
+
+func TestPipelineGraphCleanup(t *testing.T) {
+	countStreams := func(p *Pipeline) int {
+		cnt := 0
+		graphStreams.Range(func(key, value any) bool {
+			if value.(graphRef).p == p {
+				cnt++
+			}
+			return true
+		})
+		return cnt
+	}
+
+	p := NewPipeline()
+
+	ids := WithPipeline(p, FromChan(th.FromRange(0, 100), nil))
+	odd := Filter(ids, 1, func(x int) (bool, error) { return x%2 == 1, nil }) // unnamed
+	th.ExpectValue(t, countStreams(p), 2)
+
+	doubled := Map(odd, 1, func(x int) (int, error) { return 2 * x, nil }, WithStageName("double"))
+	doubled = Map(doubled, 1, func(x int) (int, error) { return x, nil }) // unnamed
+	th.ExpectValue(t, countStreams(p), 4)
+
+	outSlice, err := ToSlice(doubled)
+	th.ExpectNoError(t, err)
+	th.ExpectValue(t, len(outSlice), 50)
+
+	// records are removed before the streams are closed
+	th.ExpectValue(t, countStreams(p), 0)
+	th.ExpectValue(t, len(p.Describe()), 2)
+}
@@ -0,0 +1,223 @@
+package rill
+
+import (
+	"bufio"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/destel/rill/internal/core"
+)
+
+// Group is a collection of items that share the same key.
+type Group[K comparable, A any] struct {
+	Key   K
+	Items []A
+}
+
+// ExternalGroupBy groups items from the input stream by key and emits a stream of groups.
+// It can handle more keys and items than fit in memory. Once more than maxInMemory items are held in memory,
+// the partial groups are spilled to temporary files in dir, partitioned by the hash of the key.
+// After the end of the input stream is reached, partitions are loaded back one by one,
+// and the merged groups are emitted. This is similar to how external sorting works.
+// If dir is empty, the default directory for temporary files is used.
+// Temporary files are removed when ExternalGroupBy completes.
+//
+// Items within each group preserve their original order, while the order of groups is not specified.
+// Both keys and items must be encodable with the encoding/gob package.
+//
+// This is a non-blocking function that processes items sequentially.
+// All errors from the input stream and from the key function are forwarded to the output stream right away.
+// In case of an i/o error, it is sent to the output stream, the input stream is drained and the output stream is closed.
+//
+// See the package documentation for more information on non-blocking functions and error handling.
+func ExternalGroupBy[A any, K comparable](in <-chan Try[A], key func(A) (K, error), maxInMemory int, dir string) <-chan Try[Group[K, A]] {
+	if in == nil {
+		return nil
+	}
+
+	out := make(chan Try[Group[K, A]])
+
+	go func() {
+		defer close(out)
+
+		groups := make(map[K][]A)
+		inMemory := 0
+
+		var spill *groupSpill[K, A]
+		defer func() {
+			if spill != nil {
+				spill.Remove()
+			}
+		}()
+
+		fail := func(err error) {
+			out <- Try[Group[K, A]]{Error: err}
+			DrainNB(in)
+		}
+
+		for a := range in {
+			if a.Error != nil {
+				out <- Try[Group[K, A]]{Error: a.Error}
+				continue
+			}
+
+			k, err := key(a.Value)
+			if err != nil {
+				out <- Try[Group[K, A]]{Error: err}
+				continue
+			}
+
+			groups[k] = append(groups[k], a.Value)
+			inMemory++
+
+			if inMemory < maxInMemory {
+				continue
+			}
+
+			if spill == nil {
+				spill, err = newGroupSpill[K, A](dir)
+				if err != nil {
+					fail(err)
+					return
+				}
+			}
+
+			if err := spill.Write(groups); err != nil {
+				fail(err)
+				return
+			}
+
+			groups = make(map[K][]A)
+			inMemory = 0
+		}
+
+		// Optimization: everything fits in memory
+		if spill == nil {
+			for k, items := range groups {
+				out <- Try[Group[K, A]]{Value: Group[K, A]{Key: k, Items: items}}
+			}
+			return
+		}
+
+		if err := spill.Write(groups); err != nil {
+			out <- Try[Group[K, A]]{Error: err}
+			return
+		}
+		groups = nil
+
+		err := spill.ReadAll(func(m map[K][]A) {
+			for k, items := range m {
+				out <- Try[Group[K, A]]{Value: Group[K, A]{Key: k, Items: items}}
+			}
+		})
+		if err != nil {
+			out <- Try[Group[K, A]]{Error: err}
+		}
+	}()
+
+	return out
+}
+
+const groupSpillPartitions = 64
+
+type groupSpillRecord[K comparable, A any] struct {
+	Key   K
+	Items []A
+}
+
+// groupSpill holds partial groups in a set of temporary files, one file per partition.
+type groupSpill[K comparable, A any] struct {
+	dir      string
+	files    []*os.File
+	writers  []*bufio.Writer
+	encoders []*gob.Encoder
+}
+
+func newGroupSpill[K comparable, A any](dir string) (*groupSpill[K, A], error) {
+	dir, err := os.MkdirTemp(dir, "rill-groupby-*")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &groupSpill[K, A]{
+		dir:      dir,
+		files:    make([]*os.File, groupSpillPartitions),
+		writers:  make([]*bufio.Writer, groupSpillPartitions),
+		encoders: make([]*gob.Encoder, groupSpillPartitions),
+	}
+
+	for i := range s.files {
+		f, err := os.Create(filepath.Join(dir, fmt.Sprintf("%02d.gob", i)))
+		if err != nil {
+			s.Remove()
+			return nil, err
+		}
+
+		s.files[i] = f
+		s.writers[i] = bufio.NewWriter(f)
+		s.encoders[i] = gob.NewEncoder(s.writers[i])
+	}
+
+	return s, nil
+}
+
+// Write appends partial groups to the partition files
+func (s *groupSpill[K, A]) Write(groups map[K][]A) error {
+	for k, items := range groups {
+		i := core.HashKey(k) % groupSpillPartitions
+		if err := s.encoders[i].Encode(groupSpillRecord[K, A]{Key: k, Items: items}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadAll loads partitions one by one, merges partial groups and passes them to f.
+// Each partition file is closed and removed after it has been processed.
+func (s *groupSpill[K, A]) ReadAll(f func(map[K][]A)) error {
+	for i, file := range s.files {
+		if err := s.writers[i].Flush(); err != nil {
+			return err
+		}
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+
+		groups := make(map[K][]A)
+		dec := gob.NewDecoder(bufio.NewReader(file))
+		for {
+			var rec groupSpillRecord[K, A]
+			err := dec.Decode(&rec)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return err
+			}
+
+			groups[rec.Key] = append(groups[rec.Key], rec.Items...)
+		}
+
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+		s.files[i] = nil
+
+		f(groups)
+	}
+
+	return nil
+}
+
+// Remove closes all files and removes the temporary directory
+func (s *groupSpill[K, A]) Remove() {
+	for _, f := range s.files {
+		if f != nil {
+			_ = f.Close()
+		}
+	}
+	_ = os.RemoveAll(s.dir)
+}
@@ -0,0 +1,67 @@
+package rill
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/destel/rill/internal/th"
+)
+
+func TestExternalGroupBy(t *testing.T) {
+	t.Run("nil", func(t *testing.T) {
+		out := ExternalGroupBy(nil, func(x int) (int, error) { return x, nil }, 10, "")
+		th.ExpectValue(t, out, nil)
+	})
+
+	for _, maxInMemory := range []int{10000, 50} {
+		t.Run(th.Name("correctness", maxInMemory), func(t *testing.T) {
+			dir := t.TempDir()
+
+			in := FromChan(th.FromRange(0, 1000), nil)
+			in = replaceWithError(in, 500, fmt.Errorf("err500"))
+
+			out := ExternalGroupBy(in, func(x int) (string, error) {
+				if x == 501 {
+					return "", fmt.Errorf("err501")
+				}
+				return fmt.Sprintf("key%02d", x%37), nil
+			}, maxInMemory, dir)
+
+			groups, errs := toSliceAndErrors(out)
+
+			th.ExpectSlice(t, errs, []string{"err500", "err501"})
+			th.ExpectValue(t, len(groups), 37)
+
+			for _, g := range groups {
+				var expected []int
+				for i := 0; i < 1000; i++ {
+					if i == 500 || i == 501 {
+						continue
+					}
+					if fmt.Sprintf("key%02d", i%37) == g.Key {
+						expected = append(expected, i)
+					}
+				}
+				th.ExpectSlice(t, g.Items, expected)
+			}
+
+			// temporary files are removed
+			entries, err := os.ReadDir(dir)
+			th.ExpectNoError(t, err)
+			th.ExpectValue(t, len(entries), 0)
+		})
+	}
+
+	t.Run("spill error", func(t *testing.T) {
+		in := FromChan(th.FromRange(0, 1000), nil)
+
+		out := ExternalGroupBy(in, func(x int) (int, error) {
+			return x % 10, nil
+		}, 50, "/non-existent-dir")
+
+		groups, errs := toSliceAndErrors(out)
+		th.ExpectValue(t, len(groups), 0)
+		th.ExpectValue(t, len(errs), 1)
+	})
+}
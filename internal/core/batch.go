@@ -3,6 +3,8 @@ package core
 import (
 	"fmt"
 	"time"
+
+	"github.com/destel/rill/internal/heap"
 )
 
 // Batch groups items from an input channel into batches based on a maximum size and a timeout.
@@ -10,6 +12,28 @@ import (
 // This function never emits empty batches. The timeout countdown starts when the first item is added to a new batch.
 // To emit batches only when full, set the timeout to -1. Zero timeout is not supported and will panic.
 func Batch[A any](in <-chan A, size int, timeout time.Duration) <-chan []A {
+	return ObservedBatch(in, size, timeout, nil)
+}
+
+// ObservedBatch is like Batch, but additionally calls the observe function (if not nil) right before each batch is emitted.
+// The timedOut argument is true if the batch is emitted because of the timeout.
+func ObservedBatch[A any](in <-chan A, size int, timeout time.Duration, observe func(size int, timedOut bool)) <-chan []A {
+	return AllocBatch(in, size, timeout, observe, nil)
+}
+
+// AllocBatch is like ObservedBatch, but additionally uses the alloc function (if not nil) to get empty slices for new batches.
+// The returned slices must have zero length. Slices are allocated only when the first item of a batch arrives,
+// so every allocated slice is eventually emitted.
+func AllocBatch[A any](in <-chan A, size int, timeout time.Duration, observe func(size int, timedOut bool), alloc func() []A) <-chan []A {
+	if observe == nil {
+		observe = func(int, bool) {}
+	}
+	if alloc == nil {
+		alloc = func() []A {
+			return make([]A, 0, size)
+		}
+	}
+
 	if in == nil {
 		return nil
 	}
@@ -26,13 +50,18 @@ func Batch[A any](in <-chan A, size int, timeout time.Duration) <-chan []A {
 			defer close(out)
 			var batch []A
 			for a := range in {
+				if batch == nil {
+					batch = alloc()
+				}
 				batch = append(batch, a)
 				if len(batch) >= size {
+					observe(len(batch), false)
 					out <- batch
-					batch = make([]A, 0, size)
+					batch = nil
 				}
 			}
 			if len(batch) > 0 {
+				observe(len(batch), false)
 				out <- batch
 			}
 		}()
@@ -40,14 +69,15 @@ func Batch[A any](in <-chan A, size int, timeout time.Duration) <-chan []A {
 	default:
 		// finite timeout
 		go func() {
-			batch := make([]A, 0, size)
+			var batch []A
 			t := time.NewTicker(1 * time.Hour)
 			t.Stop()
 
-			flush := func() {
+			flush := func(timedOut bool) {
 				if len(batch) > 0 {
+					observe(len(batch), timedOut)
 					out <- batch
-					batch = make([]A, 0, size)
+					batch = nil
 				}
 
 				t.Stop()
@@ -62,17 +92,20 @@ func Batch[A any](in <-chan A, size int, timeout time.Duration) <-chan []A {
 				select {
 				case <-t.C:
 					// timeout
-					flush()
+					flush(true)
 
 				case a, ok := <-in:
 					if !ok {
 						// end of input
-						flush()
+						flush(false)
 						close(out)
 						return
 					}
 
 					// got new item
+					if batch == nil {
+						batch = alloc()
+					}
 					batch = append(batch, a)
 
 					if len(batch) == 1 {
@@ -83,7 +116,7 @@ func Batch[A any](in <-chan A, size int, timeout time.Duration) <-chan []A {
 
 					if len(batch) >= size {
 						// batch is full
-						flush()
+						flush(false)
 					}
 				}
 
@@ -95,6 +128,228 @@ func Batch[A any](in <-chan A, size int, timeout time.Duration) <-chan []A {
 	return out
 }
 
+// MinBatch is like Batch, but never emits batches smaller than minSize, except for the last one.
+// When the timeout expires and the batch is still too small, it's held until it reaches minSize.
+// If minSize is greater than size, it's treated as size.
+func MinBatch[A any](in <-chan A, minSize, size int, timeout time.Duration) <-chan []A {
+	if in == nil {
+		return nil
+	}
+	if timeout == 0 {
+		panic(fmt.Errorf("zero timeout is not supported yet"))
+	}
+	if minSize > size {
+		minSize = size
+	}
+
+	out := make(chan []A)
+
+	go func() {
+		defer close(out)
+
+		batch := make([]A, 0, size)
+		expired := false
+
+		var timer *time.Timer
+		var timerC <-chan time.Time // nil when the timer is not running
+
+		flush := func() {
+			out <- batch
+			batch = make([]A, 0, size)
+			expired = false
+			if timer != nil && !timer.Stop() && timerC != nil {
+				// consume a tick that might have been sent while we were flushing
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timerC = nil
+		}
+
+		for {
+			select {
+			case <-timerC:
+				timerC = nil
+				expired = true
+				if len(batch) >= minSize {
+					flush()
+				}
+
+			case a, ok := <-in:
+				if !ok {
+					if len(batch) > 0 {
+						flush()
+					}
+					return
+				}
+
+				batch = append(batch, a)
+
+				if len(batch) == 1 && timeout > 0 {
+					if timer == nil {
+						timer = time.NewTimer(timeout)
+					} else {
+						timer.Reset(timeout)
+					}
+					timerC = timer.C
+				}
+
+				if len(batch) >= size || (expired && len(batch) >= minSize) {
+					flush()
+				}
+			}
+		}
+	}()
+
+	return out
+}
+
+// WindowByTime groups items from an input channel into non-overlapping time windows of duration d, based on arrival time.
+// Windows are aligned to the moment the function is called. Each window is emitted at its end. Empty windows are not emitted.
+// Zero or negative d means a single window that ends when the input channel closes.
+func WindowByTime[A any](in <-chan A, d time.Duration) <-chan []A {
+	if in == nil {
+		return nil
+	}
+
+	out := make(chan []A)
+
+	go func() {
+		defer close(out)
+
+		var tick <-chan time.Time
+		if d > 0 {
+			t := time.NewTicker(d)
+			defer t.Stop()
+			tick = t.C
+		}
+
+		var window []A
+
+		for {
+			select {
+			case <-tick:
+				if len(window) > 0 {
+					out <- window
+					window = nil
+				}
+
+			case a, ok := <-in:
+				if !ok {
+					if len(window) > 0 {
+						out <- window
+					}
+					return
+				}
+				window = append(window, a)
+			}
+		}
+	}()
+
+	return out
+}
+
+type session[A any] struct {
+	Items  []A
+	LastAt time.Time
+}
+
+type sessionDeadline[K comparable] struct {
+	Key K
+	At  time.Time
+}
+
+// SessionWindow groups items from an input channel into per-key sessions. A session is closed and emitted
+// when no items with its key arrive for the duration of gap. All open sessions are emitted when the input channel closes.
+func SessionWindow[A any, K comparable](in <-chan A, gap time.Duration, key func(A) K) <-chan []A {
+	if in == nil {
+		return nil
+	}
+
+	out := make(chan []A)
+
+	go func() {
+		defer close(out)
+
+		sessions := make(map[K]*session[A])
+
+		// Deadlines are removed lazily: an entry is valid only if it matches the last activity of its session
+		deadlines := heap.New(func(a, b sessionDeadline[K]) bool {
+			return a.At.Before(b.At)
+		})
+
+		// emits expired sessions and returns the time of the next deadline
+		flush := func(now time.Time, all bool) (time.Time, bool) {
+			for {
+				d, ok := deadlines.Peek()
+				if !ok {
+					return time.Time{}, false
+				}
+
+				s := sessions[d.Key]
+				if s == nil || !s.LastAt.Add(gap).Equal(d.At) {
+					deadlines.Pop() // stale
+					continue
+				}
+
+				if !all && d.At.After(now) {
+					return d.At, true
+				}
+
+				deadlines.Pop()
+				delete(sessions, d.Key)
+				out <- s.Items
+			}
+		}
+
+		timer := time.NewTimer(time.Hour)
+		timer.Stop()
+		var timerAt time.Time
+
+		for {
+			var timerC <-chan time.Time
+			if next, ok := flush(time.Now(), false); ok {
+				if !next.Equal(timerAt) {
+					if !timer.Stop() {
+						select {
+						case <-timer.C:
+						default:
+						}
+					}
+					timer.Reset(time.Until(next))
+					timerAt = next
+				}
+				timerC = timer.C
+			}
+
+			select {
+			case <-timerC:
+				timerAt = time.Time{}
+
+			case a, ok := <-in:
+				if !ok {
+					flush(time.Time{}, true)
+					return
+				}
+
+				k := key(a)
+				s := sessions[k]
+				if s == nil {
+					s = &session[A]{}
+					sessions[k] = s
+				}
+
+				s.Items = append(s.Items, a)
+				s.LastAt = time.Now()
+				deadlines.Push(sessionDeadline[K]{Key: k, At: s.LastAt.Add(gap)})
+			}
+		}
+	}()
+
+	return out
+}
+
 // Unbatch is the inverse of Batch. It takes a channel of batches and emits individual items.
 func Unbatch[A any](in <-chan []A) <-chan A {
 	if in == nil {
@@ -1,6 +1,7 @@
 package core
 
 import (
+	"strings"
 	"testing"
 	"time"
 
@@ -99,3 +100,198 @@ func TestUnbatch(t *testing.T) {
 		th.ExpectSlice(t, outSlice, []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10})
 	})
 }
+
+func TestObservedBatch(t *testing.T) {
+	type observation struct {
+		Size     int
+		TimedOut bool
+	}
+
+	for _, timeout := range []time.Duration{-1, 500 * time.Millisecond} {
+		t.Run(th.Name("correctness", timeout), func(t *testing.T) {
+			in := make(chan int)
+			go func() {
+				defer close(in)
+				th.Send(in, 1, 2, 3, 4, 5)
+				time.Sleep(1 * time.Second)
+				th.Send(in, 6, 7, 8, 9, 10)
+			}()
+
+			var observed []observation
+			out := ObservedBatch(in, 4, timeout, func(size int, timedOut bool) {
+				observed = append(observed, observation{size, timedOut})
+			})
+
+			outSlice := th.ToSlice(out)
+
+			if timeout < 0 {
+				th.ExpectValue(t, len(outSlice), 3)
+				th.ExpectSlice(t, observed, []observation{{4, false}, {4, false}, {2, false}})
+			} else {
+				th.ExpectValue(t, len(outSlice), 4)
+				th.ExpectSlice(t, observed, []observation{{4, false}, {1, true}, {4, false}, {1, false}})
+			}
+		})
+	}
+}
+
+func TestMinBatch(t *testing.T) {
+	t.Run("nil", func(t *testing.T) {
+		var nilChan chan []string
+		th.ExpectValue(t, MinBatch(nilChan, 2, 10, 10*time.Second), nil)
+	})
+
+	t.Run("fast", func(t *testing.T) {
+		in := make(chan int)
+		go func() {
+			defer close(in)
+			th.Send(in, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10)
+		}()
+
+		outSlice := th.ToSlice(MinBatch(in, 3, 4, 500*time.Millisecond))
+		th.ExpectValue(t, len(outSlice), 3)
+		th.ExpectSlice(t, outSlice[0], []int{1, 2, 3, 4})
+		th.ExpectSlice(t, outSlice[1], []int{5, 6, 7, 8})
+		th.ExpectSlice(t, outSlice[2], []int{9, 10}) // the last batch can be smaller
+	})
+
+	t.Run("slow", func(t *testing.T) {
+		in := make(chan int)
+		go func() {
+			defer close(in)
+			th.Send(in, 1, 2, 3, 4, 5)
+			time.Sleep(1 * time.Second)
+			th.Send(in, 6)
+			time.Sleep(1 * time.Second)
+			th.Send(in, 7, 8)
+			time.Sleep(1 * time.Second)
+			th.Send(in, 9, 10, 11, 12, 13)
+		}()
+
+		outSlice := th.ToSlice(MinBatch(in, 2, 4, 500*time.Millisecond))
+		th.ExpectValue(t, len(outSlice), 5)
+		th.ExpectSlice(t, outSlice[0], []int{1, 2, 3, 4})
+		th.ExpectSlice(t, outSlice[1], []int{5, 6}) // held beyond the timeout until it reached the min size
+		th.ExpectSlice(t, outSlice[2], []int{7, 8}) // emitted on timeout
+		th.ExpectSlice(t, outSlice[3], []int{9, 10, 11, 12})
+		th.ExpectSlice(t, outSlice[4], []int{13})
+	})
+
+	t.Run("no timeout", func(t *testing.T) {
+		in := make(chan int)
+		go func() {
+			defer close(in)
+			th.Send(in, 1, 2, 3, 4, 5)
+			time.Sleep(500 * time.Millisecond)
+			th.Send(in, 6)
+		}()
+
+		outSlice := th.ToSlice(MinBatch(in, 2, 4, -1))
+		th.ExpectValue(t, len(outSlice), 2)
+		th.ExpectSlice(t, outSlice[0], []int{1, 2, 3, 4})
+		th.ExpectSlice(t, outSlice[1], []int{5, 6})
+	})
+
+	t.Run("min size greater than size", func(t *testing.T) {
+		in := make(chan int)
+		go func() {
+			defer close(in)
+			th.Send(in, 1, 2, 3, 4, 5)
+			time.Sleep(1 * time.Second)
+			th.Send(in, 6, 7, 8)
+			time.Sleep(1 * time.Second)
+			th.Send(in, 9)
+		}()
+
+		outSlice := th.ToSlice(MinBatch(in, 10, 4, 300*time.Millisecond))
+		th.ExpectValue(t, len(outSlice), 3)
+		th.ExpectSlice(t, outSlice[0], []int{1, 2, 3, 4})
+		th.ExpectSlice(t, outSlice[1], []int{5, 6, 7, 8}) // held until full, never bigger than size
+		th.ExpectSlice(t, outSlice[2], []int{9})
+	})
+}
+
+func TestWindowByTime(t *testing.T) {
+	t.Run("nil", func(t *testing.T) {
+		out := WindowByTime[int](nil, 1*time.Second)
+		th.ExpectValue(t, out, nil)
+	})
+
+	t.Run("correctness", func(t *testing.T) {
+		in := make(chan int)
+		out := WindowByTime(in, 1*time.Second)
+
+		go func() {
+			defer close(in)
+			time.Sleep(200 * time.Millisecond)
+			th.Send(in, 1, 2, 3) // first window
+			time.Sleep(1 * time.Second)
+			th.Send(in, 4, 5) // second window
+			time.Sleep(2 * time.Second)
+			th.Send(in, 6) // fourth window, third is empty
+		}()
+
+		outSlice := th.ToSlice(out)
+		th.ExpectValue(t, len(outSlice), 3)
+		th.ExpectSlice(t, outSlice[0], []int{1, 2, 3})
+		th.ExpectSlice(t, outSlice[1], []int{4, 5})
+		th.ExpectSlice(t, outSlice[2], []int{6})
+	})
+
+	for _, d := range []time.Duration{0, -1 * time.Second} {
+		t.Run(th.Name("single window", d), func(t *testing.T) {
+			var outSlice [][]int
+			th.ExpectNotHang(t, 1*time.Second, func() {
+				outSlice = th.ToSlice(WindowByTime(th.FromRange(0, 5), d))
+			})
+
+			th.ExpectValue(t, len(outSlice), 1)
+			th.ExpectSlice(t, outSlice[0], []int{0, 1, 2, 3, 4})
+		})
+	}
+
+	t.Run("single window empty", func(t *testing.T) {
+		outSlice := th.ToSlice(WindowByTime(th.FromRange(0, 0), 0))
+		th.ExpectValue(t, len(outSlice), 0)
+	})
+}
+
+func TestSessionWindow(t *testing.T) {
+	key := func(s string) byte { return s[0] }
+
+	t.Run("nil", func(t *testing.T) {
+		out := SessionWindow(nil, 1*time.Second, key)
+		th.ExpectValue(t, out, nil)
+	})
+
+	t.Run("correctness", func(t *testing.T) {
+		in := make(chan string)
+		out := SessionWindow(in, 500*time.Millisecond, key)
+
+		go func() {
+			defer close(in)
+			th.Send(in, "a1", "b1", "a2")
+			time.Sleep(300 * time.Millisecond)
+			th.Send(in, "a3") // extends session a
+			time.Sleep(300 * time.Millisecond)
+			// session b is closed by now
+			th.Send(in, "a4", "b2")
+			time.Sleep(700 * time.Millisecond)
+			// sessions a and b are closed by now
+			th.Send(in, "a5", "c1")
+			// sessions a and c are closed at the end of input
+		}()
+
+		var sessions []string
+		for s := range out {
+			sessions = append(sessions, strings.Join(s, ","))
+		}
+
+		th.ExpectValue(t, len(sessions), 5)
+		th.ExpectValue(t, sessions[0], "b1")
+		th.Sort(sessions[1:3])
+		th.ExpectSlice(t, sessions[1:3], []string{"a1,a2,a3,a4", "b2"})
+		th.Sort(sessions[3:])
+		th.ExpectSlice(t, sessions[3:], []string{"a5", "c1"})
+	})
+}
@@ -1,7 +1,6 @@
 package core
 
 import (
-	"fmt"
 	"time"
 
 	"github.com/destel/rill/internal/ringbuffer"
@@ -53,7 +52,6 @@ func infiniteBuffer[A any](in <-chan A) <-chan A {
 				hasNextValue = false
 
 			case <-shrinkTicker.C:
-				fmt.Println("<-shrinkTicker.C")
 				if canShrink {
 					buf.Shrink()
 				}
@@ -99,3 +97,63 @@ func Delay[A any](in <-chan A, delay time.Duration) <-chan A {
 
 	return out
 }
+
+// Spread smooths out bursts of items by spacing them evenly in time, maintaining the order.
+// Items are grouped by arrival time: a group starts with the first item and collects everything that arrives within the window.
+// Each group is then emitted over the course of the next window, with equal intervals between items.
+func Spread[A any](in <-chan A, window time.Duration) <-chan A {
+	if in == nil {
+		return nil
+	}
+
+	groups := make(chan []A)
+	go func() {
+		defer close(groups)
+
+		var group []A
+		var windowEnd <-chan time.Time
+
+		for {
+			select {
+			case a, ok := <-in:
+				if !ok {
+					if len(group) > 0 {
+						groups <- group
+					}
+					return
+				}
+
+				if len(group) == 0 {
+					windowEnd = time.After(window)
+				}
+				group = append(group, a)
+
+			case <-windowEnd:
+				groups <- group
+				group = nil
+				windowEnd = nil
+			}
+		}
+	}()
+
+	out := make(chan A)
+	go func() {
+		defer close(out)
+
+		var sendAt time.Time
+		for group := range groups {
+			interval := window / time.Duration(len(group))
+
+			for _, a := range group {
+				if wait := time.Until(sendAt); wait > 0 {
+					time.Sleep(wait)
+				}
+				out <- a
+
+				sendAt = time.Now().Add(interval)
+			}
+		}
+	}()
+
+	return out
+}
@@ -114,3 +114,43 @@ func TestDelay(t *testing.T) {
 		th.ExpectValue(t, i, 100-1)
 	})
 }
+
+func TestSpread(t *testing.T) {
+	t.Run("nil", func(t *testing.T) {
+		out := Spread[int](nil, 1*time.Second)
+		th.ExpectValue(t, out, nil)
+	})
+
+	t.Run("correctness", func(t *testing.T) {
+		const window = 1 * time.Second
+		const eps = 200 * time.Millisecond
+
+		in := make(chan int)
+		out := Spread(in, window)
+
+		go func() {
+			defer close(in)
+			// a burst of 10 items
+			for i := 0; i < 10; i++ {
+				in <- i
+			}
+			// keep the input open until the group is flushed
+			time.Sleep(3 * window)
+		}()
+
+		start := time.Now()
+		var receivedAt []time.Duration
+		var values []int
+		for x := range out {
+			values = append(values, x)
+			receivedAt = append(receivedAt, time.Since(start))
+		}
+
+		th.ExpectSlice(t, values, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9})
+
+		// group is collected during the first window and then spread over the next one
+		for i, at := range receivedAt {
+			th.ExpectValueInDelta(t, at, window+time.Duration(i)*window/10, eps)
+		}
+	})
+}
@@ -0,0 +1,74 @@
+package core
+
+import (
+	"container/list"
+	"time"
+)
+
+type distinctEntry[K comparable] struct {
+	Key    K
+	SeenAt time.Time
+}
+
+// DistinctWithin removes duplicate items from the channel, while keeping memory usage bounded.
+// Keys of recently seen items are kept in an LRU cache of at most maxEntries entries, each of which expires after ttl.
+// An item is considered a duplicate if its key is still in the cache.
+// Non-positive maxEntries or ttl disable the corresponding limit.
+// Items for which the key function returns false are always passed through.
+func DistinctWithin[A any, K comparable](in <-chan A, key func(A) (K, bool), maxEntries int, ttl time.Duration) <-chan A {
+	if in == nil {
+		return nil
+	}
+
+	out := make(chan A)
+
+	go func() {
+		defer close(out)
+
+		lru := list.New() // front is the most recently seen
+		index := make(map[K]*list.Element)
+
+		remove := func(e *list.Element) {
+			lru.Remove(e)
+			delete(index, e.Value.(*distinctEntry[K]).Key)
+		}
+
+		for a := range in {
+			k, ok := key(a)
+			if !ok {
+				out <- a
+				continue
+			}
+
+			now := time.Now()
+
+			// evict expired entries
+			if ttl > 0 {
+				for e := lru.Back(); e != nil; e = lru.Back() {
+					if now.Sub(e.Value.(*distinctEntry[K]).SeenAt) < ttl {
+						break
+					}
+					remove(e)
+				}
+			}
+
+			if e, seen := index[k]; seen {
+				// Expired entries may remain in the middle of the list, since duplicates move entries to the front
+				if ttl <= 0 || now.Sub(e.Value.(*distinctEntry[K]).SeenAt) < ttl {
+					lru.MoveToFront(e)
+					continue
+				}
+				remove(e)
+			}
+
+			index[k] = lru.PushFront(&distinctEntry[K]{Key: k, SeenAt: now})
+			if maxEntries > 0 && lru.Len() > maxEntries {
+				remove(lru.Back())
+			}
+
+			out <- a
+		}
+	}()
+
+	return out
+}
@@ -0,0 +1,60 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/destel/rill/internal/th"
+)
+
+func TestDistinctWithin(t *testing.T) {
+	identity := func(x int) (int, bool) { return x, true }
+
+	t.Run("nil", func(t *testing.T) {
+		out := DistinctWithin(nil, identity, 10, 0)
+		th.ExpectValue(t, out, nil)
+	})
+
+	t.Run("unbounded", func(t *testing.T) {
+		in := th.FromSlice([]int{1, 2, 1, 3, 2, 4, 1})
+		out := DistinctWithin(in, identity, 0, 0)
+
+		th.ExpectSlice(t, th.ToSlice(out), []int{1, 2, 3, 4})
+	})
+
+	t.Run("key function", func(t *testing.T) {
+		in := th.FromSlice([]int{1, -1, 2, -1, 12, 3})
+		out := DistinctWithin(in, func(x int) (int, bool) {
+			return x % 10, x >= 0
+		}, 0, 0)
+
+		th.ExpectSlice(t, th.ToSlice(out), []int{1, -1, 2, -1, 3})
+	})
+
+	t.Run("max entries", func(t *testing.T) {
+		// 1 is evicted by 3, so it's emitted again
+		// 2 is never evicted, since it keeps being seen again
+		in := th.FromSlice([]int{1, 2, 2, 3, 2, 1, 2})
+		out := DistinctWithin(in, identity, 2, 0)
+
+		th.ExpectSlice(t, th.ToSlice(out), []int{1, 2, 3, 1})
+	})
+
+	t.Run("ttl", func(t *testing.T) {
+		in := make(chan int)
+		out := DistinctWithin(in, identity, 0, 500*time.Millisecond)
+
+		go func() {
+			defer close(in)
+			in <- 1
+			in <- 2
+			in <- 1
+			time.Sleep(1 * time.Second)
+			in <- 1
+			in <- 2
+			in <- 2
+		}()
+
+		th.ExpectSlice(t, th.ToSlice(out), []int{1, 2, 1, 2})
+	})
+}
@@ -0,0 +1,21 @@
+package core
+
+import (
+	"fmt"
+	"hash/fnv"
+)
+
+// HashKey returns a hash of a comparable value. Equal values always produce equal hashes.
+// The hash is stable across program runs, but is not suitable for cryptographic purposes.
+func HashKey[K comparable](k K) uint64 {
+	h := fnv.New64a()
+
+	switch k := any(k).(type) {
+	case string:
+		_, _ = h.Write([]byte(k))
+	default:
+		_, _ = fmt.Fprintf(h, "%#v", k)
+	}
+
+	return h.Sum64()
+}
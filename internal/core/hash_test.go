@@ -0,0 +1,25 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/destel/rill/internal/th"
+)
+
+func TestHashKey(t *testing.T) {
+	type key struct {
+		A int
+		B string
+	}
+
+	th.ExpectValue(t, HashKey("abc"), HashKey("abc"))
+	th.ExpectValue(t, HashKey(10), HashKey(10))
+	th.ExpectValue(t, HashKey(key{1, "x"}), HashKey(key{1, "x"}))
+
+	if HashKey("abc") == HashKey("abd") {
+		t.Errorf("expected different hashes")
+	}
+	if HashKey(key{1, "x"}) == HashKey(key{2, "x"}) {
+		t.Errorf("expected different hashes")
+	}
+}
@@ -2,36 +2,53 @@ package core
 
 import (
 	"sync"
+
+	"github.com/destel/rill/internal/ringbuffer"
 )
 
 // Loop allows to process items from the input channel concurrently using n goroutines.
 // If done channel is not nil, it will be closed after all items are processed.
 func Loop[A, B any](in <-chan A, done chan<- B, n int, f func(A)) {
+	Claim(in)
+	loop(in, done, n, func(_ int, a A) {
+		f(a)
+	})
+}
+
+// IndexedLoop is similar to Loop, but additionally passes the index of the worker goroutine (from 0 to n-1) to f.
+func IndexedLoop[A, B any](in <-chan A, done chan<- B, n int, f func(worker int, a A)) {
+	Claim(in)
+	loop(in, done, n, f)
+}
+
+func loop[A, B any](in <-chan A, done chan<- B, n int, f func(worker int, a A)) {
+	launch := LauncherFor(in)
+
 	if n == 1 {
-		go func() {
+		launch(func() {
 			if done != nil {
 				defer close(done)
 			}
 
 			for a := range in {
-				f(a)
+				f(0, a)
 			}
-		}()
+		})
 		return
 	}
 
 	var wg sync.WaitGroup
 
 	for i := 0; i < n; i++ {
+		i := i
 		wg.Add(1)
-		go func() {
+		launch(func() {
 			defer wg.Done()
 
 			for a := range in {
-				f(a)
+				f(i, a)
 			}
-			return
-		}()
+		})
 	}
 
 	if done != nil {
@@ -43,11 +60,11 @@ func Loop[A, B any](in <-chan A, done chan<- B, n int, f func(A)) {
 }
 
 type orderedValue[A any] struct {
-	Value        A
-	CanWrite     chan struct{}
-	NextCanWrite chan struct{}
+	Value A
+	Seq   int64
 }
 
+// canWritePool holds canWrite channels for OrderedDynamicLoop, where the number of items in flight is not bounded in advance.
 var canWritePool sync.Pool
 
 func makeCanWriteChan() chan struct{} {
@@ -70,53 +87,90 @@ func releaseCanWriteChan(ch chan struct{}) {
 // - Write result of the processing somewhere. This step is optional.
 // This way processing is done concurrently, but results are written in order.
 func OrderedLoop[A, B any](in <-chan A, done chan<- B, n int, f func(a A, canWrite <-chan struct{})) {
+	Claim(in)
+	orderedLoop(in, done, n, func(_ int, a A, canWrite <-chan struct{}) {
+		f(a, canWrite)
+	})
+}
+
+// OrderedIndexedLoop is similar to OrderedLoop, but additionally passes the index of the worker goroutine (from 0 to n-1) to f.
+func OrderedIndexedLoop[A, B any](in <-chan A, done chan<- B, n int, f func(worker int, a A, canWrite <-chan struct{})) {
+	Claim(in)
+	orderedLoop(in, done, n, f)
+}
+
+func orderedLoop[A, B any](in <-chan A, done chan<- B, n int, f func(worker int, a A, canWrite <-chan struct{})) {
+	launch := LauncherFor(in)
+
+	process := func(worker int, _ int64, a A, canWrite <-chan struct{}) {
+		f(worker, a, canWrite)
+	}
+
+	if o := orderObserverFor(in); o != nil {
+		process = func(worker int, seq int64, a A, canWrite <-chan struct{}) {
+			o.Started(seq)
+			f(worker, a, canWrite)
+			o.Committed(seq) // called before the next item is allowed to write, so commits are reported in order
+		}
+	}
+
 	if n == 1 {
-		canWrite := makeCanWriteChan()
+		canWrite := make(chan struct{})
 		close(canWrite)
 
-		go func() {
+		launch(func() {
 			if done != nil {
 				defer close(done)
 			}
 
+			var seq int64
 			for a := range in {
-				f(a, canWrite)
+				process(0, seq, a, canWrite)
+				seq++
 			}
-		}()
+		})
 		return
 	}
 
 	// High level idea:
-	// Each item holds its own canWrite channel and a reference to the next item's canWrite channel.
-	// After item is processed and written, it sends a signal to the next item that it can also be written.
+	// Items are numbered sequentially. Each item waits for a signal on its canWrite channel, and after it's processed and written,
+	// it signals the canWrite channel of the next item.
+	//
+	// Since an item can't complete before all the preceding items do, at most n consecutive items can be in flight at any time.
+	// That's why canWrite channels can be taken from a ring of n+1 channels, indexed by the sequence number:
+	// by the time a channel is reused, the item that has previously used it has already completed.
+	// This avoids allocating and pooling a channel per item.
+	ring := make([]chan struct{}, n+1)
+	for i := range ring {
+		ring[i] = make(chan struct{}, 1)
+	}
+	ring[0] <- struct{}{} // first item can be written immediately
 
 	orderedIn := make(chan orderedValue[A])
 
 	go func() {
 		defer close(orderedIn)
 
-		var canWrite, nextCanWrite chan struct{}
-		nextCanWrite = makeCanWriteChan()
-		nextCanWrite <- struct{}{} // first item can be written immediately
-
+		var seq int64
 		for a := range in {
-			canWrite, nextCanWrite = nextCanWrite, makeCanWriteChan()
-			orderedIn <- orderedValue[A]{a, canWrite, nextCanWrite}
+			orderedIn <- orderedValue[A]{Value: a, Seq: seq}
+			seq++
 		}
 	}()
 
 	var wg sync.WaitGroup
 	for i := 0; i < n; i++ {
+		i := i
 		wg.Add(1)
-		go func() {
+		launch(func() {
 			defer wg.Done()
 			for a := range orderedIn {
-				f(a.Value, a.CanWrite)
+				slot := int(a.Seq % int64(len(ring)))
+				process(i, a.Seq, a.Value, ring[slot])
 
-				releaseCanWriteChan(a.CanWrite)
-				a.NextCanWrite <- struct{}{}
+				ring[(slot+1)%len(ring)] <- struct{}{}
 			}
-		}()
+		})
 	}
 
 	if done != nil {
@@ -127,16 +181,209 @@ func OrderedLoop[A, B any](in <-chan A, done chan<- B, n int, f func(a A, canWri
 	}
 }
 
-// ForEach is a blocking function that processes input channel concurrently using n goroutines
+// ForEach is a blocking function that processes input channel concurrently using n goroutines.
+// Unlike Loop, it does not claim the input channel. Since ForEach is usually called from a separate goroutine,
+// callers should do it themselves, so that a panic happens in the caller's goroutine.
 func ForEach[A any](in <-chan A, n int, f func(A)) {
 	if n == 1 {
-		for a := range in {
-			f(a)
-		}
+		runLaunched(CustomLauncherFor(in), func() {
+			for a := range in {
+				f(a)
+			}
+		})
 		return
 	}
 
 	done := make(chan struct{})
-	Loop(in, done, n, f)
+	loop(in, done, n, func(_ int, a A) {
+		f(a)
+	})
 	<-done
 }
+
+// DynamicLoop is similar to Loop, but the number of concurrently running f calls is controlled externally.
+// Before each item is processed, acquire is called, and it must block until the item can be processed.
+// After the item is processed, release is called. Each item is processed in its own goroutine, started with the launcher of the input channel.
+// If done channel is not nil, it will be closed after all items are processed.
+func DynamicLoop[A, B any](in <-chan A, done chan<- B, acquire, release func(), f func(A)) {
+	Claim(in)
+	launch := LauncherFor(in)
+
+	go func() {
+		var wg sync.WaitGroup
+
+		for a := range in {
+			a := a
+			acquire()
+
+			wg.Add(1)
+			launch(func() {
+				defer wg.Done()
+				defer release()
+				f(a)
+			})
+		}
+
+		wg.Wait()
+		if done != nil {
+			close(done)
+		}
+	}()
+}
+
+// OrderedDynamicLoop is the ordered version of DynamicLoop. The canWrite channel is passed to f the same way as in OrderedLoop.
+func OrderedDynamicLoop[A, B any](in <-chan A, done chan<- B, acquire, release func(), f func(a A, canWrite <-chan struct{})) {
+	Claim(in)
+	launch := LauncherFor(in)
+
+	go func() {
+		var wg sync.WaitGroup
+
+		var canWrite, nextCanWrite chan struct{}
+		nextCanWrite = makeCanWriteChan()
+		nextCanWrite <- struct{}{} // first item can be written immediately
+
+		for a := range in {
+			a := a
+			acquire()
+
+			canWrite, nextCanWrite = nextCanWrite, makeCanWriteChan()
+			canWrite, nextCanWrite := canWrite, nextCanWrite
+
+			wg.Add(1)
+			launch(func() {
+				defer wg.Done()
+
+				f(a, canWrite)
+				release()
+
+				releaseCanWriteChan(canWrite)
+				nextCanWrite <- struct{}{}
+			})
+		}
+
+		wg.Wait()
+		if done != nil {
+			close(done)
+		}
+	}()
+}
+
+type keyedTask[A any, K comparable] struct {
+	Value A
+	Key   K
+	Keyed bool
+}
+
+// KeyedLoop is similar to Loop, but items with the same key are processed sequentially, in the order they were read from the input.
+// Items for which the key function returns false are processed as soon as a worker is free.
+//
+// Items of a key that is already being processed are queued, so workers are never blocked by a busy key and keep processing other keys.
+// At most maxQueued items are queued in total. When this limit is reached, reading from the input pauses
+// until the queued items are picked up by workers.
+func KeyedLoop[A any, K comparable, B any](in <-chan A, done chan<- B, n int, maxQueued int, key func(A) (K, bool), f func(A)) {
+	Claim(in)
+	launch := LauncherFor(in)
+
+	if maxQueued < 1 {
+		maxQueued = 1
+	}
+
+	tasks := make(chan keyedTask[A, K])
+	finished := make(chan K, n) // buffered, so workers rarely wait for the dispatcher
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		launch(func() {
+			defer wg.Done()
+
+			for t := range tasks {
+				f(t.Value)
+				if t.Keyed {
+					finished <- t.Key
+				}
+			}
+		})
+	}
+
+	if done != nil {
+		go func() {
+			wg.Wait()
+			close(done)
+		}()
+	}
+
+	go func() {
+		defer close(tasks)
+
+		// tasks that can be picked up by workers right away
+		var ready ringbuffer.Buffer[keyedTask[A, K]]
+
+		// keys that are ready or being processed, with their queued items
+		active := make(map[K]*ringbuffer.Buffer[A])
+
+		numQueued := 0 // both ready and waiting for their keys
+
+		for {
+			next, hasNext := ready.Peek()
+			if in == nil && !hasNext && len(active) == 0 {
+				return
+			}
+
+			var tasks1 chan<- keyedTask[A, K]
+			if hasNext {
+				tasks1 = tasks
+			}
+
+			var in1 <-chan A
+			if numQueued < maxQueued {
+				in1 = in
+			}
+
+			select {
+			case a, ok := <-in1:
+				if !ok {
+					in = nil
+					continue
+				}
+
+				numQueued++
+
+				k, keyed := key(a)
+				if !keyed {
+					ready.Write(keyedTask[A, K]{Value: a})
+					continue
+				}
+
+				q, busy := active[k]
+				if !busy {
+					active[k] = nil
+					ready.Write(keyedTask[A, K]{Value: a, Key: k, Keyed: true})
+					continue
+				}
+
+				if q == nil {
+					q = new(ringbuffer.Buffer[A])
+					active[k] = q
+				}
+				q.Write(a)
+
+			case tasks1 <- next:
+				ready.Discard()
+				numQueued--
+
+			case k := <-finished:
+				q := active[k]
+				if q == nil || q.Len() == 0 {
+					delete(active, k)
+					continue
+				}
+
+				a, _ := q.Read()
+				ready.Write(keyedTask[A, K]{Value: a, Key: k, Keyed: true})
+			}
+		}
+	}()
+}
@@ -84,6 +84,62 @@ func TestLoop(t *testing.T) {
 	})
 }
 
+func universalIndexedLoop[A, B any](ord bool, in <-chan A, done chan<- B, n int, f func(worker int, a A, canWrite <-chan struct{})) {
+	if ord {
+		OrderedIndexedLoop(in, done, n, f)
+	} else {
+		canWrite := make(chan struct{}, n)
+		close(canWrite)
+
+		IndexedLoop(in, done, n, func(worker int, a A) {
+			f(worker, a, canWrite)
+		})
+	}
+}
+
+func TestIndexedLoop(t *testing.T) {
+	th.TestBothOrderings(t, func(t *testing.T, ord bool) {
+		for _, n := range []int{1, 5} {
+			t.Run(th.Name("worker index", n), func(t *testing.T) {
+				in := th.FromRange(0, 100)
+				done := make(chan struct{})
+
+				busy := make([]atomic.Int64, n)
+				used := make([]atomic.Int64, n)
+
+				universalIndexedLoop(ord, in, done, n, func(worker int, x int, canWrite <-chan struct{}) {
+					if worker < 0 || worker >= n {
+						t.Errorf("worker index %d is out of range", worker)
+						<-canWrite
+						return
+					}
+
+					if busy[worker].Add(1) > 1 {
+						t.Errorf("worker index %d is used concurrently", worker)
+					}
+					used[worker].Add(1)
+
+					time.Sleep(10 * time.Millisecond)
+
+					busy[worker].Add(-1)
+					<-canWrite
+				})
+
+				<-done
+
+				var total int64
+				for i := range used {
+					if used[i].Load() == 0 {
+						t.Errorf("worker %d was never used", i)
+					}
+					total += used[i].Load()
+				}
+				th.ExpectValue(t, total, int64(100))
+			})
+		}
+	})
+}
+
 func TestForEach(t *testing.T) {
 	for _, n := range []int{1, 5} {
 		t.Run(th.Name("correctness", n), func(t *testing.T) {
@@ -132,3 +188,200 @@ func TestForEach(t *testing.T) {
 		})
 	}
 }
+
+func universalDynamicLoop[A, B any](ord bool, in <-chan A, done chan<- B, n int, f func(a A, canWrite <-chan struct{})) {
+	sem := make(chan struct{}, n)
+	acquire := func() { sem <- struct{}{} }
+	release := func() { <-sem }
+
+	if ord {
+		OrderedDynamicLoop(in, done, acquire, release, f)
+	} else {
+		canWrite := make(chan struct{})
+		close(canWrite)
+
+		DynamicLoop(in, done, acquire, release, func(a A) {
+			f(a, canWrite)
+		})
+	}
+}
+
+func TestDynamicLoop(t *testing.T) {
+	th.TestBothOrderings(t, func(t *testing.T, ord bool) {
+		for _, n := range []int{1, 5} {
+			t.Run(th.Name("concurrency", n), func(t *testing.T) {
+				in := th.FromRange(0, 100)
+				out := make(chan int)
+
+				monitor := th.NewConcurrencyMonitor(500 * time.Millisecond)
+
+				universalDynamicLoop(ord, in, out, n, func(x int, canWrite <-chan struct{}) {
+					monitor.Inc()
+					defer monitor.Dec()
+
+					<-canWrite
+
+					out <- x
+				})
+
+				Drain(out)
+
+				th.ExpectValue(t, monitor.Max(), n)
+			})
+
+			t.Run(th.Name("ordering", n), func(t *testing.T) {
+				in := th.FromRange(0, 20000)
+				out := make(chan int)
+
+				universalDynamicLoop(ord, in, out, n, func(x int, canWrite <-chan struct{}) {
+					<-canWrite
+					out <- x
+				})
+
+				outSlice := th.ToSlice(out)
+				th.ExpectValue(t, len(outSlice), 20000)
+				if ord || n == 1 {
+					th.ExpectSorted(t, outSlice)
+				}
+			})
+		}
+	})
+}
+
+func TestKeyedLoop(t *testing.T) {
+	for _, n := range []int{1, 5} {
+		t.Run(th.Name("concurrency", n), func(t *testing.T) {
+			in := th.FromRange(0, 100)
+			done := make(chan struct{})
+
+			monitor := th.NewConcurrencyMonitor(500 * time.Millisecond)
+
+			KeyedLoop(in, done, n, 10, func(x int) (int, bool) { return x, true }, func(x int) {
+				monitor.Inc()
+				defer monitor.Dec()
+			})
+
+			<-done
+			th.ExpectValue(t, monitor.Max(), n)
+		})
+
+		t.Run(th.Name("ordering within key", n), func(t *testing.T) {
+			in := th.FromRange(0, 10000)
+			out := make(chan int)
+
+			KeyedLoop(in, out, n, 10, func(x int) (int, bool) { return x % 7, true }, func(x int) {
+				out <- x
+			})
+
+			perKey := make(map[int][]int)
+			for x := range out {
+				perKey[x%7] = append(perKey[x%7], x)
+			}
+
+			th.ExpectValue(t, len(perKey), 7)
+			for _, items := range perKey {
+				th.ExpectSorted(t, items)
+			}
+		})
+	}
+
+	t.Run("slow key", func(t *testing.T) {
+		in := th.FromRange(0, 100)
+		out := make(chan int)
+		release := make(chan struct{})
+
+		// items 0, 10, 20... share the slow key, all others have distinct keys
+		key := func(x int) (int, bool) {
+			if x%10 == 0 {
+				return 0, true
+			}
+			return x, true
+		}
+
+		KeyedLoop(in, out, 2, 20, key, func(x int) {
+			if x == 0 {
+				<-release
+			}
+			out <- x
+		})
+
+		th.ExpectNotHang(t, 10*time.Second, func() {
+			for i := 0; i < 90; i++ {
+				x := <-out
+				if x%10 == 0 {
+					t.Errorf("item %d of the slow key is processed before the blocked one", x)
+				}
+			}
+		})
+
+		close(release)
+		outSlice := th.ToSlice(out)
+		th.ExpectSlice(t, outSlice, []int{0, 10, 20, 30, 40, 50, 60, 70, 80, 90})
+	})
+
+	t.Run("max queued", func(t *testing.T) {
+		in := make(chan int)
+		done := make(chan struct{})
+		release := make(chan struct{})
+
+		var read atomic.Int64
+		go func() {
+			defer close(in)
+			for i := 0; i < 100; i++ {
+				in <- i
+				read.Add(1)
+			}
+		}()
+
+		// single key, so all items except the one in flight are queued
+		KeyedLoop(in, done, 2, 5, func(x int) (int, bool) { return 0, true }, func(x int) {
+			<-release
+		})
+
+		time.Sleep(500 * time.Millisecond)
+		th.ExpectValue(t, read.Load(), int64(6))
+
+		close(release)
+		<-done
+		th.ExpectValue(t, read.Load(), int64(100))
+	})
+
+	t.Run("unkeyed", func(t *testing.T) {
+		in := th.FromRange(0, 100)
+		done := make(chan struct{})
+
+		var sum atomic.Int64
+		KeyedLoop(in, done, 5, 10, func(x int) (int, bool) { return 0, x%2 == 0 }, func(x int) {
+			sum.Add(int64(x))
+		})
+
+		<-done
+		th.ExpectValue(t, sum.Load(), int64(99*100/2))
+	})
+}
+
+func BenchmarkOrderedLoop(b *testing.B) {
+	for _, n := range []int{2, 8} {
+		b.Run(th.Name(n), func(b *testing.B) {
+			b.ReportAllocs()
+
+			in := make(chan int)
+			out := make(chan int)
+
+			OrderedLoop(in, out, n, func(x int, canWrite <-chan struct{}) {
+				<-canWrite
+				out <- x
+			})
+
+			go func() {
+				defer close(in)
+				for i := 0; i < b.N; i++ {
+					in <- i
+				}
+			}()
+
+			for range out {
+			}
+		})
+	}
+}
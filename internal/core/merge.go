@@ -1,7 +1,10 @@
 package core
 
 import (
+	"reflect"
 	"sync"
+
+	"github.com/destel/rill/internal/heap"
 )
 
 func fastMerge[A any](ins []<-chan A) <-chan A {
@@ -87,3 +90,154 @@ func Merge[A any](ins ...<-chan A) <-chan A {
 		return slowMerge(ins)
 	}
 }
+
+// Concat sends all items from the first input to the output, then all items from the second input, and so on.
+func Concat[A any](ins ...<-chan A) <-chan A {
+	switch len(ins) {
+	case 0:
+		return nil
+	case 1:
+		return ins[0]
+	}
+
+	out := make(chan A)
+	go func() {
+		defer close(out)
+		for _, in := range ins {
+			for x := range in {
+				out <- x
+			}
+		}
+	}()
+
+	return out
+}
+
+// MergeRoundRobin is similar to Merge, but reads from the inputs fairly: on each round, every input that has an item ready
+// gets to send exactly one item, in rotation. This way a fast input can't starve the others.
+func MergeRoundRobin[A any](ins ...<-chan A) <-chan A {
+	switch len(ins) {
+	case 0:
+		return nil
+	case 1:
+		return ins[0]
+	}
+
+	ins = append([]<-chan A(nil), ins...) // closed inputs are set to nil
+	remaining := len(ins)
+
+	cases := make([]reflect.SelectCase, len(ins))
+	for i, in := range ins {
+		cases[i] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(in)}
+	}
+
+	closeInput := func(i int) {
+		ins[i] = nil
+		cases[i].Chan = reflect.Value{}
+		remaining--
+	}
+
+	out := make(chan A)
+
+	go func() {
+		defer close(out)
+
+		for remaining > 0 {
+			// give each input a turn
+			received := false
+			for i, in := range ins {
+				if in == nil {
+					continue
+				}
+
+				select {
+				case a, ok := <-in:
+					if !ok {
+						closeInput(i)
+						continue
+					}
+					out <- a
+					received = true
+				default:
+				}
+			}
+
+			if received || remaining == 0 {
+				continue
+			}
+
+			// nothing is ready, wait for any input
+			i, v, ok := reflect.Select(cases)
+			if !ok {
+				closeInput(i)
+				continue
+			}
+			a, _ := v.Interface().(A) // comma-ok handles nil interface values
+			out <- a
+		}
+	}()
+
+	return out
+}
+
+type sequencedValue[A any] struct {
+	Value A
+	Seq   int64
+}
+
+// OrderedMerge merges the inputs and restores the order of items, using their sequence numbers.
+// Sequence numbers must start from 0. Items for which the seq function returns false are sent to the output right away.
+// At most window items are held in memory: when this limit is exceeded, the item with the smallest sequence number is emitted,
+// even if some of its predecessors are still missing. Such missing items are emitted as soon as they arrive.
+// Negative window means that the number of held items is unbounded. Zero window is treated as 1.
+func OrderedMerge[A any](ins []<-chan A, seq func(A) (int64, bool), window int) <-chan A {
+	in := Merge(ins...)
+	if in == nil {
+		return nil
+	}
+	if window == 0 {
+		window = 1
+	}
+
+	out := make(chan A)
+	go func() {
+		defer close(out)
+
+		var next int64
+		pending := heap.New(func(a, b sequencedValue[A]) bool {
+			return a.Seq < b.Seq
+		})
+
+		emit := func() {
+			v, _ := pending.Pop()
+			if v.Seq >= next {
+				next = v.Seq + 1
+			}
+			out <- v.Value
+		}
+
+		for a := range in {
+			s, ok := seq(a)
+			if !ok {
+				out <- a
+				continue
+			}
+
+			pending.Push(sequencedValue[A]{Value: a, Seq: s})
+
+			for {
+				top, ok := pending.Peek()
+				if !ok || (top.Seq > next && (window < 0 || pending.Len() <= window)) {
+					break
+				}
+				emit()
+			}
+		}
+
+		for pending.Len() > 0 {
+			emit()
+		}
+	}()
+
+	return out
+}
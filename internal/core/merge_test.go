@@ -50,3 +50,212 @@ func TestMerge(t *testing.T) {
 
 	}
 }
+
+func TestConcat(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		out := Concat[string]()
+		th.ExpectValue(t, out, nil)
+	})
+
+	for _, numChans := range []int{1, 3, 10} {
+		t.Run(th.Name("correctness", numChans), func(t *testing.T) {
+			ins := make([]<-chan int, numChans)
+
+			for i := 0; i < numChans; i++ {
+				ins[i] = th.FromRange(i*10, (i+1)*10)
+			}
+
+			out := Concat(ins...)
+			outSlice := th.ToSlice(out)
+
+			expectedSlice := make([]int, 0, numChans*10)
+			for i := 0; i < numChans*10; i++ {
+				expectedSlice = append(expectedSlice, i)
+			}
+
+			th.ExpectSlice(t, outSlice, expectedSlice)
+		})
+
+		t.Run(th.Name("nil hang", numChans), func(t *testing.T) {
+			ins := make([]<-chan int, numChans)
+
+			for i := 0; i < numChans-1; i++ {
+				ins[i] = th.FromRange(i*10, (i+1)*10)
+			}
+
+			// make last channel nil
+			ins[numChans-1] = nil
+
+			out := Concat(ins...)
+
+			th.ExpectNeverClosedChan(t, out, 1*time.Second)
+		})
+	}
+}
+
+func TestMergeRoundRobin(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		out := MergeRoundRobin[string]()
+		th.ExpectValue(t, out, nil)
+	})
+
+	for _, numChans := range []int{1, 3, 10} {
+		t.Run(th.Name("correctness", numChans), func(t *testing.T) {
+			ins := make([]<-chan int, numChans)
+
+			for i := 0; i < numChans; i++ {
+				ins[i] = th.FromRange(i*10, (i+1)*10)
+			}
+
+			out := MergeRoundRobin(ins...)
+			outSlice := th.ToSlice(out)
+
+			expectedSlice := make([]int, 0, numChans*10)
+			for i := 0; i < numChans*10; i++ {
+				expectedSlice = append(expectedSlice, i)
+			}
+
+			th.Sort(outSlice)
+			th.ExpectSlice(t, outSlice, expectedSlice)
+		})
+
+		t.Run(th.Name("nil hang", numChans), func(t *testing.T) {
+			ins := make([]<-chan int, numChans)
+
+			for i := 0; i < numChans-1; i++ {
+				ins[i] = th.FromRange(i*10, (i+1)*10)
+			}
+
+			// make last channel nil
+			ins[numChans-1] = nil
+
+			out := MergeRoundRobin(ins...)
+
+			th.ExpectNeverClosedChan(t, out, 1*time.Second)
+		})
+	}
+
+	t.Run("fairness", func(t *testing.T) {
+		makeReady := func(start, end int) <-chan int {
+			ch := make(chan int, end-start)
+			for i := start; i < end; i++ {
+				ch <- i
+			}
+			close(ch)
+			return ch
+		}
+
+		// both inputs have all items ready
+		fast := makeReady(0, 1000)
+		slow := makeReady(1000, 1010)
+
+		out := MergeRoundRobin(fast, slow)
+		outSlice := th.ToSlice(out)
+
+		// items must alternate until the slow input is exhausted
+		for i := 0; i < 20; i++ {
+			isSlow := outSlice[i] >= 1000
+			if isSlow != (i%2 == 1) {
+				t.Fatalf("unfair merge: %v", outSlice[:20])
+			}
+		}
+		th.ExpectValue(t, len(outSlice), 1010)
+	})
+
+	t.Run("interface values", func(t *testing.T) {
+		in1 := make(chan error)
+		in2 := make(chan error)
+
+		go func() {
+			defer close(in1)
+			defer close(in2)
+			in1 <- nil
+			in2 <- nil
+		}()
+
+		th.ExpectValue(t, len(th.ToSlice(MergeRoundRobin(in1, in2))), 2)
+	})
+}
+
+func TestOrderedMerge(t *testing.T) {
+	seq := func(x int) (int64, bool) {
+		if x < 0 {
+			return 0, false
+		}
+		return int64(x), true
+	}
+
+	t.Run("empty", func(t *testing.T) {
+		out := OrderedMerge[int](nil, seq, 10)
+		th.ExpectValue(t, out, nil)
+	})
+
+	t.Run("correctness", func(t *testing.T) {
+		// even and odd numbers in separate streams, with unsynchronized delays
+		evens := make(chan int)
+		odds := make(chan int)
+		go func() {
+			defer close(evens)
+			for i := 0; i < 1000; i += 2 {
+				evens <- i
+			}
+		}()
+		go func() {
+			defer close(odds)
+			for i := 1; i < 1000; i += 2 {
+				odds <- i
+				if i == 501 {
+					odds <- -1 // unsequenced item
+				}
+			}
+		}()
+
+		out := OrderedMerge([]<-chan int{evens, odds}, seq, 1000)
+		outSlice := th.ToSlice(out)
+
+		expected := make([]int, 0, 1001)
+		for i := 0; i < 1000; i++ {
+			expected = append(expected, i)
+		}
+
+		th.ExpectValue(t, len(outSlice), 1001)
+		var sequenced []int
+		for _, x := range outSlice {
+			if x >= 0 {
+				sequenced = append(sequenced, x)
+			}
+		}
+		th.ExpectSlice(t, sequenced, expected)
+	})
+
+	t.Run("window", func(t *testing.T) {
+		in := make(chan int, 10)
+		th.Send(in, 1, 2, 3, 4, 0, 6, 7, 5)
+		close(in)
+
+		out := OrderedMerge([]<-chan int{in}, seq, 2)
+
+		// 0 is missing for too long, so 1 is emitted first
+		th.ExpectSlice(t, th.ToSlice(out), []int{1, 2, 3, 4, 0, 5, 6, 7})
+	})
+
+	t.Run("zero window", func(t *testing.T) {
+		in := make(chan int, 10)
+		th.Send(in, 1, 0, 3, 4, 2)
+		close(in)
+
+		out := OrderedMerge([]<-chan int{in}, seq, 0)
+
+		// same as window of 1: a single out-of-order item can be held
+		th.ExpectSlice(t, th.ToSlice(out), []int{0, 1, 3, 4, 2})
+	})
+
+	t.Run("unbounded window", func(t *testing.T) {
+		in := make(chan int, 10)
+		th.Send(in, 5, 4, 3, 2, 1, 0, 6)
+		close(in)
+
+		out := OrderedMerge([]<-chan int{in}, seq, -1)
+		th.ExpectSlice(t, th.ToSlice(out), []int{0, 1, 2, 3, 4, 5, 6})
+	})
+}
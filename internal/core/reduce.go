@@ -19,15 +19,33 @@ func nonConcurrentReduce[A any](in <-chan A, f func(A, A) A) (A, bool) {
 }
 
 // Reduce reduces the input channel into a single value using the provided function,
-// using n goroutines for concurrency
+// using n goroutines for concurrency.
+// Unlike loops, it doesn't claim the input channel: callers that run it in a background goroutine
+// must do that themselves, so that the panic happens in the caller's goroutine.
 func Reduce[A any](in <-chan A, n int, f func(A, A) A) (A, bool) {
 	if in == nil {
 		<-in
 	}
 
+	return reduce(in, n, f, CustomLauncherFor(in))
+}
+
+// reduce is a version of Reduce that starts goroutines with the launch function, unless it's nil.
+// The launch function is passed explicitly, since recursive calls consume internal channels.
+func reduce[A any](in <-chan A, n int, f func(A, A) A, launch func(f func())) (A, bool) {
 	// Phase 0: Optimized non-concurrent case
 	if n == 1 {
-		return nonConcurrentReduce(in, f)
+		var res A
+		var ok bool
+		runLaunched(launch, func() {
+			res, ok = nonConcurrentReduce(in, f)
+		})
+		return res, ok
+	}
+
+	workerLaunch := launch
+	if workerLaunch == nil {
+		workerLaunch = goLaunch
 	}
 
 	// Phase 1: Each goroutine calculates its own partial result
@@ -36,14 +54,14 @@ func Reduce[A any](in <-chan A, n int, f func(A, A) A) (A, bool) {
 
 	for i := 0; i < n; i++ {
 		wg.Add(1)
-		go func() {
+		workerLaunch(func() {
 			defer wg.Done()
 
 			res, ok := nonConcurrentReduce(in, f)
 			if ok {
 				partialResults <- res
 			}
-		}()
+		})
 	}
 
 	go func() {
@@ -65,7 +83,7 @@ func Reduce[A any](in <-chan A, n int, f func(A, A) A) (A, bool) {
 	//   finish and send their partial results through the partialResults channel.
 	// - This implies that when the number of concurrent reductions increases by 1 at the next level, it decreases by at least 2 at the current level.
 	// - Consequently, the total number of concurrent reductions across all levels starts from n and decreases as data travels down the stack.
-	return Reduce(partialResults, n/2, f)
+	return reduce(partialResults, n/2, f, launch)
 }
 
 type keyValue[K, V any] struct {
@@ -86,33 +104,45 @@ func reduceIntoMap[K comparable, V any](m map[K]V, k K, v V, f func(V, V) V) {
 // First inout is converted into key-value pairs using the mapper function and nm goroutines.
 // If there are multiple values for the same key, they are reduced into a single value using the reducer function and nr goroutines.
 // The result is a map where each key is associated with a single value.
+// Like Reduce, it doesn't claim the input channel.
 func MapReduce[A any, K comparable, V any](in <-chan A, nm int, mapper func(A) (K, V), nr int, reducer func(V, V) V) map[K]V {
 	if in == nil {
 		<-in
 	}
 
+	// The launcher of the input channel is used for the reduce phase as well, since it consumes an internal channel
+	launch := CustomLauncherFor(in)
+
 	// Phase 1: Map
-	mapped := FilterMap(in, nm, func(a A) (keyValue[K, V], bool) {
+	mapped := make(chan keyValue[K, V])
+	loop(in, mapped, nm, func(_ int, a A) {
 		k, v := mapper(a)
-		return keyValue[K, V]{k, v}, true
+		mapped <- keyValue[K, V]{k, v}
 	})
 
 	// Phase 2.1: Optimized non-concurrent reduce. Build a final map right away.
 	if nr == 1 {
 		res := make(map[K]V)
-		for kv := range mapped {
-			reduceIntoMap(res, kv.Key, kv.Value, reducer)
-		}
+		runLaunched(launch, func() {
+			for kv := range mapped {
+				reduceIntoMap(res, kv.Key, kv.Value, reducer)
+			}
+		})
 		return res
 	}
 
+	workerLaunch := launch
+	if workerLaunch == nil {
+		workerLaunch = goLaunch
+	}
+
 	// Phase 2.2: Each goroutine builds its own partial map
 	partialResults := make(chan map[K]V, nr)
 	var wg sync.WaitGroup
 
 	for i := 0; i < nr; i++ {
 		wg.Add(1)
-		go func() {
+		workerLaunch(func() {
 			defer wg.Done()
 
 			res := make(map[K]V)
@@ -120,7 +150,7 @@ func MapReduce[A any, K comparable, V any](in <-chan A, nm int, mapper func(A) (
 				reduceIntoMap(res, kv.Key, kv.Value, reducer)
 			}
 			partialResults <- res
-		}()
+		})
 	}
 
 	go func() {
@@ -129,7 +159,7 @@ func MapReduce[A any, K comparable, V any](in <-chan A, nm int, mapper func(A) (
 	}()
 
 	// Phase 3: Merge all partial maps into a single one
-	res, _ := Reduce(partialResults, nr/2, func(m1, m2 map[K]V) map[K]V {
+	res, _ := reduce(partialResults, nr/2, func(m1, m2 map[K]V) map[K]V {
 		// Always merge smaller map into a bigger one
 		if len(m2) > len(m1) {
 			m1, m2 = m2, m1
@@ -139,7 +169,7 @@ func MapReduce[A any, K comparable, V any](in <-chan A, nm int, mapper func(A) (
 			reduceIntoMap(m1, k, v, reducer)
 		}
 		return m1
-	})
+	}, launch)
 
 	return res
 }
@@ -1,11 +1,16 @@
 package core
 
 func FilterMap[A, B any](in <-chan A, n int, f func(A) (B, bool)) <-chan B {
+	return BufferedFilterMap(in, n, 0, f)
+}
+
+// BufferedFilterMap is like FilterMap, but the output channel has the given capacity.
+func BufferedFilterMap[A, B any](in <-chan A, n int, size int, f func(A) (B, bool)) <-chan B {
 	if in == nil {
 		return nil
 	}
 
-	out := make(chan B)
+	out := make(chan B, size)
 
 	Loop(in, out, n, func(a A) {
 		b, keep := f(a)
@@ -18,11 +23,16 @@ func FilterMap[A, B any](in <-chan A, n int, f func(A) (B, bool)) <-chan B {
 }
 
 func OrderedFilterMap[A, B any](in <-chan A, n int, f func(A) (B, bool)) <-chan B {
+	return BufferedOrderedFilterMap(in, n, 0, f)
+}
+
+// BufferedOrderedFilterMap is like OrderedFilterMap, but the output channel has the given capacity.
+func BufferedOrderedFilterMap[A, B any](in <-chan A, n int, size int, f func(A) (B, bool)) <-chan B {
 	if in == nil {
 		return nil
 	}
 
-	out := make(chan B)
+	out := make(chan B, size)
 	OrderedLoop(in, out, n, func(a A, canWrite <-chan struct{}) {
 		y, keep := f(a)
 		<-canWrite
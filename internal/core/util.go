@@ -1,6 +1,268 @@
 package core
 
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/destel/rill/internal/ringbuffer"
+)
+
+// chanMeta holds the metadata attached to a channel with OnDrain, OnDrainStart, StopOnDrain, WithLauncher,
+// WithOrderObserver and Guard. Fields that were not set are nil.
+type chanMeta struct {
+	drainSink  any // func(A), where A is the channel's item type
+	drainStart atomic.Pointer[func()]
+	launch     func(func())
+	observer   OrderObserver
+	claimed    *atomic.Bool // set for guarded channels only
+}
+
+// metas holds the metadata of channels. Keys are receive-only channels, values are of type *chanMeta.
+var metas sync.Map
+
+// metaFor returns the metadata registered for the channel in, or nil.
+func metaFor[A any](in <-chan A) *chanMeta {
+	if m, ok := metas.Load(in); ok {
+		return m.(*chanMeta)
+	}
+	return nil
+}
+
+// withMeta returns a channel of exactly the same items as in. The metadata of in, if any, is forwarded to
+// the returned channel, and then updated by the set function.
+//
+// Forwarding means that stacking wrappers doesn't hide anything registered before:
+// a channel returned by WithLauncher(OnDrain(in, f), launch) both has a launcher and passes drained items to f.
+// Drain hooks are chained, while a launcher or an observer registered on the outer channel replaces the forwarded one.
+// The registration is removed only after the returned channel is closed, so that no item can be read from it without
+// the metadata being in place.
+func withMeta[A any](in <-chan A, set func(m *chanMeta)) <-chan A {
+	if in == nil {
+		return nil
+	}
+
+	m := new(chanMeta)
+	if parent := metaFor(in); parent != nil {
+		m.drainSink = parent.drainSink
+		m.drainStart.Store(parent.drainStart.Load())
+		m.launch = parent.launch
+		m.observer = parent.observer
+		m.claimed = parent.claimed
+	}
+	set(m)
+
+	out := make(chan A)
+	key := (<-chan A)(out)
+	metas.Store(key, m)
+
+	go func() {
+		defer metas.Delete(key)
+		defer close(out)
+
+		for a := range in {
+			out <- a
+		}
+	}()
+
+	return out
+}
+
+// OnDrain returns a channel of exactly the same items as in, and registers f to receive
+// every item that is discarded while draining the returned channel.
+// If a function is already registered for in, both are called.
+func OnDrain[A any](in <-chan A, f func(A)) <-chan A {
+	return withMeta(in, func(m *chanMeta) {
+		sink := f
+		if prev, ok := m.drainSink.(func(A)); ok {
+			sink = func(a A) {
+				f(a)
+				prev(a)
+			}
+		}
+		m.drainSink = sink
+	})
+}
+
+// DrainSink returns the function registered with OnDrain for the channel in, or a no-op function.
+// Items read from the channel and dropped during draining should be passed to it.
+//
+// The registration is removed as soon as the channel is closed, so consumers must resolve the sink
+// before they start reading. The channel can't be closed before all its items are received,
+// so the sink resolved this way is guaranteed to cover every item the consumer reads.
+func DrainSink[A any](in <-chan A) func(A) {
+	if m := metaFor(in); m != nil && m.drainSink != nil {
+		return m.drainSink.(func(A))
+	}
+	return func(A) {}
+}
+
+// OnDrainStart returns a channel of exactly the same items as in, and registers f to be called once,
+// when draining of the returned channel begins. If a function is already registered for in, both are called.
+func OnDrainStart[A any](in <-chan A, f func()) <-chan A {
+	return withMeta(in, func(m *chanMeta) {
+		var once sync.Once
+		prev := m.drainStart.Load()
+		hook := func() {
+			once.Do(f)
+			if prev != nil {
+				(*prev)()
+			}
+		}
+		m.drainStart.Store(&hook)
+	})
+}
+
+// StopOnDrain returns a channel that is closed when draining of ch begins.
+// Sources use it to stop producing items that nobody is going to consume.
+// The release function must be called before ch is closed.
+// If a function is already registered for ch with OnDrainStart, both are called.
+func StopOnDrain[A any](ch <-chan A) (stop <-chan struct{}, release func()) {
+	stopCh := make(chan struct{})
+	var once sync.Once
+	hook := func() {
+		once.Do(func() { close(stopCh) })
+	}
+
+	// ch may already have metadata, for example when it's returned by OnDrain
+	v, loaded := metas.LoadOrStore(ch, new(chanMeta))
+	m := v.(*chanMeta)
+	if prev := m.drainStart.Load(); prev != nil {
+		own := hook
+		hook = func() {
+			own()
+			(*prev)()
+		}
+	}
+	m.drainStart.Store(&hook)
+
+	return stopCh, func() {
+		if loaded {
+			m.drainStart.CompareAndSwap(&hook, nil)
+			return
+		}
+		metas.Delete(ch)
+	}
+}
+
+// NotifyDrainStart calls the function registered for the channel with OnDrainStart or StopOnDrain, if any.
+// Drain calls it automatically. Functions that drain the channel in some other way, for example by discarding
+// items inside their own loops after an early termination, must call it themselves.
+func NotifyDrainStart[A any](in <-chan A) {
+	m := metaFor(in)
+	if m == nil {
+		return
+	}
+
+	if f := m.drainStart.Swap(nil); f != nil {
+		(*f)()
+	}
+}
+
+// WithLauncher returns a channel of exactly the same items as in, and registers the launch function, that is used
+// to start worker goroutines of all loops, ForEach, Reduce and MapReduce consuming the returned channel.
+func WithLauncher[A any](in <-chan A, launch func(f func())) <-chan A {
+	return withMeta(in, func(m *chanMeta) {
+		m.launch = launch
+	})
+}
+
+// LauncherFor returns the function registered with WithLauncher for the channel in, or a function that uses the go statement.
+func LauncherFor[A any](in <-chan A) func(f func()) {
+	if launch := CustomLauncherFor(in); launch != nil {
+		return launch
+	}
+	return goLaunch
+}
+
+// CustomLauncherFor returns the function registered with WithLauncher for the channel in, or nil.
+func CustomLauncherFor[A any](in <-chan A) func(f func()) {
+	if m := metaFor(in); m != nil {
+		return m.launch
+	}
+	return nil
+}
+
+func goLaunch(f func()) {
+	go f()
+}
+
+// runLaunched calls f in a goroutine started with the launch function, and waits for it to return.
+// If launch is nil, f is called directly, without starting a goroutine.
+func runLaunched(launch func(f func()), f func()) {
+	if launch == nil {
+		f()
+		return
+	}
+
+	done := make(chan struct{})
+	launch(func() {
+		defer close(done)
+		f()
+	})
+	<-done
+}
+
+// OrderObserver receives notifications from OrderedLoop about the progress of individual items.
+// Items are numbered sequentially starting from 0, in the order they were read from the input.
+// Methods are called concurrently from the worker goroutines.
+type OrderObserver interface {
+	// Started is called when a worker picks up the item.
+	Started(seq int64)
+	// Committed is called when the processing of the item is complete, including the write.
+	// Items are committed in order.
+	Committed(seq int64)
+}
+
+// WithOrderObserver returns a channel of exactly the same items as in, and registers the observer, that is notified
+// by OrderedLoop consuming the returned channel.
+func WithOrderObserver[A any](in <-chan A, o OrderObserver) <-chan A {
+	return withMeta(in, func(m *chanMeta) {
+		m.observer = o
+	})
+}
+
+// orderObserverFor returns the observer registered with WithOrderObserver for the channel in, or nil.
+func orderObserverFor[A any](in <-chan A) OrderObserver {
+	if m := metaFor(in); m != nil {
+		return m.observer
+	}
+	return nil
+}
+
+// Guard returns a channel of exactly the same items as in, that can be claimed by a single consumer only.
+// If in is itself guarded, both channels share the claim, so only one of them can be consumed.
+func Guard[A any](in <-chan A) <-chan A {
+	return withMeta(in, func(m *chanMeta) {
+		if m.claimed == nil {
+			m.claimed = new(atomic.Bool)
+		}
+	})
+}
+
+// Claim marks the channel returned by Guard as consumed. It panics if the channel has already been claimed.
+// For all other channels it's a no-op.
+func Claim[A any](in <-chan A) {
+	m := metaFor(in)
+	if m == nil || m.claimed == nil {
+		return
+	}
+
+	if !m.claimed.CompareAndSwap(false, true) {
+		panic("rill: stream is consumed more than once: it has already been passed to another function")
+	}
+}
+
 func Drain[A any](in <-chan A) {
+	NotifyDrainStart(in)
+
+	if m := metaFor(in); m != nil && m.drainSink != nil {
+		sink := m.drainSink.(func(A))
+		for a := range in {
+			sink(a)
+		}
+		return
+	}
+
 	for range in {
 	}
 }
@@ -9,7 +271,13 @@ func DrainNB[A any](in <-chan A) {
 	go Drain(in)
 }
 
+// Buffer returns a channel of the same items as in, holding up to size items in memory.
+// Negative size means that the buffer is unbounded.
 func Buffer[A any](in <-chan A, size int) <-chan A {
+	if size < 0 {
+		return infiniteBuffer(in)
+	}
+
 	// we use size-1 since 1 additional item is held on the stack (x variable)
 	out := make(chan A, size-1)
 
@@ -22,3 +290,123 @@ func Buffer[A any](in <-chan A, size int) <-chan A {
 
 	return out
 }
+
+// DroppingBuffer is like Buffer, but never blocks the upstream producer. When the buffer already holds size items,
+// it drops either the oldest buffered item or the incoming one, depending on the dropOldest flag.
+// Every dropped item is passed to the onDrop function. Sizes less than 1 are treated as 1.
+func DroppingBuffer[A any](in <-chan A, size int, dropOldest bool, onDrop func(A)) <-chan A {
+	if in == nil {
+		return nil
+	}
+	if size < 1 {
+		size = 1
+	}
+
+	out := make(chan A)
+	go func() {
+		defer close(out)
+
+		var buf ringbuffer.Buffer[A]
+
+		for {
+			next, hasNext := buf.Peek()
+
+			var out1 chan<- A
+			if hasNext {
+				out1 = out
+			} else if in == nil {
+				return
+			}
+
+			select {
+			case a, ok := <-in:
+				if !ok {
+					in = nil
+					continue
+				}
+
+				switch {
+				case buf.Len() < size:
+					buf.Write(a)
+				case dropOldest:
+					oldest, _ := buf.Read()
+					onDrop(oldest)
+					buf.Write(a)
+				default:
+					onDrop(a)
+				}
+
+			case out1 <- next:
+				buf.Discard()
+			}
+		}
+	}()
+
+	return out
+}
+
+type conflateSlot[A any, K comparable] struct {
+	Value A
+	Key   K
+	Keyed bool
+}
+
+// Conflate is an unbounded buffer that collapses queued items with the same key: when a new item arrives,
+// it replaces the queued item with the same key, keeping its position in the queue.
+// Items for which the key function returns false are never collapsed.
+func Conflate[A any, K comparable](in <-chan A, key func(A) (K, bool)) <-chan A {
+	if in == nil {
+		return nil
+	}
+
+	out := make(chan A)
+	go func() {
+		defer close(out)
+
+		var queue ringbuffer.Buffer[*conflateSlot[A, K]]
+		queued := make(map[K]*conflateSlot[A, K])
+
+		for {
+			next, hasNext := queue.Peek()
+
+			var out1 chan<- A
+			var nextValue A
+			if hasNext {
+				out1 = out
+				nextValue = next.Value
+			} else if in == nil {
+				return
+			}
+
+			select {
+			case a, ok := <-in:
+				if !ok {
+					in = nil
+					continue
+				}
+
+				k, keyed := key(a)
+				if keyed {
+					if slot, ok := queued[k]; ok {
+						slot.Value = a
+						continue
+					}
+				}
+
+				slot := &conflateSlot[A, K]{Value: a, Key: k, Keyed: keyed}
+				queue.Write(slot)
+				if keyed {
+					queued[k] = slot
+				}
+
+			case out1 <- nextValue:
+				queue.Discard()
+				if next.Keyed {
+					delete(queued, next.Key)
+				}
+			}
+		}
+	}()
+
+	return out
+}
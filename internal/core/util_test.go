@@ -1,6 +1,8 @@
 package core
 
 import (
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -13,6 +15,388 @@ func TestDrain(t *testing.T) {
 	th.ExpectDrainedChan(t, in)
 }
 
+func TestOnDrain(t *testing.T) {
+	t.Run("nil", func(t *testing.T) {
+		out := OnDrain[int](nil, func(int) {})
+		th.ExpectValue(t, out, nil)
+	})
+
+	t.Run("drain", func(t *testing.T) {
+		var drained []int
+		in := OnDrain(th.FromRange(0, 10), func(x int) {
+			drained = append(drained, x)
+		})
+
+		// consume some items normally
+		<-in
+		<-in
+
+		Drain(in)
+		th.ExpectSlice(t, drained, []int{2, 3, 4, 5, 6, 7, 8, 9})
+	})
+
+	t.Run("discard", func(t *testing.T) {
+		var discarded []int
+		in := OnDrain(th.FromRange(0, 10), func(x int) {
+			discarded = append(discarded, x)
+		})
+
+		discard := DrainSink(in)
+		for x := range in {
+			if x%2 == 0 {
+				discard(x)
+			}
+		}
+		th.ExpectSlice(t, discarded, []int{0, 2, 4, 6, 8})
+	})
+
+	t.Run("discard after close", func(t *testing.T) {
+		var discarded []int
+		in := OnDrain(th.FromRange(0, 3), func(x int) {
+			discarded = append(discarded, x)
+		})
+
+		discard := DrainSink(in)
+		items := th.ToSlice(in)
+		th.ExpectDrainedChan(t, in)
+
+		// the last item is discarded after the channel is closed and the registration is removed
+		discard(items[len(items)-1])
+		th.ExpectSlice(t, discarded, []int{2})
+	})
+
+	t.Run("no sink", func(t *testing.T) {
+		in := th.FromRange(0, 10)
+		DrainSink(in)(1)
+		Drain(in)
+		th.ExpectDrainedChan(t, in)
+	})
+
+	t.Run("stacked", func(t *testing.T) {
+		var inner, outer []int
+		in := OnDrain(th.FromRange(0, 5), func(x int) {
+			inner = append(inner, x)
+		})
+		in = WithLauncher(in, goLaunch)
+		in = OnDrain(in, func(x int) {
+			outer = append(outer, x)
+		})
+
+		Drain(in)
+		th.ExpectSlice(t, inner, []int{0, 1, 2, 3, 4})
+		th.ExpectSlice(t, outer, []int{0, 1, 2, 3, 4})
+	})
+}
+
+func TestOnDrainStart(t *testing.T) {
+	t.Run("nil", func(t *testing.T) {
+		out := OnDrainStart[int](nil, func() {})
+		th.ExpectValue(t, out, nil)
+	})
+
+	t.Run("drain", func(t *testing.T) {
+		calls := 0
+		in := OnDrainStart(th.FromRange(0, 10), func() {
+			calls++
+		})
+
+		// consume some items normally
+		<-in
+		<-in
+		th.ExpectValue(t, calls, 0)
+
+		Drain(in)
+		Drain(in)
+		th.ExpectValue(t, calls, 1)
+	})
+
+	t.Run("no drain", func(t *testing.T) {
+		calls := 0
+		in := OnDrainStart(th.FromRange(0, 10), func() {
+			calls++
+		})
+
+		th.ToSlice(in)
+		th.ExpectValue(t, calls, 0)
+	})
+
+	t.Run("stacked", func(t *testing.T) {
+		innerCalls, outerCalls := 0, 0
+		in := OnDrainStart(th.FromRange(0, 10), func() {
+			innerCalls++
+		})
+		in = Guard(in)
+		in = OnDrainStart(in, func() {
+			outerCalls++
+		})
+
+		Drain(in)
+		th.ExpectValue(t, innerCalls, 1)
+		th.ExpectValue(t, outerCalls, 1)
+	})
+}
+
+func TestStopOnDrain(t *testing.T) {
+	out := make(chan int)
+	stop, release := StopOnDrain((<-chan int)(out))
+
+	// infinite source
+	go func() {
+		defer close(out)
+		defer release()
+
+		for i := 0; ; i++ {
+			select {
+			case out <- i:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	<-out
+	<-out
+
+	th.ExpectNotHang(t, 1*time.Second, func() {
+		Drain(out)
+	})
+}
+
+func TestGuard(t *testing.T) {
+	expectPanic := func(t *testing.T, f func()) {
+		t.Helper()
+		defer func() {
+			if recover() == nil {
+				t.Errorf("expected panic")
+			}
+		}()
+		f()
+	}
+
+	t.Run("nil", func(t *testing.T) {
+		th.ExpectValue(t, Guard[int](nil), nil)
+	})
+
+	t.Run("single consumer", func(t *testing.T) {
+		in := Guard(th.FromRange(0, 10))
+		out := FilterMap(in, 3, func(x int) (int, bool) { return x, true })
+
+		outSlice := th.ToSlice(out)
+		th.Sort(outSlice)
+		th.ExpectSlice(t, outSlice, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9})
+	})
+
+	t.Run("second consumer", func(t *testing.T) {
+		in := Guard(th.FromRange(0, 10))
+		out := FilterMap(in, 3, func(x int) (int, bool) { return x, true })
+
+		expectPanic(t, func() {
+			OrderedFilterMap(in, 1, func(x int) (int, bool) { return x, true })
+		})
+
+		th.ExpectValue(t, len(th.ToSlice(out)), 10)
+	})
+
+	t.Run("draining is allowed", func(t *testing.T) {
+		in := Guard(th.FromRange(0, 10))
+		Claim(in)
+
+		th.ExpectNotHang(t, 1*time.Second, func() {
+			Drain(in)
+		})
+	})
+
+	t.Run("unguarded", func(t *testing.T) {
+		in := th.FromRange(0, 10)
+		Claim(in)
+		Claim(in)
+	})
+
+	t.Run("stacked", func(t *testing.T) {
+		in := Guard(th.FromRange(0, 10))
+		wrapped := WithLauncher(in, goLaunch)
+		out := FilterMap(wrapped, 3, func(x int) (int, bool) { return x, true })
+
+		expectPanic(t, func() {
+			Claim(in)
+		})
+
+		th.ExpectValue(t, len(th.ToSlice(out)), 10)
+	})
+}
+
+func TestWithLauncher(t *testing.T) {
+	t.Run("nil", func(t *testing.T) {
+		th.ExpectValue(t, WithLauncher[int](nil, func(f func()) { go f() }), nil)
+	})
+
+	th.TestBothOrderings(t, func(t *testing.T, ord bool) {
+		for _, n := range []int{1, 5} {
+			t.Run(th.Name("correctness", n), func(t *testing.T) {
+				var launched atomic.Int64
+				in := WithLauncher(th.FromRange(0, 100), func(f func()) {
+					launched.Add(1)
+					go f()
+				})
+
+				var out <-chan int
+				if ord {
+					out = OrderedFilterMap(in, n, func(x int) (int, bool) { return x, true })
+				} else {
+					out = FilterMap(in, n, func(x int) (int, bool) { return x, true })
+				}
+
+				outSlice := th.ToSlice(out)
+				th.Sort(outSlice)
+
+				th.ExpectValue(t, len(outSlice), 100)
+				th.ExpectValue(t, launched.Load(), int64(n))
+			})
+		}
+	})
+
+	t.Run("unaffected stages", func(t *testing.T) {
+		var launched atomic.Int64
+		in := WithLauncher(th.FromRange(0, 100), func(f func()) {
+			launched.Add(1)
+			go f()
+		})
+
+		out := FilterMap(in, 3, func(x int) (int, bool) { return x, true })
+		out = FilterMap(out, 3, func(x int) (int, bool) { return x, true })
+
+		th.ExpectValue(t, len(th.ToSlice(out)), 100)
+		th.ExpectValue(t, launched.Load(), int64(3))
+	})
+
+	for _, n := range []int{1, 5} {
+		t.Run(th.Name("for each", n), func(t *testing.T) {
+			var launched atomic.Int64
+			in := WithLauncher(th.FromRange(0, 100), func(f func()) {
+				launched.Add(1)
+				go f()
+			})
+
+			var sum atomic.Int64
+			ForEach(in, n, func(x int) {
+				sum.Add(int64(x))
+			})
+
+			th.ExpectValue(t, sum.Load(), int64(99*100/2))
+			th.ExpectValue(t, launched.Load(), int64(n))
+		})
+
+		t.Run(th.Name("reduce", n), func(t *testing.T) {
+			var launched atomic.Int64
+			in := WithLauncher(th.FromRange(0, 100), func(f func()) {
+				launched.Add(1)
+				go f()
+			})
+
+			res, ok := Reduce(in, n, func(x, y int) int { return x + y })
+
+			th.ExpectValue(t, ok, true)
+			th.ExpectValue(t, res, 99*100/2)
+			if launched.Load() < int64(n) {
+				t.Errorf("expected at least %d launches, got %d", n, launched.Load())
+			}
+		})
+
+		t.Run(th.Name("map reduce", n), func(t *testing.T) {
+			var launched atomic.Int64
+			in := WithLauncher(th.FromRange(0, 100), func(f func()) {
+				launched.Add(1)
+				go f()
+			})
+
+			res := MapReduce(in,
+				n, func(x int) (int, int) { return x % 2, x },
+				n, func(x, y int) int { return x + y },
+			)
+
+			th.ExpectValue(t, res[0]+res[1], 99*100/2)
+			// mapper and reducer workers
+			if launched.Load() < int64(2*n) {
+				t.Errorf("expected at least %d launches, got %d", 2*n, launched.Load())
+			}
+		})
+	}
+
+	th.TestBothOrderings(t, func(t *testing.T, ord bool) {
+		t.Run("dynamic loop", func(t *testing.T) {
+			var launched atomic.Int64
+			in := WithLauncher(th.FromRange(0, 100), func(f func()) {
+				launched.Add(1)
+				go f()
+			})
+
+			out := make(chan int)
+			if ord {
+				OrderedDynamicLoop(in, out, func() {}, func() {}, func(x int, canWrite <-chan struct{}) {
+					<-canWrite
+					out <- x
+				})
+			} else {
+				DynamicLoop(in, out, func() {}, func() {}, func(x int) {
+					out <- x
+				})
+			}
+
+			th.ExpectValue(t, len(th.ToSlice(out)), 100)
+			th.ExpectValue(t, launched.Load(), int64(100))
+		})
+	})
+}
+
+type testOrderObserver struct {
+	mu        sync.Mutex
+	started   map[int64]bool
+	committed []int64
+}
+
+func (o *testOrderObserver) Started(seq int64) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.started[seq] = true
+}
+
+func (o *testOrderObserver) Committed(seq int64) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if !o.started[seq] {
+		panic("committed before started")
+	}
+	o.committed = append(o.committed, seq)
+}
+
+func TestWithOrderObserver(t *testing.T) {
+	t.Run("nil", func(t *testing.T) {
+		th.ExpectValue(t, WithOrderObserver[int](nil, &testOrderObserver{}), nil)
+	})
+
+	for _, n := range []int{1, 5} {
+		t.Run(th.Name("correctness", n), func(t *testing.T) {
+			o := &testOrderObserver{started: make(map[int64]bool)}
+			in := WithOrderObserver(th.FromRange(0, 100), o)
+
+			out := OrderedFilterMap(in, n, func(x int) (int, bool) {
+				if x%10 == 0 {
+					time.Sleep(10 * time.Millisecond)
+				}
+				return x, true
+			})
+
+			outSlice := th.ToSlice(out)
+			th.ExpectSorted(t, outSlice)
+			th.ExpectValue(t, len(outSlice), 100)
+
+			th.ExpectValue(t, len(o.started), 100)
+			th.ExpectValue(t, len(o.committed), 100)
+			th.ExpectSorted(t, o.committed)
+		})
+	}
+}
+
 func TestDrainNB(t *testing.T) {
 	th.ExpectNotHang(t, 10*time.Second, func() {
 		in := make(chan int)
@@ -53,3 +437,114 @@ func TestBuffer(t *testing.T) {
 	inSlice := th.ToSlice(inBuf)
 	th.ExpectSlice(t, inSlice, []int{2, 4})
 }
+
+func TestBufferUnbounded(t *testing.T) {
+	in := make(chan int)
+	inBuf := Buffer(in, -1)
+
+	th.ExpectNotHang(t, 10*time.Second, func() {
+		// able to write without reading
+		for i := 0; i < 1000; i++ {
+			in <- i
+		}
+		close(in)
+	})
+
+	inSlice := th.ToSlice(inBuf)
+	th.ExpectValue(t, len(inSlice), 1000)
+	for i := range inSlice {
+		th.ExpectValue(t, inSlice[i], i)
+	}
+}
+
+func TestDroppingBuffer(t *testing.T) {
+	t.Run("nil", func(t *testing.T) {
+		out := DroppingBuffer[int](nil, 2, true, func(int) {})
+		th.ExpectValue(t, out, nil)
+	})
+
+	for _, dropOldest := range []bool{true, false} {
+		t.Run(th.Name("correctness", dropOldest), func(t *testing.T) {
+			in := make(chan int)
+
+			var dropped []int
+			out := DroppingBuffer(in, 3, dropOldest, func(x int) {
+				dropped = append(dropped, x)
+			})
+
+			th.ExpectNotHang(t, 10*time.Second, func() {
+				// able to write without reading
+				for i := 0; i < 10; i++ {
+					in <- i
+				}
+			})
+
+			// wait until the last item is accepted
+			time.Sleep(100 * time.Millisecond)
+			close(in)
+
+			outSlice := th.ToSlice(out)
+			if dropOldest {
+				th.ExpectSlice(t, outSlice, []int{7, 8, 9})
+				th.ExpectSlice(t, dropped, []int{0, 1, 2, 3, 4, 5, 6})
+			} else {
+				th.ExpectSlice(t, outSlice, []int{0, 1, 2})
+				th.ExpectSlice(t, dropped, []int{3, 4, 5, 6, 7, 8, 9})
+			}
+		})
+
+		t.Run(th.Name("zero size", dropOldest), func(t *testing.T) {
+			in := make(chan int)
+
+			var dropped []int
+			out := DroppingBuffer(in, 0, dropOldest, func(x int) {
+				dropped = append(dropped, x)
+			})
+
+			th.ExpectNotHang(t, 10*time.Second, func() {
+				for i := 1; i <= 5; i++ {
+					in <- i
+				}
+			})
+
+			// wait until the last item is accepted
+			time.Sleep(100 * time.Millisecond)
+			close(in)
+
+			outSlice := th.ToSlice(out)
+			if dropOldest {
+				th.ExpectSlice(t, outSlice, []int{5})
+				th.ExpectSlice(t, dropped, []int{1, 2, 3, 4})
+			} else {
+				th.ExpectSlice(t, outSlice, []int{1})
+				th.ExpectSlice(t, dropped, []int{2, 3, 4, 5})
+			}
+		})
+	}
+}
+
+func TestConflate(t *testing.T) {
+	t.Run("nil", func(t *testing.T) {
+		out := Conflate(nil, func(x int) (int, bool) { return x, true })
+		th.ExpectValue(t, out, nil)
+	})
+
+	t.Run("correctness", func(t *testing.T) {
+		in := make(chan int)
+		out := Conflate(in, func(x int) (int, bool) {
+			// 100+ values are never conflated
+			return x % 10, x < 100
+		})
+
+		th.ExpectNotHang(t, 10*time.Second, func() {
+			// able to write without reading
+			th.Send(in, 1, 2, 3, 11, 101, 12, 101, 21, 4)
+		})
+
+		// wait until the last item is accepted
+		time.Sleep(100 * time.Millisecond)
+		close(in)
+
+		th.ExpectSlice(t, th.ToSlice(out), []int{21, 12, 3, 101, 101, 4})
+	})
+}
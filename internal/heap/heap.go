@@ -0,0 +1,86 @@
+// Package heap provides a generic binary heap.
+package heap
+
+// Heap is a binary heap ordered by the less function: the smallest item is always on top.
+type Heap[T any] struct {
+	data []T
+	less func(a, b T) bool
+}
+
+func New[T any](less func(a, b T) bool) *Heap[T] {
+	return &Heap[T]{less: less}
+}
+
+func (h *Heap[T]) Len() int {
+	return len(h.data)
+}
+
+func (h *Heap[T]) Push(v T) {
+	h.data = append(h.data, v)
+	h.up(len(h.data) - 1)
+}
+
+// remove the smallest item
+func (h *Heap[T]) Pop() (T, bool) {
+	var zero T
+	if len(h.data) == 0 {
+		return zero, false
+	}
+
+	last := len(h.data) - 1
+	v := h.data[0]
+	h.data[0] = h.data[last]
+	h.data[last] = zero // let GC do its work
+	h.data = h.data[:last]
+	h.down(0)
+	return v, true
+}
+
+func (h *Heap[T]) Peek() (T, bool) {
+	if len(h.data) == 0 {
+		var zero T
+		return zero, false
+	}
+	return h.data[0], true
+}
+
+// replace the smallest item with v
+// this is more efficient than Pop followed by Push
+func (h *Heap[T]) ReplaceTop(v T) {
+	if len(h.data) == 0 {
+		h.Push(v)
+		return
+	}
+	h.data[0] = v
+	h.down(0)
+}
+
+func (h *Heap[T]) up(i int) {
+	for i > 0 {
+		parent := (i - 1) / 2
+		if !h.less(h.data[i], h.data[parent]) {
+			return
+		}
+		h.data[i], h.data[parent] = h.data[parent], h.data[i]
+		i = parent
+	}
+}
+
+func (h *Heap[T]) down(i int) {
+	n := len(h.data)
+	for {
+		smallest := i
+		left, right := 2*i+1, 2*i+2
+		if left < n && h.less(h.data[left], h.data[smallest]) {
+			smallest = left
+		}
+		if right < n && h.less(h.data[right], h.data[smallest]) {
+			smallest = right
+		}
+		if smallest == i {
+			return
+		}
+		h.data[i], h.data[smallest] = h.data[smallest], h.data[i]
+		i = smallest
+	}
+}
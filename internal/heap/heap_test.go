@@ -0,0 +1,59 @@
+package heap
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/destel/rill/internal/th"
+)
+
+func TestHeap(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		h := New(func(a, b int) bool { return a < b })
+
+		_, ok := h.Peek()
+		th.ExpectValue(t, ok, false)
+
+		_, ok = h.Pop()
+		th.ExpectValue(t, ok, false)
+	})
+
+	t.Run("correctness", func(t *testing.T) {
+		h := New(func(a, b int) bool { return a < b })
+
+		values := rand.Perm(1000)
+		for _, v := range values {
+			h.Push(v)
+		}
+		th.ExpectValue(t, h.Len(), 1000)
+
+		var popped []int
+		for h.Len() > 0 {
+			top, _ := h.Peek()
+			v, ok := h.Pop()
+			th.ExpectValue(t, ok, true)
+			th.ExpectValue(t, v, top)
+			popped = append(popped, v)
+		}
+
+		sort.Ints(values)
+		th.ExpectSlice(t, popped, values)
+	})
+
+	t.Run("replace top", func(t *testing.T) {
+		h := New(func(a, b int) bool { return a < b })
+		h.ReplaceTop(5) // works on empty heap
+		h.Push(3)
+		h.Push(7)
+
+		h.ReplaceTop(10)
+
+		var popped []int
+		for h.Len() > 0 {
+			v, _ := h.Pop()
+			popped = append(popped, v)
+		}
+		th.ExpectSlice(t, popped, []int{5, 7, 10})
+	})
+}
@@ -0,0 +1,261 @@
+package rill
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/destel/rill/internal/core"
+)
+
+// FromReader converts an io.Reader into a stream of tokens, using the split function, such as [bufio.ScanLines] or [bufio.ScanWords].
+// If split is nil, the reader is split into lines, with line terminators removed.
+// Read errors, including the [bufio.ErrTooLong] error for tokens longer than [bufio.MaxScanTokenSize],
+// are sent to the output stream as the last item.
+//
+//	resp, err := http.Get(url)
+//	if err != nil {
+//		return err
+//	}
+//	defer resp.Body.Close()
+//
+//	lines := rill.FromReader(resp.Body, nil)
+//
+// Once the output stream is drained or stopped with [Pipeline.StopSources], reading stops before the next token.
+// The reader is not closed by FromReader.
+func FromReader(r io.Reader, split bufio.SplitFunc) <-chan Try[string] {
+	scanner := bufio.NewScanner(r)
+	if split != nil {
+		scanner.Split(split)
+	}
+
+	return FromScanner(scanner)
+}
+
+// FromScanner converts a preconfigured [bufio.Scanner] into a stream of tokens. It's a more flexible version of [FromReader],
+// that allows to customize the split function and the maximum token size:
+//
+//	scanner := bufio.NewScanner(f)
+//	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024) // allow lines up to 10 MiB
+//
+//	lines := rill.FromScanner(scanner)
+//
+// Tokens are scanned one at a time, only after the previous one has been consumed, so the scanner never runs ahead
+// of a slow consumer. Scanner errors, including [bufio.ErrTooLong], are sent to the output stream as the last item.
+// Once the output stream is drained or stopped with [Pipeline.StopSources], scanning stops before the next token,
+// and the goroutine exits. The scanner must not be used by anyone else until the output stream is closed.
+func FromScanner(scanner *bufio.Scanner) <-chan Try[string] {
+	out := make(chan Try[string])
+	stop, release := core.StopOnDrain((<-chan Try[string])(out))
+
+	go func() {
+		defer close(out)
+		defer release()
+
+		for scanner.Scan() {
+			select {
+			case out <- Try[string]{Value: scanner.Text()}:
+			case <-stop:
+				return
+			}
+		}
+
+		if err := scanner.Err(); err != nil {
+			select {
+			case out <- Try[string]{Error: err}:
+			case <-stop:
+			}
+		}
+	}()
+
+	return out
+}
+
+// ToWriter writes all items from the input stream to w, one after another, and returns the total number of bytes written.
+// Items are written as is, without any separators. If w has a Flush method, such as [bufio.Writer], it's called
+// after the last item is written, and also before returning early because of an error.
+//
+// This is a blocking ordered function that processes items sequentially.
+// It stops on the first error, either from the stream or from writing, and returns it.
+// See the package documentation for more information on blocking ordered functions and error handling.
+func ToWriter[A ~string | ~[]byte](in <-chan Try[A], w io.Writer) (int64, error) {
+	core.Claim(in)
+
+	var written int64
+	err := func() error {
+		for a := range in {
+			if a.Error != nil {
+				return a.Error
+			}
+
+			n, err := w.Write([]byte(a.Value))
+			written += int64(n)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}()
+
+	if err != nil {
+		DrainNB(in)
+	}
+
+	if f, ok := w.(interface{ Flush() error }); ok {
+		if flushErr := f.Flush(); err == nil {
+			err = flushErr
+		}
+	}
+
+	return written, err
+}
+
+// maxJSONLineSize is the maximum length of a line accepted by DecodeJSONL.
+const maxJSONLineSize = 16 << 20
+
+// DecodeJSONL parses newline-delimited JSON (JSON Lines) from r into a stream of values of type A.
+// Empty lines are skipped. Lines that can't be decoded are sent to the output stream as errors, annotated with the line number,
+// and decoding continues with the next line. This way malformed lines can be skipped or logged with [Catch]:
+//
+//	events := rill.DecodeJSONL[Event](file)
+//	events = rill.Catch(events, 1, func(err error) error {
+//		log.Println("skipping malformed line:", err)
+//		return nil
+//	})
+//
+// Lines can be up to 16 MiB long. Read errors are sent to the output stream as the last item.
+// Once the output stream is drained or stopped with [Pipeline.StopSources], reading stops before the next line.
+// The reader is not closed by DecodeJSONL.
+func DecodeJSONL[A any](r io.Reader) <-chan Try[A] {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxJSONLineSize)
+
+	lines := FromScanner(scanner)
+
+	var lineNum int
+	out := OrderedFilterMap(lines, 1, func(line string) (A, bool, error) {
+		lineNum++
+
+		var a A
+		if strings.TrimSpace(line) == "" {
+			return a, false, nil
+		}
+
+		if err := json.Unmarshal([]byte(line), &a); err != nil {
+			return a, false, fmt.Errorf("rill: jsonl line %d: %w", lineNum, err)
+		}
+		return a, true, nil
+	})
+
+	// stop reading as soon as the output is drained
+	return core.OnDrainStart(out, func() {
+		core.NotifyDrainStart(lines)
+	})
+}
+
+// EncodeJSONL writes all items from the input stream to w as newline-delimited JSON (JSON Lines), one item per line.
+// Writes are buffered internally, and the buffer is flushed before EncodeJSONL returns. If w has a Flush method,
+// such as [bufio.Writer], it's called as well.
+//
+// This is a blocking ordered function that processes items sequentially.
+// It stops on the first error, either from the stream, from encoding or from writing, and returns it.
+// See the package documentation for more information on blocking ordered functions and error handling.
+func EncodeJSONL[A any](in <-chan Try[A], w io.Writer) error {
+	core.Claim(in)
+
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+
+	err := func() error {
+		for a := range in {
+			if a.Error != nil {
+				return a.Error
+			}
+			if err := enc.Encode(a.Value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}()
+
+	if err != nil {
+		DrainNB(in)
+	}
+
+	if flushErr := bw.Flush(); err == nil {
+		err = flushErr
+	}
+
+	if f, ok := w.(interface{ Flush() error }); ok {
+		if flushErr := f.Flush(); err == nil {
+			err = flushErr
+		}
+	}
+
+	return err
+}
+
+// FromRecvFunc converts a Recv-style streaming interface, such as a gRPC client or server stream, into a stream.
+// The recv function is called repeatedly until it returns an error. The [io.EOF] error means that the remote side has finished
+// sending, and results in the output stream being closed. Any other error is sent to the output stream as the last item.
+//
+//	stream, err := client.ListUsers(ctx, req)
+//	if err != nil {
+//		return err
+//	}
+//
+//	users := rill.FromRecvFunc(stream.Recv)
+//
+// Once the output stream is drained or stopped with [Pipeline.StopSources], FromRecvFunc stops calling recv.
+// A call that is already blocked can't be interrupted though. To abort it, cancel the context of the RPC.
+func FromRecvFunc[A any](recv func() (A, error)) <-chan Try[A] {
+	out := make(chan Try[A])
+	stop, release := core.StopOnDrain((<-chan Try[A])(out))
+
+	go func() {
+		defer close(out)
+		defer release()
+
+		for {
+			a, err := recv()
+			if err == io.EOF {
+				return
+			}
+
+			select {
+			case out <- Try[A]{Value: a, Error: err}:
+			case <-stop:
+				return
+			}
+
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	return out
+}
+
+// ToSendFunc is the counterpart of [FromRecvFunc]. It calls the send function for each item from the input stream,
+// for example to write items to a gRPC client or server stream. Closing the sending side of the RPC,
+// such as calling CloseSend on a gRPC client stream, is left to the caller.
+//
+//	stream, err := client.UploadUsers(ctx)
+//	if err != nil {
+//		return err
+//	}
+//
+//	if err := rill.ToSendFunc(users, stream.Send); err != nil {
+//		return err
+//	}
+//	summary, err := stream.CloseAndRecv()
+//
+// This is a blocking ordered function that processes items sequentially.
+// It stops on the first error, either from the stream or from send, and returns it.
+// See the package documentation for more information on blocking ordered functions and error handling.
+func ToSendFunc[A any](in <-chan Try[A], send func(A) error) error {
+	return ForEach(in, 1, send)
+}
@@ -0,0 +1,357 @@
+package rill
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/destel/rill/internal/th"
+)
+
+// errReader returns the data, and then fails with the error.
+type errReader struct {
+	data io.Reader
+	err  error
+}
+
+func (r *errReader) Read(p []byte) (int, error) {
+	n, err := r.data.Read(p)
+	if err == io.EOF {
+		return n, r.err
+	}
+	return n, err
+}
+
+func TestFromReader(t *testing.T) {
+	t.Run("lines", func(t *testing.T) {
+		out := FromReader(strings.NewReader("a\nbb\r\n\nccc"), nil)
+
+		outSlice, err := ToSlice(out)
+		th.ExpectNoError(t, err)
+		th.ExpectSlice(t, outSlice, []string{"a", "bb", "", "ccc"})
+	})
+
+	t.Run("words", func(t *testing.T) {
+		out := FromReader(strings.NewReader("  hello big\n world "), bufio.ScanWords)
+
+		outSlice, err := ToSlice(out)
+		th.ExpectNoError(t, err)
+		th.ExpectSlice(t, outSlice, []string{"hello", "big", "world"})
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		outSlice, err := ToSlice(FromReader(strings.NewReader(""), nil))
+		th.ExpectNoError(t, err)
+		th.ExpectValue(t, len(outSlice), 0)
+	})
+
+	t.Run("read error", func(t *testing.T) {
+		r := &errReader{data: strings.NewReader("a\nb\n"), err: errors.New("broken pipe")}
+
+		outSlice, errs := toSliceAndErrors(FromReader(r, nil))
+		th.ExpectSlice(t, outSlice, []string{"a", "b"})
+		th.ExpectSlice(t, errs, []string{"broken pipe"})
+	})
+
+	t.Run("stop on drain", func(t *testing.T) {
+		var sb strings.Builder
+		for i := 0; i < 10000; i++ {
+			sb.WriteString("line\n")
+		}
+
+		r := strings.NewReader(sb.String())
+
+		_, _, err := First(FromReader(r, nil))
+		th.ExpectNoError(t, err)
+
+		time.Sleep(500 * time.Millisecond)
+		if r.Len() == 0 {
+			t.Errorf("reader was consumed to the end")
+		}
+	})
+}
+
+func TestFromScanner(t *testing.T) {
+	t.Run("custom buffer", func(t *testing.T) {
+		long := strings.Repeat("x", 100_000) // longer than bufio.MaxScanTokenSize
+
+		scanner := bufio.NewScanner(strings.NewReader("a\n" + long + "\nb"))
+		scanner.Buffer(nil, 1_000_000)
+
+		outSlice, err := ToSlice(FromScanner(scanner))
+		th.ExpectNoError(t, err)
+		th.ExpectSlice(t, outSlice, []string{"a", long, "b"})
+	})
+
+	t.Run("too long", func(t *testing.T) {
+		scanner := bufio.NewScanner(strings.NewReader("a\nbbbbbbbbbb\nc"))
+		scanner.Buffer(nil, 5)
+
+		outSlice, errs := toSliceAndErrors(FromScanner(scanner))
+		th.ExpectSlice(t, outSlice, []string{"a"})
+		th.ExpectSlice(t, errs, []string{bufio.ErrTooLong.Error()})
+	})
+
+	t.Run("backpressure", func(t *testing.T) {
+		r := strings.NewReader(strings.Repeat("line\n", 10000))
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 16), 16) // make the scanner read in small chunks
+
+		out := FromScanner(scanner)
+		<-out
+
+		time.Sleep(500 * time.Millisecond)
+		if r.Len() < 40000 {
+			t.Errorf("scanner ran ahead of the consumer: %d bytes left", r.Len())
+		}
+
+		Drain(out)
+	})
+}
+
+// failingWriter accepts up to limit bytes, and then fails.
+type failingWriter struct {
+	limit int
+	buf   strings.Builder
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if w.buf.Len()+len(p) > w.limit {
+		n := w.limit - w.buf.Len()
+		w.buf.Write(p[:n])
+		return n, errors.New("disk full")
+	}
+	return w.buf.Write(p)
+}
+
+func TestToWriter(t *testing.T) {
+	t.Run("strings", func(t *testing.T) {
+		var sb strings.Builder
+		n, err := ToWriter(FromSlice([]string{"hello", " ", "world"}, nil), &sb)
+
+		th.ExpectNoError(t, err)
+		th.ExpectValue(t, n, int64(11))
+		th.ExpectValue(t, sb.String(), "hello world")
+	})
+
+	t.Run("bytes with flush", func(t *testing.T) {
+		var sb strings.Builder
+		bw := bufio.NewWriter(&sb)
+
+		n, err := ToWriter(FromSlice([][]byte{[]byte("ab"), []byte("cd")}, nil), bw)
+
+		th.ExpectNoError(t, err)
+		th.ExpectValue(t, n, int64(4))
+		th.ExpectValue(t, sb.String(), "abcd")
+	})
+
+	t.Run("stream error", func(t *testing.T) {
+		in := FromChan(th.FromRange(0, 1000), nil)
+		in = replaceWithError(in, 3, errors.New("err3"))
+		lines := OrderedMap(in, 1, func(x int) (string, error) { return "x", nil })
+
+		var sb strings.Builder
+		bw := bufio.NewWriter(&sb)
+
+		n, err := ToWriter(lines, bw)
+		th.ExpectError(t, err, "err3")
+		th.ExpectValue(t, n, int64(3))
+		th.ExpectValue(t, sb.String(), "xxx") // flushed despite the error
+
+		time.Sleep(1 * time.Second)
+		th.ExpectDrainedChan(t, in)
+	})
+
+	t.Run("write error", func(t *testing.T) {
+		w := &failingWriter{limit: 5}
+		n, err := ToWriter(FromSlice([]string{"abc", "def", "ghi"}, nil), w)
+
+		th.ExpectError(t, err, "disk full")
+		th.ExpectValue(t, n, int64(5))
+		th.ExpectValue(t, w.buf.String(), "abcde")
+	})
+}
+
+func TestDecodeJSONL(t *testing.T) {
+	type event struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+
+	t.Run("correctness", func(t *testing.T) {
+		data := `{"id": 1, "name": "a"}
+
+{"id": 2, "name": "b"}
+not json
+{"id": 3, "name": "c"}`
+
+		outSlice, errs := toSliceAndErrors(DecodeJSONL[event](strings.NewReader(data)))
+		th.ExpectSlice(t, outSlice, []event{{1, "a"}, {2, "b"}, {3, "c"}})
+
+		th.ExpectValue(t, len(errs), 1)
+		if !strings.HasPrefix(errs[0], "rill: jsonl line 4: ") {
+			t.Errorf("unexpected error: %s", errs[0])
+		}
+	})
+
+	t.Run("long lines", func(t *testing.T) {
+		long := strings.Repeat("x", 100000)
+		data := `{"id": 1, "name": "` + long + `"}` + "\n"
+
+		outSlice, err := ToSlice(DecodeJSONL[event](strings.NewReader(data)))
+		th.ExpectNoError(t, err)
+		th.ExpectValue(t, len(outSlice), 1)
+		th.ExpectValue(t, len(outSlice[0].Name), 100000)
+	})
+
+	t.Run("stop on drain", func(t *testing.T) {
+		var sb strings.Builder
+		for i := 0; i < 10000; i++ {
+			sb.WriteString(`{"id": 1}` + "\n")
+		}
+
+		r := strings.NewReader(sb.String())
+
+		_, _, err := First(DecodeJSONL[event](r))
+		th.ExpectNoError(t, err)
+
+		time.Sleep(500 * time.Millisecond)
+		if r.Len() == 0 {
+			t.Errorf("reader was consumed to the end")
+		}
+	})
+
+	t.Run("type error", func(t *testing.T) {
+		_, err := ToSlice(DecodeJSONL[event](strings.NewReader(`{"id": "one"}`)))
+
+		var typeErr *json.UnmarshalTypeError
+		if !errors.As(err, &typeErr) {
+			t.Errorf("expected UnmarshalTypeError, got %v", err)
+		}
+	})
+}
+
+func TestEncodeJSONL(t *testing.T) {
+	type event struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+
+	t.Run("correctness", func(t *testing.T) {
+		var sb strings.Builder
+		err := EncodeJSONL(FromSlice([]event{{1, "a"}, {2, "b"}}, nil), &sb)
+
+		th.ExpectNoError(t, err)
+		th.ExpectValue(t, sb.String(), `{"id":1,"name":"a"}`+"\n"+`{"id":2,"name":"b"}`+"\n")
+	})
+
+	t.Run("round trip", func(t *testing.T) {
+		var sb strings.Builder
+		bw := bufio.NewWriter(&sb)
+
+		in := OrderedMap(FromChan(th.FromRange(0, 1000), nil), 1, func(x int) (event, error) {
+			return event{ID: x, Name: "n"}, nil
+		})
+		th.ExpectNoError(t, EncodeJSONL(in, bw))
+
+		outSlice, err := ToSlice(DecodeJSONL[event](strings.NewReader(sb.String())))
+		th.ExpectNoError(t, err)
+		th.ExpectValue(t, len(outSlice), 1000)
+		th.ExpectValue(t, outSlice[999], event{ID: 999, Name: "n"})
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		in := FromSlice([]any{1, "two", func() {}, 4}, nil)
+
+		var sb strings.Builder
+		err := EncodeJSONL(in, &sb)
+
+		var typeErr *json.UnsupportedTypeError
+		if !errors.As(err, &typeErr) {
+			t.Errorf("expected UnsupportedTypeError, got %v", err)
+		}
+		th.ExpectValue(t, sb.String(), "1\n\"two\"\n") // items before the error are flushed
+	})
+
+	t.Run("write error", func(t *testing.T) {
+		w := &failingWriter{limit: 5}
+		err := EncodeJSONL(FromSlice([]int{1, 2, 3}, nil), w)
+		th.ExpectError(t, err, "disk full")
+	})
+}
+
+func TestFromRecvFunc(t *testing.T) {
+	// recvFrom returns a recv function that yields the items and then fails with err
+	recvFrom := func(items []int, err error) (func() (int, error), *atomic.Int64) {
+		var calls atomic.Int64
+		return func() (int, error) {
+			i := int(calls.Add(1)) - 1
+			if i < len(items) {
+				return items[i], nil
+			}
+			return 0, err
+		}, &calls
+	}
+
+	t.Run("eof", func(t *testing.T) {
+		recv, _ := recvFrom([]int{1, 2, 3}, io.EOF)
+
+		outSlice, err := ToSlice(FromRecvFunc(recv))
+		th.ExpectNoError(t, err)
+		th.ExpectSlice(t, outSlice, []int{1, 2, 3})
+	})
+
+	t.Run("error", func(t *testing.T) {
+		recv, calls := recvFrom([]int{1, 2}, errors.New("connection reset"))
+
+		outSlice, errs := toSliceAndErrors(FromRecvFunc(recv))
+		th.ExpectSlice(t, outSlice, []int{1, 2})
+		th.ExpectSlice(t, errs, []string{"connection reset"})
+		th.ExpectValue(t, calls.Load(), int64(3)) // recv is not called after an error
+	})
+
+	t.Run("stop on drain", func(t *testing.T) {
+		recv, calls := recvFrom(make([]int, 1000), io.EOF)
+
+		_, _, err := First(FromRecvFunc(recv))
+		th.ExpectNoError(t, err)
+
+		time.Sleep(1 * time.Second)
+		if calls.Load() > 10 {
+			t.Errorf("recv was not stopped: %d calls", calls.Load())
+		}
+	})
+}
+
+func TestToSendFunc(t *testing.T) {
+	t.Run("correctness", func(t *testing.T) {
+		var sent []int
+		err := ToSendFunc(FromChan(th.FromRange(0, 10), nil), func(x int) error {
+			sent = append(sent, x)
+			return nil
+		})
+
+		th.ExpectNoError(t, err)
+		th.ExpectSlice(t, sent, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9})
+	})
+
+	t.Run("send error", func(t *testing.T) {
+		in := FromChan(th.FromRange(0, 1000), nil)
+
+		err := ToSendFunc(in, func(x int) error {
+			if x == 5 {
+				return io.EOF
+			}
+			return nil
+		})
+
+		th.ExpectValue(t, err, io.EOF)
+		time.Sleep(1 * time.Second)
+		th.ExpectDrainedChan(t, in)
+	})
+}
@@ -4,10 +4,13 @@ package rill
 
 import (
 	"iter"
+
+	"github.com/destel/rill/internal/core"
 )
 
 // FromSeq converts an iterator into a stream.
 // If err is not nil function returns a stream with a single error.
+// Iteration stops as soon as the stream starts to be drained, for example after an early return of a downstream blocking function.
 //
 // Such function signature allows concise wrapping of functions that return an
 // iterator and an error:
@@ -26,27 +29,44 @@ func FromSeq[A any](seq iter.Seq[A], err error) <-chan Try[A] {
 	}
 
 	out := make(chan Try[A])
+	stop, release := core.StopOnDrain((<-chan Try[A])(out))
+
 	go func() {
+		defer close(out)
+		defer release()
+
 		for val := range seq {
-			out <- Wrap(val, nil)
+			select {
+			case out <- Wrap(val, nil):
+			case <-stop:
+				return
+			}
 		}
-		close(out)
 	}()
 	return out
 }
 
 // FromSeq2 converts an iterator of value-error pairs into a stream.
+// Iteration stops as soon as the stream starts to be drained.
 func FromSeq2[A any](seq iter.Seq2[A, error]) <-chan Try[A] {
 	if seq == nil {
 		return nil
 	}
 
 	out := make(chan Try[A])
+	stop, release := core.StopOnDrain((<-chan Try[A])(out))
+
 	go func() {
+		defer close(out)
+		defer release()
+
 		for val, err := range seq {
-			out <- Wrap(val, err)
+			select {
+			case out <- Wrap(val, err):
+			case <-stop:
+				return
+			}
 		}
-		close(out)
 	}()
 	return out
 }
@@ -60,6 +80,8 @@ func FromSeq2[A any](seq iter.Seq2[A, error]) <-chan Try[A] {
 //
 // See the package documentation for more information on blocking ordered functions.
 func ToSeq2[A any](in <-chan Try[A]) iter.Seq2[A, error] {
+	core.Claim(in)
+
 	return func(yield func(A, error) bool) {
 		defer DrainNB(in)
 		for x := range in {
@@ -69,3 +91,140 @@ func ToSeq2[A any](in <-chan Try[A]) iter.Seq2[A, error] {
 		}
 	}
 }
+
+// ToBatchSeq converts an input stream into an iterator of batches of up to size values, paired with errors.
+// This allows consumers that want a pull-based batch iterator, such as bulk writers or database copy protocols,
+// to integrate with a stream directly.
+//
+// Errors are yielded as separate (nil, err) pairs, preserving their position in the stream:
+// a partial batch collected before an error is yielded first. Sizes less than 1 are treated as 1.
+//
+// This is a blocking ordered function that processes items sequentially.
+// Like [ToSeq2], it does not return on the first encountered error, so all error handling, if needed,
+// should be done inside the iterator (for-range loop body).
+//
+// See the package documentation for more information on blocking ordered functions.
+func ToBatchSeq[A any](in <-chan Try[A], size int) iter.Seq2[[]A, error] {
+	core.Claim(in)
+
+	if size < 1 {
+		size = 1
+	}
+
+	return func(yield func([]A, error) bool) {
+		defer DrainNB(in)
+
+		batch := make([]A, 0, size)
+		for x := range in {
+			if x.Error != nil {
+				if len(batch) > 0 {
+					if !yield(batch, nil) {
+						return
+					}
+					batch = make([]A, 0, size)
+				}
+
+				if !yield(nil, x.Error) {
+					return
+				}
+				continue
+			}
+
+			batch = append(batch, x.Value)
+			if len(batch) >= size {
+				if !yield(batch, nil) {
+					return
+				}
+				batch = make([]A, 0, size)
+			}
+		}
+
+		if len(batch) > 0 {
+			yield(batch, nil)
+		}
+	}
+}
+
+// FlatMapSeq is similar to [FlatMap], but expands each item of the input stream into an iterator of value-error pairs,
+// returned by the function f. This allows to write sub-streams, such as paginated API responses, as plain iterators,
+// without managing channels manually:
+//
+//	users := rill.FlatMapSeq(departments, 5, func(dep string) iter.Seq2[User, error] {
+//		return func(yield func(User, error) bool) {
+//			for page := 0; ; page++ {
+//				users, err := api.ListUsers(dep, page)
+//				if err != nil {
+//					yield(User{}, err)
+//					return
+//				}
+//				for _, u := range users {
+//					if !yield(u, nil) {
+//						return
+//					}
+//				}
+//				if len(users) == 0 {
+//					return
+//				}
+//			}
+//		}
+//	})
+//
+// Iterators are always consumed until the end, so errors don't stop the iteration by themselves.
+// If f returns nil, the item is expanded into nothing.
+//
+// This is a non-blocking unordered function that processes items concurrently using n goroutines.
+// An ordered version of this function, [OrderedFlatMapSeq], is also available.
+//
+// See the package documentation for more information on non-blocking unordered functions and error handling.
+func FlatMapSeq[A, B any](in <-chan Try[A], n int, f func(A) iter.Seq2[B, error]) <-chan Try[B] {
+	if in == nil {
+		return nil
+	}
+
+	out := make(chan Try[B])
+
+	core.Loop(in, out, n, func(a Try[A]) {
+		if a.Error != nil {
+			out <- Try[B]{Error: a.Error}
+			return
+		}
+
+		sendSeq(out, f(a.Value))
+	})
+
+	return out
+}
+
+// OrderedFlatMapSeq is the ordered version of [FlatMapSeq].
+// Since iterators are lazy, each iterator is consumed only when all items produced by the previous ones are written.
+func OrderedFlatMapSeq[A, B any](in <-chan Try[A], n int, f func(A) iter.Seq2[B, error]) <-chan Try[B] {
+	if in == nil {
+		return nil
+	}
+
+	out := make(chan Try[B])
+
+	core.OrderedLoop(in, out, n, func(a Try[A], canWrite <-chan struct{}) {
+		if a.Error != nil {
+			<-canWrite
+			out <- Try[B]{Error: a.Error}
+			return
+		}
+
+		seq := f(a.Value)
+		<-canWrite
+		sendSeq(out, seq)
+	})
+
+	return out
+}
+
+func sendSeq[A any](out chan<- Try[A], seq iter.Seq2[A, error]) {
+	if seq == nil {
+		return
+	}
+
+	for a, err := range seq {
+		out <- Wrap(a, err)
+	}
+}
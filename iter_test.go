@@ -70,6 +70,69 @@ func TestToSeq2(t *testing.T) {
 	})
 }
 
+func TestToBatchSeq(t *testing.T) {
+	t.Run("errors", func(t *testing.T) {
+		in := FromSeq(rangeInt(0, 20), nil)
+		in = replaceWithError(in, 5, fmt.Errorf("err05"))
+		in = replaceWithError(in, 15, fmt.Errorf("err15"))
+
+		var batches [][]int
+		var errs []string
+		for batch, err := range ToBatchSeq(in, 3) {
+			if err != nil {
+				errs = append(errs, err.Error())
+				continue
+			}
+			batches = append(batches, batch)
+		}
+
+		th.ExpectValue(t, len(batches), 7)
+		th.ExpectSlice(t, batches[0], []int{0, 1, 2})
+		th.ExpectSlice(t, batches[1], []int{3, 4})
+		th.ExpectSlice(t, batches[2], []int{6, 7, 8})
+		th.ExpectSlice(t, batches[3], []int{9, 10, 11})
+		th.ExpectSlice(t, batches[4], []int{12, 13, 14})
+		th.ExpectSlice(t, batches[5], []int{16, 17, 18})
+		th.ExpectSlice(t, batches[6], []int{19})
+		th.ExpectSlice(t, errs, []string{"err05", "err15"})
+	})
+
+	for _, size := range []int{0, -1} {
+		t.Run(th.Name("non-positive size", size), func(t *testing.T) {
+			in := FromSeq(rangeInt(0, 3), nil)
+
+			var batches [][]int
+			for batch, err := range ToBatchSeq(in, size) {
+				th.ExpectNoError(t, err)
+				batches = append(batches, batch)
+			}
+
+			th.ExpectValue(t, len(batches), 3)
+			th.ExpectSlice(t, batches[0], []int{0})
+			th.ExpectSlice(t, batches[1], []int{1})
+			th.ExpectSlice(t, batches[2], []int{2})
+		})
+	}
+
+	t.Run("break", func(t *testing.T) {
+		in := FromSeq(rangeInt(0, 20), nil)
+
+		var batches [][]int
+		for batch, err := range ToBatchSeq(in, 3) {
+			th.ExpectNoError(t, err)
+			batches = append(batches, batch)
+			if len(batches) == 2 {
+				break
+			}
+		}
+
+		th.ExpectValue(t, len(batches), 2)
+
+		time.Sleep(1 * time.Second)
+		th.ExpectDrainedChan(t, in)
+	})
+}
+
 func TestFromSeq(t *testing.T) {
 	t.Run("nil", func(t *testing.T) {
 		in := FromSeq[int](nil, nil)
@@ -86,6 +149,29 @@ func TestFromSeq(t *testing.T) {
 		th.ExpectSlice(t, outErrs, nil)
 	})
 
+	t.Run("stop on drain", func(t *testing.T) {
+		// infinite iterator
+		done := make(chan struct{})
+		seq := func(yield func(int) bool) {
+			defer close(done)
+			for i := 0; ; i++ {
+				if !yield(i) {
+					return
+				}
+			}
+		}
+
+		in := FromSeq(seq, nil)
+
+		x, _, err := First(in)
+		th.ExpectNoError(t, err)
+		th.ExpectValue(t, x, 0)
+
+		th.ExpectNotHang(t, 10*time.Second, func() {
+			<-done
+		})
+	})
+
 	t.Run("with error", func(t *testing.T) {
 		in := FromSeq(rangeInt(0, 20), errors.New("err"))
 		a := <-in
@@ -127,4 +213,89 @@ func TestFromSeq2(t *testing.T) {
 		th.ExpectSlice(t, outSlice, []int{0, 1, 2, 3, 4, 5, 6, 7})
 		th.ExpectSlice(t, outError, []error{nil, nil, nil, nil, nil, err5, nil, nil})
 	})
+
+	t.Run("stop on drain", func(t *testing.T) {
+		// infinite iterator
+		done := make(chan struct{})
+		seq := func(yield func(int, error) bool) {
+			defer close(done)
+			for i := 0; ; i++ {
+				if !yield(i, nil) {
+					return
+				}
+			}
+		}
+
+		in := FromSeq2(seq)
+
+		x, _, err := First(in)
+		th.ExpectNoError(t, err)
+		th.ExpectValue(t, x, 0)
+
+		th.ExpectNotHang(t, 10*time.Second, func() {
+			<-done
+		})
+	})
+}
+
+func universalFlatMapSeq[A, B any](ord bool, in <-chan Try[A], n int, f func(A) iter.Seq2[B, error]) <-chan Try[B] {
+	if ord {
+		return OrderedFlatMapSeq(in, n, f)
+	}
+	return FlatMapSeq(in, n, f)
+}
+
+func TestFlatMapSeq(t *testing.T) {
+	th.TestBothOrderings(t, func(t *testing.T, ord bool) {
+		for _, n := range []int{1, 5} {
+			t.Run(th.Name("nil", n), func(t *testing.T) {
+				out := universalFlatMapSeq(ord, nil, n, func(x int) iter.Seq2[string, error] { return nil })
+				th.ExpectValue(t, out, nil)
+			})
+
+			t.Run(th.Name("correctness", n), func(t *testing.T) {
+				in := FromSeq(rangeInt(0, 20), nil)
+				in = replaceWithError(in, 5, fmt.Errorf("err05"))
+
+				out := universalFlatMapSeq(ord, in, n, func(x int) iter.Seq2[string, error] {
+					if x%3 == 0 {
+						return nil
+					}
+
+					return func(yield func(string, error) bool) {
+						if !yield(fmt.Sprintf("%03dA", x), nil) {
+							return
+						}
+						if x == 10 {
+							yield("", fmt.Errorf("err10"))
+							return
+						}
+						yield(fmt.Sprintf("%03dB", x), nil)
+					}
+				})
+
+				outSlice, errSlice := toSliceAndErrors(out)
+				if ord {
+					th.ExpectSorted(t, outSlice)
+				}
+
+				var expectedSlice []string
+				for i := 0; i < 20; i++ {
+					if i == 5 || i%3 == 0 {
+						continue
+					}
+					expectedSlice = append(expectedSlice, fmt.Sprintf("%03dA", i))
+					if i != 10 {
+						expectedSlice = append(expectedSlice, fmt.Sprintf("%03dB", i))
+					}
+				}
+
+				th.Sort(outSlice)
+				th.Sort(errSlice)
+
+				th.ExpectSlice(t, outSlice, expectedSlice)
+				th.ExpectSlice(t, errSlice, []string{"err05", "err10"})
+			})
+		}
+	})
 }
@@ -0,0 +1,195 @@
+package rill
+
+import (
+	"context"
+	"sync"
+
+	"github.com/destel/rill/internal/core"
+)
+
+// Limit is a concurrency limit that can be changed at runtime. Stages created with [MapL] or [OrderedMapL]
+// process up to Limit items concurrently, and adjust their number of workers when the limit changes.
+// This allows to scale a pipeline up or down in response to load or configuration changes, without restarting it:
+//
+//	limit := rill.NewLimit(4)
+//	users := rill.MapL(ids, limit, fetchUser)
+//
+//	// later, from another goroutine
+//	limit.Set(16)
+//
+// When the limit is decreased, items that are already being processed are not interrupted,
+// but new items are not started until the number of active calls drops below the new limit.
+//
+// Limit is safe for concurrent use.
+type Limit struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	limit  int
+	active int
+}
+
+// NewLimit creates a new [Limit] with the initial value n. Values less than 1 are treated as 1.
+func NewLimit(n int) *Limit {
+	l := &Limit{limit: normalizeLimit(n)}
+	l.cond = sync.NewCond(&l.mu)
+	return l
+}
+
+// Set changes the limit. Values less than 1 are treated as 1.
+func (l *Limit) Set(n int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.limit = normalizeLimit(n)
+	l.cond.Broadcast()
+}
+
+// Get returns the current limit.
+func (l *Limit) Get() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.limit
+}
+
+// Active returns the number of calls that are currently running under the limit.
+func (l *Limit) Active() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.active
+}
+
+// Acquire blocks until n units of the limit are available, and takes them.
+// If ctx is done before that, Acquire returns ctx.Err() and takes nothing.
+// Together with [Limit.Release], this makes Limit compatible with the [Limiter] interface.
+func (l *Limit) Acquire(ctx context.Context, n int64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.active+int(n) > l.limit && ctx.Done() != nil {
+		// wake up the waiting loop below when ctx is done
+		stop := make(chan struct{})
+		defer close(stop)
+
+		go func() {
+			select {
+			case <-ctx.Done():
+				l.mu.Lock()
+				l.cond.Broadcast()
+				l.mu.Unlock()
+			case <-stop:
+			}
+		}()
+	}
+
+	for l.active+int(n) > l.limit {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		l.cond.Wait()
+	}
+
+	l.active += int(n)
+	return nil
+}
+
+// Release returns n units of the limit, that were previously taken by [Limit.Acquire].
+func (l *Limit) Release(n int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.active -= int(n)
+	l.cond.Broadcast()
+}
+
+func (l *Limit) acquire() {
+	_ = l.Acquire(context.Background(), 1) // can't fail without a deadline
+}
+
+func (l *Limit) release() {
+	l.Release(1)
+}
+
+func normalizeLimit(n int) int {
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+// MapL is similar to [Map], but the number of goroutines is controlled by the limit,
+// and can be changed while the stream is being processed.
+//
+// This is a non-blocking unordered function that processes items concurrently using up to limit goroutines.
+// An ordered version of this function, [OrderedMapL], is also available.
+//
+// See the package documentation for more information on non-blocking unordered functions and error handling.
+func MapL[A, B any](in <-chan Try[A], limit *Limit, f func(A) (B, error)) <-chan Try[B] {
+	if in == nil {
+		return nil
+	}
+
+	out := make(chan Try[B])
+
+	core.DynamicLoop(in, out, limit.acquire, limit.release, func(a Try[A]) {
+		if a.Error != nil {
+			out <- Try[B]{Error: a.Error}
+			return
+		}
+
+		b, err := f(a.Value)
+		if err != nil {
+			out <- Try[B]{Error: err}
+			return
+		}
+
+		out <- Try[B]{Value: b}
+	})
+
+	return out
+}
+
+// OrderedMapL is the ordered version of [MapL].
+func OrderedMapL[A, B any](in <-chan Try[A], limit *Limit, f func(A) (B, error)) <-chan Try[B] {
+	if in == nil {
+		return nil
+	}
+
+	out := make(chan Try[B])
+
+	core.OrderedDynamicLoop(in, out, limit.acquire, limit.release, func(a Try[A], canWrite <-chan struct{}) {
+		if a.Error != nil {
+			<-canWrite
+			out <- Try[B]{Error: a.Error}
+			return
+		}
+
+		b, err := f(a.Value)
+		<-canWrite
+		if err != nil {
+			out <- Try[B]{Error: err}
+			return
+		}
+
+		out <- Try[B]{Value: b}
+	})
+
+	return out
+}
+
+// Limiter limits the number of concurrent calls of user-provided functions. Unlike the n argument of the stage functions,
+// a Limiter can be attached to several stages with [WithLimiter], to bound the total number of their concurrent calls.
+// This is useful when several stages of a pipeline hit the same external API:
+//
+//	api := rill.NewLimit(10) // at most 10 concurrent requests in total
+//
+//	users := rill.Map(ids, 20, fetchUser, rill.WithLimiter(api))
+//	orders := rill.Map(users, 20, fetchOrders, rill.WithLimiter(api))
+//
+// The interface is compatible with semaphore.Weighted from the golang.org/x/sync/semaphore package,
+// and is implemented by [Limit]. Each call of a function acquires a single unit.
+type Limiter interface {
+	Acquire(ctx context.Context, n int64) error
+	Release(n int64)
+}
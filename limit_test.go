@@ -0,0 +1,161 @@
+package rill
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/destel/rill/internal/th"
+)
+
+func universalMapL[A, B any](ord bool, in <-chan Try[A], limit *Limit, f func(A) (B, error)) <-chan Try[B] {
+	if ord {
+		return OrderedMapL(in, limit, f)
+	}
+	return MapL(in, limit, f)
+}
+
+func TestLimit(t *testing.T) {
+	l := NewLimit(0)
+	th.ExpectValue(t, l.Get(), 1)
+
+	l.Set(5)
+	th.ExpectValue(t, l.Get(), 5)
+
+	l.Set(-1)
+	th.ExpectValue(t, l.Get(), 1)
+}
+
+func TestMapL(t *testing.T) {
+	th.TestBothOrderings(t, func(t *testing.T, ord bool) {
+		t.Run("nil", func(t *testing.T) {
+			th.ExpectValue(t, universalMapL(ord, nil, NewLimit(1), func(x int) (int, error) { return x, nil }), nil)
+		})
+
+		t.Run("correctness", func(t *testing.T) {
+			in := FromChan(th.FromRange(0, 20), nil)
+			in = replaceWithError(in, 15, fmt.Errorf("err15"))
+
+			out := universalMapL(ord, in, NewLimit(3), func(x int) (string, error) {
+				if x == 5 {
+					return "", fmt.Errorf("err05")
+				}
+				return fmt.Sprintf("%03d", x), nil
+			})
+
+			outSlice, errSlice := toSliceAndErrors(out)
+			if ord {
+				th.ExpectSorted(t, outSlice)
+			}
+			th.Sort(errSlice)
+
+			th.ExpectValue(t, len(outSlice), 18)
+			th.ExpectSlice(t, errSlice, []string{"err05", "err15"})
+		})
+
+		t.Run("adjustable concurrency", func(t *testing.T) {
+			limit := NewLimit(2)
+
+			var mu sync.Mutex
+			var active int
+			maxActive := make(map[int]int) // limit -> max number of concurrent calls
+
+			out := universalMapL(ord, FromChan(th.FromRange(0, 60), nil), limit, func(x int) (int, error) {
+				if x == 30 {
+					limit.Set(6)
+				}
+
+				mu.Lock()
+				active++
+				l := limit.Get()
+				if active > maxActive[l] {
+					maxActive[l] = active
+				}
+				mu.Unlock()
+
+				time.Sleep(20 * time.Millisecond)
+
+				mu.Lock()
+				active--
+				mu.Unlock()
+
+				return x, nil
+			})
+
+			outSlice, err := ToSlice(out)
+			th.ExpectNoError(t, err)
+			th.ExpectValue(t, len(outSlice), 60)
+
+			th.ExpectValue(t, maxActive[2], 2)
+			th.ExpectValue(t, maxActive[6], 6)
+			th.ExpectValue(t, limit.Active(), 0)
+		})
+	})
+}
+
+func TestLimitAcquire(t *testing.T) {
+	t.Run("weighted", func(t *testing.T) {
+		l := NewLimit(5)
+		th.ExpectNoError(t, l.Acquire(context.Background(), 3))
+		th.ExpectNoError(t, l.Acquire(context.Background(), 2))
+		th.ExpectValue(t, l.Active(), 5)
+
+		l.Release(3)
+		th.ExpectValue(t, l.Active(), 2)
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		l := NewLimit(1)
+		th.ExpectNoError(t, l.Acquire(context.Background(), 1))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+
+		th.ExpectNotHang(t, 5*time.Second, func() {
+			err := l.Acquire(ctx, 1)
+			th.ExpectValue(t, err, context.DeadlineExceeded)
+		})
+		th.ExpectValue(t, l.Active(), 1)
+	})
+
+	t.Run("released", func(t *testing.T) {
+		l := NewLimit(1)
+		th.ExpectNoError(t, l.Acquire(context.Background(), 1))
+
+		go func() {
+			time.Sleep(200 * time.Millisecond)
+			l.Release(1)
+		}()
+
+		th.ExpectNotHang(t, 5*time.Second, func() {
+			th.ExpectNoError(t, l.Acquire(context.Background(), 1))
+		})
+	})
+}
+
+func TestWithLimiter(t *testing.T) {
+	limit := NewLimit(3)
+	monitor := th.NewConcurrencyMonitor(500 * time.Millisecond)
+
+	f := func(x int) (int, error) {
+		monitor.Inc()
+		defer monitor.Dec()
+		return x, nil
+	}
+
+	in := FromChan(th.FromRange(0, 30), nil)
+	out := Map(in, 5, f, WithLimiter(limit))
+	out = Filter(out, 5, func(x int) (bool, error) {
+		_, err := f(x)
+		return true, err
+	}, WithLimiter(limit))
+
+	outSlice, err := ToSlice(out)
+	th.ExpectNoError(t, err)
+	th.ExpectValue(t, len(outSlice), 30)
+
+	th.ExpectValue(t, monitor.Max(), 3)
+	th.ExpectValue(t, limit.Active(), 0)
+}
@@ -1,7 +1,13 @@
 package rill
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"math/rand"
+	"reflect"
+	"sync"
+	"sync/atomic"
 
 	"github.com/destel/rill/internal/core"
 )
@@ -17,10 +23,213 @@ func Merge[A any](ins ...<-chan A) <-chan A {
 	return core.Merge(ins...)
 }
 
+// MergeRoundRobin is similar to [Merge], but reads from the inputs fairly, in rotation:
+// whenever several inputs have items ready, each of them sends one item before any of them can send another one.
+// This way a fast producer can't starve the others, which is important when combining streams
+// with fairness requirements, for example from different tenants.
+//
+// This is a non-blocking function that processes items sequentially.
+//
+// See the package documentation for more information on non-blocking functions and error handling.
+func MergeRoundRobin[A any](ins ...<-chan A) <-chan A {
+	return core.MergeRoundRobin(ins...)
+}
+
+// Concat performs a sequential concatenation of the input streams, returning a single output stream.
+// Unlike [Merge], it fully consumes each input before moving on to the next one,
+// so the output contains all items of the first input, followed by all items of the second one, and so on.
+// This preserves per-source ordering, for example when reading multiple files or partitions.
+// The output stream is closed when the last input is fully consumed.
+//
+// This is a non-blocking ordered function that processes items sequentially.
+//
+// See the package documentation for more information on non-blocking ordered functions and error handling.
+func Concat[A any](ins ...<-chan A) <-chan A {
+	return core.Concat(ins...)
+}
+
+// Tagged is a container holding a value of type A and the name of the stream it came from.
+type Tagged[A any] struct {
+	Tag   string
+	Value A
+}
+
+// MergeTagged performs a fan-in operation on the named input streams, returning a single output stream.
+// Each item in the output stream is wrapped into a [Tagged] container holding the name of its source stream.
+// Errors are tagged as well, so they can be attributed to the input they came from.
+// The output stream is closed when all inputs are fully consumed.
+//
+// This is a non-blocking function that processes items from each input sequentially.
+//
+// See the package documentation for more information on non-blocking functions and error handling.
+func MergeTagged[A any](ins map[string]<-chan Try[A]) <-chan Try[Tagged[A]] {
+	if len(ins) == 0 {
+		return nil
+	}
+
+	out := make(chan Try[Tagged[A]])
+
+	var wg sync.WaitGroup
+	for tag, in := range ins {
+		tag, in := tag, in
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for a := range in {
+				out <- Try[Tagged[A]]{Value: Tagged[A]{Tag: tag, Value: a.Value}, Error: a.Error}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	return out
+}
+
+// OrderedMerge performs a fan-in operation on the list of input streams and restores the global order of items,
+// using their sequence numbers. This is useful for recombining streams that were produced from a single sequence,
+// for example after splitting and processing its parts separately.
+//
+// The seq function returns the sequence number of an item. Sequence numbers are expected to start from 0 and have no gaps.
+// Out-of-order items are held in memory until their predecessors arrive, but no more than window items at a time.
+// When this limit is exceeded, the item with the smallest sequence number is emitted, even if some of its predecessors are still missing.
+// This guarantees bounded memory usage in case some items never arrive, for example because they were filtered out.
+// Negative window removes this limit, and should be used only when all sequence numbers are guaranteed to arrive.
+// Zero window is treated as 1.
+//
+// This is a non-blocking ordered function that processes items sequentially.
+// Errors are not sequenced and are forwarded to the output stream right away.
+//
+// See the package documentation for more information on non-blocking ordered functions and error handling.
+func OrderedMerge[A any](seq func(A) int64, window int, ins ...<-chan Try[A]) <-chan Try[A] {
+	return core.OrderedMerge(ins, func(a Try[A]) (int64, bool) {
+		if a.Error != nil {
+			return 0, false
+		}
+		return seq(a.Value), true
+	}, window)
+}
+
+// Next reads the next item from whichever input stream is ready first, similar to a select statement
+// over a dynamic list of channels. It returns the item and the index of the stream it was read from.
+// The ok return flag is set to false if the stream at that index was closed, in which case
+// the caller would usually exclude it from subsequent calls, for example by setting ins[index] to nil.
+// Nil streams are ignored.
+//
+// If the context is canceled before any stream is ready, Next returns an item holding ctx.Err(), index -1 and ok set to false.
+// If all streams are nil, there is nothing to wait for, and Next returns a zero item, index -1 and ok set to false immediately.
+//
+// This is a blocking function that can be used to build custom schedulers and control loops over several streams:
+//
+//	for {
+//		item, i, ok := rill.Next(ctx, ins...)
+//		switch {
+//		case i < 0:
+//			return item.Error // context canceled or all streams are closed
+//		case !ok:
+//			ins[i] = nil // stream is closed
+//			continue
+//		}
+//		// process item
+//	}
+func Next[A any](ctx context.Context, ins ...<-chan Try[A]) (item Try[A], index int, ok bool) {
+	live := false
+	for _, in := range ins {
+		if in != nil {
+			live = true
+			break
+		}
+	}
+	if !live {
+		return item, -1, false
+	}
+
+	cases := make([]reflect.SelectCase, len(ins)+1)
+	cases[0] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())}
+	for i, in := range ins {
+		cases[i+1] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(in)}
+	}
+
+	chosen, v, ok := reflect.Select(cases)
+	if chosen == 0 {
+		return Try[A]{Error: ctx.Err()}, -1, false
+	}
+	if ok {
+		item = v.Interface().(Try[A])
+	}
+	return item, chosen - 1, ok
+}
+
+// Backfill combines a bounded backfill stream with a live stream of the same items.
+// It emits all items from the backfill stream first, and then switches to the live stream.
+// Live items that arrive while the backfill is in progress are buffered in memory, so nothing is lost at the cutover point.
+// The overlap between the two streams is removed using the offset function: after the switch,
+// live items with offsets less than or equal to the largest offset seen in the backfill are skipped.
+// This assumes that offsets are increasing within the live stream, as with message queue or log offsets.
+//
+// This is a typical ingestion pattern: load historical data from a database or a snapshot,
+// while subscribing to the live updates at the same time, so that no updates are missed in between.
+//
+// This is a non-blocking function that processes items sequentially.
+// Errors from both streams are forwarded to the output stream and are never skipped.
+//
+// See the package documentation for more information on non-blocking functions and error handling.
+func Backfill[A any](backfill, live <-chan Try[A], offset func(A) int64) <-chan Try[A] {
+	if backfill == nil && live == nil {
+		return nil
+	}
+
+	out := make(chan Try[A])
+
+	// keep consuming the live stream during the backfill
+	var bufferedLive <-chan Try[A]
+	if live != nil {
+		bufferedLive = core.Buffer(live, -1)
+	}
+
+	go func() {
+		defer close(out)
+
+		var maxOffset int64
+		hasOffset := false
+
+		if backfill != nil {
+			for a := range backfill {
+				if a.Error == nil {
+					if off := offset(a.Value); !hasOffset || off > maxOffset {
+						maxOffset = off
+						hasOffset = true
+					}
+				}
+				out <- a
+			}
+		}
+
+		if bufferedLive != nil {
+			for a := range bufferedLive {
+				if a.Error == nil && hasOffset && offset(a.Value) <= maxOffset {
+					continue
+				}
+				out <- a
+			}
+		}
+	}()
+
+	return out
+}
+
 // Split2 divides the input stream into two output streams based on the predicate function f:
 // The splitting behavior is determined by the boolean return value of f. When f returns true, the item is sent to the outTrue stream,
 // otherwise it is sent to the outFalse stream. In case of any error, the item is sent to one of the output streams in a non-deterministic way.
 //
+// Both output streams must be consumed concurrently, otherwise the pipeline would block.
+// If at some point one of the outputs is no longer needed, call [DrainNB] on it to release it.
+// This lets the other branch continue and terminate cleanly. [Split2WithRelease] does the same,
+// but also keeps errors from being lost in the abandoned branch. To catch a forgotten output, wrap both outputs with [Watchdog].
+//
 // This is a non-blocking unordered function that processes items concurrently using n goroutines.
 // An ordered version of this function, [OrderedSplit2], is also available.
 //
@@ -65,3 +274,317 @@ func OrderedSplit2[A any](in <-chan Try[A], n int, f func(A) (bool, error)) (out
 
 	return outs[0], outs[1]
 }
+
+// Split2WithRelease is similar to [Split2], but additionally returns a release function for each output.
+// It's meant for cases when the caller may decide midway that it only needs one of the branches.
+// Calling the release function abandons the corresponding output: it's drained in the background,
+// and from then on the items that would be sent to it are discarded. Errors are not discarded while the other output
+// is still in use: they are sent there instead, so the remaining branch terminates with the error as usual.
+//
+//	valid, invalid, _, releaseInvalid := rill.Split2WithRelease(users, 5, isValid)
+//
+//	go func() {
+//		// report the first invalid user and stop caring about the rest
+//		x := <-invalid
+//		releaseInvalid()
+//		report(x)
+//	}()
+//
+//	err := rill.ForEach(valid, 5, save)
+//
+// Release functions are safe to call multiple times and concurrently.
+//
+// This is a non-blocking unordered function that processes items concurrently using n goroutines.
+// An ordered version of this function, [OrderedSplit2WithRelease], is also available.
+//
+// See the package documentation for more information on non-blocking unordered functions and error handling.
+func Split2WithRelease[A any](in <-chan Try[A], n int, f func(A) (bool, error)) (outTrue, outFalse <-chan Try[A], releaseTrue, releaseFalse func()) {
+	return split2WithRelease(in, n, false, f)
+}
+
+// OrderedSplit2WithRelease is the ordered version of [Split2WithRelease].
+func OrderedSplit2WithRelease[A any](in <-chan Try[A], n int, f func(A) (bool, error)) (outTrue, outFalse <-chan Try[A], releaseTrue, releaseFalse func()) {
+	return split2WithRelease(in, n, true, f)
+}
+
+func split2WithRelease[A any](in <-chan Try[A], n int, ordered bool, f func(A) (bool, error)) (outTrue, outFalse <-chan Try[A], releaseTrue, releaseFalse func()) {
+	if in == nil {
+		return nil, nil, func() {}, func() {}
+	}
+
+	var released [2]atomic.Bool
+
+	// route returns the index of the output for an item that is meant to be sent to the output i.
+	// Index 2 is an internal output, which is always drained.
+	route := func(i int, isErr bool) int {
+		switch {
+		case !released[i].Load():
+			return i
+		case isErr && !released[1-i].Load():
+			return 1 - i
+		default:
+			return 2
+		}
+	}
+
+	split := func(a Try[A]) (Try[A], int) {
+		if a.Error != nil {
+			return a, route(rand.Int()&1, true)
+		}
+
+		putToTrue, err := f(a.Value)
+		switch {
+		case err != nil:
+			return Try[A]{Error: err}, route(rand.Int()&1, true)
+		case putToTrue:
+			return a, route(0, false)
+		default:
+			return a, route(1, false)
+		}
+	}
+
+	var outs []<-chan Try[A]
+	if ordered {
+		outs = core.OrderedMapAndSplit(in, 3, n, split)
+	} else {
+		outs = core.MapAndSplit(in, 3, n, split)
+	}
+	DrainNB(outs[2])
+
+	release := func(i int) func() {
+		return func() {
+			if released[i].CompareAndSwap(false, true) {
+				DrainNB(outs[i])
+			}
+		}
+	}
+
+	return outs[0], outs[1], release(0), release(1)
+}
+
+// SplitErrors divides the input stream into two output streams: values receives all items without errors,
+// and failures receives all items with errors. Unlike [ToChans], items in both outputs are still wrapped in [Try],
+// so a dead-letter branch can be built on the failures stream using regular functions of this package:
+//
+//	values, failures := rill.SplitErrors(results, 1)
+//
+//	go func() {
+//		// dead-letter branch
+//		handled := rill.Catch(failures, 5, func(err error) error {
+//			return deadLetters.Push(err)
+//		})
+//		_ = rill.Err(handled)
+//	}()
+//
+//	err := rill.ForEach(values, 5, save)
+//
+// Both output streams must be consumed concurrently, otherwise the pipeline would block.
+// If at some point one of the outputs is no longer needed, call [DrainNB] on it to release it.
+//
+// This is a non-blocking unordered function that processes items concurrently using n goroutines.
+// An ordered version of this function, [OrderedSplitErrors], is also available.
+//
+// See the package documentation for more information on non-blocking unordered functions and error handling.
+func SplitErrors[A any](in <-chan Try[A], n int) (values <-chan Try[A], failures <-chan Try[A]) {
+	outs := core.MapAndSplit(in, 2, n, splitErrorsFunc[A])
+	return outs[0], outs[1]
+}
+
+// OrderedSplitErrors is the ordered version of [SplitErrors].
+func OrderedSplitErrors[A any](in <-chan Try[A], n int) (values <-chan Try[A], failures <-chan Try[A]) {
+	outs := core.OrderedMapAndSplit(in, 2, n, splitErrorsFunc[A])
+	return outs[0], outs[1]
+}
+
+func splitErrorsFunc[A any](a Try[A]) (Try[A], int) {
+	if a.Error != nil {
+		return a, 1
+	}
+	return a, 0
+}
+
+// Partition divides the input stream into numOuts output streams based on the function f,
+// which returns the index of the output stream for each item. This is useful for sharding a stream by hash or category.
+// If f returns an index that is out of range, an error is produced instead.
+// In case of any error, the item is sent to one of the output streams in a non-deterministic way.
+//
+// All output streams must be consumed concurrently, otherwise the pipeline would block.
+// Outputs that are no longer needed can be released by calling [DrainNB] on them.
+//
+// This is a non-blocking unordered function that processes items concurrently using n goroutines.
+// An ordered version of this function, [OrderedPartition], is also available.
+//
+// See the package documentation for more information on non-blocking unordered functions and error handling.
+func Partition[A any](in <-chan Try[A], numOuts int, n int, f func(A) (int, error)) []<-chan Try[A] {
+	return core.MapAndSplit(in, numOuts, n, func(a Try[A]) (Try[A], int) {
+		if a.Error != nil {
+			return a, rand.Intn(numOuts)
+		}
+
+		i, err := f(a.Value)
+		switch {
+		case err != nil:
+			return Try[A]{Error: err}, rand.Intn(numOuts)
+		case i < 0 || i >= numOuts:
+			return Try[A]{Error: fmt.Errorf("rill: partition index %d is out of range [0, %d)", i, numOuts)}, rand.Intn(numOuts)
+		default:
+			return a, i
+		}
+	})
+}
+
+// OrderedPartition is the ordered version of [Partition].
+func OrderedPartition[A any](in <-chan Try[A], numOuts int, n int, f func(A) (int, error)) []<-chan Try[A] {
+	return core.OrderedMapAndSplit(in, numOuts, n, func(a Try[A]) (Try[A], int) {
+		if a.Error != nil {
+			return a, rand.Intn(numOuts)
+		}
+
+		i, err := f(a.Value)
+		switch {
+		case err != nil:
+			return Try[A]{Error: err}, rand.Intn(numOuts)
+		case i < 0 || i >= numOuts:
+			return Try[A]{Error: fmt.Errorf("rill: partition index %d is out of range [0, %d)", i, numOuts)}, rand.Intn(numOuts)
+		default:
+			return a, i
+		}
+	})
+}
+
+// Pair is a container holding two values of possibly different types.
+type Pair[A, B any] struct {
+	First  A
+	Second B
+}
+
+// Zip pairs items from two input streams positionally: the first item of in1 with the first item of in2, and so on.
+// The output stream is closed when either of the inputs ends, and the remaining items of the other input are drained.
+// If either of the items at some position is an error, that error is forwarded instead of a pair.
+// If both are errors, both are forwarded.
+// If either of the inputs is nil, Zip returns nil, and the other input is drained in the background.
+//
+// This is a non-blocking ordered function that processes items sequentially.
+//
+// See the package documentation for more information on non-blocking ordered functions and error handling.
+func Zip[A, B any](in1 <-chan Try[A], in2 <-chan Try[B]) <-chan Try[Pair[A, B]] {
+	if in1 == nil || in2 == nil {
+		// the other input won't be consumed, so release its producer
+		if in1 != nil {
+			DrainNB(in1)
+		}
+		if in2 != nil {
+			DrainNB(in2)
+		}
+		return nil
+	}
+
+	out := make(chan Try[Pair[A, B]])
+	discard1 := core.DrainSink(in1)
+
+	go func() {
+		defer close(out)
+		defer DrainNB(in1)
+		defer DrainNB(in2)
+
+		for {
+			a, ok1 := <-in1
+			if !ok1 {
+				return
+			}
+
+			b, ok2 := <-in2
+			if !ok2 {
+				discard1(a)
+				return
+			}
+
+			if a.Error != nil || b.Error != nil {
+				if a.Error != nil {
+					out <- Try[Pair[A, B]]{Error: a.Error}
+				}
+				if b.Error != nil {
+					out <- Try[Pair[A, B]]{Error: b.Error}
+				}
+				continue
+			}
+
+			out <- Try[Pair[A, B]]{Value: Pair[A, B]{First: a.Value, Second: b.Value}}
+		}
+	}()
+
+	return out
+}
+
+// ErrNoResult is returned by [FirstSuccess] when none of the branches produced a value or an error for an item.
+var ErrNoResult = errors.New("rill: no branch produced a result")
+
+// FirstSuccess feeds every item of the input stream to several alternative branches, and emits the first successful result
+// produced for that item. As soon as one branch succeeds, the context passed to the others is canceled, and their outputs
+// are drained in the background. If all branches fail, the errors of all branches are joined with [errors.Join].
+//
+// Each branch receives a stream with a single item and can be an arbitrary multi-stage sub-pipeline.
+// Only the first value produced by a branch is used. This is a resilience pattern for querying several alternative
+// providers, where the fastest successful one wins:
+//
+//	prices := rill.FirstSuccess(tickers, 10,
+//		func(ctx context.Context, in <-chan rill.Try[string]) <-chan rill.Try[Price] {
+//			return rill.Map(in, 1, func(t string) (Price, error) {
+//				return providerA.GetPrice(ctx, t)
+//			})
+//		},
+//		func(ctx context.Context, in <-chan rill.Try[string]) <-chan rill.Try[Price] {
+//			quotes := rill.Map(in, 1, func(t string) (Quote, error) {
+//				return providerB.GetQuote(ctx, t)
+//			})
+//			return rill.Map(quotes, 1, quoteToPrice)
+//		},
+//	)
+//
+// This is a non-blocking unordered function that processes items concurrently using n goroutines.
+// An ordered version of this function, [OrderedFirstSuccess], is also available.
+//
+// See the package documentation for more information on non-blocking unordered functions and error handling.
+func FirstSuccess[A, B any](in <-chan Try[A], n int, branches ...func(ctx context.Context, in <-chan Try[A]) <-chan Try[B]) <-chan Try[B] {
+	return Map(in, n, firstSuccessFunc(branches))
+}
+
+// OrderedFirstSuccess is the ordered version of [FirstSuccess].
+func OrderedFirstSuccess[A, B any](in <-chan Try[A], n int, branches ...func(ctx context.Context, in <-chan Try[A]) <-chan Try[B]) <-chan Try[B] {
+	return OrderedMap(in, n, firstSuccessFunc(branches))
+}
+
+func firstSuccessFunc[A, B any](branches []func(ctx context.Context, in <-chan Try[A]) <-chan Try[B]) func(A) (B, error) {
+	return func(a A) (B, error) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		outs := make([]<-chan Try[B], 0, len(branches))
+		for _, branch := range branches {
+			if out := branch(ctx, FromSlice([]A{a}, nil)); out != nil {
+				outs = append(outs, out)
+			}
+		}
+
+		var zero B
+		if len(outs) == 0 {
+			return zero, ErrNoResult
+		}
+
+		merged := core.Merge(outs...)
+
+		var errs []error
+		for x := range merged {
+			if x.Error == nil {
+				DrainNB(merged)
+				return x.Value, nil
+			}
+			errs = append(errs, x.Error)
+		}
+
+		if len(errs) == 0 {
+			return zero, ErrNoResult
+		}
+		return zero, errors.Join(errs...)
+	}
+}
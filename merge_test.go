@@ -1,8 +1,12 @@
 package rill
 
 import (
+	"context"
 	"fmt"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/destel/rill/internal/th"
 )
@@ -12,6 +16,142 @@ func TestMerge(t *testing.T) {
 	Merge[int](nil)
 }
 
+func TestMergeRoundRobin(t *testing.T) {
+	// real tests are in another package
+	MergeRoundRobin[int](nil)
+}
+
+func TestConcat(t *testing.T) {
+	// real tests are in another package
+	Concat[int](nil)
+}
+
+func TestMergeTagged(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		out := MergeTagged[int](nil)
+		th.ExpectValue(t, out, nil)
+	})
+
+	t.Run("correctness", func(t *testing.T) {
+		in1 := FromChan(th.FromRange(0, 10), nil)
+		in2 := FromChan(th.FromRange(10, 20), fmt.Errorf("err2"))
+
+		out := MergeTagged(map[string]<-chan Try[int]{
+			"first":  in1,
+			"second": in2,
+		})
+
+		var values1, values2 []int
+		var errTags []string
+		for x := range out {
+			switch {
+			case x.Error != nil:
+				errTags = append(errTags, x.Value.Tag+":"+x.Error.Error())
+			case x.Value.Tag == "first":
+				values1 = append(values1, x.Value.Value)
+			case x.Value.Tag == "second":
+				values2 = append(values2, x.Value.Value)
+			default:
+				t.Errorf("unexpected tag %q", x.Value.Tag)
+			}
+		}
+
+		th.ExpectSlice(t, values1, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9})
+		th.ExpectSlice(t, values2, []int{10, 11, 12, 13, 14, 15, 16, 17, 18, 19})
+		th.ExpectSlice(t, errTags, []string{"second:err2"})
+	})
+}
+
+func TestOrderedMerge(t *testing.T) {
+	// most logic is covered by the core package tests
+
+	t.Run("correctness", func(t *testing.T) {
+		in := FromChan(th.FromRange(0, 1000), nil)
+		in = replaceWithError(in, 500, fmt.Errorf("err500"))
+
+		// split stream into 2 parts and process them concurrently
+		evens, odds := Split2(in, 5, func(x int) (bool, error) {
+			return x%2 == 0, nil
+		})
+		evens = Map(evens, 5, func(x int) (int, error) { return x, nil })
+		odds = Map(odds, 5, func(x int) (int, error) { return x, nil })
+
+		out := OrderedMerge(func(x int) int64 { return int64(x) }, 1000, evens, odds)
+		outSlice, errs := toSliceAndErrors(out)
+
+		expected := make([]int, 0, 1000)
+		for i := 0; i < 1000; i++ {
+			if i != 500 {
+				expected = append(expected, i)
+			}
+		}
+
+		th.ExpectSlice(t, outSlice, expected)
+		th.ExpectSlice(t, errs, []string{"err500"})
+	})
+}
+
+func TestNext(t *testing.T) {
+	t.Run("correctness", func(t *testing.T) {
+		ins := []<-chan Try[int]{
+			FromChan(th.FromRange(0, 10), nil),
+			nil,
+			FromChan(th.FromRange(10, 20), fmt.Errorf("err2")),
+		}
+
+		var values []int
+		var errs []string
+		open := 2
+		for open > 0 {
+			item, i, ok := Next(context.Background(), ins...)
+			switch {
+			case i < 0:
+				t.Fatalf("unexpected context cancellation")
+			case !ok:
+				ins[i] = nil
+				open--
+			case item.Error != nil:
+				errs = append(errs, item.Error.Error())
+			default:
+				values = append(values, item.Value)
+			}
+		}
+
+		th.Sort(values)
+		th.ExpectSlice(t, values, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19})
+		th.ExpectSlice(t, errs, []string{"err2"})
+	})
+
+	t.Run("context canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		item, i, ok := Next(ctx, make(chan Try[int]), nil)
+		th.ExpectValue(t, i, -1)
+		th.ExpectValue(t, ok, false)
+		th.ExpectError(t, item.Error, context.Canceled.Error())
+	})
+
+	t.Run("no live streams", func(t *testing.T) {
+		th.ExpectNotHang(t, 10*time.Second, func() {
+			item, i, ok := Next[int](context.Background(), nil, nil)
+			th.ExpectValue(t, i, -1)
+			th.ExpectValue(t, ok, false)
+			th.ExpectNoError(t, item.Error)
+
+			_, i, _ = Next[int](context.Background())
+			th.ExpectValue(t, i, -1)
+		})
+	})
+}
+
+func universalSplit2WithRelease[A any](ord bool, in <-chan Try[A], n int, f func(A) (bool, error)) (outTrue, outFalse <-chan Try[A], releaseTrue, releaseFalse func()) {
+	if ord {
+		return OrderedSplit2WithRelease(in, n, f)
+	}
+	return Split2WithRelease(in, n, f)
+}
+
 func universalSplit2[A any](ord bool, in <-chan Try[A], n int, f func(A) (bool, error)) (outTrue <-chan Try[A], outFalse <-chan Try[A]) {
 	if ord {
 		return OrderedSplit2(in, n, f)
@@ -129,6 +269,491 @@ func TestSplit2(t *testing.T) {
 				}
 			})
 
+			t.Run(th.Name("abandoned output", n), func(t *testing.T) {
+				in := FromChan(th.FromRange(0, 1000), nil)
+
+				outTrue, outFalse := universalSplit2(ord, in, n, func(x int) (bool, error) {
+					return x%2 == 0, nil
+				})
+
+				var outSliceTrue []int
+				var err error
+
+				th.ExpectNotHang(t, 5*time.Second, func() {
+					th.DoConcurrently(
+						func() { outSliceTrue, err = ToSlice(outTrue) },
+						func() {
+							// consume a part of the false branch and abandon it
+							for i := 0; i < 100; i++ {
+								<-outFalse
+							}
+							DrainNB(outFalse)
+						},
+					)
+				})
+
+				th.ExpectNoError(t, err)
+				th.ExpectValue(t, len(outSliceTrue), 500)
+			})
+
+		}
+	})
+}
+
+func TestSplit2WithRelease(t *testing.T) {
+	th.TestBothOrderings(t, func(t *testing.T, ord bool) {
+		for _, n := range []int{1, 5} {
+			t.Run(th.Name("nil", n), func(t *testing.T) {
+				outTrue, outFalse, releaseTrue, releaseFalse := universalSplit2WithRelease(ord, nil, n, func(string) (bool, error) { return true, nil })
+				th.ExpectValue(t, outTrue, nil)
+				th.ExpectValue(t, outFalse, nil)
+				releaseTrue()
+				releaseFalse()
+			})
+
+			t.Run(th.Name("no release", n), func(t *testing.T) {
+				in := FromChan(th.FromRange(0, 100), nil)
+
+				outTrue, outFalse, _, _ := universalSplit2WithRelease(ord, in, n, func(x int) (bool, error) {
+					return x%2 == 0, nil
+				})
+
+				var outSliceTrue, outSliceFalse []int
+				th.DoConcurrently(
+					func() { outSliceTrue, _ = toSliceAndErrors(outTrue) },
+					func() { outSliceFalse, _ = toSliceAndErrors(outFalse) },
+				)
+
+				th.ExpectValue(t, len(outSliceTrue), 50)
+				th.ExpectValue(t, len(outSliceFalse), 50)
+			})
+
+			t.Run(th.Name("release", n), func(t *testing.T) {
+				in := FromChan(th.FromRange(0, 3000), nil)
+
+				outTrue, outFalse, _, releaseFalse := universalSplit2WithRelease(ord, in, n, func(x int) (bool, error) {
+					switch x % 3 {
+					case 0:
+						return true, nil
+					case 1:
+						return false, nil
+					default:
+						return false, fmt.Errorf("err%04d", x)
+					}
+				})
+
+				var outSliceTrue []int
+				var errSliceTrue []string
+
+				th.ExpectNotHang(t, 10*time.Second, func() {
+					th.DoConcurrently(
+						func() { outSliceTrue, errSliceTrue = toSliceAndErrors(outTrue) },
+						func() {
+							// consume a part of the false branch and abandon it
+							for i := 0; i < 10; i++ {
+								<-outFalse
+							}
+							releaseFalse()
+							releaseFalse()
+						},
+					)
+				})
+
+				th.ExpectValue(t, len(outSliceTrue), 1000)
+
+				// errors are redirected to the true branch, except for those that were in flight
+				if len(errSliceTrue) < 1000-10-n {
+					t.Errorf("expected at least %d errors in the true branch, got %d", 1000-10-n, len(errSliceTrue))
+				}
+				if ord {
+					th.ExpectSorted(t, outSliceTrue)
+					th.ExpectSorted(t, errSliceTrue)
+				}
+			})
+		}
+	})
+}
+
+func TestBackfill(t *testing.T) {
+	offset := func(x int) int64 { return int64(x) }
+
+	t.Run("nil", func(t *testing.T) {
+		th.ExpectValue(t, Backfill[int](nil, nil, offset), nil)
+	})
+
+	t.Run("only backfill", func(t *testing.T) {
+		out := Backfill(FromChan(th.FromRange(0, 5), nil), nil, offset)
+
+		outSlice, err := ToSlice(out)
+		th.ExpectNoError(t, err)
+		th.ExpectSlice(t, outSlice, []int{0, 1, 2, 3, 4})
+	})
+
+	t.Run("only live", func(t *testing.T) {
+		out := Backfill(nil, FromChan(th.FromRange(0, 5), nil), offset)
+
+		outSlice, err := ToSlice(out)
+		th.ExpectNoError(t, err)
+		th.ExpectSlice(t, outSlice, []int{0, 1, 2, 3, 4})
+	})
+
+	t.Run("overlap", func(t *testing.T) {
+		backfill := FromChan(th.FromRange(0, 10), nil)
+		backfill = replaceWithError(backfill, 5, fmt.Errorf("err05"))
+
+		live := make(chan Try[int])
+		go func() {
+			defer close(live)
+			// live stream is fully produced before the backfill is consumed
+			for i := 7; i < 15; i++ {
+				live <- Try[int]{Value: i}
+			}
+			live <- Try[int]{Error: fmt.Errorf("err15")}
+		}()
+
+		out := Backfill(backfill, live, offset)
+		time.Sleep(100 * time.Millisecond)
+
+		outSlice, errs := toSliceAndErrors(out)
+		th.ExpectSlice(t, outSlice, []int{0, 1, 2, 3, 4, 6, 7, 8, 9, 10, 11, 12, 13, 14})
+		th.ExpectSlice(t, errs, []string{"err05", "err15"})
+	})
+}
+
+func universalPartition[A any](ord bool, in <-chan Try[A], numOuts int, n int, f func(A) (int, error)) []<-chan Try[A] {
+	if ord {
+		return OrderedPartition(in, numOuts, n, f)
+	}
+	return Partition(in, numOuts, n, f)
+}
+
+func universalSplitErrors[A any](ord bool, in <-chan Try[A], n int) (<-chan Try[A], <-chan Try[A]) {
+	if ord {
+		return OrderedSplitErrors(in, n)
+	}
+	return SplitErrors(in, n)
+}
+
+func TestSplitErrors(t *testing.T) {
+	th.TestBothOrderings(t, func(t *testing.T, ord bool) {
+		for _, n := range []int{1, 5} {
+			t.Run(th.Name("nil", n), func(t *testing.T) {
+				values, failures := universalSplitErrors[int](ord, nil, n)
+				th.ExpectValue(t, values, nil)
+				th.ExpectValue(t, failures, nil)
+			})
+
+			t.Run(th.Name("correctness", n), func(t *testing.T) {
+				in := FromChan(th.FromRange(0, 100), nil)
+				in = OrderedMap(in, 1, func(x int) (int, error) {
+					if x%10 == 0 {
+						return 0, fmt.Errorf("err%03d", x)
+					}
+					return x, nil
+				})
+
+				values, failures := universalSplitErrors(ord, in, n)
+
+				var valuesSlice []Try[int]
+				var failuresSlice []Try[int]
+				th.DoConcurrently(
+					func() { valuesSlice = th.ToSlice(values) },
+					func() { failuresSlice = th.ToSlice(failures) },
+				)
+
+				var outValues []int
+				for _, x := range valuesSlice {
+					th.ExpectNoError(t, x.Error)
+					outValues = append(outValues, x.Value)
+				}
+
+				var outErrs []string
+				for _, x := range failuresSlice {
+					if x.Error == nil {
+						t.Fatalf("expected error, got value %d", x.Value)
+					}
+					outErrs = append(outErrs, x.Error.Error())
+				}
+
+				if ord || n == 1 {
+					th.ExpectSorted(t, outValues)
+					th.ExpectSorted(t, outErrs)
+				}
+				th.Sort(outValues)
+				th.Sort(outErrs)
+
+				th.ExpectValue(t, len(outValues), 90)
+				th.ExpectValue(t, len(outErrs), 10)
+				th.ExpectValue(t, outErrs[0], "err000")
+				th.ExpectValue(t, outErrs[9], "err090")
+			})
+		}
+	})
+}
+
+func TestPartition(t *testing.T) {
+	th.TestBothOrderings(t, func(t *testing.T, ord bool) {
+		for _, n := range []int{1, 5} {
+			t.Run(th.Name("nil", n), func(t *testing.T) {
+				outs := universalPartition(ord, nil, 3, n, func(string) (int, error) { return 0, nil })
+				th.ExpectValue(t, len(outs), 3)
+				for _, out := range outs {
+					th.ExpectValue(t, out, nil)
+				}
+			})
+
+			t.Run(th.Name("correctness", n), func(t *testing.T) {
+				// idea: 3 outputs, items with x%5 == 3 cause out of range error, x%5 == 4 cause f error
+				in := FromChan(th.FromRange(0, 20*5), nil)
+				in = replaceWithError(in, 50, fmt.Errorf("err050"))
+
+				outs := universalPartition(ord, in, 3, n, func(x int) (int, error) {
+					switch x % 5 {
+					case 3:
+						return 3, nil
+					case 4:
+						return 0, fmt.Errorf("err%03d", x)
+					default:
+						return x % 5, nil
+					}
+				})
+
+				outSlices := make([][]int, 3)
+				errSlices := make([][]string, 3)
+
+				th.DoConcurrently(
+					func() { outSlices[0], errSlices[0] = toSliceAndErrors(outs[0]) },
+					func() { outSlices[1], errSlices[1] = toSliceAndErrors(outs[1]) },
+					func() { outSlices[2], errSlices[2] = toSliceAndErrors(outs[2]) },
+				)
+
+				expectedOutSlices := make([][]int, 3)
+				var expectedAllErrs []string
+				for i := 0; i < 20*5; i++ {
+					switch {
+					case i == 50:
+						expectedAllErrs = append(expectedAllErrs, "err050")
+					case i%5 == 3:
+						expectedAllErrs = append(expectedAllErrs, "rill: partition index 3 is out of range [0, 3)")
+					case i%5 == 4:
+						expectedAllErrs = append(expectedAllErrs, fmt.Sprintf("err%03d", i))
+					default:
+						expectedOutSlices[i%5] = append(expectedOutSlices[i%5], i)
+					}
+				}
+
+				var allErrs []string
+				for i := 0; i < 3; i++ {
+					if len(errSlices[i]) == 0 {
+						t.Errorf("expected at least one error in output %d", i)
+					}
+					allErrs = append(allErrs, errSlices[i]...)
+
+					th.Sort(outSlices[i])
+					th.ExpectSlice(t, outSlices[i], expectedOutSlices[i])
+				}
+
+				th.Sort(allErrs)
+				th.Sort(expectedAllErrs)
+				th.ExpectSlice(t, allErrs, expectedAllErrs)
+			})
+
+			t.Run(th.Name("ordering", n), func(t *testing.T) {
+				in := FromChan(th.FromRange(0, 10000*3), nil)
+
+				outs := universalPartition(ord, in, 3, n, func(x int) (int, error) {
+					return x % 3, nil
+				})
+
+				outSlices := make([][]int, 3)
+				th.DoConcurrently(
+					func() { outSlices[0], _ = toSliceAndErrors(outs[0]) },
+					func() { outSlices[1], _ = toSliceAndErrors(outs[1]) },
+					func() { outSlices[2], _ = toSliceAndErrors(outs[2]) },
+				)
+
+				for _, outSlice := range outSlices {
+					if ord || n == 1 {
+						th.ExpectSorted(t, outSlice)
+					} else {
+						th.ExpectUnsorted(t, outSlice)
+					}
+				}
+			})
 		}
 	})
 }
+
+func TestZip(t *testing.T) {
+	t.Run("nil", func(t *testing.T) {
+		th.ExpectValue(t, Zip[int, string](nil, nil), nil)
+	})
+
+	t.Run("one nil", func(t *testing.T) {
+		for _, first := range []bool{true, false} {
+			in := make(chan Try[int])
+			sent := make(chan struct{})
+
+			go func() {
+				defer close(sent)
+				defer close(in)
+				for i := 0; i < 10; i++ {
+					in <- Try[int]{Value: i}
+				}
+			}()
+
+			var out <-chan Try[Pair[int, int]]
+			if first {
+				out = Zip[int, int](in, nil)
+			} else {
+				out = Zip[int, int](nil, in)
+			}
+			th.ExpectValue(t, out, nil)
+
+			th.ExpectNotHang(t, 10*time.Second, func() {
+				<-sent
+			})
+		}
+	})
+
+	t.Run("correctness", func(t *testing.T) {
+		in1 := FromChan(th.FromRange(0, 10), nil)
+		in1 = replaceWithError(in1, 3, fmt.Errorf("err3"))
+		in1 = replaceWithError(in1, 5, fmt.Errorf("err5a"))
+
+		in2 := OrderedMap(FromChan(th.FromRange(0, 20), nil), 1, func(x int) (string, error) {
+			if x == 5 {
+				return "", fmt.Errorf("err5b")
+			}
+			return fmt.Sprint(x), nil
+		})
+
+		outSlice, errs := toSliceAndErrors(Zip(in1, in2))
+
+		var expectedSlice []Pair[int, string]
+		for i := 0; i < 10; i++ {
+			if i == 3 || i == 5 {
+				continue
+			}
+			expectedSlice = append(expectedSlice, Pair[int, string]{First: i, Second: fmt.Sprint(i)})
+		}
+
+		th.ExpectSlice(t, outSlice, expectedSlice)
+		th.ExpectSlice(t, errs, []string{"err3", "err5a", "err5b"})
+
+		time.Sleep(1 * time.Second)
+		th.ExpectDrainedChan(t, in2)
+	})
+
+	t.Run("second ends first", func(t *testing.T) {
+		in1 := FromChan(th.FromRange(0, 10), nil)
+		in2 := FromChan(th.FromRange(0, 3), nil)
+
+		outSlice, err := ToSlice(Zip(in1, in2))
+		th.ExpectNoError(t, err)
+		th.ExpectSlice(t, outSlice, []Pair[int, int]{{0, 0}, {1, 1}, {2, 2}})
+
+		time.Sleep(1 * time.Second)
+		th.ExpectDrainedChan(t, in1)
+	})
+}
+
+func universalFirstSuccess[A, B any](ord bool, in <-chan Try[A], n int, branches ...func(context.Context, <-chan Try[A]) <-chan Try[B]) <-chan Try[B] {
+	if ord {
+		return OrderedFirstSuccess(in, n, branches...)
+	}
+	return FirstSuccess(in, n, branches...)
+}
+
+func TestFirstSuccess(t *testing.T) {
+	th.TestBothOrderings(t, func(t *testing.T, ord bool) {
+		t.Run("nil", func(t *testing.T) {
+			out := universalFirstSuccess[int, int](ord, nil, 1)
+			th.ExpectValue(t, out, nil)
+		})
+
+		t.Run("no branches", func(t *testing.T) {
+			outSlice, errs := toSliceAndErrors(universalFirstSuccess[int, int](ord, FromSlice([]int{1}, nil), 1))
+			th.ExpectValue(t, len(outSlice), 0)
+			th.ExpectSlice(t, errs, []string{ErrNoResult.Error()})
+		})
+
+		t.Run("correctness", func(t *testing.T) {
+			in := FromChan(th.FromRange(0, 20), nil)
+			in = replaceWithError(in, 15, fmt.Errorf("err15"))
+
+			var canceled atomic.Int64
+
+			// slow, but always succeeds
+			slow := func(ctx context.Context, in <-chan Try[int]) <-chan Try[string] {
+				return Map(in, 1, func(x int) (string, error) {
+					select {
+					case <-time.After(500 * time.Millisecond):
+						return fmt.Sprintf("slow%02d", x), nil
+					case <-ctx.Done():
+						canceled.Add(1)
+						return "", ctx.Err()
+					}
+				})
+			}
+
+			// fast multi-stage branch, fails on odd items
+			fast := func(ctx context.Context, in <-chan Try[int]) <-chan Try[string] {
+				in = Map(in, 1, func(x int) (int, error) {
+					if x%2 == 1 {
+						return 0, fmt.Errorf("fast failed")
+					}
+					return x, nil
+				})
+				return Map(in, 1, func(x int) (string, error) {
+					return fmt.Sprintf("fast%02d", x), nil
+				})
+			}
+
+			// always fails
+			broken := func(ctx context.Context, in <-chan Try[int]) <-chan Try[string] {
+				return Map(in, 1, func(x int) (string, error) {
+					return "", fmt.Errorf("broken")
+				})
+			}
+
+			out := universalFirstSuccess(ord, in, 20, slow, fast, broken)
+			outSlice, errs := toSliceAndErrors(out)
+
+			th.ExpectSlice(t, errs, []string{"err15"})
+
+			th.Sort(outSlice)
+			expected := make([]string, 0, 19)
+			for i := 0; i < 20; i++ {
+				switch {
+				case i == 15:
+				case i%2 == 0:
+					expected = append(expected, fmt.Sprintf("fast%02d", i))
+				default:
+					expected = append(expected, fmt.Sprintf("slow%02d", i))
+				}
+			}
+			th.Sort(expected)
+			th.ExpectSlice(t, outSlice, expected)
+
+			time.Sleep(100 * time.Millisecond)
+			th.ExpectValue(t, canceled.Load(), int64(10))
+		})
+
+		t.Run("all fail", func(t *testing.T) {
+			failing := func(msg string) func(context.Context, <-chan Try[int]) <-chan Try[int] {
+				return func(ctx context.Context, in <-chan Try[int]) <-chan Try[int] {
+					return Map(in, 1, func(x int) (int, error) {
+						return 0, fmt.Errorf(msg)
+					})
+				}
+			}
+
+			out := universalFirstSuccess(ord, FromSlice([]int{1}, nil), 1, failing("a"), failing("b"))
+			err := Err(out)
+			if err == nil || !strings.Contains(err.Error(), "a") || !strings.Contains(err.Error(), "b") {
+				t.Errorf("expected joined errors, got %v", err)
+			}
+		})
+	})
+}
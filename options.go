@@ -0,0 +1,210 @@
+package rill
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// StageOption configures a single stage of a pipeline. Options are passed as the last arguments of the
+// [Map], [Filter], [FilterMap], [FlatMap], [FlatMapSlice], [Tap] and [Catch] functions and their ordered versions,
+// and allow to attach additional behavior to a stage without introducing new function variants:
+//
+//	users := rill.Map(ids, 10, fetchUser,
+//		rill.WithBuffer(64),
+//		rill.WithStageName("fetch users"),
+//		rill.WithRecover(),
+//	)
+//
+// Some blocking functions, such as [ForEach], [ToSlice] and [Err], accept options as well.
+// Options that don't apply to a function, for example [WithBuffer] for functions without an output stream, are ignored.
+type StageOption func(*stageConfig)
+
+type stageConfig struct {
+	buffer  int
+	name    string
+	recover bool
+	limiter Limiter
+
+	// fields describing the stage itself, used for the pipeline graph
+	fn string
+	in any
+	n  int
+}
+
+// WithBuffer makes the output channel of the stage buffered with the given capacity. This allows the stage to keep processing
+// items while the downstream is busy, and saves a context switch per item, at the cost of holding up to size additional items in memory.
+// Zero or negative size means no buffering. WithBuffer overrides the package-level default, set with [SetDefaultBuffer].
+func WithBuffer(size int) StageOption {
+	return func(c *stageConfig) {
+		c.buffer = size
+	}
+}
+
+// defaultBuffer holds the value set with SetDefaultBuffer.
+var defaultBuffer atomic.Int64
+
+// SetDefaultBuffer sets the default output buffer size for all stages that accept [StageOption], such as [Map] or [Filter].
+// Stages created after the call use it, unless overridden with [WithBuffer] or a [Profile].
+// By default, outputs are unbuffered. Buffered outputs usually increase throughput of pipelines with cheap per-item work,
+// see BenchmarkMapChainBuffered. Since the setting is global, it's meant to be called by applications at startup, not by libraries.
+func SetDefaultBuffer(size int) {
+	if size < 0 {
+		size = 0
+	}
+	defaultBuffer.Store(int64(size))
+}
+
+// DefaultBuffer returns the value set with [SetDefaultBuffer].
+func DefaultBuffer() int {
+	return int(defaultBuffer.Load())
+}
+
+// WithStageName labels the stage with a name. Errors that are sent to the output of the stage are wrapped
+// into [PipelineError], the same way as [Stage] does. Named stages of a pipeline also appear in [Pipeline.Describe].
+// For blocking functions, the returned error is wrapped instead, with the number of values the function has processed and skipped,
+// and the time since it was called.
+func WithStageName(name string) StageOption {
+	return func(c *stageConfig) {
+		c.name = name
+	}
+}
+
+// WithRecover makes the stage convert panics in the user-provided function into errors of type [PanicError],
+// as if the function was wrapped with [Recover].
+func WithRecover() StageOption {
+	return func(c *stageConfig) {
+		c.recover = true
+	}
+}
+
+// WithLimiter makes each call of the user-provided function of the stage acquire a unit of the limiter, and release it
+// once the call returns. This way the same limiter can bound the total number of concurrent calls across several stages.
+// For [FlatMap], only the call of f that creates a sub-stream is limited, but not the consumption of that sub-stream.
+// See [Limiter] for details.
+func WithLimiter(l Limiter) StageOption {
+	return func(c *stageConfig) {
+		c.limiter = l
+	}
+}
+
+func newStageConfig(fn string, in any, n int, opts []StageOption) stageConfig {
+	c := stageConfig{fn: fn, in: in, n: n, buffer: DefaultBuffer()}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	if c.buffer < 0 {
+		c.buffer = 0
+	}
+	return c
+}
+
+// stageFunc wraps the function f of a stage according to the config.
+func stageFunc[A, B any](c stageConfig, f func(A) (B, error)) func(A) (B, error) {
+	if c.recover {
+		f = Recover(f)
+	}
+
+	if l := c.limiter; l != nil {
+		f0 := f
+		f = func(a A) (b B, err error) {
+			if lerr := withLimiter(l, func() { b, err = f0(a) }); lerr != nil {
+				return b, lerr
+			}
+			return
+		}
+	}
+
+	return f
+}
+
+// stageErrFunc is similar to stageFunc, but for functions that return only an error.
+func stageErrFunc[A any](c stageConfig, f func(A) error) func(A) error {
+	if c.recover {
+		f = RecoverErr(f)
+	}
+
+	if l := c.limiter; l != nil {
+		f0 := f
+		f = func(a A) (err error) {
+			if lerr := withLimiter(l, func() { err = f0(a) }); lerr != nil {
+				return lerr
+			}
+			return
+		}
+	}
+
+	return f
+}
+
+// stageFilterMapFunc is similar to stageFunc, but for functions used by FilterMap.
+func stageFilterMapFunc[A, B any](c stageConfig, f func(A) (B, bool, error)) func(A) (B, bool, error) {
+	if c.recover {
+		f = RecoverFilterMap(f)
+	}
+
+	if l := c.limiter; l != nil {
+		f0 := f
+		f = func(a A) (b B, keep bool, err error) {
+			if lerr := withLimiter(l, func() { b, keep, err = f0(a) }); lerr != nil {
+				return b, keep, lerr
+			}
+			return
+		}
+	}
+
+	return f
+}
+
+// stageStreamFunc is similar to stageFunc, but for functions used by FlatMap.
+// Errors, including panics, are turned into a stream with a single error.
+func stageStreamFunc[A, B any](c stageConfig, f func(A) <-chan Try[B]) func(A) <-chan Try[B] {
+	if !c.recover && c.limiter == nil {
+		return f
+	}
+
+	return func(a A) <-chan Try[B] {
+		var bb <-chan Try[B]
+		var err error
+
+		call := func() {
+			if c.recover {
+				defer recoverInto(&err)
+			}
+			bb = f(a)
+		}
+
+		if c.limiter != nil {
+			if lerr := withLimiter(c.limiter, call); lerr != nil {
+				err = lerr
+			}
+		} else {
+			call()
+		}
+
+		if err != nil {
+			return FromSlice[B](nil, err)
+		}
+		return bb
+	}
+}
+
+// withLimiter calls f while holding a unit of the limiter.
+func withLimiter(l Limiter, f func()) error {
+	if err := l.Acquire(context.Background(), 1); err != nil {
+		return err
+	}
+	defer l.Release(1)
+
+	f()
+	return nil
+}
+
+// stageOutput applies the config to the output stream of a stage.
+// The buffer is not applied here: stages create their output channels with the buffer capacity right away,
+// which avoids an extra goroutine and channel per stage.
+func stageOutput[A any](c stageConfig, out <-chan Try[A]) <-chan Try[A] {
+	if out == nil {
+		return nil
+	}
+	return trackStage(c, out)
+}
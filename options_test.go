@@ -0,0 +1,140 @@
+package rill
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/destel/rill/internal/th"
+)
+
+func TestStageOptions(t *testing.T) {
+	t.Run("nil", func(t *testing.T) {
+		th.ExpectValue(t, Map(nil, 1, func(x int) (int, error) { return x, nil }, WithBuffer(5)), nil)
+	})
+
+	t.Run("buffer", func(t *testing.T) {
+		in := make(chan Try[int])
+		go func() {
+			defer close(in)
+			for i := 0; i < 10; i++ {
+				in <- Try[int]{Value: i}
+			}
+		}()
+
+		out := OrderedMap(in, 1, func(x int) (int, error) { return x, nil }, WithBuffer(10))
+
+		// nobody reads the output yet, but all items are processed thanks to the buffer
+		time.Sleep(500 * time.Millisecond)
+		th.ExpectDrainedChan(t, in)
+
+		outSlice, err := ToSlice(out)
+		th.ExpectNoError(t, err)
+		th.ExpectSlice(t, outSlice, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9})
+	})
+
+	t.Run("default buffer", func(t *testing.T) {
+		SetDefaultBuffer(10)
+		defer SetDefaultBuffer(0)
+
+		id := func(x int) (int, error) { return x, nil }
+
+		th.ExpectValue(t, cap(Map(FromSlice([]int{1}, nil), 1, id)), 10)
+		th.ExpectValue(t, cap(Map(FromSlice([]int{1}, nil), 1, id, WithBuffer(0))), 0)
+		th.ExpectValue(t, cap(NewStream(FromSlice([]int{1}, nil)).Filter(1, func(x int) (bool, error) { return true, nil }).Chan()), 10)
+		th.ExpectValue(t, cap(NewStream(FromSlice([]int{1}, nil)).WithProfile(ProfileLowMemory).Filter(1, func(x int) (bool, error) { return true, nil }).Chan()), 0)
+	})
+
+	t.Run("stage name", func(t *testing.T) {
+		in := FromChan(th.FromRange(0, 10), nil)
+
+		out := Filter(in, 2, func(x int) (bool, error) {
+			if x == 5 {
+				return false, fmt.Errorf("err05")
+			}
+			return true, nil
+		}, WithStageName("filter"))
+
+		_, err := ToSlice(out)
+
+		var pe *PipelineError
+		if !errors.As(err, &pe) {
+			t.Fatalf("expected PipelineError, got %v", err)
+		}
+		th.ExpectValue(t, pe.Stage, "filter")
+		th.ExpectError(t, pe.Err, "err05")
+	})
+
+	t.Run("recover", func(t *testing.T) {
+		expectPanicError := func(t *testing.T, errs []string) {
+			t.Helper()
+			th.ExpectSlice(t, errs, []string{"rill: panic: boom"})
+		}
+
+		t.Run("map", func(t *testing.T) {
+			_, errs := toSliceAndErrors(OrderedMap(FromChan(th.FromRange(0, 10), nil), 2, func(x int) (int, error) {
+				if x == 5 {
+					panic("boom")
+				}
+				return x, nil
+			}, WithRecover()))
+			expectPanicError(t, errs)
+		})
+
+		t.Run("filter map", func(t *testing.T) {
+			_, errs := toSliceAndErrors(FilterMap(FromChan(th.FromRange(0, 10), nil), 2, func(x int) (int, bool, error) {
+				if x == 5 {
+					panic("boom")
+				}
+				return x, true, nil
+			}, WithRecover()))
+			expectPanicError(t, errs)
+		})
+
+		t.Run("tap", func(t *testing.T) {
+			_, errs := toSliceAndErrors(Tap(FromChan(th.FromRange(0, 10), nil), 2, func(x int) error {
+				if x == 5 {
+					panic("boom")
+				}
+				return nil
+			}, WithRecover()))
+			expectPanicError(t, errs)
+		})
+
+		t.Run("flat map", func(t *testing.T) {
+			outSlice, errs := toSliceAndErrors(OrderedFlatMap(FromChan(th.FromRange(0, 10), nil), 2, func(x int) <-chan Try[int] {
+				if x == 5 {
+					panic("boom")
+				}
+				return FromSlice([]int{x, x}, nil)
+			}, WithRecover()))
+			th.ExpectValue(t, len(outSlice), 18)
+			expectPanicError(t, errs)
+		})
+	})
+
+	t.Run("combined", func(t *testing.T) {
+		in := FromChan(th.FromRange(0, 10), nil)
+
+		out := Map(in, 3, func(x int) (int, error) {
+			if x == 5 {
+				panic("boom")
+			}
+			return x, nil
+		}, WithRecover(), WithStageName("fetch"), WithBuffer(3))
+
+		_, err := ToSlice(out)
+
+		var pipelineErr *PipelineError
+		if !errors.As(err, &pipelineErr) {
+			t.Fatalf("expected PipelineError, got %v", err)
+		}
+		th.ExpectValue(t, pipelineErr.Stage, "fetch")
+
+		var panicErr *PanicError
+		if !errors.As(err, &panicErr) {
+			t.Errorf("expected PanicError, got %v", err)
+		}
+	})
+}
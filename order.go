@@ -0,0 +1,99 @@
+package rill
+
+import (
+	"sync"
+	"time"
+
+	"github.com/destel/rill/internal/core"
+)
+
+// OrderStats is a snapshot of the state of an ordered stage, returned by [OrderMonitor.Snapshot].
+//
+// In ordered stages, results are written in the same order as items were read from the input, so a single slow item
+// holds back the results of all items after it. Such item is called the head. A small HeadWait means that the stage
+// is uniformly slow, while a large HeadWait together with a large Behind means that one stuck item is blocking the stage.
+type OrderStats struct {
+	HeadWait  time.Duration // how long the head has been blocking writes, zero if no items are being processed
+	Behind    int           // number of items after the head that are still being processed or are waiting for the head
+	Committed int64         // number of items written so far
+}
+
+// OrderMonitor collects head-of-line blocking telemetry of an ordered stage. See [MonitorOrder].
+// OrderMonitor is safe for concurrent use.
+type OrderMonitor struct {
+	mu         sync.Mutex
+	inFlight   map[int64]time.Time // start times of items that are not committed yet, by sequence number
+	committed  int64
+	lastCommit time.Time
+}
+
+// MonitorOrder returns a stream of exactly the same items as in, and an [OrderMonitor] for the ordered stage that
+// consumes the returned stream. This helps to tell whether an ordered stage is slow overall, or is blocked by a single stuck item:
+//
+//	ids, mon := rill.MonitorOrder(ids)
+//	users := rill.OrderedMap(ids, 10, fetchUser)
+//
+//	go func() {
+//		for range time.Tick(10 * time.Second) {
+//			s := mon.Snapshot()
+//			log.Printf("head blocked for %v, %d items behind it", s.HeadWait, s.Behind)
+//		}
+//	}()
+//
+// Only the stage that directly consumes the returned stream is monitored. Monitoring works with all ordered functions
+// that process items using n goroutines, such as [OrderedMap], [OrderedFilter] or [OrderedFlatMap].
+// For other stages, the snapshot stays empty.
+func MonitorOrder[A any](in <-chan Try[A]) (<-chan Try[A], *OrderMonitor) {
+	m := &OrderMonitor{
+		inFlight: make(map[int64]time.Time),
+	}
+
+	return core.WithOrderObserver(in, orderObserver{m}), m
+}
+
+// Snapshot returns the current state of the monitored stage.
+func (m *OrderMonitor) Snapshot() OrderStats {
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s := OrderStats{
+		Behind:    len(m.inFlight),
+		Committed: m.committed,
+	}
+
+	if start, ok := m.inFlight[m.committed]; ok {
+		s.Behind--
+
+		// the head starts blocking when it becomes the head, or when its processing starts, whichever is later
+		since := start
+		if m.lastCommit.After(since) {
+			since = m.lastCommit
+		}
+		s.HeadWait = now.Sub(since)
+	}
+
+	return s
+}
+
+// orderObserver adapts OrderMonitor to the core.OrderObserver interface, without exposing its methods.
+type orderObserver struct {
+	m *OrderMonitor
+}
+
+func (o orderObserver) Started(seq int64) {
+	o.m.mu.Lock()
+	defer o.m.mu.Unlock()
+
+	o.m.inFlight[seq] = time.Now()
+}
+
+func (o orderObserver) Committed(seq int64) {
+	o.m.mu.Lock()
+	defer o.m.mu.Unlock()
+
+	delete(o.m.inFlight, seq)
+	o.m.committed = seq + 1
+	o.m.lastCommit = time.Now()
+}
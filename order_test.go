@@ -0,0 +1,51 @@
+package rill
+
+import (
+	"testing"
+	"time"
+
+	"github.com/destel/rill/internal/th"
+)
+
+func TestMonitorOrder(t *testing.T) {
+	t.Run("nil", func(t *testing.T) {
+		in, mon := MonitorOrder[int](nil)
+		th.ExpectValue(t, in, nil)
+		th.ExpectValue(t, mon.Snapshot(), OrderStats{})
+	})
+
+	t.Run("stuck item", func(t *testing.T) {
+		release := make(chan struct{})
+
+		in, mon := MonitorOrder(FromChan(th.FromRange(0, 20), nil))
+		out := OrderedMap(in, 5, func(x int) (int, error) {
+			if x == 3 {
+				<-release
+			}
+			return x, nil
+		})
+
+		done := make(chan []int)
+		go func() {
+			outSlice, _ := ToSlice(out)
+			done <- outSlice
+		}()
+
+		time.Sleep(500 * time.Millisecond)
+
+		s := mon.Snapshot()
+		th.ExpectValue(t, s.Committed, int64(3))
+		th.ExpectValue(t, s.Behind, 4) // all other workers are done and waiting
+		if s.HeadWait < 400*time.Millisecond || s.HeadWait > 5*time.Second {
+			t.Errorf("unexpected head wait: %v", s.HeadWait)
+		}
+
+		close(release)
+
+		outSlice := <-done
+		th.ExpectValue(t, len(outSlice), 20)
+		th.ExpectSorted(t, outSlice)
+
+		th.ExpectValue(t, mon.Snapshot(), OrderStats{Committed: 20})
+	})
+}
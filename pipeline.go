@@ -0,0 +1,101 @@
+package rill
+
+import (
+	"sync"
+
+	"github.com/destel/rill/internal/core"
+)
+
+// Pipeline is a handle that allows to stop the sources of a pipeline from the outside,
+// for example on shutdown or when the caller has got enough results.
+// Sources are attached to the pipeline with [WithPipeline]. After [Pipeline.StopSources] is called,
+// attached streams end, so the rest of the pipeline completes normally, processing only the items that are already in flight.
+//
+// Built-in sources, such as [FromSlice], [Range] and [Generate], stop producing items as soon as their streams are
+// stopped or drained. Custom sources can watch the [Pipeline.Stopped] channel to do the same.
+//
+//	p := rill.NewPipeline()
+//	ids := rill.WithPipeline(p, rill.Range(0, math.MaxInt, 1))
+//	users := rill.Map(ids, 10, fetchUser)
+//
+//	go func() {
+//		<-shutdown
+//		p.StopSources()
+//	}()
+//
+//	err := rill.ForEach(users, 1, saveUser)
+type Pipeline struct {
+	stop     chan struct{}
+	stopOnce sync.Once
+
+	graphMu sync.Mutex
+	graph   []StageInfo
+}
+
+// NewPipeline creates a new [Pipeline].
+func NewPipeline() *Pipeline {
+	return &Pipeline{
+		stop: make(chan struct{}),
+	}
+}
+
+// StopSources stops all sources attached to the pipeline. It's safe to call StopSources multiple times
+// and from multiple goroutines.
+func (p *Pipeline) StopSources() {
+	p.stopOnce.Do(func() {
+		close(p.stop)
+	})
+}
+
+// Stopped returns a channel that is closed when [Pipeline.StopSources] is called.
+func (p *Pipeline) Stopped() <-chan struct{} {
+	return p.stop
+}
+
+// WithPipeline attaches a source stream to the pipeline p. It returns a stream of the same items,
+// which ends as soon as [Pipeline.StopSources] is called. After that, the source stream is drained in the background,
+// which also makes built-in sources stop producing items.
+//
+// This is a non-blocking ordered function that processes items sequentially.
+//
+// See the package documentation for more information on non-blocking ordered functions and error handling.
+func WithPipeline[A any](p *Pipeline, in <-chan Try[A]) <-chan Try[A] {
+	if in == nil {
+		return nil
+	}
+
+	out := make(chan Try[A])
+	discard := core.DrainSink(in)
+
+	source := graphRef{p: p, streams: &graphStreamSet{}}
+	source.stage = p.addStage(StageInfo{Name: "source", Func: "WithPipeline", Input: -1})
+	source.record((<-chan Try[A])(out))
+
+	go func() {
+		defer close(out)
+		defer source.streams.release()
+
+		for {
+			select {
+			case <-p.stop:
+				DrainNB(in)
+				return
+
+			case a, ok := <-in:
+				if !ok {
+					return
+				}
+
+				select {
+				case out <- a:
+				case <-p.stop:
+					discard(a)
+					DrainNB(in)
+					return
+				}
+			}
+		}
+	}()
+
+	return out
+}
@@ -0,0 +1,76 @@
+package rill
+
+import (
+	"math"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/destel/rill/internal/th"
+)
+
+func TestPipeline(t *testing.T) {
+	t.Run("nil", func(t *testing.T) {
+		th.ExpectValue(t, WithPipeline[int](NewPipeline(), nil), nil)
+	})
+
+	t.Run("stop built-in source", func(t *testing.T) {
+		p := NewPipeline()
+		src := Range(0, math.MaxInt, 1)
+		in := WithPipeline(p, src)
+		in = Map(in, 5, func(x int) (int, error) { return x, nil })
+
+		var processed atomic.Int64
+		th.ExpectNotHang(t, 5*time.Second, func() {
+			err := ForEach(in, 1, func(x int) error {
+				if processed.Add(1) == 100 {
+					p.StopSources()
+					p.StopSources() // second call is a no-op
+				}
+				return nil
+			})
+			th.ExpectNoError(t, err)
+		})
+
+		if processed.Load() > 200 {
+			t.Errorf("too many items processed after stop: %d", processed.Load())
+		}
+
+		time.Sleep(100 * time.Millisecond)
+		th.ExpectDrainedChan(t, src)
+	})
+
+	t.Run("stop generator", func(t *testing.T) {
+		p := NewPipeline()
+
+		var generated atomic.Int64
+		src := Generate(func(send func(int), sendErr func(error)) {
+			for i := 0; ; i++ {
+				select {
+				case <-p.Stopped():
+					return
+				default:
+				}
+				generated.Add(1)
+				send(i)
+			}
+		})
+
+		in := WithPipeline(p, src)
+
+		th.ExpectNotHang(t, 5*time.Second, func() {
+			for x := range in {
+				if x.Value == 10 {
+					p.StopSources()
+				}
+			}
+		})
+
+		time.Sleep(100 * time.Millisecond)
+		th.ExpectDrainedChan(t, src)
+
+		n := generated.Load()
+		time.Sleep(100 * time.Millisecond)
+		th.ExpectValue(t, generated.Load(), n)
+	})
+}
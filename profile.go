@@ -0,0 +1,99 @@
+package rill
+
+import "runtime"
+
+// Profile is a named set of defaults for the stages of a pipeline. Attaching a profile to a [Stream] with
+// [Stream.WithProfile] applies it to all stages created from that stream, so teams can standardize pipeline behavior
+// without repeating the same settings at every call site:
+//
+//	err := rill.NewStream(ids).
+//		WithProfile(rill.ProfileIOHeavy).
+//		Filter(0, isActive).   // uses the concurrency of the profile
+//		Tap(1, logID).         // explicit concurrency always wins
+//		ForEach(0, saveID)
+//
+// Profiles are plain values, so they can be composed by copying and modifying the predefined ones,
+// or by combining them with [Profile.Override].
+//
+// A profile only applies to stages created with the methods of [Stream] and with the functions that take a Stream,
+// such as [MapStream]. Package-level functions, such as [Map] or [ForEach], called directly on channels don't use profiles,
+// even if these channels come from [Stream.Chan]. To apply the same settings to them, pass [StageOption] values explicitly.
+//
+// Profiles have no separate setting for the reordering window of ordered stages. An ordered stage holds at most
+// as many out-of-order items as it has goroutines, so the window is already capped by the concurrency of the profile.
+// The window of [OrderedMerge] is set explicitly for each call.
+type Profile struct {
+	Name string
+
+	// Concurrency is used by stages that are created with n <= 0.
+	// If it's not positive as well, such stages process items sequentially.
+	Concurrency int
+
+	// Buffer is the size of the output buffer added after each stage. Buffers smooth out the differences in speed
+	// between the stages, at the cost of holding more items in memory. Zero means no buffering, regardless of [SetDefaultBuffer].
+	Buffer int
+
+	// Recover makes stages convert panics in user-provided functions into errors, as if they were wrapped with [Recover].
+	Recover bool
+}
+
+// Predefined profiles for common workloads.
+var (
+	// ProfileIOHeavy is for stages that mostly wait for network or disk, such as API calls or database queries.
+	ProfileIOHeavy = Profile{Name: "io-heavy", Concurrency: 32, Buffer: 64}
+
+	// ProfileCPUHeavy is for stages that mostly compute. Running more goroutines than CPUs would not make them faster.
+	ProfileCPUHeavy = Profile{Name: "cpu-heavy", Concurrency: runtime.NumCPU(), Buffer: runtime.NumCPU()}
+
+	// ProfileLowMemory keeps the number of in-flight items small, for pipelines that process large items.
+	ProfileLowMemory = Profile{Name: "low-memory", Concurrency: 2}
+)
+
+// Override returns a copy of p, with the non-zero fields of other replacing the corresponding fields of p.
+// The Recover field can only be turned on by an override.
+//
+//	profile := rill.ProfileIOHeavy.Override(rill.Profile{Name: "io-heavy-safe", Recover: true})
+func (p Profile) Override(other Profile) Profile {
+	if other.Name != "" {
+		p.Name = other.Name
+	}
+	if other.Concurrency != 0 {
+		p.Concurrency = other.Concurrency
+	}
+	if other.Buffer != 0 {
+		p.Buffer = other.Buffer
+	}
+	if other.Recover {
+		p.Recover = true
+	}
+	return p
+}
+
+// concurrency returns n, or the default concurrency of the profile if n is not positive.
+func (p Profile) concurrency(n int) int {
+	if n > 0 {
+		return n
+	}
+	if p.Concurrency > 0 {
+		return p.Concurrency
+	}
+	return 1
+}
+
+// stageConfig returns the stage configuration that corresponds to the profile.
+func (p Profile) stageConfig() stageConfig {
+	return stageConfig{buffer: p.Buffer, recover: p.Recover}
+}
+
+// options returns the profile in the form of stage options. The zero profile has no options,
+// so stages of a stream without a profile use the package-level defaults.
+func (p Profile) options() []StageOption {
+	if p == (Profile{}) {
+		return nil
+	}
+
+	return []StageOption{func(c *stageConfig) {
+		c.buffer = p.Buffer
+		c.recover = p.Recover
+	}}
+}
@@ -0,0 +1,100 @@
+package rill
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/destel/rill/internal/th"
+)
+
+func TestProfile(t *testing.T) {
+	t.Run("override", func(t *testing.T) {
+		p := ProfileIOHeavy.Override(Profile{Name: "custom", Buffer: 5, Recover: true})
+		th.ExpectValue(t, p, Profile{Name: "custom", Concurrency: ProfileIOHeavy.Concurrency, Buffer: 5, Recover: true})
+
+		// zero fields don't override
+		th.ExpectValue(t, ProfileLowMemory.Override(Profile{}), ProfileLowMemory)
+	})
+
+	t.Run("concurrency", func(t *testing.T) {
+		for _, n := range []int{0, 3} {
+			t.Run(th.Name("n", n), func(t *testing.T) {
+				monitor := th.NewConcurrencyMonitor(1 * time.Second)
+
+				err := NewStream(FromChan(th.FromRange(0, 100), nil)).
+					WithProfile(Profile{Concurrency: 5}).
+					ForEach(n, func(x int) error {
+						monitor.Inc()
+						defer monitor.Dec()
+						time.Sleep(10 * time.Millisecond)
+						return nil
+					})
+
+				th.ExpectNoError(t, err)
+				if n > 0 {
+					th.ExpectValue(t, monitor.Max(), n)
+				} else {
+					th.ExpectValue(t, monitor.Max(), 5)
+				}
+			})
+		}
+	})
+
+	t.Run("sequential by default", func(t *testing.T) {
+		monitor := th.NewConcurrencyMonitor(1 * time.Second)
+
+		err := NewStream(FromChan(th.FromRange(0, 20), nil)).ForEach(0, func(x int) error {
+			monitor.Inc()
+			defer monitor.Dec()
+			time.Sleep(10 * time.Millisecond)
+			return nil
+		})
+
+		th.ExpectNoError(t, err)
+		th.ExpectValue(t, monitor.Max(), 1)
+	})
+
+	t.Run("buffer", func(t *testing.T) {
+		in := make(chan Try[int])
+		go func() {
+			defer close(in)
+			for i := 0; i < 10; i++ {
+				in <- Try[int]{Value: i}
+			}
+		}()
+
+		s := NewStream(in).
+			WithProfile(Profile{Buffer: 10}).
+			OrderedFilter(1, func(x int) (bool, error) { return true, nil })
+
+		// nobody reads the output yet, but all items are processed thanks to the buffer
+		time.Sleep(500 * time.Millisecond)
+		th.ExpectDrainedChan(t, in)
+
+		out, err := s.ToSlice()
+		th.ExpectNoError(t, err)
+		th.ExpectSlice(t, out, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9})
+	})
+
+	t.Run("recover", func(t *testing.T) {
+		s := NewStream(FromChan(th.FromRange(0, 10), nil)).WithProfile(Profile{Recover: true})
+
+		out := OrderedMapStream(s, 2, func(x int) (string, error) {
+			if x == 5 {
+				panic("boom")
+			}
+			return fmt.Sprint(x), nil
+		})
+		th.ExpectValue(t, out.Profile(), Profile{Recover: true})
+
+		err := out.ForEach(1, func(x string) error {
+			if strings.HasPrefix(x, "7") {
+				panic("boom7")
+			}
+			return nil
+		})
+		th.ExpectError(t, err, "rill: panic: boom")
+	})
+}
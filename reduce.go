@@ -1,7 +1,11 @@
 package rill
 
 import (
+	"sync"
+	"time"
+
 	"github.com/destel/rill/internal/core"
+	"github.com/destel/rill/internal/heap"
 )
 
 // Reduce combines all items from the input stream into a single value using a binary function f.
@@ -19,6 +23,8 @@ import (
 //
 // See the package documentation for more information on blocking unordered functions and error handling.
 func Reduce[A any](in <-chan Try[A], n int, f func(A, A) (A, error)) (result A, hasResult bool, err error) {
+	core.Claim(in)
+
 	var once core.OnceWithWait
 	setReturns := func(result1 A, hasResult1 bool, err1 error) {
 		once.Do(func() {
@@ -34,6 +40,7 @@ func Reduce[A any](in <-chan Try[A], n int, f func(A, A) (A, error)) (result A,
 
 		res, ok := core.Reduce(in, n, func(a1, a2 Try[A]) Try[A] {
 			if once.WasCalled() {
+				core.NotifyDrainStart(in)
 				return zeroTry
 			}
 
@@ -77,6 +84,8 @@ func Reduce[A any](in <-chan Try[A], n int, f func(A, A) (A, error)) (result A,
 //
 // See the package documentation for more information on blocking unordered functions and error handling.
 func MapReduce[A any, K comparable, V any](in <-chan Try[A], nm int, mapper func(A) (K, V, error), nr int, reducer func(V, V) (V, error)) (map[K]V, error) {
+	core.Claim(in)
+
 	var retMap map[K]V
 	var retErr error
 	var once core.OnceWithWait
@@ -87,6 +96,8 @@ func MapReduce[A any, K comparable, V any](in <-chan Try[A], nm int, mapper func
 		})
 	}
 
+	discard := core.DrainSink(in)
+
 	go func() {
 		var zeroKey K
 		var zeroVal V
@@ -94,6 +105,8 @@ func MapReduce[A any, K comparable, V any](in <-chan Try[A], nm int, mapper func
 		res := core.MapReduce(in,
 			nm, func(a Try[A]) (K, V) {
 				if once.WasCalled() {
+					core.NotifyDrainStart(in)
+					discard(a)
 					return zeroKey, zeroVal
 				}
 
@@ -131,3 +144,297 @@ func MapReduce[A any, K comparable, V any](in <-chan Try[A], nm int, mapper func
 	once.Wait()
 	return retMap, retErr
 }
+
+// GroupBySlice collects items from the input stream into a Go map of slices, grouping them by the key returned by the key function.
+// This covers the common case of grouping without reduction, which is awkward to express with [MapReduce],
+// since a reducer that appends slices isn't commutative.
+//
+//	ordersByUser, err := rill.GroupBySlice(orders, 1, func(o Order) (int, error) {
+//		return o.UserID, nil
+//	})
+//
+// GroupBySlice is a blocking unordered function that processes items concurrently using n goroutines.
+// When n = 1, processing becomes sequential, making the function ordered, so items within each group preserve their original order.
+//
+// See the package documentation for more information on blocking unordered functions and error handling.
+func GroupBySlice[A any, K comparable](in <-chan Try[A], n int, key func(A) (K, error)) (map[K][]A, error) {
+	var mu sync.Mutex
+	res := make(map[K][]A)
+
+	err := ForEach(in, n, func(a A) error {
+		k, err := key(a)
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		res[k] = append(res[k], a)
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// KV is a key-value pair, emitted by [MapReduceStream].
+type KV[K, V any] struct {
+	Key   K
+	Value V
+}
+
+// MapReduceStream is like [MapReduce], but returns the result as a stream of key-value pairs, instead of a map.
+// This allows to feed the reduced values into further stages of a pipeline, for example to write each of them to a database:
+//
+//	counts := rill.MapReduceStream(visits,
+//		5, func(v Visit) (string, int, error) {
+//			return v.Country, 1, nil
+//		},
+//		5, func(x, y int) (int, error) {
+//			return x + y, nil
+//		},
+//	)
+//
+//	err := rill.ForEach(counts, 10, func(kv rill.KV[string, int]) error {
+//		return saveVisitCount(kv.Key, kv.Value)
+//	})
+//
+// Reduced values are still accumulated in memory, since the value for any key is not final until the input stream ends.
+// Pairs are emitted only after that, and each of them is released from memory as soon as it's sent.
+//
+// Unlike MapReduce, errors don't stop the processing. Errors from the input stream and the mapper are forwarded
+// to the output stream as is. In case of a reducer error, it's forwarded as well, and the second of the two values is discarded.
+//
+// This is a non-blocking unordered function that processes items concurrently using nm and nr goroutines
+// for the mapper and reducer functions respectively.
+//
+// See the package documentation for more information on non-blocking unordered functions and error handling.
+func MapReduceStream[A any, K comparable, V any](in <-chan Try[A], nm int, mapper func(A) (K, V, error), nr int, reducer func(V, V) (V, error)) <-chan Try[KV[K, V]] {
+	if in == nil {
+		return nil
+	}
+
+	mapped := Map(in, nm, func(a A) (KV[K, V], error) {
+		k, v, err := mapper(a)
+		return KV[K, V]{k, v}, err
+	})
+	values, errs := ToChans(mapped)
+	if launch := core.CustomLauncherFor(in); launch != nil {
+		// the reduce phase consumes an internal channel, so pass the launcher to it explicitly
+		values = core.WithLauncher(values, launch)
+	}
+
+	out := make(chan Try[KV[K, V]])
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		for err := range errs {
+			out <- Try[KV[K, V]]{Error: err}
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+
+		res := core.MapReduce(values,
+			1, func(kv KV[K, V]) (K, V) {
+				return kv.Key, kv.Value
+			},
+			nr, func(v1, v2 V) V {
+				res, err := reducer(v1, v2)
+				if err != nil {
+					out <- Try[KV[K, V]]{Error: err}
+					return v1
+				}
+				return res
+			},
+		)
+
+		for k, v := range res {
+			out <- Try[KV[K, V]]{Value: KV[K, V]{k, v}}
+			delete(res, k)
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	return out
+}
+
+// MapReduceEvery is the streaming version of [MapReduce], meant for continuous aggregation of infinite streams.
+// It maintains the reduced map of all items received so far, and emits its snapshots to the output stream.
+// A snapshot is emitted after every count mapped items, and every interval, but only if new items were mapped since the previous one.
+// Setting count or interval to zero or a negative value disables the corresponding trigger.
+// The final snapshot is always emitted when the input stream ends, even if it's empty.
+// Each snapshot is a separate map, that can be safely used and modified by the receiver.
+//
+//	// Emit per-country visit counts every 10 seconds
+//	counts := rill.MapReduceEvery(visits,
+//		5, func(v Visit) (string, int, error) {
+//			return v.Country, 1, nil
+//		},
+//		func(x, y int) (int, error) {
+//			return x + y, nil
+//		},
+//		0, 10*time.Second,
+//	)
+//
+// The mapper function is called concurrently using nm goroutines, while the reduce phase is sequential.
+// Errors from the input stream, the mapper and the reducer are forwarded to the output stream as is. In case of a reducer error,
+// the value for the key is left unchanged.
+//
+// This is a non-blocking unordered function that processes items concurrently using nm goroutines.
+//
+// See the package documentation for more information on non-blocking unordered functions and error handling.
+func MapReduceEvery[A any, K comparable, V any](in <-chan Try[A], nm int, mapper func(A) (K, V, error), reducer func(V, V) (V, error), count int, interval time.Duration) <-chan Try[map[K]V] {
+	if in == nil {
+		return nil
+	}
+
+	mapped := Map(in, nm, func(a A) (KV[K, V], error) {
+		k, v, err := mapper(a)
+		return KV[K, V]{k, v}, err
+	})
+
+	out := make(chan Try[map[K]V])
+
+	go func() {
+		defer close(out)
+
+		var tick <-chan time.Time
+		if interval > 0 {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+
+		res := make(map[K]V)
+		pending := 0 // number of items mapped since the last snapshot
+
+		emit := func() {
+			snapshot := make(map[K]V, len(res))
+			for k, v := range res {
+				snapshot[k] = v
+			}
+			out <- Try[map[K]V]{Value: snapshot}
+			pending = 0
+		}
+
+		for {
+			select {
+			case x, ok := <-mapped:
+				if !ok {
+					emit()
+					return
+				}
+
+				if x.Error != nil {
+					out <- Try[map[K]V]{Error: x.Error}
+					continue
+				}
+
+				if old, ok := res[x.Value.Key]; ok {
+					v, err := reducer(old, x.Value.Value)
+					if err != nil {
+						out <- Try[map[K]V]{Error: err}
+						continue
+					}
+					res[x.Value.Key] = v
+				} else {
+					res[x.Value.Key] = x.Value.Value
+				}
+
+				pending++
+				if count > 0 && pending >= count {
+					emit()
+				}
+
+			case <-tick:
+				if pending > 0 {
+					emit()
+				}
+			}
+		}
+	}()
+
+	return out
+}
+
+// Fold combines all items from the input stream into a single value using a function f.
+// The accumulator starts with the seed value, and f is called for each item with the current accumulator.
+// Unlike [Reduce], the accumulator can be of a different type than the items, and f doesn't need to be
+// associative or commutative. This makes Fold a natural fit for folding a stream of events into a struct.
+//
+// This is a blocking ordered function that processes items sequentially.
+// See the package documentation for more information on blocking ordered functions and error handling.
+func Fold[A, B any](in <-chan Try[A], seed B, f func(B, A) (B, error)) (B, error) {
+	core.Claim(in)
+
+	acc := seed
+
+	for a := range in {
+		if a.Error != nil {
+			DrainNB(in)
+			return acc, a.Error
+		}
+
+		next, err := f(acc, a.Value)
+		if err != nil {
+			DrainNB(in)
+			return acc, err
+		}
+		acc = next
+	}
+
+	return acc, nil
+}
+
+// TopN returns the n largest items from the input stream, according to the less function, sorted from largest to smallest.
+// Only n items are held in memory at any time, which allows "find 100 largest files" style pipelines
+// without collecting the entire stream into a slice.
+// If the stream has fewer than n items, all of them are returned.
+//
+// This is a blocking ordered function that processes items sequentially.
+// See the package documentation for more information on blocking ordered functions and error handling.
+func TopN[A any](in <-chan Try[A], n int, less func(a, b A) bool) ([]A, error) {
+	core.Claim(in)
+
+	h := heap.New(less) // the smallest of the top items is on top of the heap
+
+	for x := range in {
+		if err := x.Error; err != nil {
+			DrainNB(in)
+			return nil, err
+		}
+
+		switch {
+		case n <= 0:
+			continue
+		case h.Len() < n:
+			h.Push(x.Value)
+		default:
+			if smallest, _ := h.Peek(); less(smallest, x.Value) {
+				h.ReplaceTop(x.Value)
+			}
+		}
+	}
+
+	if h.Len() == 0 {
+		return nil, nil
+	}
+
+	res := make([]A, h.Len())
+	for i := len(res) - 1; i >= 0; i-- {
+		res[i], _ = h.Pop()
+	}
+
+	return res, nil
+}
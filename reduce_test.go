@@ -275,3 +275,337 @@ func TestMapReduce(t *testing.T) {
 		}
 	}
 }
+
+func TestGroupBySlice(t *testing.T) {
+	byDigits := func(x int) (int, error) {
+		return len(fmt.Sprint(x)), nil
+	}
+
+	for _, n := range []int{1, 4} {
+		t.Run(th.Name("empty", n), func(t *testing.T) {
+			in := FromSlice([]int{}, nil)
+
+			out, err := GroupBySlice(in, n, byDigits)
+			th.ExpectNoError(t, err)
+			th.ExpectValue(t, len(out), 0)
+		})
+
+		t.Run(th.Name("no errors", n), func(t *testing.T) {
+			in := FromChan(th.FromRange(0, 1000), nil)
+
+			out, err := GroupBySlice(in, n, byDigits)
+			th.ExpectNoError(t, err)
+			th.ExpectValue(t, len(out), 3)
+
+			if n == 1 {
+				th.ExpectSorted(t, out[3])
+			}
+
+			th.Sort(out[1])
+			th.Sort(out[2])
+			th.Sort(out[3])
+			th.ExpectSlice(t, out[1], th.ToSlice(th.FromRange(0, 10)))
+			th.ExpectSlice(t, out[2], th.ToSlice(th.FromRange(10, 100)))
+			th.ExpectSlice(t, out[3], th.ToSlice(th.FromRange(100, 1000)))
+		})
+
+		t.Run(th.Name("error in input", n), func(t *testing.T) {
+			in := FromChan(th.FromRange(0, 1000), nil)
+			in = replaceWithError(in, 100, fmt.Errorf("err100"))
+
+			var cnt atomic.Int64
+			out, err := GroupBySlice(in, n, func(x int) (int, error) {
+				cnt.Add(1)
+				return byDigits(x)
+			})
+
+			th.ExpectError(t, err, "err100")
+			th.ExpectValue(t, out == nil, true)
+			if cnt.Load() > 900 {
+				t.Errorf("early exit did not happen")
+			}
+
+			time.Sleep(1 * time.Second)
+			th.ExpectDrainedChan(t, in)
+		})
+
+		t.Run(th.Name("error in key", n), func(t *testing.T) {
+			in := FromChan(th.FromRange(0, 1000), nil)
+
+			var cnt atomic.Int64
+			_, err := GroupBySlice(in, n, func(x int) (int, error) {
+				if cnt.Add(1) == 100 {
+					return 0, fmt.Errorf("err100")
+				}
+				return byDigits(x)
+			})
+
+			th.ExpectError(t, err, "err100")
+
+			time.Sleep(1 * time.Second)
+			th.ExpectDrainedChan(t, in)
+			if cnt.Load() > 900 {
+				t.Errorf("extra calls to key were made")
+			}
+		})
+	}
+}
+
+func TestMapReduceStream(t *testing.T) {
+	toMap := func(kvs []KV[string, int]) map[string]int {
+		m := make(map[string]int)
+		for _, kv := range kvs {
+			if _, ok := m[kv.Key]; ok {
+				t.Errorf("duplicate key %s", kv.Key)
+			}
+			m[kv.Key] = kv.Value
+		}
+		return m
+	}
+
+	t.Run("nil", func(t *testing.T) {
+		out := MapReduceStream[int](nil,
+			1, func(x int) (string, int, error) { return "", x, nil },
+			1, func(x, y int) (int, error) { return x + y, nil },
+		)
+		th.ExpectValue(t, out, nil)
+	})
+
+	for _, nm := range []int{1, 4} {
+		for _, nr := range []int{1, 4} {
+			t.Run(th.Name("empty", nm, nr), func(t *testing.T) {
+				out := MapReduceStream(FromSlice[int](nil, nil),
+					nm, func(x int) (string, int, error) { return "", x, nil },
+					nr, func(x, y int) (int, error) { return x + y, nil },
+				)
+
+				kvs, errs := toSliceAndErrors(out)
+				th.ExpectValue(t, len(kvs), 0)
+				th.ExpectValue(t, len(errs), 0)
+			})
+
+			t.Run(th.Name("correctness", nm, nr), func(t *testing.T) {
+				in := FromChan(th.FromRange(0, 1000), nil)
+				in = replaceWithError(in, 500, fmt.Errorf("err500"))
+
+				var cntReduce atomic.Int64
+				out := MapReduceStream(in,
+					nm, func(x int) (string, int, error) {
+						if x == 50 {
+							return "", 0, fmt.Errorf("err50")
+						}
+						return fmt.Sprintf("%d-digit", len(fmt.Sprint(x))), x, nil
+					},
+					nr, func(x, y int) (int, error) {
+						if cntReduce.Add(1) == 100 {
+							return 0, fmt.Errorf("err100")
+						}
+						return x + y, nil
+					},
+				)
+
+				kvs, errs := toSliceAndErrors(out)
+
+				th.Sort(errs)
+				th.ExpectSlice(t, errs, []string{"err100", "err50", "err500"})
+
+				m := toMap(kvs)
+				th.ExpectValue(t, len(m), 3)
+
+				// one value was discarded due to the reducer error
+				total := m["1-digit"] + m["2-digit"] + m["3-digit"]
+				if total >= (0+999)*1000/2-50-500 {
+					t.Errorf("expected one value to be discarded, got total %d", total)
+				}
+			})
+		}
+	}
+}
+
+func TestMapReduceEvery(t *testing.T) {
+	digits := func(x int) (string, int, error) {
+		if x == 50 {
+			return "", 0, fmt.Errorf("err50")
+		}
+		return fmt.Sprintf("%d-digit", len(fmt.Sprint(x))), x, nil
+	}
+
+	sum := func(x, y int) (int, error) {
+		return x + y, nil
+	}
+
+	t.Run("nil", func(t *testing.T) {
+		th.ExpectValue(t, MapReduceEvery[int](nil, 1, digits, sum, 10, -1), nil)
+	})
+
+	for _, nm := range []int{1, 4} {
+		t.Run(th.Name("empty", nm), func(t *testing.T) {
+			snapshots, errs := toSliceAndErrors(MapReduceEvery(FromSlice[int](nil, nil), nm, digits, sum, 10, 1*time.Second))
+			th.ExpectValue(t, len(errs), 0)
+			th.ExpectValue(t, len(snapshots), 1)
+			th.ExpectMap(t, snapshots[0], map[string]int{})
+		})
+
+		t.Run(th.Name("by count", nm), func(t *testing.T) {
+			in := FromChan(th.FromRange(0, 1000), nil)
+			in = replaceWithError(in, 500, fmt.Errorf("err500"))
+
+			snapshots, errs := toSliceAndErrors(MapReduceEvery(in, nm, digits, sum, 100, -1))
+			th.Sort(errs)
+			th.ExpectSlice(t, errs, []string{"err50", "err500"})
+
+			// 998 mapped items: 9 snapshots by count and the final one
+			th.ExpectValue(t, len(snapshots), 10)
+
+			th.ExpectMap(t, snapshots[9], map[string]int{
+				"1-digit": (0 + 9) * 10 / 2,
+				"2-digit": (10+99)*90/2 - 50,
+				"3-digit": (100+999)*900/2 - 500,
+			})
+		})
+	}
+
+	t.Run("by interval", func(t *testing.T) {
+		in := make(chan Try[int])
+		go func() {
+			defer close(in)
+			for i := 1; i <= 5; i++ {
+				in <- Try[int]{Value: i}
+			}
+			time.Sleep(1 * time.Second)
+			for i := 10; i <= 12; i++ {
+				in <- Try[int]{Value: i}
+			}
+			time.Sleep(1 * time.Second) // no new items, so no new snapshots
+		}()
+
+		snapshots, errs := toSliceAndErrors(MapReduceEvery(in, 1, digits, sum, 0, 300*time.Millisecond))
+		th.ExpectValue(t, len(errs), 0)
+
+		// one snapshot per group of items, and the final one
+		th.ExpectValue(t, len(snapshots), 3)
+		th.ExpectMap(t, snapshots[0], map[string]int{"1-digit": 15})
+		th.ExpectMap(t, snapshots[1], map[string]int{"1-digit": 15, "2-digit": 33})
+		th.ExpectMap(t, snapshots[2], map[string]int{"1-digit": 15, "2-digit": 33})
+	})
+
+	t.Run("error in reducer", func(t *testing.T) {
+		in := FromSlice([]int{1, 2, 3}, nil)
+
+		snapshots, errs := toSliceAndErrors(MapReduceEvery(in, 1, digits, func(x, y int) (int, error) {
+			if y == 2 {
+				return 0, fmt.Errorf("err2")
+			}
+			return x + y, nil
+		}, 0, -1))
+
+		th.ExpectSlice(t, errs, []string{"err2"})
+		th.ExpectValue(t, len(snapshots), 1)
+		th.ExpectMap(t, snapshots[0], map[string]int{"1-digit": 4})
+	})
+
+	t.Run("snapshots are independent", func(t *testing.T) {
+		in := FromSlice([]int{1, 2, 3}, nil)
+
+		snapshots, _ := toSliceAndErrors(MapReduceEvery(in, 1, digits, sum, 1, -1))
+		th.ExpectValue(t, len(snapshots), 4)
+		th.ExpectMap(t, snapshots[0], map[string]int{"1-digit": 1})
+		th.ExpectMap(t, snapshots[1], map[string]int{"1-digit": 3})
+		th.ExpectMap(t, snapshots[3], map[string]int{"1-digit": 6})
+	})
+}
+
+func TestFold(t *testing.T) {
+	type stats struct {
+		Count int
+		Text  string
+	}
+
+	f := func(acc stats, x int) (stats, error) {
+		if x == 50 {
+			return stats{}, fmt.Errorf("err50")
+		}
+		return stats{Count: acc.Count + 1, Text: acc.Text + fmt.Sprint(x)}, nil
+	}
+
+	t.Run("empty", func(t *testing.T) {
+		res, err := Fold(FromSlice[int](nil, nil), stats{Text: ">"}, f)
+		th.ExpectNoError(t, err)
+		th.ExpectValue(t, res, stats{Text: ">"})
+	})
+
+	t.Run("correctness", func(t *testing.T) {
+		res, err := Fold(FromChan(th.FromRange(0, 5), nil), stats{Text: ">"}, f)
+		th.ExpectNoError(t, err)
+		th.ExpectValue(t, res, stats{Count: 5, Text: ">01234"})
+	})
+
+	t.Run("error in input", func(t *testing.T) {
+		in := FromChan(th.FromRange(0, 1000), nil)
+		in = replaceWithError(in, 3, fmt.Errorf("err3"))
+
+		res, err := Fold(in, stats{}, f)
+		th.ExpectError(t, err, "err3")
+		th.ExpectValue(t, res, stats{Count: 3, Text: "012"})
+
+		time.Sleep(1 * time.Second)
+		th.ExpectDrainedChan(t, in)
+	})
+
+	t.Run("error in f", func(t *testing.T) {
+		in := FromChan(th.FromRange(0, 1000), nil)
+
+		res, err := Fold(in, stats{}, f)
+		th.ExpectError(t, err, "err50")
+		th.ExpectValue(t, res.Count, 50)
+
+		time.Sleep(1 * time.Second)
+		th.ExpectDrainedChan(t, in)
+	})
+}
+
+func TestTopN(t *testing.T) {
+	less := func(a, b int) bool { return a < b }
+
+	t.Run("empty", func(t *testing.T) {
+		res, err := TopN(FromSlice[int](nil, nil), 3, less)
+		th.ExpectNoError(t, err)
+		th.ExpectSlice(t, res, nil)
+	})
+
+	t.Run("fewer than n", func(t *testing.T) {
+		res, err := TopN(FromSlice([]int{3, 1, 2}, nil), 5, less)
+		th.ExpectNoError(t, err)
+		th.ExpectSlice(t, res, []int{3, 2, 1})
+	})
+
+	t.Run("zero n", func(t *testing.T) {
+		res, err := TopN(FromSlice([]int{3, 1, 2}, nil), 0, less)
+		th.ExpectNoError(t, err)
+		th.ExpectSlice(t, res, nil)
+	})
+
+	t.Run("correctness", func(t *testing.T) {
+		// shuffled numbers from 0 to 999
+		inSlice := make([]int, 1000)
+		for i := range inSlice {
+			inSlice[i] = (i * 7919) % 1000
+		}
+
+		res, err := TopN(FromSlice(inSlice, nil), 5, less)
+		th.ExpectNoError(t, err)
+		th.ExpectSlice(t, res, []int{999, 998, 997, 996, 995})
+	})
+
+	t.Run("error", func(t *testing.T) {
+		in := FromChan(th.FromRange(0, 1000), nil)
+		in = replaceWithError(in, 100, fmt.Errorf("err100"))
+
+		res, err := TopN(in, 5, less)
+		th.ExpectError(t, err, "err100")
+		th.ExpectSlice(t, res, nil)
+
+		time.Sleep(1 * time.Second)
+		th.ExpectDrainedChan(t, in)
+	})
+}
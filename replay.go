@@ -0,0 +1,67 @@
+package rill
+
+import (
+	"github.com/destel/rill/internal/core"
+)
+
+// Replayable holds a fully materialized stream, that can be re-emitted any number of times. See [Materialize].
+// It's safe for concurrent use.
+type Replayable[A any] struct {
+	items []Try[A]
+}
+
+// Materialize consumes the whole input stream and stores all its items, both values and errors, in their original order.
+// The result can be re-emitted any number of times using [Replayable.Stream]. This is useful for debugging,
+// multi-pass algorithms over small streams, and tests. For convenience, the first error encountered in the stream
+// is returned as well, but unlike other blocking functions, Materialize does not stop on errors.
+// The returned [Replayable] is never nil.
+//
+// This is a blocking ordered function that processes items sequentially.
+// See the package documentation for more information on blocking ordered functions and error handling.
+func Materialize[A any](in <-chan Try[A]) (*Replayable[A], error) {
+	core.Claim(in)
+
+	var items []Try[A]
+	var firstErr error
+
+	for a := range in {
+		if a.Error != nil && firstErr == nil {
+			firstErr = a.Error
+		}
+		items = append(items, a)
+	}
+
+	return &Replayable[A]{items: items}, firstErr
+}
+
+// Len returns the number of items, including errors.
+func (r *Replayable[A]) Len() int {
+	return len(r.items)
+}
+
+// Items returns a copy of all items, including errors, in their original order.
+func (r *Replayable[A]) Items() []Try[A] {
+	return append([]Try[A](nil), r.items...)
+}
+
+// Stream returns a new stream of all items, including errors, in their original order.
+// Each call returns an independent stream.
+func (r *Replayable[A]) Stream() <-chan Try[A] {
+	out := make(chan Try[A])
+	stop, release := core.StopOnDrain((<-chan Try[A])(out))
+
+	go func() {
+		defer close(out)
+		defer release()
+
+		for _, a := range r.items {
+			select {
+			case out <- a:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	return out
+}
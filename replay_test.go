@@ -0,0 +1,65 @@
+package rill
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/destel/rill/internal/th"
+)
+
+func TestMaterialize(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		r, err := Materialize(FromSlice[int](nil, nil))
+		th.ExpectNoError(t, err)
+		th.ExpectValue(t, r.Len(), 0)
+		th.ExpectValue(t, len(th.ToSlice(r.Stream())), 0)
+	})
+
+	t.Run("correctness", func(t *testing.T) {
+		in := FromChan(th.FromRange(0, 20), nil)
+		in = replaceWithError(in, 5, fmt.Errorf("err05"))
+		in = replaceWithError(in, 15, fmt.Errorf("err15"))
+
+		r, err := Materialize(in)
+		th.ExpectError(t, err, "err05")
+		th.ExpectValue(t, r.Len(), 20)
+
+		expectedValues := make([]int, 0, 18)
+		for i := 0; i < 20; i++ {
+			if i != 5 && i != 15 {
+				expectedValues = append(expectedValues, i)
+			}
+		}
+
+		// can be replayed multiple times
+		for i := 0; i < 3; i++ {
+			values, errs := toSliceAndErrors(r.Stream())
+			th.ExpectSlice(t, values, expectedValues)
+			th.ExpectSlice(t, errs, []string{"err05", "err15"})
+		}
+	})
+
+	t.Run("items are copied", func(t *testing.T) {
+		r, err := Materialize(FromSlice([]int{1, 2, 3}, nil))
+		th.ExpectNoError(t, err)
+
+		items := r.Items()
+		items[0].Value = 100
+
+		values, _ := toSliceAndErrors(r.Stream())
+		th.ExpectSlice(t, values, []int{1, 2, 3})
+	})
+
+	t.Run("early exit", func(t *testing.T) {
+		r, err := Materialize(FromChan(th.FromRange(0, 1000), nil))
+		th.ExpectNoError(t, err)
+
+		out := r.Stream()
+		_, _, err = First(out)
+		th.ExpectNoError(t, err)
+
+		time.Sleep(1 * time.Second)
+		th.ExpectDrainedChan(t, out)
+	})
+}
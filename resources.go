@@ -0,0 +1,148 @@
+package rill
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+// Resources is a registry of resources, such as files, cursors and connections, that must be closed
+// when a pipeline completes. Sources and stages register resources as they open them,
+// and [WithResources] closes all of them exactly once, when the stream ends. This also covers early termination,
+// since blocking functions drain the remaining items in the background, which eventually ends the stream.
+//
+//	res := rill.NewResources()
+//	lines := rill.Generate(func(send func(string), sendErr func(error)) {
+//		f, err := os.Open(path)
+//		if err != nil {
+//			sendErr(err)
+//			return
+//		}
+//		res.Add(f)
+//		// read lines and send them
+//	})
+//	lines = rill.WithResources(lines, res)
+//
+// Resources is safe for concurrent use. The zero value is ready to use.
+type Resources struct {
+	mu      sync.Mutex
+	closers []io.Closer
+	closed  bool
+}
+
+// NewResources creates a new empty [Resources] registry.
+func NewResources() *Resources {
+	return &Resources{}
+}
+
+// Add registers a resource to be closed. If the registry has already been closed, the resource is closed immediately.
+func (r *Resources) Add(c io.Closer) {
+	r.mu.Lock()
+	if !r.closed {
+		r.closers = append(r.closers, c)
+		r.mu.Unlock()
+		return
+	}
+	r.mu.Unlock()
+
+	_ = c.Close()
+}
+
+// AddFunc registers a cleanup function. It's a shorthand for [Resources.Add] with a function instead of an io.Closer.
+func (r *Resources) AddFunc(f func() error) {
+	r.Add(closerFunc(f))
+}
+
+// Close closes all registered resources in the reverse order of registration, similarly to deferred calls.
+// It returns all errors joined together. Subsequent calls do nothing and return nil.
+func (r *Resources) Close() error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil
+	}
+	r.closed = true
+	closers := r.closers
+	r.closers = nil
+	r.mu.Unlock()
+
+	var errs []error
+	for i := len(closers) - 1; i >= 0; i-- {
+		if err := closers[i].Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+type closerFunc func() error
+
+func (f closerFunc) Close() error {
+	return f()
+}
+
+// WithResources forwards all items from the input stream to the output stream, and closes the resources in r
+// after the input stream ends. Errors returned by closing the resources are sent to the output stream,
+// right before it is closed.
+//
+// This is a non-blocking ordered function that processes items sequentially.
+//
+// See the package documentation for more information on non-blocking ordered functions and error handling.
+func WithResources[A any](in <-chan Try[A], r *Resources) <-chan Try[A] {
+	if in == nil {
+		return nil
+	}
+
+	out := make(chan Try[A])
+	go func() {
+		defer close(out)
+
+		for a := range in {
+			out <- a
+		}
+
+		if err := r.Close(); err != nil {
+			out <- Try[A]{Error: err}
+		}
+	}()
+
+	return out
+}
+
+// Finally forwards all items from the input stream to the output stream, and calls f exactly once after the input stream ends,
+// right before the output stream is closed. The first error encountered in the stream, or nil, is passed to f.
+// This is useful for releasing resources tied to the lifetime of a stream, such as database connections or temporary files.
+//
+//	rows := rill.Finally(streamRows(db), func(err error) {
+//		conn.Close()
+//	})
+//
+// If the output stream is drained after an early return of a downstream blocking function, f is called once the draining is complete.
+// For cleanup that can fail, see [WithResources].
+//
+// This is a non-blocking ordered function that processes items sequentially.
+//
+// See the package documentation for more information on non-blocking ordered functions and error handling.
+func Finally[A any](in <-chan Try[A], f func(err error)) <-chan Try[A] {
+	if in == nil {
+		return nil
+	}
+
+	out := make(chan Try[A])
+	go func() {
+		defer close(out)
+
+		var firstErr error
+		for a := range in {
+			if a.Error != nil && firstErr == nil {
+				firstErr = a.Error
+			}
+			out <- a
+		}
+
+		f(firstErr)
+	}()
+
+	return out
+}
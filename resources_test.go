@@ -0,0 +1,135 @@
+package rill
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/destel/rill/internal/th"
+)
+
+func TestResources(t *testing.T) {
+	t.Run("close order", func(t *testing.T) {
+		var closed []int
+		r := NewResources()
+		for i := 0; i < 3; i++ {
+			i := i
+			r.AddFunc(func() error {
+				closed = append(closed, i)
+				return nil
+			})
+		}
+
+		th.ExpectNoError(t, r.Close())
+		th.ExpectSlice(t, closed, []int{2, 1, 0})
+
+		// second close is a no-op
+		th.ExpectNoError(t, r.Close())
+		th.ExpectSlice(t, closed, []int{2, 1, 0})
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		var r Resources
+		r.AddFunc(func() error { return fmt.Errorf("err1") })
+		r.AddFunc(func() error { return nil })
+		r.AddFunc(func() error { return fmt.Errorf("err3") })
+
+		th.ExpectError(t, r.Close(), "err3\nerr1")
+	})
+
+	t.Run("add after close", func(t *testing.T) {
+		r := NewResources()
+		th.ExpectNoError(t, r.Close())
+
+		closed := false
+		r.AddFunc(func() error {
+			closed = true
+			return nil
+		})
+		th.ExpectValue(t, closed, true)
+	})
+}
+
+func TestWithResources(t *testing.T) {
+	t.Run("nil", func(t *testing.T) {
+		th.ExpectValue(t, WithResources[int](nil, NewResources()), nil)
+	})
+
+	t.Run("completion", func(t *testing.T) {
+		r := NewResources()
+		r.AddFunc(func() error { return fmt.Errorf("close error") })
+
+		in := FromChan(th.FromRange(0, 10), nil)
+		outSlice, errs := toSliceAndErrors(WithResources(in, r))
+
+		th.ExpectSlice(t, outSlice, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9})
+		th.ExpectSlice(t, errs, []string{"close error"})
+	})
+
+	t.Run("early termination", func(t *testing.T) {
+		closed := make(chan struct{})
+
+		r := NewResources()
+		r.AddFunc(func() error {
+			close(closed)
+			return nil
+		})
+
+		in := FromChan(th.FromRange(0, 1000), nil)
+		in = replaceWithError(in, 100, fmt.Errorf("err100"))
+
+		_, err := ToSlice(WithResources(in, r))
+		th.ExpectError(t, err, "err100")
+
+		select {
+		case <-closed:
+		case <-time.After(1 * time.Second):
+			t.Errorf("resources were not closed")
+		}
+	})
+}
+
+func TestFinally(t *testing.T) {
+	t.Run("nil", func(t *testing.T) {
+		th.ExpectValue(t, Finally[int](nil, func(error) {}), nil)
+	})
+
+	t.Run("no errors", func(t *testing.T) {
+		var calls int
+		var finalErr error
+
+		in := FromChan(th.FromRange(0, 10), nil)
+		outSlice, errs := toSliceAndErrors(Finally(in, func(err error) {
+			calls++
+			finalErr = err
+		}))
+
+		th.ExpectSlice(t, outSlice, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9})
+		th.ExpectValue(t, len(errs), 0)
+		th.ExpectValue(t, calls, 1)
+		th.ExpectNoError(t, finalErr)
+	})
+
+	t.Run("first error", func(t *testing.T) {
+		done := make(chan error, 10)
+
+		in := FromChan(th.FromRange(0, 1000), nil)
+		in = replaceWithError(in, 100, fmt.Errorf("err100"))
+		in = replaceWithError(in, 200, fmt.Errorf("err200"))
+
+		_, err := ToSlice(Finally(in, func(err error) {
+			done <- err
+		}))
+		th.ExpectError(t, err, "err100")
+
+		select {
+		case err := <-done:
+			th.ExpectError(t, err, "err100")
+		case <-time.After(1 * time.Second):
+			t.Fatalf("f was not called")
+		}
+
+		time.Sleep(100 * time.Millisecond)
+		th.ExpectValue(t, len(done), 0)
+	})
+}
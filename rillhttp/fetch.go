@@ -0,0 +1,164 @@
+// Package rillhttp provides ready-made pipeline stages for working with HTTP.
+// Downloading a stream of URLs concurrently is one of the most common use cases for rill,
+// and this package takes care of the boilerplate: timeouts, retries, status code checks and body size limits.
+package rillhttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/destel/rill"
+)
+
+// Options configure the behavior of [FetchURLs]. The zero value is a valid configuration.
+type Options struct {
+	// Client is used to make requests. If nil, http.DefaultClient is used.
+	Client *http.Client
+
+	// Timeout limits the duration of each attempt, including reading the body. Zero means no timeout.
+	Timeout time.Duration
+
+	// Retries is the number of additional attempts made after a failed one.
+	// Network errors, timeouts, 429 and 5xx responses are retried. Other errors are returned immediately.
+	Retries int
+
+	// RetryDelay is the delay before the first retry. It is doubled after each subsequent retry.
+	RetryDelay time.Duration
+
+	// AcceptStatus reports whether a response with the given status code is successful.
+	// If nil, only 2xx status codes are accepted.
+	AcceptStatus func(code int) bool
+
+	// MaxBodySize limits the size of the response body in bytes. Larger bodies result in an error.
+	// Zero means no limit.
+	MaxBodySize int64
+}
+
+// Response is the result of fetching a single URL.
+type Response struct {
+	URL        string
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// StatusError is returned when the server responds with a status code that is not accepted.
+type StatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("rillhttp: %s: unexpected status %d", e.URL, e.StatusCode)
+}
+
+// ErrBodyTooLarge is returned when the response body exceeds [Options.MaxBodySize].
+var ErrBodyTooLarge = errors.New("rillhttp: response body is too large")
+
+// FetchURLs downloads the URLs from the input stream using n goroutines and returns a stream of responses.
+// If opts is nil, default options are used. Canceling the context aborts in-flight requests and pending retries.
+//
+// This is a non-blocking unordered function. Use [rill.OrderedMap] with [Fetch] if the order of responses matters.
+func FetchURLs(ctx context.Context, urls <-chan rill.Try[string], n int, opts *Options) <-chan rill.Try[Response] {
+	return rill.Map(urls, n, func(url string) (Response, error) {
+		return Fetch(ctx, url, opts)
+	})
+}
+
+// Fetch downloads a single URL, applying the timeouts, retries and limits from opts.
+// If opts is nil, default options are used.
+func Fetch(ctx context.Context, url string, opts *Options) (Response, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	delay := opts.RetryDelay
+	for attempt := 0; ; attempt++ {
+		res, err := fetchOnce(ctx, url, opts)
+		if err == nil || attempt >= opts.Retries || !isRetryable(ctx, err) {
+			return res, err
+		}
+
+		if delay > 0 {
+			t := time.NewTimer(delay)
+			select {
+			case <-t.C:
+			case <-ctx.Done():
+				t.Stop()
+				return Response{}, ctx.Err()
+			}
+			delay *= 2
+		}
+	}
+}
+
+func fetchOnce(ctx context.Context, url string, opts *Options) (Response, error) {
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return Response{}, err
+	}
+
+	client := opts.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return Response{}, err
+	}
+	defer resp.Body.Close()
+
+	accept := opts.AcceptStatus
+	if accept == nil {
+		accept = func(code int) bool { return code >= 200 && code < 300 }
+	}
+	if !accept(resp.StatusCode) {
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096)) // allow connection reuse
+		return Response{}, &StatusError{URL: url, StatusCode: resp.StatusCode}
+	}
+
+	var body io.Reader = resp.Body
+	if opts.MaxBodySize > 0 {
+		body = io.LimitReader(resp.Body, opts.MaxBodySize+1)
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return Response{}, err
+	}
+	if opts.MaxBodySize > 0 && int64(len(data)) > opts.MaxBodySize {
+		return Response{}, ErrBodyTooLarge
+	}
+
+	return Response{
+		URL:        url,
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       data,
+	}, nil
+}
+
+func isRetryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false // parent context is done
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= 500
+	}
+
+	// network errors and per-attempt timeouts
+	return !errors.Is(err, ErrBodyTooLarge)
+}
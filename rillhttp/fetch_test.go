@@ -0,0 +1,145 @@
+package rillhttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/destel/rill"
+	"github.com/destel/rill/internal/th"
+)
+
+func TestFetch(t *testing.T) {
+	var flakyCalls, overloadedCalls atomic.Int64
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "hello")
+	})
+	mux.HandleFunc("/big", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, strings.Repeat("x", 100))
+	})
+	mux.HandleFunc("/missing", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not found", http.StatusNotFound)
+	})
+	mux.HandleFunc("/flaky", func(w http.ResponseWriter, r *http.Request) {
+		if flakyCalls.Add(1) < 3 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, "finally")
+	})
+	mux.HandleFunc("/overloaded", func(w http.ResponseWriter, r *http.Request) {
+		overloadedCalls.Add(1)
+		http.Error(w, "slow down", http.StatusTooManyRequests)
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(500 * time.Millisecond)
+		fmt.Fprint(w, "slow")
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	ctx := context.Background()
+
+	t.Run("ok", func(t *testing.T) {
+		res, err := Fetch(ctx, srv.URL+"/ok", nil)
+		th.ExpectNoError(t, err)
+		th.ExpectValue(t, res.StatusCode, 200)
+		th.ExpectValue(t, string(res.Body), "hello")
+	})
+
+	t.Run("status", func(t *testing.T) {
+		_, err := Fetch(ctx, srv.URL+"/missing", &Options{Retries: 3})
+
+		var statusErr *StatusError
+		if !errors.As(err, &statusErr) {
+			t.Fatalf("expected StatusError, got %v", err)
+		}
+		th.ExpectValue(t, statusErr.StatusCode, 404)
+	})
+
+	t.Run("accept status", func(t *testing.T) {
+		res, err := Fetch(ctx, srv.URL+"/missing", &Options{
+			AcceptStatus: func(code int) bool { return code == 404 },
+		})
+		th.ExpectNoError(t, err)
+		th.ExpectValue(t, res.StatusCode, 404)
+	})
+
+	t.Run("retries", func(t *testing.T) {
+		res, err := Fetch(ctx, srv.URL+"/flaky", &Options{Retries: 5, RetryDelay: 10 * time.Millisecond})
+		th.ExpectNoError(t, err)
+		th.ExpectValue(t, string(res.Body), "finally")
+		th.ExpectValue(t, flakyCalls.Load(), int64(3))
+	})
+
+	t.Run("retries exhausted", func(t *testing.T) {
+		_, err := Fetch(ctx, srv.URL+"/overloaded", &Options{Retries: 2})
+
+		var statusErr *StatusError
+		if !errors.As(err, &statusErr) {
+			t.Fatalf("expected StatusError, got %v", err)
+		}
+		th.ExpectValue(t, overloadedCalls.Load(), int64(3))
+	})
+
+	t.Run("body size", func(t *testing.T) {
+		_, err := Fetch(ctx, srv.URL+"/big", &Options{MaxBodySize: 100})
+		th.ExpectNoError(t, err)
+
+		_, err = Fetch(ctx, srv.URL+"/big", &Options{MaxBodySize: 99})
+		th.ExpectError(t, err, ErrBodyTooLarge.Error())
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		_, err := Fetch(ctx, srv.URL+"/slow", &Options{Timeout: 100 * time.Millisecond})
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected deadline exceeded, got %v", err)
+		}
+	})
+
+	t.Run("canceled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+
+		_, err := Fetch(ctx, srv.URL+"/ok", &Options{Retries: 5})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context canceled, got %v", err)
+		}
+	})
+}
+
+func TestFetchURLs(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, r.URL.Path)
+	}))
+	defer srv.Close()
+
+	urls := rill.FromSlice([]string{srv.URL + "/a", srv.URL + "/missing", srv.URL + "/b"}, nil)
+
+	var bodies []string
+	var errs []string
+	for res := range FetchURLs(context.Background(), urls, 2, nil) {
+		if res.Error != nil {
+			errs = append(errs, res.Error.Error())
+			continue
+		}
+		bodies = append(bodies, string(res.Value.Body))
+	}
+
+	th.Sort(bodies)
+	th.ExpectSlice(t, bodies, []string{"/a", "/b"})
+	th.ExpectSlice(t, errs, []string{"rillhttp: " + srv.URL + "/missing: unexpected status 404"})
+}
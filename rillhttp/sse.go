@@ -0,0 +1,264 @@
+package rillhttp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/destel/rill"
+	"github.com/destel/rill/internal/core"
+)
+
+// Event is a single Server-Sent Event.
+type Event struct {
+	// ID is the last event ID set by the server. It may have been set by one of the previous events.
+	ID string
+
+	// Type is the event type. It is "message" unless the server specified another one.
+	Type string
+
+	// Data is the event payload. Multiple data lines are joined with "\n".
+	Data string
+
+	// Retry is the reconnection delay requested by the server along with this event, or zero.
+	Retry time.Duration
+}
+
+// SSEOptions configure the behavior of [FromSSE]. The zero value is a valid configuration.
+type SSEOptions struct {
+	// Client is used to make requests. If nil, http.DefaultClient is used.
+	// The client should not have a timeout, since event streams are long-lived.
+	Client *http.Client
+
+	// Header holds additional request headers, such as Authorization.
+	Header http.Header
+
+	// MaxReconnects is the number of consecutive reconnection attempts made after the connection is lost.
+	// The counter is reset after each received event. Zero means no reconnection, negative means unlimited attempts.
+	MaxReconnects int
+
+	// ReconnectDelay is the delay before each reconnection attempt, unless the server has requested another one.
+	// Defaults to 3 seconds.
+	ReconnectDelay time.Duration
+}
+
+const defaultReconnectDelay = 3 * time.Second
+
+// errNoContent signals that the server has asked the client to stop reconnecting.
+var errNoContent = errors.New("rillhttp: no content")
+
+// FromSSE connects to the Server-Sent Events endpoint at url, and returns a stream of received events.
+//
+// When the connection is lost, FromSSE reconnects, sending the ID of the last received event in the Last-Event-ID header,
+// so the server can resume the stream. If the connection can't be restored after [SSEOptions.MaxReconnects] attempts,
+// the last error is sent to the output stream, and the stream is closed. The stream is also closed when the server
+// responds with 204 No Content. Other responses with a non-2xx status code result in a [StatusError] without reconnection.
+//
+//	events := rillhttp.FromSSE(ctx, "https://example.com/events", &rillhttp.SSEOptions{MaxReconnects: -1})
+//	err := rill.ForEach(events, 1, func(e rillhttp.Event) error {
+//		return handle(e)
+//	})
+//
+// Canceling the context closes the connection, and sends ctx.Err() to the output stream.
+// The connection is also closed when the output stream is drained. If opts is nil, default options are used.
+func FromSSE(ctx context.Context, url string, opts *SSEOptions) <-chan rill.Try[Event] {
+	if opts == nil {
+		opts = &SSEOptions{}
+	}
+
+	out := make(chan rill.Try[Event])
+	stop, release := core.StopOnDrain((<-chan rill.Try[Event])(out))
+
+	go func() {
+		defer close(out)
+		defer release()
+
+		// abort the in-flight request as soon as the output starts to be drained
+		connCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go func() {
+			select {
+			case <-stop:
+				cancel()
+			case <-connCtx.Done():
+			}
+		}()
+
+		send := func(x rill.Try[Event]) bool {
+			select {
+			case out <- x:
+				return true
+			case <-stop:
+				return false
+			}
+		}
+
+		state := sseState{retry: opts.ReconnectDelay}
+		if state.retry <= 0 {
+			state.retry = defaultReconnectDelay
+		}
+		attempts := 0
+
+		for {
+			received := false
+			err := readSSE(connCtx, url, opts, &state, func(e Event) bool {
+				received = true
+				return send(rill.Try[Event]{Value: e})
+			})
+
+			select {
+			case <-stop:
+				return
+			default:
+			}
+
+			if err := ctx.Err(); err != nil {
+				send(rill.Try[Event]{Error: err})
+				return
+			}
+
+			var statusErr *StatusError
+			switch {
+			case errors.Is(err, errNoContent):
+				return
+			case errors.As(err, &statusErr):
+				send(rill.Try[Event]{Error: err})
+				return
+			case err == nil:
+				err = io.ErrUnexpectedEOF
+			}
+
+			if received {
+				attempts = 0
+			}
+			if opts.MaxReconnects >= 0 && attempts >= opts.MaxReconnects {
+				send(rill.Try[Event]{Error: err})
+				return
+			}
+			attempts++
+
+			timer := time.NewTimer(state.retry)
+			select {
+			case <-timer.C:
+			case <-connCtx.Done():
+				timer.Stop()
+			}
+		}
+	}()
+
+	return out
+}
+
+// sseState is the part of the parser state that is kept across reconnections.
+type sseState struct {
+	lastID string
+	retry  time.Duration
+}
+
+// readSSE makes a single request to the event stream and passes every received event to the emit function,
+// until the stream ends, an error occurs or emit returns false.
+func readSSE(ctx context.Context, url string, opts *SSEOptions, state *sseState, emit func(Event) bool) error {
+	client := opts.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	for k, vs := range opts.Header {
+		for _, v := range vs {
+			req.Header.Add(k, v)
+		}
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Cache-Control", "no-cache")
+	if state.lastID != "" {
+		req.Header.Set("Last-Event-ID", state.lastID)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNoContent:
+		return errNoContent
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return &StatusError{URL: url, StatusCode: resp.StatusCode}
+	}
+
+	return parseSSE(resp.Body, state, emit)
+}
+
+// parseSSE parses the event stream format, as described in the HTML specification.
+// The last event ID and the reconnection delay are updated in state as they are received.
+func parseSSE(r io.Reader, state *sseState, emit func(Event) bool) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+
+	var data strings.Builder
+	var hasData bool
+	var e Event
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if line == "" {
+			// dispatch the event; events without data are ignored
+			if hasData {
+				e.ID = state.lastID
+				e.Data = data.String()
+				if e.Type == "" {
+					e.Type = "message"
+				}
+				if !emit(e) {
+					return nil
+				}
+			}
+
+			data.Reset()
+			hasData = false
+			e = Event{}
+			continue
+		}
+
+		if strings.HasPrefix(line, ":") {
+			continue // comment
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+
+		switch field {
+		case "event":
+			e.Type = value
+		case "data":
+			if hasData {
+				data.WriteByte('\n')
+			}
+			data.WriteString(value)
+			hasData = true
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				state.lastID = value
+			}
+		case "retry":
+			if ms, err := strconv.Atoi(value); err == nil && ms >= 0 {
+				e.Retry = time.Duration(ms) * time.Millisecond
+				state.retry = e.Retry
+			}
+		}
+	}
+
+	// an incomplete event at the end of the stream is discarded
+	return scanner.Err()
+}
@@ -0,0 +1,166 @@
+package rillhttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/destel/rill"
+	"github.com/destel/rill/internal/th"
+)
+
+func TestParseSSE(t *testing.T) {
+	input := strings.Join([]string{
+		": comment",
+		"data: first",
+		"",
+		"id: 1",
+		"event: update",
+		"data: line1",
+		"data:line2",
+		"retry: 250",
+		"",
+		"id: 2",
+		"", // no data, only updates the last event ID
+		"data: third",
+		"",
+		"data: incomplete",
+	}, "\n")
+
+	var state sseState
+	var events []Event
+	err := parseSSE(strings.NewReader(input), &state, func(e Event) bool {
+		events = append(events, e)
+		return true
+	})
+
+	th.ExpectNoError(t, err)
+	th.ExpectSlice(t, events, []Event{
+		{Type: "message", Data: "first"},
+		{ID: "1", Type: "update", Data: "line1\nline2", Retry: 250 * time.Millisecond},
+		{ID: "2", Type: "message", Data: "third"},
+	})
+	th.ExpectValue(t, state.lastID, "2")
+	th.ExpectValue(t, state.retry, 250*time.Millisecond)
+}
+
+func TestFromSSE(t *testing.T) {
+	var mu sync.Mutex
+	var lastEventIDs []string
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/events", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		lastEventIDs = append(lastEventIDs, r.Header.Get("Last-Event-ID"))
+		mu.Unlock()
+
+		// send 3 events per connection, resuming after the last received one
+		start := 0
+		if id := r.Header.Get("Last-Event-ID"); id != "" {
+			fmt.Sscan(id, &start)
+		}
+		if start >= 6 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		for i := start + 1; i <= start+3; i++ {
+			fmt.Fprintf(w, "id: %d\ndata: event%d\n\n", i, i)
+		}
+	})
+	mux.HandleFunc("/missing", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not found", http.StatusNotFound)
+	})
+	mux.HandleFunc("/endless", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for i := 0; ; i++ {
+			if _, err := fmt.Fprintf(w, "data: %d\n\n", i); err != nil {
+				return
+			}
+			w.(http.Flusher).Flush()
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	t.Run("reconnect", func(t *testing.T) {
+		lastEventIDs = nil
+
+		events, err := rill.ToSlice(FromSSE(context.Background(), srv.URL+"/events", &SSEOptions{
+			MaxReconnects:  5,
+			ReconnectDelay: 10 * time.Millisecond,
+		}))
+		th.ExpectNoError(t, err)
+
+		var data []string
+		for _, e := range events {
+			data = append(data, e.Data)
+		}
+		th.ExpectSlice(t, data, []string{"event1", "event2", "event3", "event4", "event5", "event6"})
+		th.ExpectSlice(t, lastEventIDs, []string{"", "3", "6"})
+	})
+
+	t.Run("no reconnect", func(t *testing.T) {
+		events, err := rill.ToSlice(FromSSE(context.Background(), srv.URL+"/events", nil))
+		th.ExpectValue(t, len(events), 3)
+		if !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("expected io.ErrUnexpectedEOF, got %v", err)
+		}
+	})
+
+	t.Run("status", func(t *testing.T) {
+		_, err := rill.ToSlice(FromSSE(context.Background(), srv.URL+"/missing", &SSEOptions{MaxReconnects: -1}))
+
+		var statusErr *StatusError
+		if !errors.As(err, &statusErr) {
+			t.Fatalf("expected StatusError, got %v", err)
+		}
+		th.ExpectValue(t, statusErr.StatusCode, 404)
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		th.ExpectNotHang(t, 5*time.Second, func() {
+			err := rill.ForEach(FromSSE(ctx, srv.URL+"/endless", nil), 1, func(e Event) error {
+				if e.Data == "5" {
+					cancel()
+				}
+				return nil
+			})
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("expected context.Canceled, got %v", err)
+			}
+		})
+	})
+
+	t.Run("early exit", func(t *testing.T) {
+		th.ExpectNotHang(t, 5*time.Second, func() {
+			events := FromSSE(context.Background(), srv.URL+"/endless", nil)
+
+			e, ok, err := rill.First(events)
+			th.ExpectNoError(t, err)
+			th.ExpectValue(t, ok, true)
+			th.ExpectValue(t, e.Data, "0")
+
+			// the connection is closed, so the stream ends without waiting for a reconnection delay
+			time.Sleep(500 * time.Millisecond)
+			th.ExpectDrainedChan(t, events)
+		})
+	})
+}
@@ -0,0 +1,236 @@
+// Package rillprom exposes metrics of rill pipelines in the Prometheus text exposition format.
+// It tracks per-stage throughput, in-flight items, errors and batch sizes, which are the first things to look at
+// when a long-running worker slows down or starts failing.
+//
+// The package has no dependencies. A [Registry] implements http.Handler, so it can be mounted as a separate scrape endpoint,
+// or its output can be appended to the output of an existing metrics handler with [Registry.WriteTo].
+//
+//	metrics := rillprom.NewRegistry("worker")
+//	http.Handle("/metrics/rill", metrics)
+//
+//	users := rill.Map(ids, 10, rillprom.Instrument(metrics, "fetch", fetchUser))
+//	batches := rillprom.ObserveBatches(metrics, "batch", rill.Batch(users, 100, time.Second))
+//	err := rill.ForEach(batches, 1, rillprom.InstrumentErr(metrics, "save", saveUsers))
+package rillprom
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/destel/rill"
+)
+
+// batchSizeBuckets are the upper bounds of the batch size histogram buckets.
+var batchSizeBuckets = []float64{1, 2, 5, 10, 20, 50, 100, 200, 500, 1000}
+
+// Registry holds metrics of all instrumented stages. It's safe for concurrent use.
+type Registry struct {
+	namespace string
+
+	mu     sync.Mutex
+	stages map[string]*stageMetrics
+}
+
+type stageMetrics struct {
+	items    atomic.Int64
+	errors   atomic.Int64
+	inFlight atomic.Int64
+
+	batchMu      sync.Mutex
+	batchBuckets []int64 // non-cumulative, summed up on write
+	batchCount   int64
+	batchSum     int64
+}
+
+// NewRegistry creates a new [Registry]. All metric names are prefixed with the namespace, which defaults to "rill".
+func NewRegistry(namespace string) *Registry {
+	if namespace == "" {
+		namespace = "rill"
+	}
+
+	return &Registry{
+		namespace: namespace,
+		stages:    make(map[string]*stageMetrics),
+	}
+}
+
+func (r *Registry) stage(name string) *stageMetrics {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s, ok := r.stages[name]
+	if !ok {
+		s = &stageMetrics{batchBuckets: make([]int64, len(batchSizeBuckets))}
+		r.stages[name] = s
+	}
+	return s
+}
+
+// Instrument wraps the function f, making it report the number of processed items, errors and in-flight items
+// of the stage. The wrapped function can be used with [rill.Map], [rill.Filter], [rill.FilterMap] and all other functions
+// with a compatible signature. Multiple functions may report to the same stage.
+func Instrument[A, B any](r *Registry, stage string, f func(A) (B, error)) func(A) (B, error) {
+	s := r.stage(stage)
+
+	return func(a A) (B, error) {
+		s.inFlight.Add(1)
+		defer s.inFlight.Add(-1)
+
+		b, err := f(a)
+		s.observe(err)
+		return b, err
+	}
+}
+
+// InstrumentErr is similar to [Instrument], but wraps functions that return only an error, such as the ones used by [rill.ForEach].
+func InstrumentErr[A any](r *Registry, stage string, f func(A) error) func(A) error {
+	s := r.stage(stage)
+
+	return func(a A) error {
+		s.inFlight.Add(1)
+		defer s.inFlight.Add(-1)
+
+		err := f(a)
+		s.observe(err)
+		return err
+	}
+}
+
+func (s *stageMetrics) observe(err error) {
+	s.items.Add(1)
+	if err != nil {
+		s.errors.Add(1)
+	}
+}
+
+// ObserveBatches returns a stream of the same batches as in, and records their sizes in the batch size histogram of the stage.
+//
+// This is a non-blocking ordered function that processes items sequentially.
+//
+// See the package documentation of rill for more information on non-blocking ordered functions and error handling.
+func ObserveBatches[A any](r *Registry, stage string, in <-chan rill.Try[[]A]) <-chan rill.Try[[]A] {
+	s := r.stage(stage)
+
+	return rill.OrderedTap(in, 1, func(batch []A) error {
+		s.observeBatch(len(batch))
+		return nil
+	})
+}
+
+func (s *stageMetrics) observeBatch(size int) {
+	s.batchMu.Lock()
+	defer s.batchMu.Unlock()
+
+	i := sort.SearchFloat64s(batchSizeBuckets, float64(size))
+	if i < len(s.batchBuckets) {
+		s.batchBuckets[i]++
+	}
+	s.batchCount++
+	s.batchSum += int64(size)
+}
+
+// ServeHTTP writes all metrics in the Prometheus text exposition format.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = r.WriteTo(w)
+}
+
+// WriteTo writes all metrics to w in the Prometheus text exposition format. Stages are sorted by name.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.stages))
+	for name := range r.stages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	stages := make([]*stageMetrics, len(names))
+	for i, name := range names {
+		stages[i] = r.stages[name]
+	}
+	r.mu.Unlock()
+
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+
+	ns := r.namespace
+
+	cw.printf("# HELP %s_stage_items_total Number of items processed by the stage.\n", ns)
+	cw.printf("# TYPE %s_stage_items_total counter\n", ns)
+	for i, name := range names {
+		cw.printf("%s_stage_items_total{stage=%s} %d\n", ns, quote(name), stages[i].items.Load())
+	}
+
+	cw.printf("# HELP %s_stage_errors_total Number of items processed by the stage with an error.\n", ns)
+	cw.printf("# TYPE %s_stage_errors_total counter\n", ns)
+	for i, name := range names {
+		cw.printf("%s_stage_errors_total{stage=%s} %d\n", ns, quote(name), stages[i].errors.Load())
+	}
+
+	cw.printf("# HELP %s_stage_in_flight Number of items currently being processed by the stage.\n", ns)
+	cw.printf("# TYPE %s_stage_in_flight gauge\n", ns)
+	for i, name := range names {
+		cw.printf("%s_stage_in_flight{stage=%s} %d\n", ns, quote(name), stages[i].inFlight.Load())
+	}
+
+	cw.printf("# HELP %s_stage_batch_size Size of batches produced by the stage.\n", ns)
+	cw.printf("# TYPE %s_stage_batch_size histogram\n", ns)
+	for i, name := range names {
+		s := stages[i]
+		s.batchMu.Lock()
+		var cumulative int64
+		for j, le := range batchSizeBuckets {
+			cumulative += s.batchBuckets[j]
+			cw.printf("%s_stage_batch_size_bucket{stage=%s,le=%q} %d\n", ns, quote(name), strconv.FormatFloat(le, 'g', -1, 64), cumulative)
+		}
+		cw.printf("%s_stage_batch_size_bucket{stage=%s,le=\"+Inf\"} %d\n", ns, quote(name), s.batchCount)
+		cw.printf("%s_stage_batch_size_sum{stage=%s} %d\n", ns, quote(name), s.batchSum)
+		cw.printf("%s_stage_batch_size_count{stage=%s} %d\n", ns, quote(name), s.batchCount)
+		s.batchMu.Unlock()
+	}
+
+	if cw.err == nil {
+		cw.err = cw.w.Flush()
+	}
+	return cw.n, cw.err
+}
+
+// quote formats a label value, escaping backslashes, double quotes and line feeds.
+func quote(v string) string {
+	var sb []byte
+	sb = append(sb, '"')
+	for i := 0; i < len(v); i++ {
+		switch v[i] {
+		case '\\':
+			sb = append(sb, `\\`...)
+		case '"':
+			sb = append(sb, `\"`...)
+		case '\n':
+			sb = append(sb, `\n`...)
+		default:
+			sb = append(sb, v[i])
+		}
+	}
+	return string(append(sb, '"'))
+}
+
+// countingWriter remembers the number of bytes written and the first error.
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (cw *countingWriter) printf(format string, args ...any) {
+	if cw.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(cw.w, format, args...)
+	cw.n += int64(n)
+	cw.err = err
+}
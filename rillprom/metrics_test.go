@@ -0,0 +1,98 @@
+package rillprom
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/destel/rill"
+	"github.com/destel/rill/internal/th"
+)
+
+func expectLines(t *testing.T, text string, lines ...string) {
+	t.Helper()
+	for _, line := range lines {
+		if !strings.Contains(text, line+"\n") {
+			t.Errorf("expected line %q in:\n%s", line, text)
+		}
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry("")
+
+	in := rill.FromChan(th.FromRange(0, 20), nil)
+	in = rill.Map(in, 3, Instrument(r, "square", func(x int) (int, error) {
+		if x%10 == 3 {
+			return 0, fmt.Errorf("err%d", x)
+		}
+		return x * x, nil
+	}))
+	in = rill.Catch(in, 1, func(err error) error { return nil })
+
+	batches := ObserveBatches(r, "batch", rill.Batch(in, 5, -1))
+
+	err := rill.ForEach(batches, 1, InstrumentErr(r, `save "all"`, func(b []int) error {
+		return nil
+	}))
+	th.ExpectNoError(t, err)
+
+	var sb strings.Builder
+	n, err := r.WriteTo(&sb)
+	th.ExpectNoError(t, err)
+	th.ExpectValue(t, n, int64(sb.Len()))
+
+	text := sb.String()
+	expectLines(t, text,
+		"# TYPE rill_stage_items_total counter",
+		`rill_stage_items_total{stage="square"} 20`,
+		`rill_stage_items_total{stage="save \"all\""} 4`,
+		`rill_stage_errors_total{stage="square"} 2`,
+		`rill_stage_errors_total{stage="save \"all\""} 0`,
+		`rill_stage_in_flight{stage="square"} 0`,
+		"# TYPE rill_stage_batch_size histogram",
+		`rill_stage_batch_size_bucket{stage="batch",le="2"} 0`,
+		`rill_stage_batch_size_bucket{stage="batch",le="5"} 4`,
+		`rill_stage_batch_size_bucket{stage="batch",le="+Inf"} 4`,
+		`rill_stage_batch_size_sum{stage="batch"} 18`,
+		`rill_stage_batch_size_count{stage="batch"} 4`,
+	)
+
+	// stages are sorted by name
+	if strings.Index(text, `items_total{stage="batch"}`) > strings.Index(text, `items_total{stage="square"}`) {
+		t.Errorf("expected stages to be sorted")
+	}
+}
+
+func TestInFlight(t *testing.T) {
+	r := NewRegistry("worker")
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	f := InstrumentErr(r, "slow", func(x int) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	})
+
+	done := make(chan error)
+	go func() {
+		done <- rill.ForEach(rill.FromSlice([]int{1, 2, 3}, nil), 3, f)
+	}()
+
+	for i := 0; i < 3; i++ {
+		<-started
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	th.ExpectValue(t, rec.Header().Get("Content-Type"), "text/plain; version=0.0.4; charset=utf-8")
+	expectLines(t, rec.Body.String(),
+		`worker_stage_in_flight{stage="slow"} 3`,
+		`worker_stage_items_total{stage="slow"} 0`,
+	)
+
+	close(release)
+	th.ExpectNoError(t, <-done)
+}
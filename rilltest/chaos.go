@@ -0,0 +1,106 @@
+package rilltest
+
+import (
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/destel/rill"
+)
+
+// ErrInjected is the default error injected by [Chaos].
+var ErrInjected = errors.New("rilltest: injected error")
+
+// ChaosOptions configure the faults injected by [Chaos]. Probabilities are in the range [0, 1].
+// The zero value injects no faults.
+type ChaosOptions struct {
+	// Seed makes the injected faults reproducible. The same seed and the same input always produce the same decisions,
+	// although the timing of latency injection naturally varies.
+	Seed int64
+
+	// LatencyProb is the probability of delaying an item by a random duration in the range [0, MaxLatency).
+	LatencyProb float64
+	MaxLatency  time.Duration
+
+	// ErrorProb is the probability of replacing an item with the Error.
+	ErrorProb float64
+
+	// Error is the injected error. Defaults to [ErrInjected].
+	Error error
+
+	// DropProb is the probability of silently dropping an item.
+	DropProb float64
+
+	// ReorderProb is the probability of holding an item back, and sending it after the next one.
+	ReorderProb float64
+}
+
+// Chaos returns a stream of the items from in, with faults injected according to the options.
+// It allows to verify that error handling, retries and ordering logic of a pipeline hold up under adverse conditions:
+//
+//	in := rilltest.Chaos(rill.FromSlice(ids, nil), rilltest.ChaosOptions{
+//		Seed:        42,
+//		ErrorProb:   0.1,
+//		ReorderProb: 0.2,
+//	})
+//
+// Errors that are already in the input stream are never dropped or replaced, but may be delayed or reordered.
+// Faults are decided for each item independently, in the following order: drop, error, reorder, latency.
+func Chaos[A any](in <-chan rill.Try[A], opts ChaosOptions) <-chan rill.Try[A] {
+	if in == nil {
+		return nil
+	}
+
+	if opts.Error == nil {
+		opts.Error = ErrInjected
+	}
+
+	out := make(chan rill.Try[A])
+
+	go func() {
+		defer close(out)
+
+		rnd := rand.New(rand.NewSource(opts.Seed))
+		chance := func(p float64) bool {
+			return p > 0 && rnd.Float64() < p
+		}
+
+		var held rill.Try[A]
+		var hasHeld bool
+
+		send := func(a rill.Try[A]) {
+			if chance(opts.LatencyProb) && opts.MaxLatency > 0 {
+				time.Sleep(time.Duration(rnd.Int63n(int64(opts.MaxLatency))))
+			}
+			out <- a
+		}
+
+		for a := range in {
+			if a.Error == nil {
+				if chance(opts.DropProb) {
+					continue
+				}
+				if chance(opts.ErrorProb) {
+					a = rill.Try[A]{Error: opts.Error}
+				}
+			}
+
+			if !hasHeld && chance(opts.ReorderProb) {
+				held, hasHeld = a, true
+				continue
+			}
+
+			send(a)
+			if hasHeld {
+				send(held)
+				held, hasHeld = rill.Try[A]{}, false
+			}
+		}
+
+		if hasHeld {
+			send(held)
+		}
+	}()
+
+	return out
+}
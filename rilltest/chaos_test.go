@@ -0,0 +1,75 @@
+package rilltest
+
+import (
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/destel/rill"
+)
+
+func chaosRange(n int) <-chan rill.Try[int] {
+	items := make([]int, n)
+	for i := range items {
+		items[i] = i
+	}
+	return rill.FromSlice(items, nil)
+}
+
+func TestChaos(t *testing.T) {
+	t.Run("no faults", func(t *testing.T) {
+		expected, _ := Collect(chaosRange(100))
+		ExpectValues(t, Chaos(chaosRange(100), ChaosOptions{}), expected)
+	})
+
+	t.Run("input errors", func(t *testing.T) {
+		in := rill.FromSlice([]int{1, 2}, errors.New("input"))
+		ExpectErrors(t, Chaos(in, ChaosOptions{DropProb: 1, ErrorProb: 1}), []string{"input"})
+	})
+
+	t.Run("drop and error", func(t *testing.T) {
+		out := Chaos(chaosRange(1000), ChaosOptions{Seed: 1, DropProb: 0.1, ErrorProb: 0.1})
+		values, errs := Collect(out)
+
+		for _, err := range errs {
+			if !errors.Is(err, ErrInjected) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+
+		dropped := 1000 - len(values) - len(errs)
+		if dropped < 50 || dropped > 150 {
+			t.Errorf("expected about 100 dropped items, got %d", dropped)
+		}
+		if len(errs) < 50 || len(errs) > 150 {
+			t.Errorf("expected about 90 errors, got %d", len(errs))
+		}
+		if !sort.IntsAreSorted(values) {
+			t.Errorf("expected order to be preserved")
+		}
+	})
+
+	t.Run("reorder", func(t *testing.T) {
+		values, _ := Collect(Chaos(chaosRange(100), ChaosOptions{Seed: 1, ReorderProb: 0.3}))
+
+		if sort.IntsAreSorted(values) {
+			t.Errorf("expected items to be reordered")
+		}
+
+		sort.Ints(values)
+		for i, v := range values {
+			if v != i {
+				t.Fatalf("expected all items to be present, got %v", values)
+			}
+		}
+	})
+
+	t.Run("reproducible", func(t *testing.T) {
+		opts := ChaosOptions{Seed: 7, DropProb: 0.2, ErrorProb: 0.2, ReorderProb: 0.2, LatencyProb: 0.1, MaxLatency: time.Millisecond}
+
+		first, _ := Collect(Chaos(chaosRange(200), opts))
+		second, _ := Collect(Chaos(chaosRange(200), opts))
+		ExpectValues(t, rill.FromSlice(second, nil), first)
+	})
+}
@@ -0,0 +1,111 @@
+package rilltest
+
+import (
+	"sync"
+	"time"
+)
+
+// ConcurrencyMonitor measures the maximum number of goroutines that run a function at the same time.
+// This allows to check that a stage respects its concurrency limit, and actually reaches it.
+//
+// Each goroutine must call [ConcurrencyMonitor.Inc] at the start and [ConcurrencyMonitor.Dec] at the end of its work.
+// To make the peak reproducible, Inc blocks until the concurrency level stays unchanged for the window duration,
+// which gives all goroutines that can run concurrently the time to start:
+//
+//	monitor := rilltest.NewConcurrencyMonitor(100 * time.Millisecond)
+//
+//	err := rill.ForEach(items, 5, func(x int) error {
+//		monitor.Inc()
+//		defer monitor.Dec()
+//		return process(x)
+//	})
+//
+//	if monitor.Max() != 5 {
+//		t.Errorf("expected concurrency 5, got %d", monitor.Max())
+//	}
+type ConcurrencyMonitor struct {
+	cond    *sync.Cond
+	current int
+	max     int
+
+	window time.Duration
+
+	lastChangeAt time.Time
+	timer        *time.Timer
+	timerFired   bool
+}
+
+// NewConcurrencyMonitor creates a new [ConcurrencyMonitor] with the given stabilization window.
+func NewConcurrencyMonitor(window time.Duration) *ConcurrencyMonitor {
+	c := &ConcurrencyMonitor{
+		cond:   sync.NewCond(&sync.Mutex{}),
+		window: window,
+	}
+
+	c.timer = time.AfterFunc(1*time.Hour, func() {
+		c.cond.L.Lock()
+		defer c.cond.L.Unlock()
+
+		c.timerFired = true
+		c.cond.Broadcast()
+	})
+
+	return c
+}
+
+// Inc increments the concurrency level, and blocks until it stays unchanged for the window duration.
+func (c *ConcurrencyMonitor) Inc() {
+	c.cond.L.Lock()
+	defer c.cond.L.Unlock()
+
+	c.touch()
+
+	c.current++
+	if c.max < c.current {
+		c.max = c.current
+	}
+
+	for !c.timerFired && time.Since(c.lastChangeAt) < c.window {
+		c.cond.Wait()
+	}
+}
+
+// Dec decrements the concurrency level.
+func (c *ConcurrencyMonitor) Dec() {
+	c.cond.L.Lock()
+	defer c.cond.L.Unlock()
+
+	c.touch()
+
+	c.current--
+	c.cond.Broadcast()
+}
+
+func (c *ConcurrencyMonitor) touch() {
+	c.lastChangeAt = time.Now()
+	if !c.timerFired {
+		c.timer.Reset(c.window)
+	}
+}
+
+// Max returns the maximum concurrency level reached so far.
+func (c *ConcurrencyMonitor) Max() int {
+	c.cond.L.Lock()
+	defer c.cond.L.Unlock()
+
+	return c.max
+}
+
+// Reset resets the monitor to its initial state, so it can be reused.
+func (c *ConcurrencyMonitor) Reset() {
+	c.cond.L.Lock()
+	defer c.cond.L.Unlock()
+
+	c.timer.Stop()
+	c.timer.Reset(1 * time.Hour)
+	c.timerFired = false
+
+	c.current = 0
+	c.max = 0
+	c.lastChangeAt = time.Time{}
+}
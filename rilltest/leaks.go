@@ -0,0 +1,108 @@
+package rilltest
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// leakWait is how long CheckNoLeaks waits for goroutines to exit, since draining and cleanup happen in the background.
+const leakWait = 5 * time.Second
+
+// CheckNoLeaks runs the function f, and fails the test if goroutines spawned by rill during the run are still alive
+// after it returns. This catches streams that were neither consumed nor drained, such as a forgotten output of [rill.Split2],
+// or a missing [rill.DrainNB] call after an early exit from a loop over a stream:
+//
+//	rilltest.CheckNoLeaks(t, func() {
+//		err := runPipeline(ctx)
+//		// ...
+//	})
+//
+// Goroutines get some time to finish, since draining happens in the background. Goroutines that have existed before
+// the call, as well as goroutines that don't execute rill code, are ignored.
+func CheckNoLeaks(t testing.TB, f func()) {
+	t.Helper()
+
+	before := make(map[string]bool)
+	for _, g := range goroutines() {
+		before[g.id] = true
+	}
+
+	f()
+
+	var leaked []goroutine
+	deadline := time.Now().Add(leakWait)
+	for {
+		leaked = leaked[:0]
+		for _, g := range goroutines() {
+			if !before[g.id] && g.isRill() {
+				leaked = append(leaked, g)
+			}
+		}
+
+		if len(leaked) == 0 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if len(leaked) > 0 {
+		var sb strings.Builder
+		for _, g := range leaked {
+			sb.WriteString("\n\n")
+			sb.WriteString(g.stack)
+		}
+		t.Errorf("found %d leaked goroutines:%s", len(leaked), sb.String())
+	}
+}
+
+type goroutine struct {
+	id    string
+	stack string
+}
+
+// isRill reports whether the goroutine runs code of the rill module, other than this package.
+func (g goroutine) isRill() bool {
+	for _, line := range strings.Split(g.stack, "\n") {
+		if strings.HasPrefix(line, "github.com/destel/rill") && !strings.HasPrefix(line, "github.com/destel/rill/rilltest.") {
+			return true
+		}
+	}
+	return false
+}
+
+// goroutines returns the stacks of all goroutines, except the current one.
+func goroutines() []goroutine {
+	buf := make([]byte, 64*1024)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+
+	var res []goroutine
+	for i, stack := range bytes.Split(buf, []byte("\n\n")) {
+		if i == 0 {
+			continue // the current goroutine always comes first
+		}
+
+		// the header looks like "goroutine 42 [chan send]:"
+		header, _, _ := strings.Cut(string(stack), "\n")
+		fields := strings.Fields(header)
+		if len(fields) < 2 || fields[0] != "goroutine" {
+			continue
+		}
+		if _, err := strconv.Atoi(fields[1]); err != nil {
+			continue
+		}
+
+		res = append(res, goroutine{id: fields[1], stack: string(stack)})
+	}
+	return res
+}
@@ -0,0 +1,34 @@
+package rilltest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/destel/rill"
+)
+
+func TestCheckNoLeaks(t *testing.T) {
+	double := func(x int) (int, error) { return 2 * x, nil }
+
+	t.Run("no leaks", func(t *testing.T) {
+		expectFailure(t, false, func(t testing.TB) {
+			CheckNoLeaks(t, func() {
+				out := rill.Map(rill.FromSlice(make([]int, 100), nil), 5, double)
+				_ = rill.ForEach(out, 1, func(x int) error { return errors.New("early exit") })
+			})
+		})
+	})
+
+	t.Run("leak", func(t *testing.T) {
+		var out <-chan rill.Try[int]
+
+		expectFailure(t, true, func(t testing.TB) {
+			CheckNoLeaks(t, func() {
+				out = rill.Map(rill.FromSlice(make([]int, 100), nil), 5, double)
+				<-out // forgets to drain the rest
+			})
+		})
+
+		rill.Drain(out)
+	})
+}
@@ -0,0 +1,165 @@
+// Package rilltest provides helpers for testing pipelines built with rill: assertions for stream contents and errors,
+// checks for drained channels and hangs, and a monitor of the concurrency level reached by stage functions.
+//
+//	func TestPipeline(t *testing.T) {
+//		in := rill.FromSlice([]int{1, 2, 3, 4}, nil)
+//		out := rill.Map(in, 2, double)
+//
+//		rilltest.ExpectValuesUnordered(t, out, []int{2, 4, 6, 8})
+//	}
+package rilltest
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/destel/rill"
+)
+
+// Collect consumes the whole stream and returns its values and errors, in the order they were received.
+func Collect[A any](in <-chan rill.Try[A]) (values []A, errs []error) {
+	for a := range in {
+		if a.Error != nil {
+			errs = append(errs, a.Error)
+			continue
+		}
+		values = append(values, a.Value)
+	}
+	return
+}
+
+// ExpectValues consumes the whole stream, and checks that it contains exactly the expected values in the same order,
+// and no errors.
+func ExpectValues[A comparable](t testing.TB, in <-chan rill.Try[A], expected []A) {
+	t.Helper()
+
+	values, errs := Collect(in)
+	expectNoErrors(t, errs)
+
+	if len(values) != len(expected) {
+		t.Errorf("expected %v, got %v", expected, values)
+		return
+	}
+	for i := range expected {
+		if values[i] != expected[i] {
+			t.Errorf("expected %v, got %v, mismatch at pos %d: %v != %v", expected, values, i, expected[i], values[i])
+			return
+		}
+	}
+}
+
+// ExpectValuesUnordered is similar to [ExpectValues], but ignores the order of values. This is the usual assertion
+// for streams produced by unordered functions, such as [rill.Map].
+func ExpectValuesUnordered[A comparable](t testing.TB, in <-chan rill.Try[A], expected []A) {
+	t.Helper()
+
+	values, errs := Collect(in)
+	expectNoErrors(t, errs)
+
+	counts := make(map[A]int, len(expected))
+	for _, v := range expected {
+		counts[v]++
+	}
+	for _, v := range values {
+		counts[v]--
+	}
+
+	for v, c := range counts {
+		if c != 0 {
+			t.Errorf("expected %v in any order, got %v, count mismatch for %v", expected, values, v)
+			return
+		}
+	}
+}
+
+// ExpectErrors consumes the whole stream, and checks that the messages of the errors it contains match the expected ones.
+// The order of errors is ignored, since it's usually nondeterministic. Values are ignored as well.
+func ExpectErrors[A any](t testing.TB, in <-chan rill.Try[A], expected []string) {
+	t.Helper()
+
+	_, errs := Collect(in)
+
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+
+	expected = append([]string(nil), expected...)
+	sort.Strings(messages)
+	sort.Strings(expected)
+
+	if len(messages) != len(expected) {
+		t.Errorf("expected errors %q, got %q", expected, messages)
+		return
+	}
+	for i := range expected {
+		if messages[i] != expected[i] {
+			t.Errorf("expected errors %q, got %q", expected, messages)
+			return
+		}
+	}
+}
+
+func expectNoErrors(t testing.TB, errs []error) {
+	t.Helper()
+	if len(errs) > 0 {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+}
+
+// ExpectDrained checks that the channel gets closed within the given time. Items that are still in the channel are discarded.
+// This is useful to verify that the input of a pipeline is drained after an early return of a blocking function,
+// such as [rill.ForEach], instead of being left blocked forever.
+func ExpectDrained[A any](t testing.TB, ch <-chan A, within time.Duration) {
+	t.Helper()
+
+	timeout := time.After(within)
+	for {
+		select {
+		case _, ok := <-ch:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Errorf("expected channel to be drained within %v, but it's still open", within)
+			return
+		}
+	}
+}
+
+// ExpectNotHang checks that the function f returns within the given time. If it doesn't, the test fails immediately,
+// leaving f running in the background.
+func ExpectNotHang(t testing.TB, within time.Duration, f func()) {
+	t.Helper()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(within):
+		t.Fatalf("function did not return within %v", within)
+	}
+}
+
+// ExpectHang checks that the function f does not return within the given time.
+// This is useful to verify that a pipeline applies back pressure, or waits for its inputs.
+func ExpectHang(t testing.TB, waitFor time.Duration, f func()) {
+	t.Helper()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f()
+	}()
+
+	select {
+	case <-done:
+		t.Errorf("expected function to hang, but it returned")
+	case <-time.After(waitFor):
+	}
+}
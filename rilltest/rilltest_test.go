@@ -0,0 +1,117 @@
+package rilltest
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/destel/rill"
+)
+
+// recorder is a testing.TB that records failures instead of reporting them.
+type recorder struct {
+	testing.TB
+	failed bool
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.failed = true
+}
+
+func (r *recorder) Fatalf(format string, args ...any) {
+	r.failed = true
+}
+
+// expectFailure runs the assertion against a recorder, and checks whether it has failed.
+func expectFailure(t *testing.T, shouldFail bool, assert func(t testing.TB)) {
+	t.Helper()
+
+	r := &recorder{TB: t}
+	assert(r)
+	if r.failed != shouldFail {
+		t.Errorf("expected failure to be %v, got %v", shouldFail, r.failed)
+	}
+}
+
+func TestExpectValues(t *testing.T) {
+	stream := func() <-chan rill.Try[int] {
+		return rill.FromSlice([]int{1, 2, 3}, nil)
+	}
+
+	expectFailure(t, false, func(t testing.TB) { ExpectValues(t, stream(), []int{1, 2, 3}) })
+	expectFailure(t, true, func(t testing.TB) { ExpectValues(t, stream(), []int{1, 3, 2}) })
+	expectFailure(t, true, func(t testing.TB) { ExpectValues(t, stream(), []int{1, 2}) })
+	expectFailure(t, true, func(t testing.TB) { ExpectValues(t, rill.FromSlice([]int{1}, errors.New("err")), nil) })
+
+	expectFailure(t, false, func(t testing.TB) { ExpectValuesUnordered(t, stream(), []int{3, 1, 2}) })
+	expectFailure(t, true, func(t testing.TB) { ExpectValuesUnordered(t, stream(), []int{3, 1, 1}) })
+	expectFailure(t, true, func(t testing.TB) { ExpectValuesUnordered(t, stream(), []int{3, 1, 2, 2}) })
+}
+
+func TestExpectErrors(t *testing.T) {
+	stream := func() <-chan rill.Try[int] {
+		in := rill.FromSlice([]int{1, 2, 3, 4}, nil)
+		return rill.Map(in, 2, func(x int) (int, error) {
+			if x%2 == 0 {
+				return 0, fmt.Errorf("err%d", x)
+			}
+			return x, nil
+		})
+	}
+
+	expectFailure(t, false, func(t testing.TB) { ExpectErrors(t, stream(), []string{"err4", "err2"}) })
+	expectFailure(t, true, func(t testing.TB) { ExpectErrors(t, stream(), []string{"err2"}) })
+	expectFailure(t, true, func(t testing.TB) { ExpectErrors(t, stream(), []string{"err2", "err3"}) })
+
+	values, errs := Collect(stream())
+	if len(values) != 2 || len(errs) != 2 {
+		t.Errorf("expected 2 values and 2 errors, got %v and %v", values, errs)
+	}
+}
+
+func TestExpectDrained(t *testing.T) {
+	expectFailure(t, false, func(t testing.TB) {
+		in := rill.FromSlice([]int{1, 2, 3}, nil)
+		_ = rill.ForEach(in, 1, func(x int) error { return errors.New("stop") })
+		ExpectDrained(t, in, time.Second)
+	})
+
+	expectFailure(t, true, func(t testing.TB) {
+		ExpectDrained(t, make(chan int), 100*time.Millisecond)
+	})
+}
+
+func TestExpectHang(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+
+	expectFailure(t, false, func(t testing.TB) { ExpectNotHang(t, time.Second, func() {}) })
+	expectFailure(t, true, func(t testing.TB) { ExpectNotHang(t, 100*time.Millisecond, func() { <-block }) })
+
+	expectFailure(t, false, func(t testing.TB) { ExpectHang(t, 100*time.Millisecond, func() { <-block }) })
+	expectFailure(t, true, func(t testing.TB) { ExpectHang(t, time.Second, func() {}) })
+}
+
+func TestConcurrencyMonitor(t *testing.T) {
+	monitor := NewConcurrencyMonitor(100 * time.Millisecond)
+
+	for _, n := range []int{1, 5} {
+		monitor.Reset()
+
+		err := rill.ForEach(rill.FromSlice(make([]int, 20), nil), n, func(x int) error {
+			monitor.Inc()
+			defer monitor.Dec()
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if monitor.Max() != n {
+			t.Errorf("expected max concurrency %d, got %d", n, monitor.Max())
+		}
+	}
+}
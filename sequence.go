@@ -0,0 +1,135 @@
+package rill
+
+import (
+	"crypto/rand"
+	"fmt"
+	"sort"
+
+	"github.com/destel/rill/internal/core"
+)
+
+// Stamped is a container holding a value along with its sequence number and the ID of the pipeline run it belongs to.
+type Stamped[A any] struct {
+	Seq   int64
+	RunID string
+	Value A
+}
+
+// Stamp assigns each value in the input stream a monotonically increasing sequence number, starting from 0,
+// and an optional run ID. Sequence numbers are unique within the stream and remain attached to the values
+// even after they pass through unordered stages. This makes them useful for checkpointing, auditing and
+// restoring the original order, for example with [OrderedMerge] or [ToSliceOrdered].
+//
+// Errors are forwarded to the output stream as is and do not consume sequence numbers.
+//
+// This is a non-blocking ordered function that processes items sequentially.
+//
+// See the package documentation for more information on non-blocking ordered functions and error handling.
+func Stamp[A any](in <-chan Try[A], runID string) <-chan Try[Stamped[A]] {
+	if in == nil {
+		return nil
+	}
+
+	out := make(chan Try[Stamped[A]])
+	go func() {
+		defer close(out)
+
+		var seq int64
+		for a := range in {
+			if a.Error != nil {
+				out <- Try[Stamped[A]]{Error: a.Error}
+				continue
+			}
+
+			out <- Try[Stamped[A]]{Value: Stamped[A]{Seq: seq, RunID: runID, Value: a.Value}}
+			seq++
+		}
+	}()
+
+	return out
+}
+
+// OnCommit forwards all items from the input stream to the output stream, and calls the function f for each item
+// right after it has been delivered downstream, together with its zero-based index in the stream.
+// When placed after an ordered stage, f is called exactly in the order of emission, which allows to tie progress tracking
+// or offset commits to the ordered output, rather than to the completion of processing.
+// Errors are delivered and counted as regular items.
+//
+// This is a non-blocking ordered function that processes items sequentially.
+//
+// See the package documentation for more information on non-blocking ordered functions and error handling.
+func OnCommit[A any](in <-chan Try[A], f func(index int64, item Try[A])) <-chan Try[A] {
+	if in == nil {
+		return nil
+	}
+
+	out := make(chan Try[A])
+	go func() {
+		defer close(out)
+
+		var index int64
+		for a := range in {
+			out <- a
+			f(index, a)
+			index++
+		}
+	}()
+
+	return out
+}
+
+// ToSliceOrdered converts a stream of stamped items into a slice of values, ordered by their sequence numbers.
+// This allows to restore the original order of items that went through unordered stages,
+// without paying the cost of using ordered functions in every intermediate stage:
+//
+//	stamped := rill.Stamp(urls, "")
+//	pages := rill.Map(stamped, 10, func(s rill.Stamped[string]) (rill.Stamped[Page], error) {
+//		page, err := fetch(s.Value)
+//		return rill.Stamped[Page]{Seq: s.Seq, Value: page}, err
+//	})
+//	res, err := rill.ToSliceOrdered(pages)
+//
+// Gaps in sequence numbers, for example caused by filtering, are allowed.
+//
+// This is a blocking function that processes items sequentially.
+// See the package documentation for more information on blocking functions and error handling.
+func ToSliceOrdered[A any](in <-chan Try[Stamped[A]]) ([]A, error) {
+	core.Claim(in)
+
+	var stamped []Stamped[A]
+	for x := range in {
+		if err := x.Error; err != nil {
+			DrainNB(in)
+			return nil, err
+		}
+		stamped = append(stamped, x.Value)
+	}
+
+	if len(stamped) == 0 {
+		return nil, nil
+	}
+
+	sort.Slice(stamped, func(i, j int) bool {
+		return stamped[i].Seq < stamped[j].Seq
+	})
+
+	res := make([]A, len(stamped))
+	for i, s := range stamped {
+		res[i] = s.Value
+	}
+	return res, nil
+}
+
+// NewRunID returns a random identifier in the UUID version 4 format.
+// It can be used to distinguish the runs of a pipeline, for example with [Stamp].
+func NewRunID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(fmt.Errorf("rill: failed to generate run ID: %w", err))
+	}
+
+	b[6] = (b[6] & 0x0f) | 0x40 // version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // variant 10
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
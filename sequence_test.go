@@ -0,0 +1,146 @@
+package rill
+
+import (
+	"fmt"
+	"regexp"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/destel/rill/internal/th"
+)
+
+func TestStamp(t *testing.T) {
+	t.Run("nil", func(t *testing.T) {
+		out := Stamp[int](nil, "")
+		th.ExpectValue(t, out, nil)
+	})
+
+	t.Run("correctness", func(t *testing.T) {
+		in := FromChan(th.FromRange(0, 20), nil)
+		in = replaceWithError(in, 5, fmt.Errorf("err05"))
+
+		out := Stamp(in, "run1")
+		outSlice, errs := toSliceAndErrors(out)
+
+		th.ExpectSlice(t, errs, []string{"err05"})
+		th.ExpectValue(t, len(outSlice), 19)
+		for i, x := range outSlice {
+			th.ExpectValue(t, x.Seq, int64(i))
+			th.ExpectValue(t, x.RunID, "run1")
+
+			expectedValue := i
+			if i >= 5 {
+				expectedValue++
+			}
+			th.ExpectValue(t, x.Value, expectedValue)
+		}
+	})
+
+	t.Run("unordered stages", func(t *testing.T) {
+		in := Stamp(FromChan(th.FromRange(0, 1000), nil), "")
+
+		in = Map(in, 5, func(x Stamped[int]) (Stamped[int], error) {
+			return x, nil
+		})
+
+		out := OrderedMerge(func(x Stamped[int]) int64 { return x.Seq }, 1000, in)
+		outSlice, errs := toSliceAndErrors(out)
+
+		th.ExpectValue(t, len(errs), 0)
+		th.ExpectValue(t, len(outSlice), 1000)
+		for i, x := range outSlice {
+			th.ExpectValue(t, x.Value, i)
+		}
+	})
+}
+
+func TestOnCommit(t *testing.T) {
+	t.Run("nil", func(t *testing.T) {
+		th.ExpectValue(t, OnCommit[int](nil, func(int64, Try[int]) {}), nil)
+	})
+
+	t.Run("correctness", func(t *testing.T) {
+		in := FromChan(th.FromRange(0, 100), nil)
+		in = replaceWithError(in, 50, fmt.Errorf("err50"))
+		in = OrderedMap(in, 5, func(x int) (int, error) { return x, nil })
+
+		var committed []int64
+		var received atomic.Int64
+		out := OnCommit(in, func(index int64, item Try[int]) {
+			if index > received.Load() {
+				t.Errorf("item %d was committed before it has been received", index)
+			}
+			if item.Error == nil && int64(item.Value) != index {
+				t.Errorf("expected index %d for item %d", item.Value, index)
+			}
+			committed = append(committed, index)
+		})
+
+		var values []int
+		for x := range out {
+			if x.Error == nil {
+				values = append(values, x.Value)
+			}
+			received.Add(1)
+			time.Sleep(time.Millisecond) // give f a chance to run
+		}
+
+		th.ExpectValue(t, len(values), 99)
+		th.ExpectValue(t, len(committed), 100)
+		th.ExpectSorted(t, committed)
+	})
+}
+
+func TestToSliceOrdered(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		res, err := ToSliceOrdered(FromSlice[Stamped[int]](nil, nil))
+		th.ExpectNoError(t, err)
+		th.ExpectSlice(t, res, nil)
+	})
+
+	t.Run("correctness", func(t *testing.T) {
+		stamped := Stamp(FromChan(th.FromRange(0, 1000), nil), "")
+
+		// shuffle and filter
+		processed := FilterMap(stamped, 5, func(s Stamped[int]) (Stamped[int], bool, error) {
+			return Stamped[int]{Seq: s.Seq, Value: 2 * s.Value}, s.Value%3 != 0, nil
+		})
+
+		res, err := ToSliceOrdered(processed)
+		th.ExpectNoError(t, err)
+
+		var expected []int
+		for i := 0; i < 1000; i++ {
+			if i%3 != 0 {
+				expected = append(expected, 2*i)
+			}
+		}
+		th.ExpectSlice(t, res, expected)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		in := Stamp(FromChan(th.FromRange(0, 1000), nil), "")
+		in = replaceWithError(in, Stamped[int]{Seq: 100, Value: 100}, fmt.Errorf("err100"))
+
+		res, err := ToSliceOrdered(in)
+		th.ExpectError(t, err, "err100")
+		th.ExpectSlice(t, res, nil)
+
+		time.Sleep(1 * time.Second)
+		th.ExpectDrainedChan(t, in)
+	})
+}
+
+func TestNewRunID(t *testing.T) {
+	re := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	id1 := NewRunID()
+	id2 := NewRunID()
+
+	th.ExpectValue(t, re.MatchString(id1), true)
+	th.ExpectValue(t, re.MatchString(id2), true)
+	if id1 == id2 {
+		t.Errorf("expected different IDs")
+	}
+}
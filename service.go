@@ -0,0 +1,128 @@
+package rill
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type serviceRequest[A, B any] struct {
+	Value A
+	Reply func(B, error) // nil means that the response goes to the output stream
+}
+
+// Service is a request-response front end for a pipeline. It formalizes the pattern of embedding a pipeline
+// behind a service API: requests are submitted one by one, possibly from many goroutines,
+// and are processed concurrently and in batches. Each response is delivered either
+// to the shared output stream or to a per-request callback.
+//
+// A Service must be created with [NewService] and closed with [Service.Close] when it is no longer needed.
+type Service[A, B any] struct {
+	requests  chan serviceRequest[A, B]
+	out       chan Try[B]
+	closeOnce sync.Once
+}
+
+// NewService creates a [Service] that groups incoming requests into batches and processes them
+// using the function f and n goroutines. Batches are formed the same way as in the [Batch] function,
+// using the maximum size and the timeout.
+//
+// The function f must return exactly one response per request, in the same order as requests.
+// If f returns an error, this error is delivered as a response to each request in the batch.
+func NewService[A, B any](n int, batchSize int, timeout time.Duration, f func([]A) ([]B, error)) *Service[A, B] {
+	s := &Service[A, B]{
+		requests: make(chan serviceRequest[A, B]),
+		out:      make(chan Try[B]),
+	}
+
+	batches := Batch(FromChan(s.requests, nil), batchSize, timeout)
+
+	go func() {
+		defer close(s.out)
+
+		_ = ForEach(batches, n, func(batch []serviceRequest[A, B]) error {
+			values := make([]A, len(batch))
+			for i, req := range batch {
+				values[i] = req.Value
+			}
+
+			results, err := f(values)
+			if err == nil && len(results) != len(batch) {
+				err = fmt.Errorf("rill: expected %d results, got %d", len(batch), len(results))
+			}
+
+			for i, req := range batch {
+				var res B
+				if err == nil {
+					res = results[i]
+				}
+
+				if req.Reply != nil {
+					req.Reply(res, err)
+				} else {
+					s.out <- Try[B]{Value: res, Error: err}
+				}
+			}
+			return nil
+		})
+	}()
+
+	return s
+}
+
+// Submit sends a request to the service. The response is delivered to the output stream returned by [Service.Out].
+// Submit blocks until the request is accepted for processing. It must not be called after [Service.Close].
+func (s *Service[A, B]) Submit(a A) {
+	s.requests <- serviceRequest[A, B]{Value: a}
+}
+
+// SubmitFunc sends a request to the service. The response is delivered to the reply callback,
+// which is called from one of the service's goroutines.
+// SubmitFunc blocks until the request is accepted for processing. It must not be called after [Service.Close].
+func (s *Service[A, B]) SubmitFunc(a A, reply func(B, error)) {
+	s.requests <- serviceRequest[A, B]{Value: a, Reply: reply}
+}
+
+// Call sends a request to the service and waits for the response.
+// If the context is canceled before the response is ready, Call returns the context error.
+// It must not be called after [Service.Close].
+func (s *Service[A, B]) Call(ctx context.Context, a A) (B, error) {
+	var zero B
+	replies := make(chan Try[B], 1)
+
+	req := serviceRequest[A, B]{
+		Value: a,
+		Reply: func(b B, err error) {
+			replies <- Try[B]{Value: b, Error: err}
+		},
+	}
+
+	select {
+	case s.requests <- req:
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	}
+
+	select {
+	case res := <-replies:
+		return res.Value, res.Error
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	}
+}
+
+// Out returns the stream of responses for requests sent with [Service.Submit].
+// This stream must be consumed, otherwise the service would block.
+// It is closed after the service is closed and all pending requests are processed.
+func (s *Service[A, B]) Out() <-chan Try[B] {
+	return s.out
+}
+
+// Close stops accepting new requests. Requests that were already accepted are still processed.
+// It is safe to call Close multiple times.
+func (s *Service[A, B]) Close() {
+	s.closeOnce.Do(func() {
+		close(s.requests)
+	})
+}
@@ -0,0 +1,113 @@
+package rill
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/destel/rill/internal/th"
+)
+
+func TestService(t *testing.T) {
+	double := func(values []int) ([]int, error) {
+		res := make([]int, len(values))
+		for i, x := range values {
+			if x == 13 {
+				return nil, fmt.Errorf("err13")
+			}
+			res[i] = 2 * x
+		}
+		return res, nil
+	}
+
+	for _, n := range []int{1, 5} {
+		t.Run(th.Name("call", n), func(t *testing.T) {
+			s := NewService(n, 3, 10*time.Millisecond, double)
+			defer s.Close()
+
+			th.DoConcurrentlyN(20, func(i int) {
+				x, err := s.Call(context.Background(), i)
+				if i == 13 || err != nil {
+					// the whole batch containing item 13 fails
+					th.ExpectError(t, err, "err13")
+					return
+				}
+				th.ExpectValue(t, x, 2*i)
+			})
+		})
+
+		t.Run(th.Name("submit", n), func(t *testing.T) {
+			s := NewService(n, 3, 10*time.Millisecond, double)
+
+			var outSlice []int
+			var wg sync.WaitGroup
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				outSlice, _ = toSliceAndErrors(s.Out())
+			}()
+
+			for i := 0; i < 10; i++ {
+				s.Submit(i)
+			}
+			s.Close()
+			wg.Wait()
+
+			th.Sort(outSlice)
+			th.ExpectSlice(t, outSlice, []int{0, 2, 4, 6, 8, 10, 12, 14, 16, 18})
+		})
+
+		t.Run(th.Name("submit func", n), func(t *testing.T) {
+			s := NewService(n, 3, 10*time.Millisecond, double)
+
+			var wg sync.WaitGroup
+			var mu sync.Mutex
+			var outSlice []int
+
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				s.SubmitFunc(i, func(x int, err error) {
+					defer wg.Done()
+					th.ExpectNoError(t, err)
+
+					mu.Lock()
+					outSlice = append(outSlice, x)
+					mu.Unlock()
+				})
+			}
+			wg.Wait()
+			s.Close()
+
+			// nothing should go to the output stream
+			rest, _ := toSliceAndErrors(s.Out())
+			th.ExpectValue(t, len(rest), 0)
+
+			th.Sort(outSlice)
+			th.ExpectSlice(t, outSlice, []int{0, 2, 4, 6, 8, 10, 12, 14, 16, 18})
+		})
+	}
+
+	t.Run("wrong number of results", func(t *testing.T) {
+		s := NewService(1, 3, 10*time.Millisecond, func(values []int) ([]int, error) {
+			return nil, nil
+		})
+		defer s.Close()
+
+		_, err := s.Call(context.Background(), 1)
+		th.ExpectError(t, err, "rill: expected 1 results, got 0")
+	})
+
+	t.Run("context canceled", func(t *testing.T) {
+		s := NewService(1, 3, -1, double)
+		defer s.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		// batch is never full, so the call can't complete
+		_, err := s.Call(ctx, 1)
+		th.ExpectError(t, err, context.DeadlineExceeded.Error())
+	})
+}
@@ -0,0 +1,73 @@
+package rill
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"github.com/destel/rill/internal/core"
+)
+
+var errEmptySideInput = errors.New("rill: side input stream ended without items")
+
+// SideInput holds the latest item of a slowly changing stream, such as feature flags, configuration or exchange rates,
+// and makes it available to the functions of other stages. This removes the need to manage a mutex-protected
+// global variable in the closure.
+//
+// The input stream is consumed in the background for as long as it's open:
+//
+//	rates := rill.NewSideInput(exchangeRates)
+//
+//	prices := rill.Map(orders, 5, func(o Order) (Price, error) {
+//		rate, err := rates.Get()
+//		if err != nil {
+//			return Price{}, err
+//		}
+//		return convert(o, rate), nil
+//	})
+type SideInput[A any] struct {
+	latest    atomic.Pointer[Try[A]]
+	ready     chan struct{}
+	readyOnce sync.Once
+}
+
+// NewSideInput creates a [SideInput] and starts consuming the input stream in the background.
+func NewSideInput[A any](in <-chan Try[A]) *SideInput[A] {
+	core.Claim(in)
+
+	s := &SideInput[A]{
+		ready: make(chan struct{}),
+	}
+
+	markReady := func() {
+		s.readyOnce.Do(func() {
+			close(s.ready)
+		})
+	}
+
+	go func() {
+		defer markReady()
+
+		for a := range in {
+			a := a
+			s.latest.Store(&a)
+			markReady()
+		}
+	}()
+
+	return s
+}
+
+// Get atomically returns the latest value or error received from the input stream.
+// It blocks until the first item is received. If the input stream ended without any items, Get returns an error.
+// Get is safe for concurrent use.
+func (s *SideInput[A]) Get() (A, error) {
+	<-s.ready
+
+	if a := s.latest.Load(); a != nil {
+		return a.Value, a.Error
+	}
+
+	var zero A
+	return zero, errEmptySideInput
+}
@@ -0,0 +1,76 @@
+package rill
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/destel/rill/internal/th"
+)
+
+func TestSideInput(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		s := NewSideInput(FromSlice[int](nil, nil))
+
+		_, err := s.Get()
+		th.ExpectError(t, err, "rill: side input stream ended without items")
+	})
+
+	t.Run("blocks until first item", func(t *testing.T) {
+		in := make(chan Try[int])
+		s := NewSideInput(in)
+
+		th.ExpectHang(t, 500*time.Millisecond, func() {
+			_, _ = s.Get()
+		})
+
+		in <- Try[int]{Value: 1}
+		x, err := s.Get()
+		th.ExpectNoError(t, err)
+		th.ExpectValue(t, x, 1)
+
+		close(in)
+	})
+
+	t.Run("latest item", func(t *testing.T) {
+		in := make(chan Try[int])
+		s := NewSideInput(in)
+
+		in <- Try[int]{Value: 1}
+		in <- Try[int]{Value: 2}
+		in <- Try[int]{Error: fmt.Errorf("err3")}
+		time.Sleep(100 * time.Millisecond)
+
+		_, err := s.Get()
+		th.ExpectError(t, err, "err3")
+
+		in <- Try[int]{Value: 4}
+		close(in)
+		time.Sleep(100 * time.Millisecond)
+
+		// last value is kept after the stream ends
+		x, err := s.Get()
+		th.ExpectNoError(t, err)
+		th.ExpectValue(t, x, 4)
+	})
+
+	t.Run("concurrent access", func(t *testing.T) {
+		in := make(chan Try[int])
+		s := NewSideInput(in)
+
+		go func() {
+			defer close(in)
+			for i := 0; i < 1000; i++ {
+				in <- Try[int]{Value: i}
+			}
+		}()
+
+		out := Map(FromChan(th.FromRange(0, 1000), nil), 5, func(x int) (int, error) {
+			return s.Get()
+		})
+
+		outSlice, err := ToSlice(out)
+		th.ExpectNoError(t, err)
+		th.ExpectValue(t, len(outSlice), 1000)
+	})
+}
@@ -0,0 +1,101 @@
+package rill
+
+import (
+	"time"
+
+	"github.com/destel/rill/internal/core"
+)
+
+// SlowItem describes an item that spent too much time in a stage. See [MapWithSLA].
+type SlowItem[A any] struct {
+	Value      A
+	Queued     time.Duration // time between the item being read from the input and a worker picking it up
+	Processing time.Duration // time spent in the user-provided function
+	Reordering time.Duration // time spent waiting for earlier items to be written; always zero for unordered stages
+}
+
+type timedItem[A any] struct {
+	Item       Try[A]
+	ReceivedAt time.Time
+}
+
+// stampArrival records the time each item is read from the input stream.
+// The output is unbuffered, so the time until a worker picks up an item is its queuing time.
+func stampArrival[A any](in <-chan Try[A]) <-chan timedItem[A] {
+	stamped := core.FilterMap(in, 1, func(a Try[A]) (timedItem[A], bool) {
+		return timedItem[A]{Item: a, ReceivedAt: time.Now()}, true
+	})
+
+	// workers consume the stamped channel, so the launcher registered for in must be passed to it
+	if launch := core.CustomLauncherFor(in); launch != nil {
+		stamped = core.WithLauncher(stamped, launch)
+	}
+	return stamped
+}
+
+// MapWithSLA is similar to [Map], but additionally calls onSlow for every item that spent more than
+// the threshold in the stage. Time spent waiting for a free worker and time spent in the function f are reported separately.
+// The ordered version additionally reports time spent waiting for earlier items, since the result can't be written before them.
+// This helps to find pathological items that stall the pipeline.
+// The onSlow function is called from the worker goroutines and must be safe for concurrent use.
+//
+// This is a non-blocking unordered function that processes items concurrently using n goroutines.
+// An ordered version of this function, [OrderedMapWithSLA], is also available.
+//
+// See the package documentation for more information on non-blocking unordered functions and error handling.
+func MapWithSLA[A, B any](in <-chan Try[A], n int, threshold time.Duration, onSlow func(SlowItem[A]), f func(A) (B, error)) <-chan Try[B] {
+	if in == nil {
+		return nil
+	}
+
+	return core.FilterMap(stampArrival(in), n, func(x timedItem[A]) (Try[B], bool) {
+		return mapWithSLA(x, threshold, onSlow, f, nil), true
+	})
+}
+
+// OrderedMapWithSLA is the ordered version of [MapWithSLA].
+func OrderedMapWithSLA[A, B any](in <-chan Try[A], n int, threshold time.Duration, onSlow func(SlowItem[A]), f func(A) (B, error)) <-chan Try[B] {
+	if in == nil {
+		return nil
+	}
+
+	out := make(chan Try[B])
+	core.OrderedLoop(stampArrival(in), out, n, func(x timedItem[A], canWrite <-chan struct{}) {
+		out <- mapWithSLA(x, threshold, onSlow, f, canWrite)
+	})
+
+	return out
+}
+
+// mapWithSLA calls f and reports the item if it's slow. For ordered stages canWrite is not nil:
+// it's read exactly once, and the time until it's ready is reported as reordering time.
+func mapWithSLA[A, B any](x timedItem[A], threshold time.Duration, onSlow func(SlowItem[A]), f func(A) (B, error), canWrite <-chan struct{}) Try[B] {
+	if x.Item.Error != nil {
+		if canWrite != nil {
+			<-canWrite
+		}
+		return Try[B]{Error: x.Item.Error}
+	}
+
+	startedAt := time.Now()
+	b, err := f(x.Item.Value)
+	finishedAt := time.Now()
+
+	writableAt := finishedAt
+	if canWrite != nil {
+		<-canWrite
+		writableAt = time.Now()
+	}
+
+	queued := startedAt.Sub(x.ReceivedAt)
+	processing := finishedAt.Sub(startedAt)
+	reordering := writableAt.Sub(finishedAt)
+	if queued+processing+reordering > threshold {
+		onSlow(SlowItem[A]{Value: x.Item.Value, Queued: queued, Processing: processing, Reordering: reordering})
+	}
+
+	if err != nil {
+		return Try[B]{Error: err}
+	}
+	return Try[B]{Value: b}
+}
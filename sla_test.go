@@ -0,0 +1,155 @@
+package rill
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/destel/rill/internal/th"
+)
+
+func universalMapWithSLA[A, B any](ord bool, in <-chan Try[A], n int, threshold time.Duration, onSlow func(SlowItem[A]), f func(A) (B, error)) <-chan Try[B] {
+	if ord {
+		return OrderedMapWithSLA(in, n, threshold, onSlow, f)
+	}
+	return MapWithSLA(in, n, threshold, onSlow, f)
+}
+
+func TestMapWithSLA(t *testing.T) {
+	th.TestBothOrderings(t, func(t *testing.T, ord bool) {
+		for _, n := range []int{1, 5} {
+			t.Run(th.Name("nil", n), func(t *testing.T) {
+				out := universalMapWithSLA(ord, nil, n, time.Second, func(SlowItem[int]) {}, func(x int) (int, error) { return x, nil })
+				th.ExpectValue(t, out, nil)
+			})
+
+			t.Run(th.Name("correctness", n), func(t *testing.T) {
+				in := FromChan(th.FromRange(0, 20), nil)
+				in = replaceWithError(in, 15, fmt.Errorf("err15"))
+
+				var mu sync.Mutex
+				var slow []SlowItem[int]
+
+				out := universalMapWithSLA(ord, in, n, 100*time.Millisecond, func(item SlowItem[int]) {
+					mu.Lock()
+					defer mu.Unlock()
+					slow = append(slow, item)
+				}, func(x int) (int, error) {
+					if x == 7 {
+						time.Sleep(300 * time.Millisecond)
+					}
+					if x == 5 {
+						return 0, fmt.Errorf("err05")
+					}
+					return 2 * x, nil
+				})
+
+				outSlice, errs := toSliceAndErrors(out)
+				th.Sort(outSlice)
+				th.Sort(errs)
+
+				expectedSlice := make([]int, 0, 20)
+				for i := 0; i < 20; i++ {
+					if i == 5 || i == 15 {
+						continue
+					}
+					expectedSlice = append(expectedSlice, 2*i)
+				}
+
+				th.ExpectSlice(t, outSlice, expectedSlice)
+				th.ExpectSlice(t, errs, []string{"err05", "err15"})
+
+				// with a single worker, items queued behind the slow one may also be reported
+				var slow7 *SlowItem[int]
+				for i := range slow {
+					if slow[i].Value == 7 {
+						slow7 = &slow[i]
+					}
+				}
+				if slow7 == nil {
+					t.Fatalf("expected item 7 to be reported as slow")
+				}
+				if slow7.Processing < 300*time.Millisecond {
+					t.Errorf("expected processing time of at least 300ms, got %v", slow7.Processing)
+				}
+			})
+		}
+
+		t.Run("queued", func(t *testing.T) {
+			in := FromChan(th.FromRange(0, 2), nil)
+
+			var mu sync.Mutex
+			var slow []SlowItem[int]
+
+			out := universalMapWithSLA(ord, in, 1, 100*time.Millisecond, func(item SlowItem[int]) {
+				mu.Lock()
+				defer mu.Unlock()
+				slow = append(slow, item)
+			}, func(x int) (int, error) {
+				time.Sleep(200 * time.Millisecond)
+				return x, nil
+			})
+
+			_, err := ToSlice(out)
+			th.ExpectNoError(t, err)
+
+			th.ExpectValue(t, len(slow), 2)
+			th.ExpectValue(t, slow[1].Value, 1)
+			if slow[1].Queued < 150*time.Millisecond {
+				t.Errorf("expected queued time of at least 150ms, got %v", slow[1].Queued)
+			}
+		})
+
+		t.Run("launcher", func(t *testing.T) {
+			var launched atomic.Int64
+			in := WithLauncher(FromChan(th.FromRange(0, 20), nil), func(f func()) {
+				launched.Add(1)
+				go f()
+			})
+
+			out := universalMapWithSLA(ord, in, 3, time.Second, func(SlowItem[int]) {}, func(x int) (int, error) {
+				return x, nil
+			})
+
+			_, err := ToSlice(out)
+			th.ExpectNoError(t, err)
+
+			// the workers and the internal goroutine that stamps arrival times
+			th.ExpectValue(t, launched.Load(), int64(4))
+		})
+	})
+}
+
+func TestOrderedMapWithSLA(t *testing.T) {
+	t.Run("reordering", func(t *testing.T) {
+		in := FromChan(th.FromRange(0, 2), nil)
+
+		var mu sync.Mutex
+		var slow []SlowItem[int]
+
+		out := OrderedMapWithSLA(in, 2, 100*time.Millisecond, func(item SlowItem[int]) {
+			mu.Lock()
+			defer mu.Unlock()
+			slow = append(slow, item)
+		}, func(x int) (int, error) {
+			if x == 0 {
+				time.Sleep(300 * time.Millisecond)
+			}
+			return x, nil
+		})
+
+		outSlice, err := ToSlice(out)
+		th.ExpectNoError(t, err)
+		th.ExpectSlice(t, outSlice, []int{0, 1})
+
+		// item 1 is processed quickly, but has to wait for item 0 to be written
+		th.ExpectValue(t, len(slow), 2)
+		th.ExpectValue(t, slow[1].Value, 1)
+		if slow[1].Reordering < 200*time.Millisecond {
+			t.Errorf("expected reordering time of at least 200ms, got %v", slow[1].Reordering)
+		}
+		th.ExpectValue(t, slow[0].Reordering < 100*time.Millisecond, true)
+	})
+}
@@ -0,0 +1,192 @@
+package rill
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/destel/rill/internal/core"
+)
+
+// Source is a minimal interface of a message queue client with at-least-once delivery semantics,
+// such as a Kafka consumer, an SQS queue or a NATS JetStream subscription.
+// Use [FromSource] to turn it into a stream.
+type Source[T any] interface {
+	// Fetch blocks until at least one message is available, and returns a batch of messages.
+	// It must return promptly after ctx is canceled.
+	Fetch(ctx context.Context) ([]T, error)
+
+	// Ack reports that the message has been processed successfully.
+	Ack(msg T) error
+
+	// Nack reports that the message has not been processed, so it can be redelivered.
+	Nack(msg T) error
+}
+
+// Msg is a message received from a [Source]. Exactly one of [Msg.Ack] or [Msg.Nack] should be called
+// after the message is processed.
+type Msg[T any] struct {
+	Value T
+
+	src     Source[T]
+	settled *atomic.Bool
+}
+
+// Ack acknowledges the message. Only the first call to Ack or Nack has an effect, subsequent calls return nil.
+func (m Msg[T]) Ack() error {
+	if !m.settled.CompareAndSwap(false, true) {
+		return nil
+	}
+	return m.src.Ack(m.Value)
+}
+
+// Nack negatively acknowledges the message, so it can be redelivered.
+// Only the first call to Ack or Nack has an effect, subsequent calls return nil.
+func (m Msg[T]) Nack() error {
+	if !m.settled.CompareAndSwap(false, true) {
+		return nil
+	}
+	return m.src.Nack(m.Value)
+}
+
+const (
+	fromSourceMinBackoff = 10 * time.Millisecond
+	fromSourceMaxBackoff = 5 * time.Second
+)
+
+// FromSource creates a stream of messages fetched from the source. Up to prefetch messages are fetched ahead of the consumer,
+// so the next ones are usually ready by the time the previous ones are processed.
+// Messages must be acknowledged explicitly after processing, which gives at-least-once delivery for the whole pipeline:
+//
+//	msgs := rill.FromSource(ctx, queue, 100)
+//
+//	err := rill.ForEach(msgs, 10, func(m rill.Msg[Job]) error {
+//		if err := process(m.Value); err != nil {
+//			return m.Nack()
+//		}
+//		return m.Ack()
+//	})
+//
+// Errors returned by Fetch are sent to the output stream, and fetching is retried after a delay.
+// The delay starts at 10ms and doubles with each consecutive error, up to 5s. It's reset after a successful fetch.
+// If ctx is canceled, fetching stops, and ctx.Err() is sent to the output stream as the last item.
+// Fetching also stops when the output stream is drained or stopped with [Pipeline.StopSources].
+// In this case messages that have already been fetched, but not consumed are nacked, so they are redelivered without waiting
+// for a visibility timeout. Messages dropped somewhere downstream of the stream are not tracked and must be handled
+// by the source itself, for example by redelivering unacknowledged messages after a timeout.
+func FromSource[T any](ctx context.Context, src Source[T], prefetch int) <-chan Try[Msg[T]] {
+	if prefetch < 0 {
+		prefetch = 0
+	}
+
+	buf := make(chan Try[Msg[T]], prefetch)
+	out := core.OnDrain((<-chan Try[Msg[T]])(buf), func(x Try[Msg[T]]) {
+		if x.Error == nil {
+			_ = x.Value.Nack()
+		}
+	})
+	stop, release := core.StopOnDrain(out)
+
+	go func() {
+		defer close(buf)
+		defer release()
+
+		// abort the in-flight fetch as soon as the output starts to be drained
+		fetchCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go func() {
+			select {
+			case <-stop:
+				cancel()
+			case <-fetchCtx.Done():
+			}
+		}()
+
+		// stopped checks stop directly, since fetchCtx is canceled asynchronously
+		stopped := func() bool {
+			select {
+			case <-stop:
+				return true
+			case <-fetchCtx.Done():
+				return true
+			default:
+				return false
+			}
+		}
+
+		backoff := time.Duration(0)
+
+	loop:
+		for !stopped() {
+			msgs, err := src.Fetch(fetchCtx)
+			if stopped() {
+				nackAll(src, msgs)
+				break
+			}
+			if err != nil {
+				select {
+				case buf <- Try[Msg[T]]{Error: err}:
+				case <-stop:
+					break loop
+				case <-fetchCtx.Done():
+					break loop
+				}
+
+				backoff *= 2
+				if backoff < fromSourceMinBackoff {
+					backoff = fromSourceMinBackoff
+				}
+				if backoff > fromSourceMaxBackoff {
+					backoff = fromSourceMaxBackoff
+				}
+				if !sleepCtx(fetchCtx, stop, backoff) {
+					break
+				}
+				continue
+			}
+			backoff = 0
+
+			for i, msg := range msgs {
+				select {
+				case buf <- Try[Msg[T]]{Value: Msg[T]{Value: msg, src: src, settled: new(atomic.Bool)}}:
+				case <-stop:
+					nackAll(src, msgs[i:])
+					break loop
+				case <-fetchCtx.Done():
+					nackAll(src, msgs[i:])
+					break loop
+				}
+			}
+		}
+
+		if err := ctx.Err(); err != nil {
+			select {
+			case buf <- Try[Msg[T]]{Error: err}:
+			case <-stop:
+			}
+		}
+	}()
+
+	return out
+}
+
+func nackAll[T any](src Source[T], msgs []T) {
+	for _, msg := range msgs {
+		_ = src.Nack(msg)
+	}
+}
+
+// sleepCtx waits for the duration d. It returns false if ctx is done or stop is closed before that.
+func sleepCtx(ctx context.Context, stop <-chan struct{}, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return true
+	case <-stop:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
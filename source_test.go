@@ -0,0 +1,161 @@
+package rill
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/destel/rill/internal/th"
+)
+
+// fakeSource delivers integers in batches of 3 and records how each of them was settled.
+type fakeSource struct {
+	mu      sync.Mutex
+	next    int
+	fetched int
+	failAt  int
+	failAll bool
+	fails   int
+	acked   []int
+	nacked  []int
+}
+
+func (s *fakeSource) Fetch(ctx context.Context) ([]int, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.failAll || s.failAt > 0 && s.next == s.failAt {
+		s.failAt = 0
+		s.fails++
+		return nil, fmt.Errorf("fetch failed")
+	}
+
+	batch := []int{s.next, s.next + 1, s.next + 2}
+	s.next += 3
+	s.fetched += 3
+	return batch, nil
+}
+
+func (s *fakeSource) Ack(msg int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.acked = append(s.acked, msg)
+	return nil
+}
+
+func (s *fakeSource) Nack(msg int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nacked = append(s.nacked, msg)
+	return nil
+}
+
+func TestFromSource(t *testing.T) {
+	for _, prefetch := range []int{0, 10} {
+		t.Run(th.Name("correctness", prefetch), func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			src := &fakeSource{failAt: 6}
+
+			var values []int
+			var errs []error
+			for x := range FromSource[int](ctx, src, prefetch) {
+				if x.Error != nil {
+					errs = append(errs, x.Error)
+					continue
+				}
+
+				values = append(values, x.Value.Value)
+				if x.Value.Value%2 == 0 {
+					th.ExpectNoError(t, x.Value.Ack())
+				} else {
+					th.ExpectNoError(t, x.Value.Nack())
+				}
+				th.ExpectNoError(t, x.Value.Ack()) // no-op
+
+				if x.Value.Value == 11 {
+					cancel()
+				}
+			}
+
+			if len(errs) != 2 || errs[0].Error() != "fetch failed" || !errors.Is(errs[1], context.Canceled) {
+				t.Errorf("expected fetch error followed by context.Canceled, got %v", errs)
+			}
+
+			// the first 12 messages must be consumed in order, the rest may or may not be delivered before cancellation
+			th.ExpectSlice(t, values[:12], []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11})
+
+			src.mu.Lock()
+			defer src.mu.Unlock()
+			th.ExpectValue(t, len(src.acked)+len(src.nacked), src.fetched)
+			th.ExpectSorted(t, src.acked)
+			for _, x := range src.acked {
+				if x%2 != 0 {
+					t.Errorf("message %d must not be acked", x)
+				}
+			}
+		})
+
+		t.Run(th.Name("early exit", prefetch), func(t *testing.T) {
+			src := &fakeSource{}
+
+			msgs := FromSource[int](context.Background(), src, prefetch)
+
+			err := ForEach(msgs, 1, func(m Msg[int]) error {
+				if m.Value == 5 {
+					return fmt.Errorf("err05")
+				}
+				return m.Ack()
+			})
+			th.ExpectError(t, err, "err05")
+
+			th.ExpectNotHang(t, 10*time.Second, func() {
+				// ForEach keeps draining the stream in the background. Join it to wait until the stream is closed,
+				// and no more messages are fetched.
+				Drain(msgs)
+
+				// everything fetched after the failed message is nacked, but the last nack may still be in flight
+				for {
+					src.mu.Lock()
+					done := len(src.nacked) == src.fetched-6
+					src.mu.Unlock()
+					if done {
+						return
+					}
+					runtime.Gosched()
+				}
+			})
+
+			src.mu.Lock()
+			defer src.mu.Unlock()
+			th.ExpectSlice(t, src.acked, []int{0, 1, 2, 3, 4})
+			// besides the consumed messages, only the prefetched ones and at most two more batches are fetched
+			th.ExpectValueLTE(t, src.fetched, 6+prefetch+6)
+		})
+	}
+
+	t.Run("repeated errors", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+
+		src := &fakeSource{failAll: true}
+
+		_, errs := toSliceAndErrors(FromSource[int](ctx, src, 0))
+		th.ExpectValue(t, errs[len(errs)-1], context.DeadlineExceeded.Error())
+
+		src.mu.Lock()
+		defer src.mu.Unlock()
+		// fetch is retried with a growing delay: 10ms, 20ms, 40ms, 80ms, ...
+		th.ExpectValueLTE(t, src.fails, 6)
+		th.ExpectValue(t, len(errs), src.fails+1)
+	})
+}
@@ -0,0 +1,61 @@
+package rill
+
+import (
+	"database/sql"
+
+	"github.com/destel/rill/internal/core"
+)
+
+// FromSQLRows converts database rows into a stream. Each row is converted into a value using the scan function.
+// Rows are iterated in a background goroutine, and are closed when iteration is complete,
+// or when the output stream is drained or stopped with [Pipeline.StopSources].
+//
+//	rows, err := db.QueryContext(ctx, "SELECT id, name FROM users")
+//	if err != nil {
+//		return err
+//	}
+//
+//	users := rill.FromSQLRows(rows, func(rows *sql.Rows) (User, error) {
+//		var u User
+//		err := rows.Scan(&u.ID, &u.Name)
+//		return u, err
+//	})
+//
+// Errors returned by scan are sent to the output stream, and iteration continues with the next row.
+// The error reported by rows.Err after the iteration, if any, is sent to the output stream as the last item.
+func FromSQLRows[A any](rows *sql.Rows, scan func(*sql.Rows) (A, error)) <-chan Try[A] {
+	if rows == nil {
+		return nil
+	}
+
+	out := make(chan Try[A])
+	stop, release := core.StopOnDrain((<-chan Try[A])(out))
+
+	go func() {
+		defer close(out)
+		defer release()
+		defer rows.Close()
+
+		send := func(a Try[A]) bool {
+			select {
+			case out <- a:
+				return true
+			case <-stop:
+				return false
+			}
+		}
+
+		for rows.Next() {
+			a, err := scan(rows)
+			if !send(Wrap(a, err)) {
+				return
+			}
+		}
+
+		if err := rows.Err(); err != nil {
+			send(Try[A]{Error: err})
+		}
+	}()
+
+	return out
+}
@@ -0,0 +1,122 @@
+package rill
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/destel/rill/internal/th"
+)
+
+// fakeDriver is a minimal database driver. The query is the number of rows to return,
+// optionally followed by the index of the row at which iteration fails: "10" or "10,5".
+type fakeDriver struct{}
+
+type fakeConn struct{}
+
+type fakeStmt struct {
+	query string
+}
+
+type fakeRows struct {
+	n, failAt, i int
+}
+
+var fakeRowsOpen atomic.Int64
+
+func init() {
+	sql.Register("rill-fake", fakeDriver{})
+}
+
+func (fakeDriver) Open(string) (driver.Conn, error) { return fakeConn{}, nil }
+
+func (fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{query}, nil }
+func (fakeConn) Close() error                              { return nil }
+func (fakeConn) Begin() (driver.Tx, error)                 { return nil, errors.New("not supported") }
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return 0 }
+func (s fakeStmt) Exec([]driver.Value) (driver.Result, error) {
+	return nil, errors.New("not supported")
+}
+func (s fakeStmt) Query([]driver.Value) (driver.Rows, error) {
+	r := &fakeRows{failAt: -1}
+	if _, err := fmt.Sscanf(s.query, "%d,%d", &r.n, &r.failAt); err != nil && r.n == 0 {
+		return nil, err
+	}
+	fakeRowsOpen.Add(1)
+	return r, nil
+}
+
+func (r *fakeRows) Columns() []string { return []string{"n"} }
+func (r *fakeRows) Close() error {
+	fakeRowsOpen.Add(-1)
+	return nil
+}
+func (r *fakeRows) Next(dest []driver.Value) error {
+	switch {
+	case r.i == r.failAt:
+		return errors.New("connection lost")
+	case r.i >= r.n:
+		return io.EOF
+	}
+	dest[0] = int64(r.i)
+	r.i++
+	return nil
+}
+
+func TestFromSQLRows(t *testing.T) {
+	db, err := sql.Open("rill-fake", "")
+	th.ExpectNoError(t, err)
+	defer db.Close()
+
+	scanInt := func(rows *sql.Rows) (int, error) {
+		var x int
+		err := rows.Scan(&x)
+		if err == nil && x == 7 {
+			return 0, fmt.Errorf("bad row")
+		}
+		return x, err
+	}
+
+	t.Run("nil", func(t *testing.T) {
+		th.ExpectValue(t, FromSQLRows(nil, scanInt), nil)
+	})
+
+	t.Run("correctness", func(t *testing.T) {
+		rows, err := db.Query("10")
+		th.ExpectNoError(t, err)
+
+		outSlice, errs := toSliceAndErrors(FromSQLRows(rows, scanInt))
+		th.ExpectSlice(t, outSlice, []int{0, 1, 2, 3, 4, 5, 6, 8, 9})
+		th.ExpectSlice(t, errs, []string{"bad row"})
+		th.ExpectValue(t, fakeRowsOpen.Load(), int64(0))
+	})
+
+	t.Run("rows error", func(t *testing.T) {
+		rows, err := db.Query("10,5")
+		th.ExpectNoError(t, err)
+
+		outSlice, errs := toSliceAndErrors(FromSQLRows(rows, scanInt))
+		th.ExpectSlice(t, outSlice, []int{0, 1, 2, 3, 4})
+		th.ExpectSlice(t, errs, []string{"connection lost"})
+		th.ExpectValue(t, fakeRowsOpen.Load(), int64(0))
+	})
+
+	t.Run("early termination", func(t *testing.T) {
+		rows, err := db.Query("1000000")
+		th.ExpectNoError(t, err)
+
+		x, _, err := First(FromSQLRows(rows, scanInt))
+		th.ExpectNoError(t, err)
+		th.ExpectValue(t, x, 0)
+
+		time.Sleep(500 * time.Millisecond)
+		th.ExpectValue(t, fakeRowsOpen.Load(), int64(0))
+	})
+}
@@ -0,0 +1,135 @@
+package rill
+
+import (
+	"math"
+	"time"
+
+	"github.com/destel/rill/internal/core"
+)
+
+// Summary holds statistics of a numeric stream, computed by [Stats] and [StatsEvery].
+type Summary struct {
+	Count    int64
+	Mean     float64
+	Variance float64 // sample variance, zero if there are fewer than 2 items
+	Min      float64
+	Max      float64
+}
+
+// StdDev returns the sample standard deviation.
+func (s Summary) StdDev() float64 {
+	return math.Sqrt(s.Variance)
+}
+
+// welford computes running statistics in a single pass, using Welford's numerically stable algorithm.
+type welford struct {
+	count int64
+	mean  float64
+	m2    float64 // sum of squared differences from the mean
+	min   float64
+	max   float64
+}
+
+func (w *welford) Add(x float64) {
+	w.count++
+	if w.count == 1 {
+		w.min, w.max = x, x
+	} else {
+		w.min = math.Min(w.min, x)
+		w.max = math.Max(w.max, x)
+	}
+
+	delta := x - w.mean
+	w.mean += delta / float64(w.count)
+	w.m2 += delta * (x - w.mean)
+}
+
+func (w *welford) Summary() Summary {
+	s := Summary{
+		Count: w.count,
+		Mean:  w.mean,
+		Min:   w.min,
+		Max:   w.max,
+	}
+	if w.count > 1 {
+		s.Variance = w.m2 / float64(w.count-1)
+	}
+	return s
+}
+
+// Stats computes the count, mean, variance, min and max of all items in the input stream.
+// For an empty stream, a zero [Summary] is returned.
+//
+// This is a blocking ordered function that processes items sequentially.
+// See the package documentation for more information on blocking ordered functions and error handling.
+func Stats[A Number](in <-chan Try[A]) (Summary, error) {
+	core.Claim(in)
+
+	var w welford
+
+	for a := range in {
+		if a.Error != nil {
+			DrainNB(in)
+			return w.Summary(), a.Error
+		}
+		w.Add(float64(a.Value))
+	}
+
+	return w.Summary(), nil
+}
+
+// StatsEvery is the streaming version of [Stats]. It consumes the input stream and periodically emits a [Summary] of
+// all items received so far. Snapshots are emitted every interval, but only if new items were received since the previous one.
+// The final summary is always emitted when the input stream ends, even if it's empty.
+// Setting interval to zero or a negative value disables periodic snapshots, so only the final summary is emitted.
+// Errors are forwarded to the output stream as is and don't affect the statistics.
+//
+// This is a non-blocking ordered function that processes items sequentially.
+//
+// See the package documentation for more information on non-blocking ordered functions and error handling.
+func StatsEvery[A Number](in <-chan Try[A], interval time.Duration) <-chan Try[Summary] {
+	if in == nil {
+		return nil
+	}
+
+	out := make(chan Try[Summary])
+
+	go func() {
+		defer close(out)
+
+		var tick <-chan time.Time
+		if interval > 0 {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+
+		var w welford
+		var emitted int64 // count at the time of the last snapshot
+
+		for {
+			select {
+			case a, ok := <-in:
+				if !ok {
+					out <- Try[Summary]{Value: w.Summary()}
+					return
+				}
+
+				if a.Error != nil {
+					out <- Try[Summary]{Error: a.Error}
+					continue
+				}
+				w.Add(float64(a.Value))
+
+			case <-tick:
+				if w.count == emitted {
+					continue
+				}
+				emitted = w.count
+				out <- Try[Summary]{Value: w.Summary()}
+			}
+		}
+	}()
+
+	return out
+}
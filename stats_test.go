@@ -0,0 +1,118 @@
+package rill
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/destel/rill/internal/th"
+)
+
+func expectSummary(t *testing.T, actual, expected Summary) {
+	t.Helper()
+
+	closeEnough := func(a, b float64) bool {
+		return math.Abs(a-b) < 1e-9
+	}
+
+	if actual.Count != expected.Count || !closeEnough(actual.Mean, expected.Mean) || !closeEnough(actual.Variance, expected.Variance) ||
+		actual.Min != expected.Min || actual.Max != expected.Max {
+		t.Errorf("expected %+v, got %+v", expected, actual)
+	}
+}
+
+func TestStats(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		s, err := Stats(FromSlice[int](nil, nil))
+		th.ExpectNoError(t, err)
+		expectSummary(t, s, Summary{})
+	})
+
+	t.Run("single item", func(t *testing.T) {
+		s, err := Stats(FromSlice([]float64{-1.5}, nil))
+		th.ExpectNoError(t, err)
+		expectSummary(t, s, Summary{Count: 1, Mean: -1.5, Min: -1.5, Max: -1.5})
+	})
+
+	t.Run("correctness", func(t *testing.T) {
+		s, err := Stats(FromSlice([]int{4, 2, 5, 1, 3}, nil))
+		th.ExpectNoError(t, err)
+		expectSummary(t, s, Summary{Count: 5, Mean: 3, Variance: 2.5, Min: 1, Max: 5})
+		th.ExpectValue(t, math.Abs(s.StdDev()-math.Sqrt(2.5)) < 1e-9, true)
+	})
+
+	t.Run("numeric stability", func(t *testing.T) {
+		// naive sum of squares would lose all precision here
+		s, err := Stats(FromSlice([]float64{1e9 + 4, 1e9 + 7, 1e9 + 13, 1e9 + 16}, nil))
+		th.ExpectNoError(t, err)
+		expectSummary(t, s, Summary{Count: 4, Mean: 1e9 + 10, Variance: 30, Min: 1e9 + 4, Max: 1e9 + 16})
+	})
+
+	t.Run("error", func(t *testing.T) {
+		in := FromChan(th.FromRange(0, 1000), nil)
+		in = replaceWithError(in, 100, fmt.Errorf("err100"))
+
+		_, err := Stats(in)
+		th.ExpectError(t, err, "err100")
+
+		time.Sleep(1 * time.Second)
+		th.ExpectDrainedChan(t, in)
+	})
+}
+
+func TestStatsEvery(t *testing.T) {
+	t.Run("nil", func(t *testing.T) {
+		th.ExpectValue(t, StatsEvery[int](nil, 1*time.Second), nil)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		summaries, errs := toSliceAndErrors(StatsEvery(FromSlice[int](nil, nil), 100*time.Millisecond))
+		th.ExpectValue(t, len(errs), 0)
+		th.ExpectValue(t, len(summaries), 1)
+		expectSummary(t, summaries[0], Summary{})
+	})
+
+	t.Run("correctness", func(t *testing.T) {
+		in := make(chan Try[int])
+		go func() {
+			defer close(in)
+			for i := 1; i <= 5; i++ {
+				in <- Try[int]{Value: i}
+			}
+			in <- Try[int]{Error: fmt.Errorf("err")}
+			time.Sleep(1 * time.Second)
+			for i := 6; i <= 10; i++ {
+				in <- Try[int]{Value: i}
+			}
+			time.Sleep(1 * time.Second) // no new items, so no new snapshots
+		}()
+
+		summaries, errs := toSliceAndErrors(StatsEvery(in, 300*time.Millisecond))
+		th.ExpectSlice(t, errs, []string{"err"})
+
+		// one or more snapshots per batch of items, and the final summary
+		if len(summaries) < 3 || len(summaries) > 4 {
+			t.Fatalf("unexpected number of summaries: %d", len(summaries))
+		}
+
+		expectSummary(t, summaries[0], Summary{Count: 5, Mean: 3, Variance: 2.5, Min: 1, Max: 5})
+		expectSummary(t, summaries[len(summaries)-1], Summary{Count: 10, Mean: 5.5, Variance: 55.0 / 6, Min: 1, Max: 10})
+	})
+
+	for _, interval := range []time.Duration{0, -1 * time.Second} {
+		t.Run(th.Name("no interval", interval), func(t *testing.T) {
+			in := FromSlice([]int{1, 2, 3, 4, 5}, nil)
+
+			var summaries []Summary
+			var errs []string
+			th.ExpectNotHang(t, 1*time.Second, func() {
+				summaries, errs = toSliceAndErrors(StatsEvery(in, interval))
+			})
+
+			th.ExpectValue(t, len(errs), 0)
+			th.ExpectValue(t, len(summaries), 1)
+			expectSummary(t, summaries[0], Summary{Count: 5, Mean: 3, Variance: 2.5, Min: 1, Max: 5})
+		})
+	}
+}
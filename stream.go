@@ -0,0 +1,138 @@
+package rill
+
+import "time"
+
+// Stream is a thin wrapper around a stream channel, that allows to build pipelines using chainable method calls,
+// instead of deeply nested function calls. Since Go doesn't allow methods with type parameters, only the stages
+// that keep the item type are available as methods. Stages that change the item type are applied using
+// [MapStream], [OrderedMapStream], [BatchStream] or [Via]:
+//
+//	users := rill.MapStream(
+//		rill.NewStream(ids).
+//			Filter(5, isActive).
+//			Catch(1, ignoreNotFound),
+//		10, fetchUser,
+//	).Tap(1, logUser)
+//
+//	err := rill.BatchStream(users, 100, time.Second).ForEach(1, saveUsers)
+//
+// Methods are shorthands for the package-level functions with the same names, and behave exactly the same way,
+// unless a [Profile] is attached to the stream.
+// Stream values are cheap to copy. The underlying channel can be obtained with [Stream.Chan] at any point,
+// to pass it to functions that don't have a method counterpart.
+type Stream[A any] struct {
+	ch      <-chan Try[A]
+	profile Profile
+}
+
+// NewStream wraps the stream channel in into a [Stream].
+func NewStream[A any](in <-chan Try[A]) Stream[A] {
+	return Stream[A]{ch: in}
+}
+
+// WithProfile returns a copy of the stream, with the profile p attached. The profile applies to all stages created from
+// the returned stream and its descendants, including the ones created with [MapStream], [OrderedMapStream] and [BatchStream].
+// See [Profile] for details.
+func (s Stream[A]) WithProfile(p Profile) Stream[A] {
+	s.profile = p
+	return s
+}
+
+// Profile returns the profile attached to the stream, or a zero [Profile] if there is none.
+func (s Stream[A]) Profile() Profile {
+	return s.profile
+}
+
+// Chan returns the underlying stream channel.
+func (s Stream[A]) Chan() <-chan Try[A] {
+	return s.ch
+}
+
+// Apply applies an arbitrary stage that keeps the item type, such as a custom function or a closure around a package-level function.
+//
+//	s = s.Apply(func(in <-chan rill.Try[User]) <-chan rill.Try[User] {
+//		return rill.Take(in, 100)
+//	})
+func (s Stream[A]) Apply(stage func(<-chan Try[A]) <-chan Try[A]) Stream[A] {
+	return Stream[A]{ch: stage(s.ch), profile: s.profile}
+}
+
+// Filter is the method version of [Filter].
+func (s Stream[A]) Filter(n int, f func(A) (bool, error)) Stream[A] {
+	return Stream[A]{ch: Filter(s.ch, s.profile.concurrency(n), f, s.profile.options()...), profile: s.profile}
+}
+
+// OrderedFilter is the method version of [OrderedFilter].
+func (s Stream[A]) OrderedFilter(n int, f func(A) (bool, error)) Stream[A] {
+	return Stream[A]{ch: OrderedFilter(s.ch, s.profile.concurrency(n), f, s.profile.options()...), profile: s.profile}
+}
+
+// Tap is the method version of [Tap].
+func (s Stream[A]) Tap(n int, f func(A) error) Stream[A] {
+	return Stream[A]{ch: Tap(s.ch, s.profile.concurrency(n), f, s.profile.options()...), profile: s.profile}
+}
+
+// OrderedTap is the method version of [OrderedTap].
+func (s Stream[A]) OrderedTap(n int, f func(A) error) Stream[A] {
+	return Stream[A]{ch: OrderedTap(s.ch, s.profile.concurrency(n), f, s.profile.options()...), profile: s.profile}
+}
+
+// Catch is the method version of [Catch].
+func (s Stream[A]) Catch(n int, f func(error) error) Stream[A] {
+	return Stream[A]{ch: Catch(s.ch, s.profile.concurrency(n), f, s.profile.options()...), profile: s.profile}
+}
+
+// OrderedCatch is the method version of [OrderedCatch].
+func (s Stream[A]) OrderedCatch(n int, f func(error) error) Stream[A] {
+	return Stream[A]{ch: OrderedCatch(s.ch, s.profile.concurrency(n), f, s.profile.options()...), profile: s.profile}
+}
+
+// Take is the method version of [Take].
+func (s Stream[A]) Take(count int) Stream[A] {
+	return Stream[A]{ch: Take(s.ch, count), profile: s.profile}
+}
+
+// Buffer is the method version of [Buffer].
+func (s Stream[A]) Buffer(size int) Stream[A] {
+	return Stream[A]{ch: Buffer(s.ch, size), profile: s.profile}
+}
+
+// ForEach is the method version of [ForEach].
+func (s Stream[A]) ForEach(n int, f func(A) error) error {
+	return ForEach(s.ch, s.profile.concurrency(n), stageErrFunc(s.profile.stageConfig(), f))
+}
+
+// ToSlice is the method version of [ToSlice].
+func (s Stream[A]) ToSlice() ([]A, error) {
+	return ToSlice(s.ch)
+}
+
+// Err is the method version of [Err].
+func (s Stream[A]) Err() error {
+	return Err(s.ch)
+}
+
+// MapStream is the [Stream] version of [Map].
+func MapStream[A, B any](s Stream[A], n int, f func(A) (B, error)) Stream[B] {
+	return Stream[B]{ch: Map(s.ch, s.profile.concurrency(n), f, s.profile.options()...), profile: s.profile}
+}
+
+// OrderedMapStream is the [Stream] version of [OrderedMap].
+func OrderedMapStream[A, B any](s Stream[A], n int, f func(A) (B, error)) Stream[B] {
+	return Stream[B]{ch: OrderedMap(s.ch, s.profile.concurrency(n), f, s.profile.options()...), profile: s.profile}
+}
+
+// BatchStream is the [Stream] version of [Batch]. It can't be a method, since a method of Stream[A]
+// returning Stream[[]A] would make the Stream type infinitely recursive.
+func BatchStream[A any](s Stream[A], size int, timeout time.Duration) Stream[[]A] {
+	return Stream[[]A]{ch: Batch(s.ch, size, timeout), profile: s.profile}
+}
+
+// Via applies an arbitrary stage, that may change the item type, to the stream s.
+//
+//	lines := rill.Via(s, func(in <-chan rill.Try[Document]) <-chan rill.Try[string] {
+//		return rill.FlatMap(in, 5, splitLines)
+//	})
+func Via[A, B any](s Stream[A], stage func(<-chan Try[A]) <-chan Try[B]) Stream[B] {
+	return Stream[B]{ch: stage(s.ch), profile: s.profile}
+}
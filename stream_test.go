@@ -0,0 +1,94 @@
+package rill
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/destel/rill/internal/th"
+)
+
+func TestStream(t *testing.T) {
+	t.Run("nil", func(t *testing.T) {
+		th.ExpectValue(t, NewStream[int](nil).Filter(1, func(x int) (bool, error) { return true, nil }).Chan(), nil)
+	})
+
+	t.Run("chain", func(t *testing.T) {
+		in := FromChan(th.FromRange(0, 100), nil)
+		in = replaceWithError(in, 11, fmt.Errorf("err11"))
+		in = replaceWithError(in, 22, fmt.Errorf("err22"))
+
+		var tapped atomic.Int64
+		s := NewStream(in).
+			OrderedFilter(3, func(x int) (bool, error) { return x%2 == 0, nil }).
+			OrderedCatch(1, func(err error) error {
+				if strings.Contains(err.Error(), "11") {
+					return nil
+				}
+				return err
+			}).
+			OrderedTap(1, func(x int) error {
+				tapped.Add(1)
+				return nil
+			}).
+			Take(20)
+
+		strs := OrderedMapStream(s, 3, func(x int) (string, error) {
+			return fmt.Sprintf("%02d", x), nil
+		})
+
+		batches := BatchStream(strs, 5, 1*time.Second)
+
+		var outSlice []string
+		var errs []string
+		for x := range batches.Chan() {
+			if x.Error != nil {
+				errs = append(errs, x.Error.Error())
+				continue
+			}
+			outSlice = append(outSlice, strings.Join(x.Value, ","))
+		}
+
+		th.ExpectSlice(t, errs, []string{"err22"})
+		th.ExpectValue(t, len(outSlice), 4)
+		th.ExpectValue(t, outSlice[0], "00,02,04,06,08")
+		if tapped.Load() < 19 {
+			t.Errorf("expected at least 19 tapped items, got %d", tapped.Load())
+		}
+	})
+
+	t.Run("via and apply", func(t *testing.T) {
+		s := NewStream(FromChan(th.FromRange(0, 10), nil)).
+			Apply(func(in <-chan Try[int]) <-chan Try[int] {
+				return Take(in, 5)
+			}).
+			Buffer(2)
+
+		out, err := Via(s, func(in <-chan Try[int]) <-chan Try[string] {
+			return OrderedMap(in, 1, func(x int) (string, error) { return fmt.Sprint(x), nil })
+		}).ToSlice()
+
+		th.ExpectNoError(t, err)
+		th.ExpectSlice(t, out, []string{"0", "1", "2", "3", "4"})
+	})
+
+	t.Run("terminal", func(t *testing.T) {
+		in := FromChan(th.FromRange(0, 10), nil)
+		in = replaceWithError(in, 5, fmt.Errorf("err05"))
+
+		th.ExpectError(t, NewStream(in).Tap(2, func(int) error { return nil }).Err(), "err05")
+
+		var sum int
+		err := MapStream(NewStream(FromChan(th.FromRange(0, 10), nil)), 2, func(x int) (int, error) {
+			return x * 2, nil
+		}).ForEach(1, func(x int) error {
+			sum += x
+			return nil
+		})
+
+		th.ExpectNoError(t, err)
+		th.ExpectValue(t, sum, 90)
+	})
+}
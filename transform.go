@@ -1,6 +1,11 @@
 package rill
 
 import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
 	"github.com/destel/rill/internal/core"
 )
 
@@ -11,8 +16,11 @@ import (
 // An ordered version of this function, [OrderedMap], is also available.
 //
 // See the package documentation for more information on non-blocking unordered functions and error handling.
-func Map[A, B any](in <-chan Try[A], n int, f func(A) (B, error)) <-chan Try[B] {
-	return core.FilterMap(in, n, func(a Try[A]) (Try[B], bool) {
+func Map[A, B any](in <-chan Try[A], n int, f func(A) (B, error), opts ...StageOption) <-chan Try[B] {
+	cfg := newStageConfig("Map", in, n, opts)
+	f = stageFunc(cfg, f)
+
+	return stageOutput(cfg, core.BufferedFilterMap(in, n, cfg.buffer, func(a Try[A]) (Try[B], bool) {
 		if a.Error != nil {
 			return Try[B]{Error: a.Error}, true
 		}
@@ -23,12 +31,15 @@ func Map[A, B any](in <-chan Try[A], n int, f func(A) (B, error)) <-chan Try[B]
 		}
 
 		return Try[B]{Value: b}, true
-	})
+	}))
 }
 
 // OrderedMap is the ordered version of [Map].
-func OrderedMap[A, B any](in <-chan Try[A], n int, f func(A) (B, error)) <-chan Try[B] {
-	return core.OrderedFilterMap(in, n, func(a Try[A]) (Try[B], bool) {
+func OrderedMap[A, B any](in <-chan Try[A], n int, f func(A) (B, error), opts ...StageOption) <-chan Try[B] {
+	cfg := newStageConfig("OrderedMap", in, n, opts)
+	f = stageFunc(cfg, f)
+
+	return stageOutput(cfg, core.BufferedOrderedFilterMap(in, n, cfg.buffer, func(a Try[A]) (Try[B], bool) {
 		if a.Error != nil {
 			return Try[B]{Error: a.Error}, true
 		}
@@ -39,7 +50,74 @@ func OrderedMap[A, B any](in <-chan Try[A], n int, f func(A) (B, error)) <-chan
 		}
 
 		return Try[B]{Value: b}, true
+	}))
+}
+
+// MapN is similar to [Map], but additionally passes the index of the worker goroutine (from 0 to n-1) to the function f.
+// Each index is used by exactly one goroutine, so f is never called concurrently with the same index.
+// This allows to use simple sharded resources, such as a slice of connections or clients indexed by worker:
+//
+//	clients := make([]*Client, 10)
+//	// ...
+//	users := rill.MapN(ids, len(clients), func(worker int, id int) (*User, error) {
+//		return clients[worker].GetUser(ctx, id)
+//	})
+//
+// This is a non-blocking unordered function that processes items concurrently using n goroutines.
+// An ordered version of this function, [OrderedMapN], is also available.
+//
+// See the package documentation for more information on non-blocking unordered functions and error handling.
+func MapN[A, B any](in <-chan Try[A], n int, f func(worker int, a A) (B, error)) <-chan Try[B] {
+	if in == nil {
+		return nil
+	}
+
+	out := make(chan Try[B])
+
+	core.IndexedLoop(in, out, n, func(worker int, a Try[A]) {
+		if a.Error != nil {
+			out <- Try[B]{Error: a.Error}
+			return
+		}
+
+		b, err := f(worker, a.Value)
+		if err != nil {
+			out <- Try[B]{Error: err}
+			return
+		}
+
+		out <- Try[B]{Value: b}
 	})
+
+	return out
+}
+
+// OrderedMapN is the ordered version of [MapN].
+func OrderedMapN[A, B any](in <-chan Try[A], n int, f func(worker int, a A) (B, error)) <-chan Try[B] {
+	if in == nil {
+		return nil
+	}
+
+	out := make(chan Try[B])
+
+	core.OrderedIndexedLoop(in, out, n, func(worker int, a Try[A], canWrite <-chan struct{}) {
+		if a.Error != nil {
+			<-canWrite
+			out <- Try[B]{Error: a.Error}
+			return
+		}
+
+		b, err := f(worker, a.Value)
+		<-canWrite
+		if err != nil {
+			out <- Try[B]{Error: err}
+			return
+		}
+
+		out <- Try[B]{Value: b}
+	})
+
+	return out
 }
 
 // Filter takes a stream of items of type A and filters them using a predicate function f.
@@ -49,8 +127,11 @@ func OrderedMap[A, B any](in <-chan Try[A], n int, f func(A) (B, error)) <-chan
 // An ordered version of this function, [OrderedFilter], is also available.
 //
 // See the package documentation for more information on non-blocking unordered functions and error handling.
-func Filter[A any](in <-chan Try[A], n int, f func(A) (bool, error)) <-chan Try[A] {
-	return core.FilterMap(in, n, func(a Try[A]) (Try[A], bool) {
+func Filter[A any](in <-chan Try[A], n int, f func(A) (bool, error), opts ...StageOption) <-chan Try[A] {
+	cfg := newStageConfig("Filter", in, n, opts)
+	f = stageFunc(cfg, f)
+
+	return stageOutput(cfg, core.BufferedFilterMap(in, n, cfg.buffer, func(a Try[A]) (Try[A], bool) {
 		if a.Error != nil {
 			return a, true // never filter out errors
 		}
@@ -61,12 +142,15 @@ func Filter[A any](in <-chan Try[A], n int, f func(A) (bool, error)) <-chan Try[
 		}
 
 		return a, keep
-	})
+	}))
 }
 
 // OrderedFilter is the ordered version of [Filter].
-func OrderedFilter[A any](in <-chan Try[A], n int, f func(A) (bool, error)) <-chan Try[A] {
-	return core.OrderedFilterMap(in, n, func(a Try[A]) (Try[A], bool) {
+func OrderedFilter[A any](in <-chan Try[A], n int, f func(A) (bool, error), opts ...StageOption) <-chan Try[A] {
+	cfg := newStageConfig("OrderedFilter", in, n, opts)
+	f = stageFunc(cfg, f)
+
+	return stageOutput(cfg, core.BufferedOrderedFilterMap(in, n, cfg.buffer, func(a Try[A]) (Try[A], bool) {
 		if a.Error != nil {
 			return a, true // never filter out errors
 		}
@@ -77,7 +161,51 @@ func OrderedFilter[A any](in <-chan Try[A], n int, f func(A) (bool, error)) <-ch
 		}
 
 		return a, keep
-	})
+	}))
+}
+
+// Tap invokes the function f for each item of the input stream, and passes the items through unchanged.
+// It's intended for side effects, such as logging, metrics or progress reporting,
+// and makes such intent clearer than using [Map] with an identity function.
+// If f returns an error, the item is replaced with that error in the output stream.
+//
+// This is a non-blocking unordered function that processes items concurrently using n goroutines.
+// An ordered version of this function, [OrderedTap], is also available.
+//
+// See the package documentation for more information on non-blocking unordered functions and error handling.
+func Tap[A any](in <-chan Try[A], n int, f func(A) error, opts ...StageOption) <-chan Try[A] {
+	cfg := newStageConfig("Tap", in, n, opts)
+	f = stageErrFunc(cfg, f)
+
+	return stageOutput(cfg, core.BufferedFilterMap(in, n, cfg.buffer, func(a Try[A]) (Try[A], bool) {
+		if a.Error != nil {
+			return a, true
+		}
+
+		if err := f(a.Value); err != nil {
+			return Try[A]{Error: err}, true
+		}
+
+		return a, true
+	}))
+}
+
+// OrderedTap is the ordered version of [Tap].
+func OrderedTap[A any](in <-chan Try[A], n int, f func(A) error, opts ...StageOption) <-chan Try[A] {
+	cfg := newStageConfig("OrderedTap", in, n, opts)
+	f = stageErrFunc(cfg, f)
+
+	return stageOutput(cfg, core.BufferedOrderedFilterMap(in, n, cfg.buffer, func(a Try[A]) (Try[A], bool) {
+		if a.Error != nil {
+			return a, true
+		}
+
+		if err := f(a.Value); err != nil {
+			return Try[A]{Error: err}, true
+		}
+
+		return a, true
+	}))
 }
 
 // FilterMap takes a stream of items of type A, applies a function f that can filter and transform them into items of type B.
@@ -88,8 +216,11 @@ func OrderedFilter[A any](in <-chan Try[A], n int, f func(A) (bool, error)) <-ch
 // An ordered version of this function, [OrderedFilterMap], is also available.
 //
 // See the package documentation for more information on non-blocking unordered functions and error handling.
-func FilterMap[A, B any](in <-chan Try[A], n int, f func(A) (B, bool, error)) <-chan Try[B] {
-	return core.FilterMap(in, n, func(a Try[A]) (Try[B], bool) {
+func FilterMap[A, B any](in <-chan Try[A], n int, f func(A) (B, bool, error), opts ...StageOption) <-chan Try[B] {
+	cfg := newStageConfig("FilterMap", in, n, opts)
+	f = stageFilterMapFunc(cfg, f)
+
+	return stageOutput(cfg, core.BufferedFilterMap(in, n, cfg.buffer, func(a Try[A]) (Try[B], bool) {
 		if a.Error != nil {
 			return Try[B]{Error: a.Error}, true
 		}
@@ -100,12 +231,15 @@ func FilterMap[A, B any](in <-chan Try[A], n int, f func(A) (B, bool, error)) <-
 		}
 
 		return Try[B]{Value: b}, keep
-	})
+	}))
 }
 
 // OrderedFilterMap is the ordered version of [FilterMap].
-func OrderedFilterMap[A, B any](in <-chan Try[A], n int, f func(A) (B, bool, error)) <-chan Try[B] {
-	return core.OrderedFilterMap(in, n, func(a Try[A]) (Try[B], bool) {
+func OrderedFilterMap[A, B any](in <-chan Try[A], n int, f func(A) (B, bool, error), opts ...StageOption) <-chan Try[B] {
+	cfg := newStageConfig("OrderedFilterMap", in, n, opts)
+	f = stageFilterMapFunc(cfg, f)
+
+	return stageOutput(cfg, core.BufferedOrderedFilterMap(in, n, cfg.buffer, func(a Try[A]) (Try[B], bool) {
 		if a.Error != nil {
 			return Try[B]{Error: a.Error}, true
 		}
@@ -116,7 +250,7 @@ func OrderedFilterMap[A, B any](in <-chan Try[A], n int, f func(A) (B, bool, err
 		}
 
 		return Try[B]{Value: b}, keep
-	})
+	}))
 }
 
 // FlatMap takes a stream of items of type A and transforms each item into a new sub-stream of items of type B using a function f.
@@ -126,12 +260,15 @@ func OrderedFilterMap[A, B any](in <-chan Try[A], n int, f func(A) (B, bool, err
 // An ordered version of this function, [OrderedFlatMap], is also available.
 //
 // See the package documentation for more information on non-blocking unordered functions and error handling.
-func FlatMap[A, B any](in <-chan Try[A], n int, f func(A) <-chan Try[B]) <-chan Try[B] {
+func FlatMap[A, B any](in <-chan Try[A], n int, f func(A) <-chan Try[B], opts ...StageOption) <-chan Try[B] {
 	if in == nil {
 		return nil
 	}
 
-	out := make(chan Try[B])
+	cfg := newStageConfig("FlatMap", in, n, opts)
+	f = stageStreamFunc(cfg, f)
+
+	out := make(chan Try[B], cfg.buffer)
 
 	core.Loop(in, out, n, func(a Try[A]) {
 		if a.Error != nil {
@@ -145,16 +282,19 @@ func FlatMap[A, B any](in <-chan Try[A], n int, f func(A) <-chan Try[B]) <-chan
 		}
 	})
 
-	return out
+	return stageOutput(cfg, out)
 }
 
 // OrderedFlatMap is the ordered version of [FlatMap].
-func OrderedFlatMap[A, B any](in <-chan Try[A], n int, f func(A) <-chan Try[B]) <-chan Try[B] {
+func OrderedFlatMap[A, B any](in <-chan Try[A], n int, f func(A) <-chan Try[B], opts ...StageOption) <-chan Try[B] {
 	if in == nil {
 		return nil
 	}
 
-	out := make(chan Try[B])
+	cfg := newStageConfig("OrderedFlatMap", in, n, opts)
+	f = stageStreamFunc(cfg, f)
+
+	out := make(chan Try[B], cfg.buffer)
 
 	core.OrderedLoop(in, out, n, func(a Try[A], canWrite <-chan struct{}) {
 		if a.Error != nil {
@@ -170,7 +310,111 @@ func OrderedFlatMap[A, B any](in <-chan Try[A], n int, f func(A) <-chan Try[B])
 		}
 	})
 
-	return out
+	return stageOutput(cfg, out)
+}
+
+// FlatMapSlice is similar to [FlatMap], but expands each item of the input stream into a slice, returned by the function f.
+// Items of the slice are written directly to the output stream, which has far less overhead than creating a sub-stream per item.
+// If f returns an error, it's sent to the output stream instead of the slice items.
+//
+// This is a non-blocking unordered function that processes items concurrently using n goroutines.
+// An ordered version of this function, [OrderedFlatMapSlice], is also available.
+//
+// See the package documentation for more information on non-blocking unordered functions and error handling.
+func FlatMapSlice[A, B any](in <-chan Try[A], n int, f func(A) ([]B, error), opts ...StageOption) <-chan Try[B] {
+	if in == nil {
+		return nil
+	}
+
+	cfg := newStageConfig("FlatMapSlice", in, n, opts)
+	f = stageFunc(cfg, f)
+
+	out := make(chan Try[B], cfg.buffer)
+
+	core.Loop(in, out, n, func(a Try[A]) {
+		if a.Error != nil {
+			out <- Try[B]{Error: a.Error}
+			return
+		}
+
+		bb, err := f(a.Value)
+		if err != nil {
+			out <- Try[B]{Error: err}
+			return
+		}
+
+		for _, b := range bb {
+			out <- Try[B]{Value: b}
+		}
+	})
+
+	return stageOutput(cfg, out)
+}
+
+// OrderedFlatMapSlice is the ordered version of [FlatMapSlice].
+func OrderedFlatMapSlice[A, B any](in <-chan Try[A], n int, f func(A) ([]B, error), opts ...StageOption) <-chan Try[B] {
+	if in == nil {
+		return nil
+	}
+
+	cfg := newStageConfig("OrderedFlatMapSlice", in, n, opts)
+	f = stageFunc(cfg, f)
+
+	out := make(chan Try[B], cfg.buffer)
+
+	core.OrderedLoop(in, out, n, func(a Try[A], canWrite <-chan struct{}) {
+		if a.Error != nil {
+			<-canWrite
+			out <- Try[B]{Error: a.Error}
+			return
+		}
+
+		bb, err := f(a.Value)
+		<-canWrite
+		if err != nil {
+			out <- Try[B]{Error: err}
+			return
+		}
+
+		for _, b := range bb {
+			out <- Try[B]{Value: b}
+		}
+	})
+
+	return stageOutput(cfg, out)
+}
+
+// FlatMapPrefetch is like [FlatMap], but reads each sub-stream ahead of the consumer, holding up to prefetch items in memory.
+// This hides the latency of sub-streams that produce items in bursts, such as paginated API responses:
+// the next page can be fetched while the items of the current page are still being consumed downstream.
+// If prefetch is zero or negative, no items are read ahead and FlatMapPrefetch behaves exactly like FlatMap.
+//
+// This is a non-blocking unordered function that processes items concurrently using n goroutines.
+// An ordered version of this function, [OrderedFlatMapPrefetch], is also available.
+//
+// See the package documentation for more information on non-blocking unordered functions and error handling.
+func FlatMapPrefetch[A, B any](in <-chan Try[A], n int, prefetch int, f func(A) <-chan Try[B]) <-chan Try[B] {
+	return FlatMap(in, n, prefetchFunc(prefetch, f))
+}
+
+// OrderedFlatMapPrefetch is the ordered version of [FlatMapPrefetch].
+// Here prefetching is even more useful, since sub-streams can be read ahead while they wait for their turn to be emitted.
+func OrderedFlatMapPrefetch[A, B any](in <-chan Try[A], n int, prefetch int, f func(A) <-chan Try[B]) <-chan Try[B] {
+	return OrderedFlatMap(in, n, prefetchFunc(prefetch, f))
+}
+
+func prefetchFunc[A, B any](prefetch int, f func(A) <-chan Try[B]) func(A) <-chan Try[B] {
+	if prefetch <= 0 {
+		return f
+	}
+
+	return func(a A) <-chan Try[B] {
+		bb := f(a)
+		if bb == nil {
+			return nil
+		}
+		return core.Buffer(bb, prefetch)
+	}
 }
 
 // Catch allows handling errors in the middle of a stream processing pipeline.
@@ -184,8 +428,11 @@ func OrderedFlatMap[A, B any](in <-chan Try[A], n int, f func(A) <-chan Try[B])
 // An ordered version of this function, [OrderedCatch], is also available.
 //
 // See the package documentation for more information on non-blocking unordered functions and error handling.
-func Catch[A any](in <-chan Try[A], n int, f func(error) error) <-chan Try[A] {
-	return core.FilterMap(in, n, func(a Try[A]) (Try[A], bool) {
+func Catch[A any](in <-chan Try[A], n int, f func(error) error, opts ...StageOption) <-chan Try[A] {
+	cfg := newStageConfig("Catch", in, n, opts)
+	f = stageErrFunc(cfg, f)
+
+	return stageOutput(cfg, core.BufferedFilterMap(in, n, cfg.buffer, func(a Try[A]) (Try[A], bool) {
 		if a.Error == nil {
 			return a, true
 		}
@@ -196,12 +443,15 @@ func Catch[A any](in <-chan Try[A], n int, f func(error) error) <-chan Try[A] {
 		}
 
 		return Try[A]{Error: err}, true // error replaced by f(a.Error)
-	})
+	}))
 }
 
 // OrderedCatch is the ordered version of [Catch].
-func OrderedCatch[A any](in <-chan Try[A], n int, f func(error) error) <-chan Try[A] {
-	return core.OrderedFilterMap(in, n, func(a Try[A]) (Try[A], bool) {
+func OrderedCatch[A any](in <-chan Try[A], n int, f func(error) error, opts ...StageOption) <-chan Try[A] {
+	cfg := newStageConfig("OrderedCatch", in, n, opts)
+	f = stageErrFunc(cfg, f)
+
+	return stageOutput(cfg, core.BufferedOrderedFilterMap(in, n, cfg.buffer, func(a Try[A]) (Try[A], bool) {
 		if a.Error == nil {
 			return a, true
 		}
@@ -212,5 +462,332 @@ func OrderedCatch[A any](in <-chan Try[A], n int, f func(error) error) <-chan Tr
 		}
 
 		return Try[A]{Error: err}, true // error replaced by f(a.Error)
+	}))
+}
+
+// Conflate collapses queued items with the same key when the consumer is slower than the producer.
+// When a new item arrives while an item with the same key is still waiting to be consumed,
+// the new item replaces the waiting one and keeps its position in the stream. This way only the most recent value
+// per key is delivered, which is a common pattern for streams of price or status updates.
+// When the consumer keeps up with the producer, all items are delivered as is.
+//
+// Conflate never blocks the upstream producer. Memory usage is bounded by the number of distinct keys,
+// plus the number of queued errors. Errors are never collapsed.
+//
+// This is a non-blocking ordered function that processes items sequentially.
+//
+// See the package documentation for more information on non-blocking ordered functions and error handling.
+func Conflate[A any, K comparable](in <-chan Try[A], key func(A) K) <-chan Try[A] {
+	return core.Conflate(in, func(a Try[A]) (K, bool) {
+		if a.Error != nil {
+			var zero K
+			return zero, false
+		}
+		return key(a.Value), true
+	})
+}
+
+// MapByKey is similar to [Map], but guarantees that items with the same key are processed sequentially
+// and in the order they appear in the input stream. Items with different keys are processed concurrently.
+// This preserves causal ordering per entity, such as per user or per account, while still allowing parallelism.
+//
+// Any free worker picks up the next item whose key is not being processed at the moment. Items of a busy key are queued in memory,
+// so a slow key doesn't hold back the other keys. At most 1024 items are queued in total.
+// Once the limit is reached, for example because a single key is much slower than the rest, MapByKey stops reading the input stream
+// until the queue shrinks, and only then the other keys are affected.
+//
+// This is a non-blocking function that processes items concurrently using n goroutines.
+// The output is ordered within each key, but not across keys.
+//
+// See the package documentation for more information on non-blocking functions and error handling.
+func MapByKey[A, B any, K comparable](in <-chan Try[A], n int, key func(A) K, f func(A) (B, error)) <-chan Try[B] {
+	if in == nil {
+		return nil
+	}
+
+	out := make(chan Try[B])
+	done := make(chan struct{})
+
+	core.KeyedLoop(in, done, n, mapByKeyQueueSize, func(a Try[A]) (K, bool) {
+		if a.Error != nil {
+			var zero K
+			return zero, false
+		}
+		return key(a.Value), true
+	}, func(a Try[A]) {
+		if a.Error != nil {
+			out <- Try[B]{Error: a.Error}
+			return
+		}
+
+		b, err := f(a.Value)
+		if err != nil {
+			out <- Try[B]{Error: err}
+			return
+		}
+		out <- Try[B]{Value: b}
+	})
+
+	go func() {
+		<-done
+		close(out)
+	}()
+
+	return out
+}
+
+// mapByKeyQueueSize is the maximum number of items MapByKey holds in memory while their keys are being processed.
+const mapByKeyQueueSize = 1024
+
+// DistinctWithin removes duplicate items from the input stream, using bounded memory.
+// This makes deduplication practical for infinite streams and long-running pipelines.
+// Keys of recently emitted items are kept in an LRU cache of at most maxEntries entries, each of which expires after ttl.
+// An item is dropped if an item with the same key has been emitted within ttl and its key hasn't been evicted from the cache yet.
+// Non-positive maxEntries or ttl disable the corresponding limit.
+//
+// This is a non-blocking ordered function that processes items sequentially.
+// Errors are never dropped.
+//
+// See the package documentation for more information on non-blocking ordered functions and error handling.
+func DistinctWithin[A any, K comparable](in <-chan Try[A], key func(A) K, maxEntries int, ttl time.Duration) <-chan Try[A] {
+	return core.DistinctWithin(in, func(a Try[A]) (K, bool) {
+		if a.Error != nil {
+			var zero K
+			return zero, false
+		}
+		return key(a.Value), true
+	}, maxEntries, ttl)
+}
+
+// Take forwards the first count values from the input stream, and then closes the output stream.
+// After that, the rest of the input stream is drained in the background, so upstream goroutines are not leaked.
+// Errors are forwarded as well, but are not counted.
+//
+// This is a non-blocking ordered function that processes items sequentially.
+//
+// See the package documentation for more information on non-blocking ordered functions and error handling.
+func Take[A any](in <-chan Try[A], count int) <-chan Try[A] {
+	if in == nil {
+		return nil
+	}
+
+	out := make(chan Try[A])
+	if count <= 0 {
+		close(out)
+		DrainNB(in)
+		return out
+	}
+
+	go func() {
+		defer close(out)
+
+		taken := 0
+		for a := range in {
+			if a.Error == nil {
+				taken++
+			}
+			out <- a
+
+			if taken >= count {
+				DrainNB(in)
+				return
+			}
+		}
+	}()
+
+	return out
+}
+
+type takeWhileItem[A any] struct {
+	Value   A
+	Keep    bool
+	Dropped bool // the item was dropped without calling the predicate
+}
+
+// TakeWhile forwards items from the input stream as long as the predicate f returns true.
+// Once f returns false, the output stream is closed, and the rest of the input stream is drained in the background.
+// Errors returned by f are forwarded to the output stream and do not stop it.
+//
+// This is a non-blocking ordered function that processes items concurrently using n goroutines.
+// The predicate may be called for a few items past the first one that did not pass, but not more than
+// the ones that were already being processed at that moment.
+//
+// See the package documentation for more information on non-blocking ordered functions and error handling.
+func TakeWhile[A any](in <-chan Try[A], n int, f func(A) (bool, error)) <-chan Try[A] {
+	if in == nil {
+		return nil
+	}
+
+	discard := core.DrainSink(in)
+
+	// stopped is set once the first item that did not pass is seen in order.
+	// After that, the remaining items are dropped without calling f.
+	var stopped atomic.Bool
+
+	checked := OrderedMap(in, n, func(a A) (takeWhileItem[A], error) {
+		if stopped.Load() {
+			discard(Try[A]{Value: a})
+			return takeWhileItem[A]{Dropped: true}, nil
+		}
+
+		keep, err := f(a)
+		return takeWhileItem[A]{Value: a, Keep: keep}, err
+	})
+
+	out := make(chan Try[A])
+
+	go func() {
+		defer close(out)
+
+		for x := range checked {
+			if x.Error != nil {
+				out <- Try[A]{Error: x.Error}
+				continue
+			}
+
+			if !x.Value.Keep {
+				stopped.Store(true)
+				discard(Try[A]{Value: x.Value.Value})
+				DrainNB(in)
+
+				// items checked before the stop are dropped here
+				go func() {
+					for x := range checked {
+						if x.Error == nil && !x.Value.Dropped {
+							discard(Try[A]{Value: x.Value.Value})
+						}
+					}
+				}()
+				return
+			}
+
+			out <- Try[A]{Value: x.Value.Value}
+		}
+	}()
+
+	return out
+}
+
+// ErrCountMismatch is sent by [ExpectCount] and [ExpectCountBetween] when the number of values in the stream is unexpected.
+var ErrCountMismatch = errors.New("rill: unexpected number of items")
+
+// ExpectCount forwards all items from the input stream as is. If the stream ends with a number of values other than count,
+// an error wrapping [ErrCountMismatch] is sent to the output stream right before it's closed.
+// Errors are forwarded as well, but are not counted.
+// This helps to catch silent data loss, such as dropped pages or truncated files, in ingestion pipelines.
+//
+// This is a non-blocking ordered function that processes items sequentially.
+//
+// See the package documentation for more information on non-blocking ordered functions and error handling.
+func ExpectCount[A any](in <-chan Try[A], count int) <-chan Try[A] {
+	return ExpectCountBetween(in, count, count)
+}
+
+// ExpectCountBetween is similar to [ExpectCount], but allows the number of values to be anywhere in the [min, max] range.
+//
+// This is a non-blocking ordered function that processes items sequentially.
+//
+// See the package documentation for more information on non-blocking ordered functions and error handling.
+func ExpectCountBetween[A any](in <-chan Try[A], min, max int) <-chan Try[A] {
+	if in == nil {
+		return nil
+	}
+
+	out := make(chan Try[A])
+
+	go func() {
+		defer close(out)
+
+		count := 0
+		for a := range in {
+			if a.Error == nil {
+				count++
+			}
+			out <- a
+		}
+
+		if count < min || count > max {
+			var err error
+			if min == max {
+				err = fmt.Errorf("%w: expected %d, got %d", ErrCountMismatch, min, count)
+			} else {
+				err = fmt.Errorf("%w: expected between %d and %d, got %d", ErrCountMismatch, min, max, count)
+			}
+			out <- Try[A]{Error: err}
+		}
+	}()
+
+	return out
+}
+
+// Scan is similar to a fold, but instead of returning the final result, it emits the running accumulator
+// after each item. The accumulator starts with the seed value and is updated using the function f.
+// This enables running totals, cumulative statistics and stateful enrichment of items.
+// If f returns an error, it is sent to the output stream and the accumulator remains unchanged.
+//
+// This is a non-blocking ordered function that processes items sequentially.
+//
+// See the package documentation for more information on non-blocking ordered functions and error handling.
+func Scan[A, B any](in <-chan Try[A], seed B, f func(acc B, a A) (B, error)) <-chan Try[B] {
+	acc := seed
+
+	return core.FilterMap(in, 1, func(a Try[A]) (Try[B], bool) {
+		if a.Error != nil {
+			return Try[B]{Error: a.Error}, true
+		}
+
+		next, err := f(acc, a.Value)
+		if err != nil {
+			return Try[B]{Error: err}, true
+		}
+
+		acc = next
+		return Try[B]{Value: acc}, true
+	})
+}
+
+// Pairwise emits each item of the input stream paired with its predecessor, as Pair{First: previous, Second: current}.
+// The first item has no predecessor, so it's not emitted on its own. This means that a stream of n values
+// produces n-1 pairs. Errors are forwarded to the output stream as is and don't break the chain of values.
+//
+// This is a non-blocking ordered function that processes items sequentially.
+//
+// See the package documentation for more information on non-blocking ordered functions and error handling.
+func Pairwise[A any](in <-chan Try[A]) <-chan Try[Pair[A, A]] {
+	return Delta(in, func(prev, cur A) (Pair[A, A], error) {
+		return Pair[A, A]{First: prev, Second: cur}, nil
+	})
+}
+
+// Delta transforms each item of the input stream using the function f, which receives both the previous and the current item.
+// This is useful for computing differences between consecutive items, such as speed from a stream of coordinates
+// or changes between consecutive snapshots. The first item has no predecessor, so f is not called for it.
+// Errors are forwarded to the output stream as is and don't break the chain of values.
+// If f returns an error, it's sent to the output stream, and the current item still becomes the previous one for the next call.
+//
+// This is a non-blocking ordered function that processes items sequentially.
+//
+// See the package documentation for more information on non-blocking ordered functions and error handling.
+func Delta[A, B any](in <-chan Try[A], f func(prev, cur A) (B, error)) <-chan Try[B] {
+	var prev A
+	hasPrev := false
+
+	return core.FilterMap(in, 1, func(a Try[A]) (Try[B], bool) {
+		if a.Error != nil {
+			return Try[B]{Error: a.Error}, true
+		}
+
+		if !hasPrev {
+			prev, hasPrev = a.Value, true
+			return Try[B]{}, false
+		}
+
+		b, err := f(prev, a.Value)
+		prev = a.Value
+		if err != nil {
+			return Try[B]{Error: err}, true
+		}
+
+		return Try[B]{Value: b}, true
 	})
 }
@@ -1,9 +1,12 @@
 package rill
 
 import (
+	"errors"
 	"fmt"
 	"sort"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/destel/rill/internal/th"
 )
@@ -83,6 +86,68 @@ func TestMap(t *testing.T) {
 	})
 }
 
+func universalMapN[A, B any](ord bool, in <-chan Try[A], n int, f func(int, A) (B, error)) <-chan Try[B] {
+	if ord {
+		return OrderedMapN(in, n, f)
+	}
+	return MapN(in, n, f)
+}
+
+func TestMapN(t *testing.T) {
+	// most logic is covered by the core package tests
+
+	th.TestBothOrderings(t, func(t *testing.T, ord bool) {
+		for _, n := range []int{1, 5} {
+			t.Run(th.Name("nil", n), func(t *testing.T) {
+				out := universalMapN(ord, nil, n, func(worker int, x int) (int, error) { return x, nil })
+				th.ExpectValue(t, out, nil)
+			})
+
+			t.Run(th.Name("correctness", n), func(t *testing.T) {
+				in := FromChan(th.FromRange(0, 20), nil)
+				in = replaceWithError(in, 15, fmt.Errorf("err15"))
+
+				workers := make([]int, n)
+
+				out := universalMapN(ord, in, n, func(worker int, x int) (string, error) {
+					workers[worker]++ // safe: each index is used by a single goroutine
+					if x == 5 {
+						return "", fmt.Errorf("err05")
+					}
+					return fmt.Sprintf("%03d", x), nil
+				})
+
+				outSlice, errSlice := toSliceAndErrors(out)
+
+				expectedSlice := make([]string, 0, 20)
+				for i := 0; i < 20; i++ {
+					if i == 5 || i == 15 {
+						continue
+					}
+					expectedSlice = append(expectedSlice, fmt.Sprintf("%03d", i))
+				}
+
+				if ord {
+					th.ExpectSorted(t, outSlice)
+					th.ExpectSorted(t, errSlice)
+				} else {
+					sort.Strings(outSlice)
+					sort.Strings(errSlice)
+				}
+
+				th.ExpectSlice(t, outSlice, expectedSlice)
+				th.ExpectSlice(t, errSlice, []string{"err05", "err15"})
+
+				total := 0
+				for _, cnt := range workers {
+					total += cnt
+				}
+				th.ExpectValue(t, total, 19)
+			})
+		}
+	})
+}
+
 func universalFilter(ord bool, in <-chan Try[int], n int, f func(int) (bool, error)) <-chan Try[int] {
 	if ord {
 		return OrderedFilter(in, n, f)
@@ -161,6 +226,76 @@ func TestFilter(t *testing.T) {
 	})
 }
 
+func universalTap[A any](ord bool, in <-chan Try[A], n int, f func(A) error) <-chan Try[A] {
+	if ord {
+		return OrderedTap(in, n, f)
+	}
+	return Tap(in, n, f)
+}
+
+func TestTap(t *testing.T) {
+	th.TestBothOrderings(t, func(t *testing.T, ord bool) {
+		for _, n := range []int{1, 5} {
+			t.Run(th.Name("nil", n), func(t *testing.T) {
+				out := universalTap(ord, nil, n, func(x int) error { return nil })
+				th.ExpectValue(t, out, nil)
+			})
+
+			t.Run(th.Name("correctness", n), func(t *testing.T) {
+				in := FromChan(th.FromRange(0, 20), nil)
+				in = replaceWithError(in, 15, fmt.Errorf("err15"))
+
+				var seen atomic.Int64
+				out := universalTap(ord, in, n, func(x int) error {
+					seen.Add(1)
+					if x == 5 {
+						return fmt.Errorf("err05")
+					}
+					return nil
+				})
+
+				outSlice, errSlice := toSliceAndErrors(out)
+
+				expectedSlice := make([]int, 0, 20)
+				for i := 0; i < 20; i++ {
+					if i == 5 || i == 15 {
+						continue
+					}
+					expectedSlice = append(expectedSlice, i)
+				}
+
+				th.Sort(outSlice)
+				th.Sort(errSlice)
+
+				th.ExpectSlice(t, outSlice, expectedSlice)
+				th.ExpectSlice(t, errSlice, []string{"err05", "err15"})
+				th.ExpectValue(t, seen.Load(), int64(19))
+			})
+
+			t.Run(th.Name("ordering", n), func(t *testing.T) {
+				in := FromChan(th.FromRange(0, 20000), nil)
+
+				out := universalTap(ord, in, n, func(x int) error {
+					if x%2 == 0 {
+						return fmt.Errorf("err%06d", x)
+					}
+					return nil
+				})
+
+				outSlice, errSlice := toSliceAndErrors(out)
+
+				if ord || n == 1 {
+					th.ExpectSorted(t, outSlice)
+					th.ExpectSorted(t, errSlice)
+				} else {
+					th.ExpectUnsorted(t, outSlice)
+					th.ExpectUnsorted(t, errSlice)
+				}
+			})
+		}
+	})
+}
+
 func universalFilterMap[A, B any](ord bool, in <-chan Try[A], n int, f func(A) (B, bool, error)) <-chan Try[B] {
 	if ord {
 		return OrderedFilterMap(in, n, f)
@@ -240,6 +375,58 @@ func TestFilterMap(t *testing.T) {
 	})
 }
 
+func universalFlatMapSlice[A, B any](ord bool, in <-chan Try[A], n int, f func(A) ([]B, error)) <-chan Try[B] {
+	if ord {
+		return OrderedFlatMapSlice(in, n, f)
+	}
+	return FlatMapSlice(in, n, f)
+}
+
+func TestFlatMapSlice(t *testing.T) {
+	th.TestBothOrderings(t, func(t *testing.T, ord bool) {
+		for _, n := range []int{1, 5} {
+			t.Run(th.Name("nil", n), func(t *testing.T) {
+				out := universalFlatMapSlice(ord, nil, n, func(x int) ([]string, error) { return nil, nil })
+				th.ExpectValue(t, out, nil)
+			})
+
+			t.Run(th.Name("correctness", n), func(t *testing.T) {
+				in := FromChan(th.FromRange(0, 20), nil)
+				in = replaceWithError(in, 5, fmt.Errorf("err05"))
+
+				out := universalFlatMapSlice(ord, in, n, func(x int) ([]string, error) {
+					switch {
+					case x == 15:
+						return nil, fmt.Errorf("err15")
+					case x%3 == 0:
+						return nil, nil
+					}
+					return []string{fmt.Sprintf("%03dA", x), fmt.Sprintf("%03dB", x)}, nil
+				})
+
+				outSlice, errSlice := toSliceAndErrors(out)
+				if ord {
+					th.ExpectSorted(t, outSlice)
+				}
+
+				expectedSlice := make([]string, 0, 20*2)
+				for i := 0; i < 20; i++ {
+					if i == 5 || i == 15 || i%3 == 0 {
+						continue
+					}
+					expectedSlice = append(expectedSlice, fmt.Sprintf("%03dA", i), fmt.Sprintf("%03dB", i))
+				}
+
+				sort.Strings(outSlice)
+				sort.Strings(errSlice)
+
+				th.ExpectSlice(t, outSlice, expectedSlice)
+				th.ExpectSlice(t, errSlice, []string{"err05", "err15"})
+			})
+		}
+	})
+}
+
 func universalFlatMap[A, B any](ord bool, in <-chan Try[A], n int, f func(A) <-chan Try[B]) <-chan Try[B] {
 	if ord {
 		return OrderedFlatMap(in, n, f)
@@ -316,6 +503,83 @@ func TestFlatMap(t *testing.T) {
 	})
 }
 
+func universalFlatMapPrefetch[A, B any](ord bool, in <-chan Try[A], n int, prefetch int, f func(A) <-chan Try[B]) <-chan Try[B] {
+	if ord {
+		return OrderedFlatMapPrefetch(in, n, prefetch, f)
+	}
+	return FlatMapPrefetch(in, n, prefetch, f)
+}
+
+func TestFlatMapPrefetch(t *testing.T) {
+	// most logic is covered by the FlatMap tests
+
+	th.TestBothOrderings(t, func(t *testing.T, ord bool) {
+		t.Run("nil", func(t *testing.T) {
+			out := universalFlatMapPrefetch(ord, nil, 1, 10, func(x int) <-chan Try[string] { return nil })
+			th.ExpectValue(t, out, nil)
+		})
+
+		for _, prefetch := range []int{0, 1, 3} {
+			t.Run(th.Name("correctness", prefetch), func(t *testing.T) {
+				in := FromChan(th.FromRange(0, 20), nil)
+				in = replaceWithError(in, 5, fmt.Errorf("err05"))
+
+				out := universalFlatMapPrefetch(ord, in, 3, prefetch, func(x int) <-chan Try[string] {
+					return FromSlice([]string{
+						fmt.Sprintf("%03dA", x),
+						fmt.Sprintf("%03dB", x),
+					}, nil)
+				})
+
+				outSlice, errSlice := toSliceAndErrors(out)
+
+				expectedSlice := make([]string, 0, 20*2)
+				for i := 0; i < 20; i++ {
+					if i == 5 {
+						continue
+					}
+					expectedSlice = append(expectedSlice, fmt.Sprintf("%03dA", i), fmt.Sprintf("%03dB", i))
+				}
+
+				if ord {
+					th.ExpectSorted(t, outSlice)
+				} else {
+					sort.Strings(outSlice)
+				}
+
+				th.ExpectSlice(t, outSlice, expectedSlice)
+				th.ExpectSlice(t, errSlice, []string{"err05"})
+			})
+		}
+
+		t.Run("read ahead", func(t *testing.T) {
+			var sent atomic.Int64
+
+			in := FromSlice([]int{1}, nil)
+			out := universalFlatMapPrefetch(ord, in, 1, 5, func(x int) <-chan Try[int] {
+				sub := make(chan Try[int])
+				go func() {
+					defer close(sub)
+					for i := 0; i < 100; i++ {
+						sub <- Try[int]{Value: i}
+						sent.Add(1)
+					}
+				}()
+				return sub
+			})
+
+			// nobody reads the output, but the sub-stream is still read ahead, up to the limit
+			time.Sleep(500 * time.Millisecond)
+			if cnt := sent.Load(); cnt < 5 || cnt > 7 {
+				t.Errorf("expected about 5 items to be read ahead, got %d", cnt)
+			}
+
+			outSlice, _ := toSliceAndErrors(out)
+			th.ExpectValue(t, len(outSlice), 100)
+		})
+	})
+}
+
 func universalCatch(ord bool, in <-chan Try[int], n int, f func(error) error) <-chan Try[int] {
 	if ord {
 		return OrderedCatch(in, n, f)
@@ -392,3 +656,321 @@ func TestCatch(t *testing.T) {
 		}
 	})
 }
+
+func TestConflate(t *testing.T) {
+	// most logic is covered by the core package tests
+
+	t.Run("correctness", func(t *testing.T) {
+		in := make(chan Try[int])
+		out := Conflate(in, func(x int) int { return x % 10 })
+
+		th.Send(in,
+			Try[int]{Value: 1},
+			Try[int]{Error: fmt.Errorf("err1")},
+			Try[int]{Value: 11},
+			Try[int]{Error: fmt.Errorf("err2")},
+			Try[int]{Value: 2},
+		)
+		close(in)
+
+		outSlice, errs := toSliceAndErrors(out)
+		th.ExpectSlice(t, outSlice, []int{11, 2})
+		th.ExpectSlice(t, errs, []string{"err1", "err2"})
+	})
+}
+
+func TestMapByKey(t *testing.T) {
+	for _, n := range []int{1, 5} {
+		t.Run(th.Name("nil", n), func(t *testing.T) {
+			out := MapByKey(nil, n, func(x int) int { return x }, func(x int) (int, error) { return x, nil })
+			th.ExpectValue(t, out, nil)
+		})
+
+		t.Run(th.Name("correctness", n), func(t *testing.T) {
+			in := FromChan(th.FromRange(0, 100), nil)
+			in = replaceWithError(in, 15, fmt.Errorf("err15"))
+
+			out := MapByKey(in, n, func(x int) int { return x % 7 }, func(x int) (int, error) {
+				if x == 5 {
+					return 0, fmt.Errorf("err05")
+				}
+				return 2 * x, nil
+			})
+
+			outSlice, errs := toSliceAndErrors(out)
+
+			expectedSlice := make([]int, 0, 100)
+			for i := 0; i < 100; i++ {
+				if i == 5 || i == 15 {
+					continue
+				}
+				expectedSlice = append(expectedSlice, 2*i)
+			}
+
+			th.Sort(outSlice)
+			th.Sort(errs)
+			th.ExpectSlice(t, outSlice, expectedSlice)
+			th.ExpectSlice(t, errs, []string{"err05", "err15"})
+		})
+
+		t.Run(th.Name("ordering within key", n), func(t *testing.T) {
+			in := FromChan(th.FromRange(0, 10000), nil)
+
+			out := MapByKey(in, n, func(x int) int { return x % 13 }, func(x int) (int, error) {
+				return x, nil
+			})
+
+			perKey := make(map[int][]int)
+			var all []int
+			for x := range out {
+				perKey[x.Value%13] = append(perKey[x.Value%13], x.Value)
+				all = append(all, x.Value)
+			}
+
+			th.ExpectValue(t, len(perKey), 13)
+			for _, items := range perKey {
+				th.ExpectSorted(t, items)
+			}
+
+			if n == 1 {
+				th.ExpectSorted(t, all)
+			} else {
+				th.ExpectUnsorted(t, all)
+			}
+		})
+	}
+}
+
+func TestDistinctWithin(t *testing.T) {
+	// most logic is covered by the core package tests
+
+	t.Run("correctness", func(t *testing.T) {
+		in := FromSlice([]int{1, 2, 11, 3, 12, 4}, nil)
+		in = replaceWithError(in, 2, fmt.Errorf("err2"))
+		in = replaceWithError(in, 12, fmt.Errorf("err12"))
+
+		out := DistinctWithin(in, func(x int) int { return x % 10 }, 10, time.Minute)
+
+		outSlice, errs := toSliceAndErrors(out)
+		th.ExpectSlice(t, outSlice, []int{1, 3, 4})
+		th.ExpectSlice(t, errs, []string{"err2", "err12"})
+	})
+}
+
+func TestTake(t *testing.T) {
+	t.Run("nil", func(t *testing.T) {
+		th.ExpectValue(t, Take[int](nil, 5), nil)
+	})
+
+	t.Run("zero count", func(t *testing.T) {
+		in := make(chan Try[int])
+		out := Take(in, 0)
+
+		// the output is closed without waiting for input items
+		th.ExpectNotHang(t, 10*time.Second, func() {
+			th.ExpectValue(t, len(th.ToSlice(out)), 0)
+		})
+
+		// the input is drained
+		th.ExpectNotHang(t, 10*time.Second, func() {
+			in <- Try[int]{Value: 1}
+			close(in)
+		})
+	})
+
+	for _, count := range []int{0, 5, 100} {
+		t.Run(th.Name("correctness", count), func(t *testing.T) {
+			in := FromChan(th.FromRange(0, 20), nil)
+			in = replaceWithError(in, 2, fmt.Errorf("err2"))
+
+			outSlice, errs := toSliceAndErrors(Take(in, count))
+
+			var expectedSlice []int
+			var expectedErrs []string
+			for i := 0; i < 20 && len(expectedSlice) < count; i++ {
+				if i == 2 {
+					expectedErrs = append(expectedErrs, "err2")
+					continue
+				}
+				expectedSlice = append(expectedSlice, i)
+			}
+
+			th.ExpectSlice(t, outSlice, expectedSlice)
+			th.ExpectSlice(t, errs, expectedErrs)
+
+			time.Sleep(1 * time.Second)
+			th.ExpectDrainedChan(t, in)
+		})
+	}
+}
+
+func TestTakeWhile(t *testing.T) {
+	for _, n := range []int{1, 5} {
+		t.Run(th.Name("nil", n), func(t *testing.T) {
+			out := TakeWhile(nil, n, func(x int) (bool, error) { return true, nil })
+			th.ExpectValue(t, out, nil)
+		})
+
+		t.Run(th.Name("correctness", n), func(t *testing.T) {
+			in := FromChan(th.FromRange(0, 1000), nil)
+			in = replaceWithError(in, 5, fmt.Errorf("err05"))
+
+			out := TakeWhile(in, n, func(x int) (bool, error) {
+				if x == 7 {
+					return false, fmt.Errorf("err07")
+				}
+				return x < 10, nil
+			})
+
+			outSlice, errs := toSliceAndErrors(out)
+			th.ExpectSlice(t, outSlice, []int{0, 1, 2, 3, 4, 6, 8, 9})
+			th.ExpectSlice(t, errs, []string{"err05", "err07"})
+
+			time.Sleep(1 * time.Second)
+			th.ExpectDrainedChan(t, in)
+		})
+
+		t.Run(th.Name("stops calling f", n), func(t *testing.T) {
+			in := FromChan(th.FromRange(0, 100000), nil)
+
+			var calls atomic.Int64
+			out := TakeWhile(in, n, func(x int) (bool, error) {
+				calls.Add(1)
+				return x < 10, nil
+			})
+
+			outSlice, errs := toSliceAndErrors(out)
+			th.ExpectSlice(t, outSlice, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9})
+			th.ExpectValue(t, len(errs), 0)
+
+			th.ExpectNotHang(t, 10*time.Second, func() {
+				Drain(in)
+			})
+			// only the items that were in flight when the stop happened are checked past the first one that did not pass
+			th.ExpectValueLTE(t, calls.Load(), int64(11+2*n))
+		})
+	}
+}
+
+func TestScan(t *testing.T) {
+	t.Run("nil", func(t *testing.T) {
+		out := Scan(nil, 0, func(acc int, x int) (int, error) { return acc + x, nil })
+		th.ExpectValue(t, out, nil)
+	})
+
+	t.Run("correctness", func(t *testing.T) {
+		in := FromChan(th.FromRange(1, 8), nil)
+		in = replaceWithError(in, 3, fmt.Errorf("err3"))
+
+		out := Scan(in, 100, func(acc int, x int) (int, error) {
+			if x == 5 {
+				return 0, fmt.Errorf("err5")
+			}
+			return acc + x, nil
+		})
+
+		outSlice, errs := toSliceAndErrors(out)
+		th.ExpectSlice(t, outSlice, []int{101, 103, 107, 113, 120})
+		th.ExpectSlice(t, errs, []string{"err3", "err5"})
+	})
+}
+
+func TestPairwise(t *testing.T) {
+	t.Run("nil", func(t *testing.T) {
+		out := Pairwise[int](nil)
+		th.ExpectValue(t, out, nil)
+	})
+
+	t.Run("single item", func(t *testing.T) {
+		outSlice, errs := toSliceAndErrors(Pairwise(FromSlice([]int{1}, nil)))
+		th.ExpectValue(t, len(outSlice), 0)
+		th.ExpectValue(t, len(errs), 0)
+	})
+
+	t.Run("correctness", func(t *testing.T) {
+		in := FromChan(th.FromRange(1, 6), nil)
+		in = replaceWithError(in, 3, fmt.Errorf("err3"))
+
+		outSlice, errs := toSliceAndErrors(Pairwise(in))
+		th.ExpectSlice(t, outSlice, []Pair[int, int]{{1, 2}, {2, 4}, {4, 5}})
+		th.ExpectSlice(t, errs, []string{"err3"})
+	})
+}
+
+func TestDelta(t *testing.T) {
+	t.Run("nil", func(t *testing.T) {
+		out := Delta(nil, func(prev, cur int) (int, error) { return cur - prev, nil })
+		th.ExpectValue(t, out, nil)
+	})
+
+	t.Run("correctness", func(t *testing.T) {
+		in := FromSlice([]int{1, 3, 6, 10, 15, 21}, nil)
+		in = replaceWithError(in, 6, fmt.Errorf("err6"))
+
+		out := Delta(in, func(prev, cur int) (int, error) {
+			if cur == 15 {
+				return 0, fmt.Errorf("err15")
+			}
+			return cur - prev, nil
+		})
+
+		outSlice, errs := toSliceAndErrors(out)
+		th.ExpectSlice(t, outSlice, []int{2, 7, 6})
+		th.ExpectSlice(t, errs, []string{"err6", "err15"})
+	})
+}
+
+func TestExpectCount(t *testing.T) {
+	t.Run("nil", func(t *testing.T) {
+		th.ExpectValue(t, ExpectCount[int](nil, 10), nil)
+	})
+
+	t.Run("exact", func(t *testing.T) {
+		in := FromChan(th.FromRange(0, 10), nil)
+		in = replaceWithError(in, 5, fmt.Errorf("err5"))
+
+		outSlice, errs := toSliceAndErrors(ExpectCount(in, 9))
+		th.ExpectValue(t, len(outSlice), 9)
+		th.ExpectSlice(t, errs, []string{"err5"})
+	})
+
+	t.Run("too few", func(t *testing.T) {
+		out := ExpectCount(FromChan(th.FromRange(0, 10), nil), 11)
+
+		outSlice, errs := toSliceAndErrors(out)
+		th.ExpectValue(t, len(outSlice), 10)
+		th.ExpectSlice(t, errs, []string{"rill: unexpected number of items: expected 11, got 10"})
+	})
+
+	t.Run("too many", func(t *testing.T) {
+		out := ExpectCount(FromChan(th.FromRange(0, 10), nil), 9)
+
+		err := Err(out)
+		th.ExpectError(t, err, "rill: unexpected number of items: expected 9, got 10")
+		if !errors.Is(err, ErrCountMismatch) {
+			t.Errorf("expected ErrCountMismatch")
+		}
+	})
+}
+
+func TestExpectCountBetween(t *testing.T) {
+	for _, tc := range []struct {
+		min, max int
+		err      string
+	}{
+		{5, 10, ""},
+		{10, 20, ""},
+		{0, 5, "rill: unexpected number of items: expected between 0 and 5, got 10"},
+		{11, 20, "rill: unexpected number of items: expected between 11 and 20, got 10"},
+	} {
+		t.Run(th.Name(tc.min, tc.max), func(t *testing.T) {
+			outSlice, errs := toSliceAndErrors(ExpectCountBetween(FromChan(th.FromRange(0, 10), nil), tc.min, tc.max))
+			th.ExpectValue(t, len(outSlice), 10)
+			if tc.err == "" {
+				th.ExpectValue(t, len(errs), 0)
+			} else {
+				th.ExpectSlice(t, errs, []string{tc.err})
+			}
+		})
+	}
+}
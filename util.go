@@ -1,6 +1,12 @@
 package rill
 
-import "github.com/destel/rill/internal/core"
+import (
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"github.com/destel/rill/internal/core"
+)
 
 // Drain consumes and discards all items from an input channel, blocking until the channel is closed.
 func Drain[A any](in <-chan A) {
@@ -12,10 +18,158 @@ func DrainNB[A any](in <-chan A) {
 	core.DrainNB(in)
 }
 
+// OnDrain returns a channel of exactly the same items as in, and registers a function f that receives
+// every item discarded during draining of the returned channel. This includes draining done by [Drain] and [DrainNB], as well as
+// background draining initiated by blocking functions such as [ForEach], [ToSlice] or [First] in case of an early termination.
+//
+// By default, such items are dropped silently. OnDrain allows to handle them instead,
+// for example to requeue them into a durable queue for later processing:
+//
+//	results = rill.OnDrain(results, func(x rill.Try[Job]) {
+//		if x.Error == nil {
+//			requeue(x.Value)
+//		}
+//	})
+//
+//	err := rill.ForEach(results, 1, func(job Job) error {
+//		// ...
+//	})
+//
+// Function f is called sequentially, except for the case of unordered blocking functions with n > 1, where it's called
+// concurrently from n goroutines. [Reduce] does not pass discarded items to f, since they may be partially reduced.
+// The registration is kept when the returned channel is wrapped with [OnDrain], [OnDrainStart], [Guard] or [WithLauncher].
+func OnDrain[A any](in <-chan A, f func(A)) <-chan A {
+	return core.OnDrain(in, f)
+}
+
+// OnDrainStart returns a channel of exactly the same items as in, and registers a function f that is called once,
+// when draining of the returned channel begins. This includes draining done by [Drain] and [DrainNB], as well as
+// background draining initiated by blocking functions in case of an early termination.
+//
+// Draining consumes and discards items as fast as possible, but the source still has to produce them.
+// OnDrainStart allows to signal the source to stop producing instead, which matters for sources that do expensive work per item.
+// Built-in sources, such as [FromSlice] and [Range], stop automatically when their output channels are drained.
+// For custom sources the same can be achieved like this:
+//
+//	stop := make(chan struct{})
+//	pages := rill.Generate(func(send func(Page), sendErr func(error)) {
+//		for i := 0; ; i++ {
+//			select {
+//			case <-stop:
+//				return
+//			default:
+//				send(fetchPage(i))
+//			}
+//		}
+//	})
+//	pages = rill.OnDrainStart(pages, func() { close(stop) })
+//
+// Note that f is called only when the returned channel itself is drained, or a channel returned by [OnDrain], [OnDrainStart],
+// [Guard] or [WithLauncher] applied to it. Other functions produce new streams, so OnDrainStart should be applied
+// to the stream that is passed to the blocking function.
+func OnDrainStart[A any](in <-chan A, f func()) <-chan A {
+	return core.OnDrainStart(in, f)
+}
+
+// Guard returns a channel of exactly the same items as in, that panics with a clear message when it's passed to a second consumer.
+// This catches the classic bug of consuming the same stream twice right at the point of mistake,
+// instead of letting it manifest later as a hang or as items silently split between two consumers.
+//
+//	users := rill.Guard(getUsers())
+//	names := rill.Map(users, 5, getName)
+//	ages := rill.Map(users, 5, getAge) // panics
+//
+// The check is done by all blocking functions of this package, such as [ForEach], [ToSlice], [Count] or [Reduce],
+// and by all non-blocking functions that process items using n goroutines, such as [Map] or [Filter].
+// Non-blocking functions that process items sequentially, such as [Batch] or [Scan], don't do the check.
+// Draining a guarded stream with [Drain] or [DrainNB] is always allowed.
+// Once the stream is closed, it's no longer tracked, since consuming a closed stream can't cause a hang.
+func Guard[A any](in <-chan A) <-chan A {
+	return core.Guard(in)
+}
+
+// WithLauncher returns a channel of exactly the same items as in, and makes the stage consuming this channel
+// start its worker goroutines using the launch function, instead of the go statement.
+// This allows to run workers inside an existing worker framework, install panic handlers or
+// call runtime.LockOSThread for stages that use cgo:
+//
+//	ids = rill.WithLauncher(ids, func(f func()) {
+//		go func() {
+//			runtime.LockOSThread()
+//			defer runtime.UnlockOSThread()
+//			f()
+//		}()
+//	})
+//
+//	users := rill.Map(ids, 5, fetchUserViaCgo)
+//
+// The launch function must run f in a separate goroutine, and must not block until f returns.
+// It's called once per worker goroutine, so a stage with concurrency n calls it n times. Stages with dynamic concurrency,
+// such as [MapL], start a goroutine per item, and call it once per item. Only the stage that
+// directly consumes the returned channel is affected; each stage of a pipeline needs its own WithLauncher call.
+//
+// Launchers are used by all functions that call user functions using n goroutines, including
+// [Map], [Filter], [FlatMap], [MapL], [MapByKey], [MapControlled], [ForEach], [Reduce], [MapReduce] and their variants.
+// This also covers the case of n = 1, where f runs in a single goroutine started by launch.
+// Functions that process items sequentially in an internal goroutine, such as [Batch] or [Scan], don't use launchers.
+func WithLauncher[A any](in <-chan A, launch func(f func())) <-chan A {
+	return core.WithLauncher(in, launch)
+}
+
+// YieldEvery wraps the function f, making it call runtime.Gosched after every k calls.
+// Long CPU-bound stages with high concurrency can occupy all available threads, and delay other goroutines of the host process,
+// such as HTTP handlers or health checks. YieldEvery makes such stages periodically give way to other goroutines:
+//
+//	hashes := rill.Map(blocks, runtime.NumCPU(), rill.YieldEvery(100, func(b Block) (Hash, error) {
+//		return computeHash(b), nil
+//	}))
+//
+// Calls are counted across all goroutines that share the wrapped function, so with n workers each of them yields
+// roughly every n*k calls on average. If k is not positive, f is returned as is.
+//
+// The wrapped function can be used with [Map], [Filter], [FilterMap] and all other functions with a compatible signature.
+// For functions that return only an error, such as the ones used by [ForEach], see [YieldEveryErr].
+func YieldEvery[A, B any](k int, f func(A) (B, error)) func(A) (B, error) {
+	if k <= 0 {
+		return f
+	}
+
+	yield := yielder(k)
+	return func(a A) (B, error) {
+		defer yield()
+		return f(a)
+	}
+}
+
+// YieldEveryErr is similar to [YieldEvery], but wraps functions that return only an error, such as the ones used by [ForEach].
+func YieldEveryErr[A any](k int, f func(A) error) func(A) error {
+	if k <= 0 {
+		return f
+	}
+
+	yield := yielder(k)
+	return func(a A) error {
+		defer yield()
+		return f(a)
+	}
+}
+
+// yielder returns a function that calls runtime.Gosched on every k-th call.
+func yielder(k int) func() {
+	var calls atomic.Int64
+	return func() {
+		if calls.Add(1)%int64(k) == 0 {
+			runtime.Gosched()
+		}
+	}
+}
+
 // Buffer takes a channel of items and returns a buffered channel of exact same items in the same order.
 // This can be useful for preventing write operations on the input channel from blocking, especially if subsequent stages
 // in the processing pipeline are slow.
 // Buffering allows up to size items to be held in memory before back pressure is applied to the upstream producer.
+// Setting the size to -1 makes the buffer unbounded, so the upstream producer is never blocked.
+// Use this with care, since a slow consumer can cause unlimited memory growth.
 //
 // Typical usage of Buffer might look like this:
 //
@@ -26,3 +180,63 @@ func DrainNB[A any](in <-chan A) {
 func Buffer[A any](in <-chan A, size int) <-chan A {
 	return core.Buffer(in, size)
 }
+
+// OverflowPolicy defines the behavior of [BufferWithPolicy] when the buffer is full.
+type OverflowPolicy int
+
+const (
+	// Block applies back pressure to the upstream producer until there is room in the buffer.
+	Block OverflowPolicy = iota
+	// DropOldest discards the oldest buffered item to make room for the incoming one.
+	DropOldest
+	// DropNewest discards the incoming item.
+	DropNewest
+)
+
+// BufferWithPolicy is like [Buffer], but allows to choose what happens when the buffer is full, using the policy argument.
+// With [DropOldest] and [DropNewest] policies the upstream producer is never blocked, and the excess items are discarded instead.
+// This is useful for load-shedding pipelines, such as telemetry or metrics, where dropping items is preferable to back pressure.
+// With these policies, sizes less than 1 are treated as 1.
+//
+// The dropped function returns the number of items discarded so far. It is safe to call it concurrently.
+//
+//	metrics, dropped := rill.BufferWithPolicy(metrics, 1000, rill.DropOldest)
+//	// ...
+//	log.Println("dropped metrics:", dropped())
+func BufferWithPolicy[A any](in <-chan A, size int, policy OverflowPolicy) (out <-chan A, dropped func() int64) {
+	var cnt atomic.Int64
+	dropped = cnt.Load
+
+	switch policy {
+	case DropOldest, DropNewest:
+		out = core.DroppingBuffer(in, size, policy == DropOldest, func(A) {
+			cnt.Add(1)
+		})
+	default:
+		out = core.Buffer(in, size)
+	}
+
+	return out, dropped
+}
+
+// Delay postpones the delivery of each item from an input channel by the given duration, maintaining the order.
+// Delay does not apply back pressure to the upstream producer: items are buffered in memory until they are due.
+//
+//	// Delay each item by 1 second
+//	delayed := rill.Delay(stream, 1*time.Second)
+func Delay[A any](in <-chan A, d time.Duration) <-chan A {
+	return core.Delay(in, d)
+}
+
+// Spread smooths out bursts of items by spacing them evenly over a time window, maintaining the order.
+// This can be useful for pacing bursty producers before hitting rate-limited downstream services.
+//
+// Items are grouped by their arrival time. A group starts with the first item and collects all items that
+// arrive within the window. Each group is then emitted over the course of the following window,
+// with equal intervals between items. So each item is delayed by at most two windows.
+//
+//	// Emit each burst of requests over the next 10 seconds
+//	requests = rill.Spread(requests, 10*time.Second)
+func Spread[A any](in <-chan A, window time.Duration) <-chan A {
+	return core.Spread(in, window)
+}
@@ -1,7 +1,10 @@
 package rill
 
 import (
+	"fmt"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/destel/rill/internal/th"
 )
@@ -16,3 +19,282 @@ func TestBuffer(t *testing.T) {
 	// real tests are in another package
 	Buffer[int](th.FromRange(0, 10), 5)
 }
+
+func TestDelay(t *testing.T) {
+	// real tests are in another package
+	Drain(Delay[int](th.FromRange(0, 10), 10*time.Millisecond))
+}
+
+func TestSpread(t *testing.T) {
+	// real tests are in another package
+	Drain(Spread[int](th.FromRange(0, 10), 10*time.Millisecond))
+}
+
+func TestOnDrain(t *testing.T) {
+	// most logic is covered by the core package tests
+
+	t.Run("early termination", func(t *testing.T) {
+		var drained atomic.Int64
+		in := FromChan(th.FromRange(0, 1000), nil)
+		in = replaceWithError(in, 100, fmt.Errorf("err100"))
+		in = OnDrain(in, func(x Try[int]) {
+			drained.Add(1)
+		})
+
+		var processed atomic.Int64
+		err := ForEach(in, 5, func(x int) error {
+			processed.Add(1)
+			return nil
+		})
+		th.ExpectError(t, err, "err100")
+
+		// wait until it drained
+		time.Sleep(1 * time.Second)
+		th.ExpectDrainedChan(t, in)
+
+		// each item was either processed, drained or was an error
+		th.ExpectValue(t, processed.Load()+drained.Load()+1, 1000)
+	})
+}
+
+func TestBufferWithPolicy(t *testing.T) {
+	// most logic is covered by the core package tests
+
+	for _, policy := range []OverflowPolicy{Block, DropOldest, DropNewest} {
+		t.Run(th.Name("correctness", policy), func(t *testing.T) {
+			in := make(chan int)
+			out, dropped := BufferWithPolicy(in, 5, policy)
+
+			go func() {
+				defer close(in)
+				for i := 0; i < 10; i++ {
+					in <- i
+				}
+				// wait until the last item is accepted
+				time.Sleep(100 * time.Millisecond)
+			}()
+
+			if policy == Block {
+				th.ExpectSlice(t, th.ToSlice(out), []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9})
+				th.ExpectValue(t, dropped(), 0)
+				return
+			}
+
+			time.Sleep(200 * time.Millisecond)
+			outSlice := th.ToSlice(out)
+			th.ExpectValue(t, len(outSlice), 5)
+			th.ExpectValue(t, dropped(), 5)
+		})
+	}
+}
+
+func TestOnDrainStart(t *testing.T) {
+	// most logic is covered by the core package tests
+
+	t.Run("intermediate stage", func(t *testing.T) {
+		var calls atomic.Int64
+		in := OnDrainStart(FromChan(th.FromRange(0, 100), nil), func() {
+			calls.Add(1)
+		})
+		in = replaceWithError(in, 10, fmt.Errorf("err10"))
+
+		_, err := ToSlice(in)
+		th.ExpectError(t, err, "err10")
+
+		// wait until the intermediate stage has consumed the whole wrapped channel
+		th.ExpectNotHang(t, 10*time.Second, func() {
+			for range in {
+			}
+		})
+		th.ExpectValue(t, calls.Load(), int64(0)) // the wrapped channel itself isn't drained
+	})
+
+	for _, n := range []int{1, 5} {
+		t.Run(th.Name("early termination", n), func(t *testing.T) {
+			var calls atomic.Int64
+			called := make(chan struct{})
+			in := OnDrainStart(FromChan(th.FromRange(0, 1000), nil), func() {
+				if calls.Add(1) == 1 {
+					close(called)
+				}
+			})
+
+			err := ForEach(in, n, func(x int) error {
+				if x == 10 {
+					return fmt.Errorf("err10")
+				}
+				return nil
+			})
+			th.ExpectError(t, err, "err10")
+
+			th.ExpectNotHang(t, 10*time.Second, func() {
+				<-called
+			})
+			th.ExpectValue(t, calls.Load(), int64(1))
+		})
+	}
+
+	t.Run("drained directly", func(t *testing.T) {
+		var calls atomic.Int64
+		called := make(chan struct{})
+		in := OnDrainStart(FromChan(th.FromRange(0, 100), nil), func() {
+			if calls.Add(1) == 1 {
+				close(called)
+			}
+		})
+
+		_, _, err := First(in)
+		th.ExpectNoError(t, err)
+
+		th.ExpectNotHang(t, 10*time.Second, func() {
+			<-called
+		})
+		th.ExpectValue(t, calls.Load(), int64(1))
+	})
+}
+
+func TestGuard(t *testing.T) {
+	// most logic is covered by the core package tests
+
+	expectPanic := func(t *testing.T, f func()) {
+		t.Helper()
+		defer func() {
+			if recover() == nil {
+				t.Errorf("expected panic")
+			}
+		}()
+		f()
+	}
+
+	t.Run("stage and consumer", func(t *testing.T) {
+		in := Guard(FromChan(th.FromRange(0, 10), nil))
+		out := Map(in, 3, func(x int) (int, error) { return x, nil })
+
+		expectPanic(t, func() {
+			_, _ = ToSlice(in)
+		})
+
+		outSlice, err := ToSlice(out)
+		th.ExpectNoError(t, err)
+		th.ExpectValue(t, len(outSlice), 10)
+	})
+
+	t.Run("two consumers", func(t *testing.T) {
+		in := Guard(FromChan(th.FromRange(0, 10), nil))
+
+		values, errs := ToChans(in)
+
+		expectPanic(t, func() {
+			_ = Err(in)
+		})
+
+		expectPanic(t, func() {
+			_ = ForEach(in, 1, func(x int) error { return nil })
+		})
+
+		go Drain(errs)
+		th.ExpectValue(t, len(th.ToSlice(values)), 10)
+	})
+
+	less := func(a, b int) bool { return a < b }
+	sum := func(a, b int) (int, error) { return a + b, nil }
+	id := func(x int) int { return x }
+
+	consumers := map[string]func(in <-chan Try[int]){
+		"Count":          func(in <-chan Try[int]) { _, _ = Count(in) },
+		"Sum":            func(in <-chan Try[int]) { _, _ = Sum(in) },
+		"Min":            func(in <-chan Try[int]) { _, _, _ = Min(in, less) },
+		"Max":            func(in <-chan Try[int]) { _, _, _ = Max(in, less) },
+		"Fold":           func(in <-chan Try[int]) { _, _ = Fold(in, 0, sum) },
+		"TopN":           func(in <-chan Try[int]) { _, _ = TopN(in, 3, less) },
+		"Reduce":         func(in <-chan Try[int]) { _, _, _ = Reduce(in, 2, sum) },
+		"Materialize":    func(in <-chan Try[int]) { _, _ = Materialize(in) },
+		"NewSideInput":   func(in <-chan Try[int]) { NewSideInput(in) },
+		"Stats":          func(in <-chan Try[int]) { _, _ = Stats(in) },
+		"CollectInto":    func(in <-chan Try[int]) { _, _ = CollectInto(in, make([]int, 10)) },
+		"CollectIntoMap": func(in <-chan Try[int]) { _, _ = CollectIntoMap(in, map[int]int{}, id) },
+		"ToMap":          func(in <-chan Try[int]) { _, _ = ToMap(in, id, id, KeepLast) },
+		"Aggregate":      func(in <-chan Try[int]) { _ = Aggregate(in) },
+		"OrderedFind": func(in <-chan Try[int]) {
+			_, _, _ = OrderedFind(in, 2, func(x int) (bool, error) { return false, nil })
+		},
+		"MapReduce": func(in <-chan Try[int]) {
+			_, _ = MapReduce(in, 2, func(x int) (int, int, error) { return x, x, nil }, 2, sum)
+		},
+	}
+
+	for name, consume := range consumers {
+		t.Run(th.Name("consumer", name), func(t *testing.T) {
+			in := Guard(FromChan(th.FromRange(0, 10), nil))
+
+			values, errs := ToChans(in)
+
+			expectPanic(t, func() {
+				consume(in)
+			})
+
+			go Drain(errs)
+			th.ExpectValue(t, len(th.ToSlice(values)), 10)
+		})
+	}
+}
+
+func TestWithLauncher(t *testing.T) {
+	// most logic is covered by the core package tests
+
+	t.Run("panic handler", func(t *testing.T) {
+		var recovered atomic.Int64
+
+		in := WithLauncher(FromChan(th.FromRange(0, 10), nil), func(f func()) {
+			go func() {
+				defer func() {
+					if r := recover(); r != nil {
+						recovered.Add(1)
+					}
+				}()
+				f()
+			}()
+		})
+
+		var processed atomic.Int64
+		err := ForEach(in, 2, func(x int) error {
+			if processed.Add(1) == 5 {
+				panic("boom")
+			}
+			return nil
+		})
+
+		th.ExpectNoError(t, err)
+		th.ExpectValue(t, recovered.Load(), int64(1))
+	})
+}
+
+func TestYieldEvery(t *testing.T) {
+	for _, k := range []int{0, 1, 7} {
+		t.Run(th.Name("map", k), func(t *testing.T) {
+			in := FromChan(th.FromRange(0, 100), nil)
+			out := OrderedMap(in, 4, YieldEvery(k, func(x int) (int, error) {
+				if x == 50 {
+					return 0, fmt.Errorf("err50")
+				}
+				return x * 2, nil
+			}))
+
+			outSlice, errs := toSliceAndErrors(out)
+			th.ExpectValue(t, len(outSlice), 99)
+			th.ExpectSorted(t, outSlice)
+			th.ExpectSlice(t, errs, []string{"err50"})
+		})
+
+		t.Run(th.Name("for each", k), func(t *testing.T) {
+			var sum atomic.Int64
+			err := ForEach(FromChan(th.FromRange(0, 100), nil), 4, YieldEveryErr(k, func(x int) error {
+				sum.Add(int64(x))
+				return nil
+			}))
+
+			th.ExpectNoError(t, err)
+			th.ExpectValue(t, sum.Load(), int64(4950))
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package rill
+
+import (
+	"fmt"
+	"time"
+)
+
+// StallError is reported by [Watchdog] when items of a stream are not consumed for too long.
+type StallError struct {
+	Stage   string        // the name passed to Watchdog
+	Timeout time.Duration // how long the item has been waiting to be consumed
+}
+
+func (e *StallError) Error() string {
+	return fmt.Sprintf("rill: stage %s made no progress for %v: items are waiting, but nobody consumes them", e.Stage, e.Timeout)
+}
+
+// Watchdog returns a stream of exactly the same items as in, and reports a [StallError] when an item stays unconsumed
+// for longer than timeout. This helps to find stalled pipelines and deadlocks, such as an output of [Split2] or [Tee]
+// that is never read, which otherwise manifest as a silent hang:
+//
+//	evens, odds := rill.Split2(numbers, 5, isEven)
+//	evens = rill.Watchdog(evens, "evens", 30*time.Second, func(err error) {
+//		log.Println(err) // or cancel the context, or dump goroutines
+//	})
+//
+// The onStall function is called from a separate goroutine, at most once per stalled item, while the stall is still ongoing.
+// If onStall is nil, Watchdog panics instead, which is useful in tests.
+//
+// Watchdog adds a little overhead only to the items that can't be sent immediately, so it can be left enabled in production.
+//
+// This is a non-blocking ordered function that processes items sequentially.
+//
+// See the package documentation for more information on non-blocking ordered functions and error handling.
+func Watchdog[A any](in <-chan Try[A], stage string, timeout time.Duration, onStall func(err error)) <-chan Try[A] {
+	if in == nil {
+		return nil
+	}
+
+	if onStall == nil {
+		onStall = func(err error) {
+			panic(err)
+		}
+	}
+
+	out := make(chan Try[A])
+
+	go func() {
+		defer close(out)
+
+		for a := range in {
+			// fast path: the consumer is ready
+			select {
+			case out <- a:
+				continue
+			default:
+			}
+
+			timer := time.AfterFunc(timeout, func() {
+				onStall(&StallError{Stage: stage, Timeout: timeout})
+			})
+			out <- a
+			timer.Stop()
+		}
+	}()
+
+	return out
+}
@@ -0,0 +1,69 @@
+package rill
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/destel/rill/internal/th"
+)
+
+func TestWatchdog(t *testing.T) {
+	t.Run("nil", func(t *testing.T) {
+		th.ExpectValue(t, Watchdog[int](nil, "test", time.Second, nil), nil)
+	})
+
+	t.Run("no stall", func(t *testing.T) {
+		in := FromChan(th.FromRange(0, 100), nil)
+		out := Watchdog(in, "test", 100*time.Millisecond, nil) // panics on stall
+
+		outSlice, err := ToSlice(OrderedMap(out, 1, func(x int) (int, error) {
+			if x%20 == 0 {
+				time.Sleep(20 * time.Millisecond) // slow, but not stalled
+			}
+			return x, nil
+		}))
+		th.ExpectNoError(t, err)
+		th.ExpectValue(t, len(outSlice), 100)
+	})
+
+	t.Run("unconsumed split branch", func(t *testing.T) {
+		var mu sync.Mutex
+		var stalls []error
+		onStall := func(err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			stalls = append(stalls, err)
+		}
+
+		evens, odds := OrderedSplit2(FromChan(th.FromRange(0, 10), nil), 1, func(x int) (bool, error) {
+			return x%2 == 0, nil
+		})
+		evens = Watchdog(evens, "evens", 200*time.Millisecond, onStall)
+		odds = Watchdog(odds, "odds", 200*time.Millisecond, onStall)
+
+		// consume only the evens, which gets blocked by the odds
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			Drain(evens)
+		}()
+
+		time.Sleep(500 * time.Millisecond)
+		DrainNB(odds)
+		<-done
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		th.ExpectValue(t, len(stalls), 1)
+
+		var stallErr *StallError
+		if !errors.As(stalls[0], &stallErr) {
+			t.Fatalf("expected StallError, got %v", stalls[0])
+		}
+		th.ExpectValue(t, stallErr.Stage, "odds")
+		th.ExpectValue(t, stallErr.Timeout, 200*time.Millisecond)
+	})
+}
@@ -1,5 +1,13 @@
 package rill
 
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/destel/rill/internal/core"
+)
+
 // Try is a container holding a value of type A or an error
 type Try[A any] struct {
 	Value A
@@ -32,37 +40,288 @@ func FromSlice[A any](slice []A, err error) <-chan Try[A] {
 		return out
 	}
 
-	sendAll := func(in []A, out chan Try[A]) {
-		for _, a := range in {
+	if len(slice) <= maxBufferSize {
+		out := make(chan Try[A], len(slice))
+		for _, a := range slice {
 			out <- Try[A]{Value: a}
 		}
 		close(out)
+		return out
 	}
 
-	if len(slice) <= maxBufferSize {
-		out := make(chan Try[A], len(slice))
-		sendAll(slice, out)
+	out := make(chan Try[A], maxBufferSize)
+	goSendAll(slice, out)
+	return out
+}
+
+// FromBigSlice converts a large slice into a stream. Unlike [FromSlice], it never wraps the whole slice at once:
+// items are converted into [Try] containers lazily, as the stream is consumed, and at most chunk of them are buffered.
+// This keeps memory usage constant regardless of the slice size.
+// A chunk less than 1 is treated as 1.
+func FromBigSlice[A any](slice []A, chunk int) <-chan Try[A] {
+	if chunk < 1 {
+		chunk = 1
+	}
+
+	out := make(chan Try[A], chunk)
+	goSendAll(slice, out)
+	return out
+}
+
+// FromRange returns a stream of integers in the range [start, end), without materializing a slice.
+// If start is greater than or equal to end, the stream is empty.
+// It's a shorthand for [Range] with a step of 1.
+func FromRange(start, end int) <-chan Try[int] {
+	return Range(start, end, 1)
+}
+
+// Range returns a stream of integers from start to end (exclusive), incremented by step.
+// A negative step produces a descending sequence, in which case start must be greater than end.
+// If the range is empty, the stream is empty as well. If step is zero, the stream contains a single error.
+//
+// Range is a typical starting point of pipelines that generate N indices and process them:
+//
+//	results := rill.Map(rill.Range(0, 1000, 1), 10, func(i int) (Result, error) {
+//		return fetchPage(i)
+//	})
+//
+// A context-aware version of this function, [RangeCtx], is also available.
+func Range(start, end, step int) <-chan Try[int] {
+	return RangeCtx(context.Background(), start, end, step)
+}
+
+// RangeCtx is similar to [Range], but stops early when ctx is done. In that case a single ctx.Err() error
+// is sent to the output stream, and then the stream is closed. This makes it possible to generate
+// long or practically infinite ranges, that are bounded by a deadline or cancellation.
+func RangeCtx(ctx context.Context, start, end, step int) <-chan Try[int] {
+	if step == 0 {
+		out := make(chan Try[int], 1)
+		out <- Try[int]{Error: errors.New("rill: range step must not be zero")}
+		close(out)
 		return out
 	}
 
-	out := make(chan Try[A], maxBufferSize)
-	go sendAll(slice, out)
+	out := make(chan Try[int])
+	stop, release := core.StopOnDrain((<-chan Try[int])(out))
+
+	go func() {
+		defer close(out)
+		defer release()
+
+		sendCtxErr := func() {
+			select {
+			case out <- Try[int]{Error: ctx.Err()}:
+			case <-stop:
+			}
+		}
+
+		if (step > 0 && start >= end) || (step < 0 && start <= end) {
+			return
+		}
+
+		for i := start; ; i += step {
+			// check the context first, so that cancellation wins over the next item
+			if ctx.Err() != nil {
+				sendCtxErr()
+				return
+			}
+
+			select {
+			case out <- Try[int]{Value: i}:
+			case <-stop:
+				return
+			case <-ctx.Done():
+				sendCtxErr()
+				return
+			}
+
+			// Compare the remaining distance instead of the next value, since i+step may overflow.
+			// Unsigned arithmetic gives exact distances for any pair of ints.
+			if step > 0 && uint(end)-uint(i) <= uint(step) {
+				return
+			}
+			if step < 0 && uint(i)-uint(end) <= -uint(step) {
+				return
+			}
+		}
+	}()
+
 	return out
 }
 
+// goSendAll sends the slice items to the out channel in a separate goroutine, and then closes the channel.
+// It stops early if the channel is being drained.
+func goSendAll[A any](slice []A, out chan Try[A]) {
+	stop, release := core.StopOnDrain((<-chan Try[A])(out))
+
+	go func() {
+		defer close(out)
+		defer release()
+
+		for _, a := range slice {
+			select {
+			case out <- Try[A]{Value: a}:
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
 // ToSlice converts an input stream into a slice.
 //
+// ToSlice accepts the [WithStageName] option. When labeled with a name, the returned error is wrapped into [PipelineError].
+//
 // This is a blocking ordered function that processes items sequentially.
 // See the package documentation for more information on blocking ordered functions and error handling.
-func ToSlice[A any](in <-chan Try[A]) ([]A, error) {
+func ToSlice[A any](in <-chan Try[A], opts ...StageOption) ([]A, error) {
+	report := newStageReport(newStageConfig("ToSlice", in, 1, opts))
+
+	core.Claim(in)
+
 	var res []A
 
 	for x := range in {
 		if err := x.Error; err != nil {
 			DrainNB(in)
-			return res, err
+			return res, report.wrap(err)
 		}
 		res = append(res, x.Value)
+		report.addProcessed()
+	}
+
+	return res, nil
+}
+
+// ToSliceAll is similar to [ToSlice], but doesn't stop on errors. It consumes the whole input stream,
+// collects all values into a slice, and returns all errors joined with [errors.Join].
+// If there were no errors, the returned error is nil.
+//
+// This is a blocking ordered function that processes items sequentially.
+// See the package documentation for more information on blocking ordered functions and error handling.
+func ToSliceAll[A any](in <-chan Try[A]) ([]A, error) {
+	core.Claim(in)
+
+	var res []A
+	var errs []error
+
+	for x := range in {
+		if x.Error != nil {
+			errs = append(errs, x.Error)
+			continue
+		}
+		res = append(res, x.Value)
+	}
+
+	return res, errors.Join(errs...)
+}
+
+// ErrDstTooSmall is returned by [CollectInto] when the input stream has more items than the destination slice can hold.
+var ErrDstTooSmall = errors.New("rill: destination is too small")
+
+// CollectInto writes items from the input stream into the caller-provided slice, in the order they appear in the stream.
+// It returns the number of items written. This avoids the extra allocations and copying of [ToSlice]
+// in performance-sensitive code, where the number of items is known in advance.
+// To get the items in the original order, the input stream must be produced by ordered functions only.
+//
+// If the stream has more items than len(dst), CollectInto returns [ErrDstTooSmall].
+// In case of an error, dst holds the items received before the error.
+//
+// This is a blocking ordered function that processes items sequentially.
+// See the package documentation for more information on blocking ordered functions and error handling.
+func CollectInto[A any](in <-chan Try[A], dst []A) (n int, err error) {
+	core.Claim(in)
+
+	for x := range in {
+		if err := x.Error; err != nil {
+			DrainNB(in)
+			return n, err
+		}
+
+		if n >= len(dst) {
+			DrainNB(in)
+			return n, ErrDstTooSmall
+		}
+
+		dst[n] = x.Value
+		n++
+	}
+
+	return n, nil
+}
+
+// CollectIntoMap writes items from the input stream into the caller-provided map, using the key function.
+// If several items have the same key, the last one wins. It returns the number of items written.
+//
+// This is a blocking ordered function that processes items sequentially.
+// See the package documentation for more information on blocking ordered functions and error handling.
+func CollectIntoMap[A any, K comparable](in <-chan Try[A], dst map[K]A, key func(A) K) (n int, err error) {
+	core.Claim(in)
+
+	for x := range in {
+		if err := x.Error; err != nil {
+			DrainNB(in)
+			return n, err
+		}
+
+		dst[key(x.Value)] = x.Value
+		n++
+	}
+
+	return n, nil
+}
+
+// ConflictPolicy defines the behavior of [ToMap] when several items have the same key.
+type ConflictPolicy int
+
+const (
+	// KeepLast overwrites the previously stored value with the new one.
+	KeepLast ConflictPolicy = iota
+	// KeepFirst keeps the previously stored value and ignores the new one.
+	KeepFirst
+	// FailOnConflict stops the collection and returns an error wrapping [ErrDuplicateKey].
+	FailOnConflict
+)
+
+// ErrDuplicateKey is returned by [ToMap] when the FailOnConflict policy is used and several items have the same key.
+var ErrDuplicateKey = errors.New("rill: duplicate key")
+
+// ToMap converts an input stream into a map, using the key and value functions.
+// The onConflict argument defines what happens when several items have the same key.
+//
+// Example:
+//
+//	usersByID, err := rill.ToMap(users,
+//		func(u User) int { return u.ID },
+//		func(u User) string { return u.Name },
+//		rill.FailOnConflict,
+//	)
+//
+// This is a blocking ordered function that processes items sequentially.
+// See the package documentation for more information on blocking ordered functions and error handling.
+func ToMap[A any, K comparable, V any](in <-chan Try[A], key func(A) K, value func(A) V, onConflict ConflictPolicy) (map[K]V, error) {
+	core.Claim(in)
+
+	res := make(map[K]V)
+
+	for x := range in {
+		if err := x.Error; err != nil {
+			DrainNB(in)
+			return res, err
+		}
+
+		k := key(x.Value)
+		if _, exists := res[k]; exists {
+			switch onConflict {
+			case KeepFirst:
+				continue
+			case FailOnConflict:
+				DrainNB(in)
+				return res, fmt.Errorf("%w: %v", ErrDuplicateKey, k)
+			}
+		}
+
+		res[k] = value(x.Value)
 	}
 
 	return res, nil
@@ -151,6 +410,8 @@ func ToChans[A any](in <-chan Try[A]) (<-chan A, <-chan error) {
 		return nil, nil
 	}
 
+	core.Claim(in)
+
 	out := make(chan A)
 	errs := make(chan error)
 
@@ -190,16 +451,27 @@ func ToChans[A any](in <-chan Try[A]) (<-chan A, <-chan error) {
 //		}
 //		stream <- rill.Try[int]{Error: someError}
 //	}()
+//
+// Once the output stream is drained or stopped with [Pipeline.StopSources], send and sendErr discard items and return immediately.
 func Generate[A any](f func(send func(A), sendErr func(error))) <-chan Try[A] {
 	out := make(chan Try[A])
+	stop, release := core.StopOnDrain((<-chan Try[A])(out))
+
 	go func() {
 		defer close(out)
+		defer release()
 
 		send := func(a A) {
-			out <- Try[A]{Value: a}
+			select {
+			case out <- Try[A]{Value: a}:
+			case <-stop:
+			}
 		}
 		sendErr := func(err error) {
-			out <- Try[A]{Error: err}
+			select {
+			case out <- Try[A]{Error: err}:
+			case <-stop:
+			}
 		}
 
 		f(send, sendErr)
@@ -1,7 +1,10 @@
 package rill
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"math"
 	"testing"
 	"time"
 
@@ -42,6 +45,16 @@ func TestFromSlice(t *testing.T) {
 		th.ExpectNoError(t, err)
 	})
 
+	t.Run("stop on drain", func(t *testing.T) {
+		in := FromSlice(make([]int, 1000000), nil)
+
+		_, _, err := First(in)
+		th.ExpectNoError(t, err)
+
+		time.Sleep(100 * time.Millisecond)
+		th.ExpectDrainedChan(t, in)
+	})
+
 	t.Run("no errors large", func(t *testing.T) {
 		inSlice := make([]int, 4000)
 		for i := range inSlice {
@@ -75,6 +88,297 @@ func TestFromSlice(t *testing.T) {
 	})
 }
 
+func TestFromBigSlice(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		outSlice, err := ToSlice(FromBigSlice[int](nil, 10))
+
+		th.ExpectSlice(t, outSlice, nil)
+		th.ExpectNoError(t, err)
+	})
+
+	for _, chunk := range []int{0, 1, 100, 10000} {
+		t.Run(th.Name("correctness", chunk), func(t *testing.T) {
+			inSlice := make([]int, 4000)
+			for i := range inSlice {
+				inSlice[i] = i
+			}
+
+			outSlice, err := ToSlice(FromBigSlice(inSlice, chunk))
+			th.ExpectSlice(t, outSlice, inSlice)
+			th.ExpectNoError(t, err)
+		})
+	}
+}
+
+func TestFromRange(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		outSlice, err := ToSlice(FromRange(5, 5))
+		th.ExpectSlice(t, outSlice, nil)
+		th.ExpectNoError(t, err)
+
+		outSlice, err = ToSlice(FromRange(5, 0))
+		th.ExpectSlice(t, outSlice, nil)
+		th.ExpectNoError(t, err)
+	})
+
+	t.Run("correctness", func(t *testing.T) {
+		outSlice, err := ToSlice(FromRange(-2, 3))
+		th.ExpectSlice(t, outSlice, []int{-2, -1, 0, 1, 2})
+		th.ExpectNoError(t, err)
+	})
+}
+
+func TestRange(t *testing.T) {
+	t.Run("zero step", func(t *testing.T) {
+		outSlice, err := ToSlice(Range(0, 10, 0))
+		th.ExpectSlice(t, outSlice, nil)
+		th.ExpectError(t, err, "rill: range step must not be zero")
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		outSlice, err := ToSlice(Range(10, 0, 1))
+		th.ExpectSlice(t, outSlice, nil)
+		th.ExpectNoError(t, err)
+
+		outSlice, err = ToSlice(Range(0, 10, -1))
+		th.ExpectSlice(t, outSlice, nil)
+		th.ExpectNoError(t, err)
+	})
+
+	t.Run("ascending", func(t *testing.T) {
+		outSlice, err := ToSlice(Range(0, 10, 3))
+		th.ExpectSlice(t, outSlice, []int{0, 3, 6, 9})
+		th.ExpectNoError(t, err)
+	})
+
+	t.Run("descending", func(t *testing.T) {
+		outSlice, err := ToSlice(Range(10, 0, -4))
+		th.ExpectSlice(t, outSlice, []int{10, 6, 2})
+		th.ExpectNoError(t, err)
+	})
+
+	t.Run("overflow", func(t *testing.T) {
+		th.ExpectNotHang(t, 10*time.Second, func() {
+			outSlice, err := ToSlice(Range(math.MaxInt-4, math.MaxInt, 3))
+			th.ExpectSlice(t, outSlice, []int{math.MaxInt - 4, math.MaxInt - 1})
+			th.ExpectNoError(t, err)
+
+			outSlice, err = ToSlice(Range(math.MaxInt-1, math.MaxInt, 2))
+			th.ExpectSlice(t, outSlice, []int{math.MaxInt - 1})
+			th.ExpectNoError(t, err)
+
+			outSlice, err = ToSlice(Range(math.MinInt+4, math.MinInt, -3))
+			th.ExpectSlice(t, outSlice, []int{math.MinInt + 4, math.MinInt + 1})
+			th.ExpectNoError(t, err)
+
+			outSlice, err = ToSlice(Range(math.MinInt, math.MaxInt, math.MaxInt))
+			th.ExpectSlice(t, outSlice, []int{math.MinInt, -1, math.MaxInt - 1})
+			th.ExpectNoError(t, err)
+
+			outSlice, err = ToSlice(Range(math.MaxInt, math.MinInt, math.MinInt))
+			th.ExpectSlice(t, outSlice, []int{math.MaxInt, -1})
+			th.ExpectNoError(t, err)
+		})
+	})
+
+	t.Run("stop on drain", func(t *testing.T) {
+		in := Range(0, math.MaxInt, 1)
+
+		x, _, err := First(in)
+		th.ExpectNoError(t, err)
+		th.ExpectValue(t, x, 0)
+
+		time.Sleep(100 * time.Millisecond)
+		th.ExpectDrainedChan(t, in)
+	})
+}
+
+func TestRangeCtx(t *testing.T) {
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		outSlice, err := ToSlice(RangeCtx(ctx, 0, 10, 1))
+		th.ExpectSlice(t, outSlice, nil)
+		th.ExpectError(t, err, context.Canceled.Error())
+	})
+
+	t.Run("canceled while consuming", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		in := RangeCtx(ctx, 0, math.MaxInt, 1)
+
+		var values []int
+		var errs []string
+		th.ExpectNotHang(t, 10*time.Second, func() {
+			for x := range in {
+				if x.Error != nil {
+					errs = append(errs, x.Error.Error())
+					continue
+				}
+
+				values = append(values, x.Value)
+				if x.Value == 4 {
+					cancel()
+				}
+			}
+		})
+
+		// the item following the cancellation may already be in flight
+		if len(values) > 6 {
+			t.Errorf("expected at most 6 values, got %d", len(values))
+		}
+		th.ExpectSorted(t, values)
+		th.ExpectSlice(t, errs, []string{context.Canceled.Error()})
+	})
+}
+
+func TestCollectInto(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		n, err := CollectInto(FromSlice[int](nil, nil), make([]int, 5))
+		th.ExpectValue(t, n, 0)
+		th.ExpectNoError(t, err)
+	})
+
+	t.Run("no errors", func(t *testing.T) {
+		dst := make([]int, 30)
+		n, err := CollectInto(FromChan(th.FromRange(0, 20), nil), dst)
+
+		th.ExpectValue(t, n, 20)
+		th.ExpectNoError(t, err)
+		th.ExpectSlice(t, dst[:n], th.ToSlice(th.FromRange(0, 20)))
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		in := FromChan(th.FromRange(0, 20), nil)
+		in = replaceWithError(in, 15, fmt.Errorf("err15"))
+
+		dst := make([]int, 30)
+		n, err := CollectInto(in, dst)
+
+		th.ExpectValue(t, n, 15)
+		th.ExpectError(t, err, "err15")
+		th.ExpectSlice(t, dst[:n], th.ToSlice(th.FromRange(0, 15)))
+
+		time.Sleep(1 * time.Second)
+		th.ExpectDrainedChan(t, in)
+	})
+
+	t.Run("too small", func(t *testing.T) {
+		in := FromChan(th.FromRange(0, 20), nil)
+
+		dst := make([]int, 10)
+		n, err := CollectInto(in, dst)
+
+		th.ExpectValue(t, n, 10)
+		th.ExpectError(t, err, ErrDstTooSmall.Error())
+		th.ExpectSlice(t, dst, th.ToSlice(th.FromRange(0, 10)))
+
+		time.Sleep(1 * time.Second)
+		th.ExpectDrainedChan(t, in)
+	})
+}
+
+func TestCollectIntoMap(t *testing.T) {
+	t.Run("no errors", func(t *testing.T) {
+		dst := map[int]int{100: 100}
+		n, err := CollectIntoMap(FromChan(th.FromRange(0, 20), nil), dst, func(x int) int { return x % 5 })
+
+		th.ExpectValue(t, n, 20)
+		th.ExpectNoError(t, err)
+		th.ExpectValue(t, len(dst), 6)
+		for k := 0; k < 5; k++ {
+			th.ExpectValue(t, dst[k], 15+k)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		in := FromChan(th.FromRange(0, 20), nil)
+		in = replaceWithError(in, 15, fmt.Errorf("err15"))
+
+		dst := make(map[int]int)
+		n, err := CollectIntoMap(in, dst, func(x int) int { return x })
+
+		th.ExpectValue(t, n, 15)
+		th.ExpectError(t, err, "err15")
+		th.ExpectValue(t, len(dst), 15)
+	})
+}
+
+func TestToSliceAll(t *testing.T) {
+	t.Run("no errors", func(t *testing.T) {
+		res, err := ToSliceAll(FromChan(th.FromRange(0, 10), nil))
+		th.ExpectNoError(t, err)
+		th.ExpectSlice(t, res, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9})
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		in := FromChan(th.FromRange(0, 10), nil)
+		in = replaceWithError(in, 3, fmt.Errorf("err3"))
+		in = replaceWithError(in, 7, fmt.Errorf("err7"))
+
+		res, err := ToSliceAll(in)
+		th.ExpectError(t, err, "err3\nerr7")
+		th.ExpectSlice(t, res, []int{0, 1, 2, 4, 5, 6, 8, 9})
+	})
+}
+
+func TestToMap(t *testing.T) {
+	key := func(x int) int { return x % 5 }
+	value := func(x int) string { return fmt.Sprint(x) }
+
+	t.Run("keep last", func(t *testing.T) {
+		res, err := ToMap(FromChan(th.FromRange(0, 20), nil), key, value, KeepLast)
+		th.ExpectNoError(t, err)
+		th.ExpectValue(t, len(res), 5)
+		for k := 0; k < 5; k++ {
+			th.ExpectValue(t, res[k], fmt.Sprint(15+k))
+		}
+	})
+
+	t.Run("keep first", func(t *testing.T) {
+		res, err := ToMap(FromChan(th.FromRange(0, 20), nil), key, value, KeepFirst)
+		th.ExpectNoError(t, err)
+		th.ExpectValue(t, len(res), 5)
+		for k := 0; k < 5; k++ {
+			th.ExpectValue(t, res[k], fmt.Sprint(k))
+		}
+	})
+
+	t.Run("fail on conflict", func(t *testing.T) {
+		in := FromChan(th.FromRange(0, 1000), nil)
+
+		res, err := ToMap(in, key, value, FailOnConflict)
+		th.ExpectError(t, err, "rill: duplicate key: 0")
+		if !errors.Is(err, ErrDuplicateKey) {
+			t.Errorf("expected ErrDuplicateKey, got %v", err)
+		}
+		th.ExpectValue(t, len(res), 5)
+
+		time.Sleep(1 * time.Second)
+		th.ExpectDrainedChan(t, in)
+	})
+
+	t.Run("no conflicts", func(t *testing.T) {
+		res, err := ToMap(FromChan(th.FromRange(0, 20), nil), func(x int) int { return x }, value, FailOnConflict)
+		th.ExpectNoError(t, err)
+		th.ExpectValue(t, len(res), 20)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		in := FromChan(th.FromRange(0, 1000), nil)
+		in = replaceWithError(in, 3, fmt.Errorf("err3"))
+
+		res, err := ToMap(in, key, value, KeepLast)
+		th.ExpectError(t, err, "err3")
+		th.ExpectValue(t, len(res), 3)
+
+		time.Sleep(1 * time.Second)
+		th.ExpectDrainedChan(t, in)
+	})
+}
+
 func TestFromChan(t *testing.T) {
 	t.Run("nil", func(t *testing.T) {
 		res := FromChan[int](nil, nil)